@@ -4,14 +4,33 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
+// DefaultServerAddress is the address the credentials of the Docker Hub are
+// saved under in docker config files
+const DefaultServerAddress = "https://index.docker.io/v1/"
+
+var ErrCredentialsStore = errors.New("Credentials are kept by a credentials store")
+
 // AuthConfig hold parameters for authenticating with the docker registry
 type AuthConfig struct {
 	Username      string `json:"username,omitempty"`
 	Password      string `json:"password,omitempty"`
 	Email         string `json:"email,omitempty"`
 	RegistryToken string `json:"registrytoken,omitempty"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+}
+
+// AuthResponse is the answer of the engine to Login
+type AuthResponse struct {
+	Status        string // Such as Login Succeeded
+	IdentityToken string `json:",omitempty"`
 }
 
 // encode the auth configuration struct into base64 for the X-Registry-Auth header
@@ -37,3 +56,120 @@ func (c *ConfigFile) encode() (string, error) {
 	}
 	return base64.URLEncoding.EncodeToString(buf.Bytes()), nil
 }
+
+// LoginAndSave logs in with Login and, if the engine returned an identity
+// token, stores it in the RegistryToken of auth. If configPath isn't empty,
+// such as ~/.docker/config.json, the credentials are then saved there with
+// SaveAuth.
+func LoginAndSave(client Client, auth *AuthConfig, serverAddress, configPath string) (*AuthResponse, error) {
+	resp, err := client.Login(auth, serverAddress)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IdentityToken != "" {
+		auth.RegistryToken = resp.IdentityToken
+	}
+	if configPath != "" {
+		if err := SaveAuth(configPath, serverAddress, auth); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+// SaveAuth saves auth as the credentials of serverAddress, the Docker Hub
+// if empty, in the docker config file at path, creating it if needed. The
+// rest of the file is kept. If auth has a RegistryToken, it is saved as the
+// identity token in place of the password, as the docker CLI does.
+//
+// It fails with ErrCredentialsStore if the file has a credsStore or a
+// credHelpers entry for serverAddress, since the docker CLI would read the
+// credentials from the store rather than the file.
+func SaveAuth(path, serverAddress string, auth *AuthConfig) error {
+	if serverAddress == "" {
+		serverAddress = DefaultServerAddress
+	}
+	// write through symlinks, such as a config file kept in dotfiles
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	config := make(map[string]json.RawMessage)
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return err
+		}
+	}
+	if usesCredentialsStore(config, serverAddress) {
+		return fmt.Errorf("%s: %v", serverAddress, ErrCredentialsStore)
+	}
+	auths := make(map[string]json.RawMessage)
+	if raw, ok := config["auths"]; ok {
+		if err := json.Unmarshal(raw, &auths); err != nil {
+			return err
+		}
+	}
+
+	entry := struct {
+		Auth          string `json:"auth"`
+		IdentityToken string `json:"identitytoken,omitempty"`
+	}{}
+	password := auth.Password
+	if auth.RegistryToken != "" {
+		password, entry.IdentityToken = "", auth.RegistryToken
+	}
+	entry.Auth = base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + password))
+	if auths[serverAddress], err = json.Marshal(entry); err != nil {
+		return err
+	}
+	if config["auths"], err = json.Marshal(auths); err != nil {
+		return err
+	}
+	if data, err = json.MarshalIndent(config, "", "\t"); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// write aside then rename, not to leave a truncated file behind
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// usesCredentialsStore reports whether the docker config file config keeps
+// the credentials of serverAddress in a store: credHelpers are keyed by
+// hostname, such as ghcr.io
+func usesCredentialsStore(config map[string]json.RawMessage, serverAddress string) bool {
+	var store string
+	if raw, ok := config["credsStore"]; ok && json.Unmarshal(raw, &store) == nil && store != "" {
+		return true
+	}
+	var helpers map[string]string
+	if raw, ok := config["credHelpers"]; !ok || json.Unmarshal(raw, &helpers) != nil {
+		return false
+	}
+	hostname := serverAddress
+	if i := strings.Index(hostname, "://"); i >= 0 {
+		hostname = hostname[i+3:]
+	}
+	hostname = strings.SplitN(hostname, "/", 2)[0]
+	return helpers[serverAddress] != "" || helpers[hostname] != ""
+}
@@ -1,6 +1,14 @@
 package dockerclient
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -13,3 +21,133 @@ func TestAuthEncode(t *testing.T) {
 		t.Errorf("testAuthEncode failed. Expected [%s] got [%s]", expected, got)
 	}
 }
+
+func TestLogin(t *testing.T) {
+	var body map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1.15/auth" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["password"] != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "login attempt to https://registry.example.com/v2/ failed with status: 401 Unauthorized"}`))
+			return
+		}
+		w.Write([]byte(`{"Status": "Login Succeeded", "IdentityToken": "9cbaf023786cd7"}`))
+	}))
+	defer server.Close()
+	client, err := NewDockerClient(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := filepath.Join(t.TempDir(), ".docker", "config.json")
+	auth := &AuthConfig{Username: "user", Password: "secret"}
+	resp, err := LoginAndSave(client, auth, "registry.example.com", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != "Login Succeeded" || auth.RegistryToken != "9cbaf023786cd7" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if body["serveraddress"] != "registry.example.com" || body["username"] != "user" {
+		t.Fatalf("unexpected request %v", body)
+	}
+	data, err := ioutil.ReadFile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+	"auths": {
+		"registry.example.com": {
+			"auth": "dXNlcjo=",
+			"identitytoken": "9cbaf023786cd7"
+		}
+	}
+}`
+	if string(data) != expected {
+		t.Fatalf("expected the config\n%s\ngot\n%s", expected, data)
+	}
+
+	_, err = client.Login(&AuthConfig{Username: "user", Password: "wrong"}, "registry.example.com")
+	if e, ok := err.(Error); !ok || e.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the login to fail, got %v", err)
+	}
+}
+
+func TestSaveAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	existing := `{"auths": {"ghcr.io": {"auth": "b3JnOnRva2Vu"}}, "psFormat": "table {{.Names}}"}`
+	if err := ioutil.WriteFile(path, []byte(existing), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveAuth(path, "", &AuthConfig{Username: "user", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Auths    map[string]map[string]string `json:"auths"`
+		PsFormat string                       `json:"psFormat"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]map[string]string{
+		"ghcr.io":            {"auth": "b3JnOnRva2Vu"},
+		DefaultServerAddress: {"auth": "dXNlcjpzZWNyZXQ="},
+	}
+	if !reflect.DeepEqual(config.Auths, expected) || config.PsFormat != "table {{.Names}}" {
+		t.Fatalf("unexpected config %s", data)
+	}
+
+	// the file a symlink points to is written, and no temporary file is
+	// left behind
+	link := filepath.Join(filepath.Dir(path), "link.json")
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveAuth(link, "registry.example.com", &AuthConfig{Username: "user", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("expected the symlink to be kept, got %v", err)
+	}
+	if data, err := ioutil.ReadFile(path); err != nil || !strings.Contains(string(data), "registry.example.com") {
+		t.Fatalf("expected the credentials in the linked file, got %s %v", data, err)
+	}
+	if files, _ := ioutil.ReadDir(filepath.Dir(path)); len(files) != 2 {
+		t.Fatalf("expected the config file and the link only, got %d files", len(files))
+	}
+}
+
+func TestSaveAuthCredentialsStore(t *testing.T) {
+	for _, test := range []struct {
+		config, serverAddress string
+	}{
+		{`{"credsStore": "desktop"}`, ""},
+		{`{"credHelpers": {"ghcr.io": "gh"}}`, "ghcr.io"},
+		{`{"credHelpers": {"gcr.io": "gcloud"}}`, "https://gcr.io/v2/"},
+	} {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := ioutil.WriteFile(path, []byte(test.config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		err := SaveAuth(path, test.serverAddress, &AuthConfig{Username: "user", Password: "secret"})
+		if err == nil || !strings.HasSuffix(err.Error(), ErrCredentialsStore.Error()) {
+			t.Errorf("%s: expected the credentials store to be refused, got %v", test.config, err)
+		}
+	}
+	// helpers of other registries don't matter
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(`{"credHelpers": {"ghcr.io": "gh"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveAuth(path, "", &AuthConfig{Username: "user", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+}
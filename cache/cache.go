@@ -0,0 +1,492 @@
+// Package cache keeps a local, event-driven copy of the containers, images,
+// networks and volumes of a Docker engine.
+//
+// A Cache does an initial list, then follows the events stream and
+// re-inspects only the resources an event refers to. A periodic full resync
+// recovers from events missed while the stream was down. It only relies on
+// the dockerclient.Client interface, so it works with mockclient as well.
+package cache
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+// reconnectDelay is how long the cache waits before resubscribing to the
+// events stream after it was interrupted
+var reconnectDelay = time.Second
+
+// Handler is notified of changes to the cache. Objects are one of
+// *dockerclient.ContainerInfo, *dockerclient.ImageInfo,
+// *dockerclient.NetworkResource or *dockerclient.Volume and must not be
+// modified.
+type Handler interface {
+	OnAdd(obj interface{})
+	OnUpdate(oldObj, newObj interface{})
+	OnDelete(obj interface{})
+}
+
+// HandlerFuncs is a Handler built from optional functions
+type HandlerFuncs struct {
+	AddFunc    func(obj interface{})
+	UpdateFunc func(oldObj, newObj interface{})
+	DeleteFunc func(obj interface{})
+}
+
+func (h HandlerFuncs) OnAdd(obj interface{}) {
+	if h.AddFunc != nil {
+		h.AddFunc(obj)
+	}
+}
+
+func (h HandlerFuncs) OnUpdate(oldObj, newObj interface{}) {
+	if h.UpdateFunc != nil {
+		h.UpdateFunc(oldObj, newObj)
+	}
+}
+
+func (h HandlerFuncs) OnDelete(obj interface{}) {
+	if h.DeleteFunc != nil {
+		h.DeleteFunc(obj)
+	}
+}
+
+type notification struct {
+	oldObj interface{}
+	newObj interface{}
+}
+
+type Cache struct {
+	client       dockerclient.Client
+	resyncPeriod time.Duration
+
+	mu       sync.RWMutex
+	stores   map[dockerclient.EventType]*store
+	handlers []Handler
+	synced   bool
+}
+
+// NewCache returns a cache backed by client. If resyncPeriod is not zero,
+// the whole cache is relisted at that interval.
+func NewCache(client dockerclient.Client, resyncPeriod time.Duration) *Cache {
+	return &Cache{
+		client:       client,
+		resyncPeriod: resyncPeriod,
+		stores: map[dockerclient.EventType]*store{
+			dockerclient.ContainerEventType: newStore(containerMeta),
+			dockerclient.ImageEventType:     newStore(imageMeta),
+			dockerclient.NetworkEventType:   newStore(networkMeta),
+			dockerclient.VolumeEventType:    newStore(volumeMeta),
+		},
+	}
+}
+
+func containerMeta(obj interface{}) (string, []string, map[string]string) {
+	c := obj.(*dockerclient.ContainerInfo)
+	var labels map[string]string
+	if c.Config != nil {
+		labels = c.Config.Labels
+	}
+	return c.Id, []string{strings.TrimPrefix(c.Name, "/")}, labels
+}
+
+func imageMeta(obj interface{}) (string, []string, map[string]string) {
+	i := obj.(*dockerclient.ImageInfo)
+	var labels map[string]string
+	if i.Config != nil {
+		labels = i.Config.Labels
+	}
+	names := append([]string{}, i.RepoTags...)
+	return i.Id, append(names, i.RepoDigests...), labels
+}
+
+func networkMeta(obj interface{}) (string, []string, map[string]string) {
+	n := obj.(*dockerclient.NetworkResource)
+	return n.ID, []string{n.Name}, n.Labels
+}
+
+func volumeMeta(obj interface{}) (string, []string, map[string]string) {
+	v := obj.(*dockerclient.Volume)
+	return v.Name, nil, v.Labels
+}
+
+// AddHandler registers a handler. Handlers are called from the goroutine
+// that processes events, one notification at a time.
+func (c *Cache) AddHandler(h Handler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers = append(c.handlers, h)
+}
+
+// HasSynced reports whether the initial list has completed
+func (c *Cache) HasSynced() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.synced
+}
+
+// Start subscribes to the events stream, does the initial list and then
+// keeps the cache up to date until stopChan is closed. Errors that happen
+// after the initial list are sent on ec, if it is not nil; they are dropped
+// when nobody is receiving.
+func (c *Cache) Start(stopChan <-chan struct{}, ec chan error) error {
+	// subscribe before listing so that nothing happening in between is lost
+	events, eventsStop, err := c.watch()
+	if err != nil {
+		return err
+	}
+	if err := c.Resync(); err != nil {
+		close(eventsStop)
+		return err
+	}
+	go c.run(events, eventsStop, stopChan, ec)
+	return nil
+}
+
+func (c *Cache) watch() (<-chan dockerclient.EventOrError, chan struct{}, error) {
+	stop := make(chan struct{})
+	events, err := c.client.MonitorEvents(nil, stop)
+	if err != nil {
+		close(stop)
+		return nil, nil, err
+	}
+	return events, stop, nil
+}
+
+func (c *Cache) run(events <-chan dockerclient.EventOrError, eventsStop chan struct{}, stopChan <-chan struct{}, ec chan error) {
+	var resync <-chan time.Time
+	if c.resyncPeriod > 0 {
+		ticker := time.NewTicker(c.resyncPeriod)
+		defer ticker.Stop()
+		resync = ticker.C
+	}
+	var reconnect <-chan time.Time
+
+	for {
+		select {
+		case <-stopChan:
+			if eventsStop != nil {
+				close(eventsStop)
+			}
+			return
+		case <-resync:
+			if err := c.Resync(); err != nil {
+				sendError(ec, err)
+			}
+		case e, ok := <-events:
+			if ok && e.Error == nil {
+				if err := c.handleEvent(&e.Event); err != nil {
+					sendError(ec, err)
+				}
+				continue
+			}
+			if ok {
+				sendError(ec, e.Error)
+			}
+			// The stream is gone: reconnect and relist, since events
+			// may have been missed in the meantime.
+			close(eventsStop)
+			events, eventsStop = nil, nil
+			reconnect = time.After(reconnectDelay)
+		case <-reconnect:
+			var err error
+			events, eventsStop, err = c.watch()
+			if err != nil {
+				sendError(ec, err)
+				reconnect = time.After(reconnectDelay)
+				continue
+			}
+			reconnect = nil
+			if err := c.Resync(); err != nil {
+				sendError(ec, err)
+			}
+		}
+	}
+}
+
+func sendError(ec chan error, err error) {
+	if ec == nil {
+		return
+	}
+	select {
+	case ec <- err:
+	default:
+	}
+}
+
+// Resync relists every resource and notifies handlers of the differences
+// with the cached state. It returns the first error encountered; the kinds
+// that could be listed are updated regardless.
+func (c *Cache) Resync() error {
+	var firstErr error
+	for _, kind := range []dockerclient.EventType{
+		dockerclient.ContainerEventType,
+		dockerclient.ImageEventType,
+		dockerclient.NetworkEventType,
+		dockerclient.VolumeEventType,
+	} {
+		if err := c.resyncKind(kind); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		c.mu.Lock()
+		c.synced = true
+		c.mu.Unlock()
+	}
+	return firstErr
+}
+
+func (c *Cache) resyncKind(kind dockerclient.EventType) error {
+	var (
+		objs []interface{}
+		err  error
+	)
+	switch kind {
+	case dockerclient.ContainerEventType:
+		objs, err = c.listContainers()
+	case dockerclient.ImageEventType:
+		objs, err = c.listImages()
+	case dockerclient.NetworkEventType:
+		objs, err = c.listNetworks()
+	case dockerclient.VolumeEventType:
+		objs, err = c.listVolumes()
+	}
+	if err != nil {
+		return err
+	}
+	c.replace(kind, objs)
+	return nil
+}
+
+func (c *Cache) listContainers() ([]interface{}, error) {
+	containers, err := c.client.ListContainers(true, false, "")
+	if err != nil {
+		return nil, err
+	}
+	objs := []interface{}{}
+	for _, container := range containers {
+		info, err := c.client.InspectContainer(container.Id)
+		if err != nil {
+			if dockerclient.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		objs = append(objs, info)
+	}
+	return objs, nil
+}
+
+func (c *Cache) listImages() ([]interface{}, error) {
+	images, err := c.client.ListImages(false)
+	if err != nil {
+		return nil, err
+	}
+	objs := []interface{}{}
+	for _, image := range images {
+		info, err := c.client.InspectImage(image.Id)
+		if err != nil {
+			if dockerclient.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		objs = append(objs, info)
+	}
+	return objs, nil
+}
+
+func (c *Cache) listNetworks() ([]interface{}, error) {
+	networks, err := c.client.ListNetworks("")
+	if err != nil {
+		return nil, err
+	}
+	objs := []interface{}{}
+	for _, network := range networks {
+		objs = append(objs, network)
+	}
+	return objs, nil
+}
+
+func (c *Cache) listVolumes() ([]interface{}, error) {
+	volumes, err := c.client.ListVolumes()
+	if err != nil {
+		return nil, err
+	}
+	objs := []interface{}{}
+	for _, volume := range volumes {
+		objs = append(objs, volume)
+	}
+	return objs, nil
+}
+
+// replace swaps the content of a store for objs and notifies the handlers
+func (c *Cache) replace(kind dockerclient.EventType, objs []interface{}) {
+	c.mu.Lock()
+	s := c.stores[kind]
+	seen := make(map[string]struct{}, len(objs))
+	notifications := []notification{}
+	for _, obj := range objs {
+		id, _, _ := s.meta(obj)
+		seen[id] = struct{}{}
+		if n, changed := putNotification(s, obj); changed {
+			notifications = append(notifications, n)
+		}
+	}
+	for _, obj := range s.list() {
+		id, _, _ := s.meta(obj)
+		if _, ok := seen[id]; !ok {
+			s.delete(id)
+			notifications = append(notifications, notification{oldObj: obj})
+		}
+	}
+	c.mu.Unlock()
+	c.notify(notifications)
+}
+
+func putNotification(s *store, obj interface{}) (notification, bool) {
+	old, existed := s.put(obj)
+	if !existed {
+		return notification{newObj: obj}, true
+	}
+	if reflect.DeepEqual(old, obj) {
+		return notification{}, false
+	}
+	return notification{oldObj: old, newObj: obj}, true
+}
+
+// set adds or replaces a single object
+func (c *Cache) set(kind dockerclient.EventType, obj interface{}) {
+	c.mu.Lock()
+	n, changed := putNotification(c.stores[kind], obj)
+	c.mu.Unlock()
+	if changed {
+		c.notify([]notification{n})
+	}
+}
+
+// remove deletes a single object by ID or name
+func (c *Cache) remove(kind dockerclient.EventType, key string) {
+	c.mu.Lock()
+	old, existed := c.stores[kind].delete(key)
+	c.mu.Unlock()
+	if existed {
+		c.notify([]notification{{oldObj: old}})
+	}
+}
+
+func (c *Cache) notify(notifications []notification) {
+	if len(notifications) == 0 {
+		return
+	}
+	c.mu.RLock()
+	handlers := c.handlers
+	c.mu.RUnlock()
+	for _, n := range notifications {
+		for _, h := range handlers {
+			switch {
+			case n.oldObj == nil:
+				h.OnAdd(n.newObj)
+			case n.newObj == nil:
+				h.OnDelete(n.oldObj)
+			default:
+				h.OnUpdate(n.oldObj, n.newObj)
+			}
+		}
+	}
+}
+
+// ignoredContainerActions don't change anything that InspectContainer
+// reports, so they don't trigger a re-inspection
+var ignoredContainerActions = map[dockerclient.EventAction]bool{
+	dockerclient.ActionAttach:     true,
+	dockerclient.ActionCommit:     true,
+	dockerclient.ActionCopy:       true,
+	dockerclient.ActionDetach:     true,
+	dockerclient.ActionExecCreate: true,
+	dockerclient.ActionExecDetach: true,
+	dockerclient.ActionExecDie:    true,
+	dockerclient.ActionExecStart:  true,
+	dockerclient.ActionExport:     true,
+	dockerclient.ActionResize:     true,
+	dockerclient.ActionTop:        true,
+}
+
+func (c *Cache) handleEvent(e *dockerclient.Event) error {
+	e.Normalize()
+	action := e.BaseAction()
+	switch e.Type {
+	case dockerclient.ContainerEventType:
+		if action == dockerclient.ActionDestroy {
+			c.remove(dockerclient.ContainerEventType, e.Actor.ID)
+			return nil
+		}
+		if ignoredContainerActions[action] {
+			return nil
+		}
+		return c.refreshContainer(e.Actor.ID)
+	case dockerclient.ImageEventType:
+		return c.refreshImage(e.Actor.ID)
+	case dockerclient.NetworkEventType:
+		if action == dockerclient.ActionDestroy {
+			c.remove(dockerclient.NetworkEventType, e.Actor.ID)
+			return nil
+		}
+		if err := c.refreshNetwork(e.Actor.ID); err != nil {
+			return err
+		}
+		if container := e.Attribute("container"); container != "" {
+			return c.refreshContainer(container)
+		}
+	case dockerclient.VolumeEventType:
+		// there is no way to inspect a single volume, relist them instead
+		if action == dockerclient.ActionCreate || action == dockerclient.ActionDestroy {
+			return c.resyncKind(dockerclient.VolumeEventType)
+		}
+	}
+	return nil
+}
+
+func (c *Cache) refreshContainer(id string) error {
+	info, err := c.client.InspectContainer(id)
+	if err != nil {
+		if dockerclient.IsNotFound(err) {
+			c.remove(dockerclient.ContainerEventType, id)
+			return nil
+		}
+		return err
+	}
+	c.set(dockerclient.ContainerEventType, info)
+	return nil
+}
+
+func (c *Cache) refreshImage(ref string) error {
+	info, err := c.client.InspectImage(ref)
+	if err != nil {
+		if dockerclient.IsNotFound(err) {
+			c.remove(dockerclient.ImageEventType, ref)
+			return nil
+		}
+		return err
+	}
+	c.set(dockerclient.ImageEventType, info)
+	return nil
+}
+
+func (c *Cache) refreshNetwork(id string) error {
+	network, err := c.client.InspectNetwork(id)
+	if err != nil {
+		if dockerclient.IsNotFound(err) {
+			c.remove(dockerclient.NetworkEventType, id)
+			return nil
+		}
+		return err
+	}
+	c.set(dockerclient.NetworkEventType, network)
+	return nil
+}
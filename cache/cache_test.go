@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/samalba/dockerclient/mockclient"
+	"github.com/stretchr/testify/mock"
+)
+
+type recorder struct {
+	added   chan interface{}
+	updated chan interface{}
+	deleted chan interface{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		added:   make(chan interface{}, 10),
+		updated: make(chan interface{}, 10),
+		deleted: make(chan interface{}, 10),
+	}
+}
+
+func (r *recorder) OnAdd(obj interface{})               { r.added <- obj }
+func (r *recorder) OnUpdate(oldObj, newObj interface{}) { r.updated <- newObj }
+func (r *recorder) OnDelete(obj interface{})            { r.deleted <- obj }
+
+func receive(t *testing.T, ch chan interface{}) interface{} {
+	select {
+	case obj := <-ch:
+		return obj
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out!")
+	}
+	return nil
+}
+
+func newTestCache(t *testing.T) (*Cache, *mockclient.MockClient, chan dockerclient.EventOrError, chan struct{}) {
+	client := mockclient.NewMockClient()
+	events := make(chan dockerclient.EventOrError)
+	client.On("MonitorEvents", (*dockerclient.MonitorEventsOptions)(nil), mock.Anything).Return((<-chan dockerclient.EventOrError)(events), nil)
+	client.On("ListContainers", true, false, "").Return([]dockerclient.Container{{Id: "c1"}}, nil).Once()
+	client.On("InspectContainer", "c1").Return(&dockerclient.ContainerInfo{
+		Id:     "c1",
+		Name:   "/web",
+		Config: &dockerclient.ContainerConfig{Labels: map[string]string{"app": "web"}},
+		State:  &dockerclient.State{Running: true},
+	}, nil).Once()
+	client.On("ListImages", false).Return([]*dockerclient.Image{{Id: "sha256:aaa"}}, nil).Once()
+	client.On("InspectImage", "sha256:aaa").Return(&dockerclient.ImageInfo{Id: "sha256:aaa", RepoTags: []string{"nginx:latest"}}, nil).Once()
+	client.On("ListNetworks", "").Return([]*dockerclient.NetworkResource{{ID: "n1", Name: "bridge"}}, nil).Once()
+	client.On("ListVolumes").Return([]*dockerclient.Volume{{Name: "data", Labels: map[string]string{"app": "web"}}}, nil).Once()
+
+	c := NewCache(client, 0)
+	stopChan := make(chan struct{})
+	if err := c.Start(stopChan, nil); err != nil {
+		t.Fatal(err)
+	}
+	return c, client, events, stopChan
+}
+
+func TestCacheInitialList(t *testing.T) {
+	c, client, _, stopChan := newTestCache(t)
+	defer close(stopChan)
+
+	if !c.HasSynced() {
+		t.Fatal("cache should be synced after Start")
+	}
+	if info, ok := c.Container("web"); !ok || info.Id != "c1" {
+		t.Fatalf("cannot find container by name: %v", info)
+	}
+	if _, ok := c.Container("c"); !ok {
+		t.Fatal("cannot find container by ID prefix")
+	}
+	if containers := c.ContainersByLabel("app", "web"); len(containers) != 1 {
+		t.Fatalf("expected 1 container labelled app=web, got %d", len(containers))
+	}
+	if containers := c.ContainersByLabel("app", "db"); len(containers) != 0 {
+		t.Fatalf("expected no container labelled app=db, got %d", len(containers))
+	}
+	if image, ok := c.Image("nginx:latest"); !ok || image.Id != "sha256:aaa" {
+		t.Fatalf("cannot find image by tag: %v", image)
+	}
+	if _, ok := c.Network("bridge"); !ok {
+		t.Fatal("cannot find network by name")
+	}
+	if volumes := c.VolumesByLabel("app", ""); len(volumes) != 1 {
+		t.Fatalf("expected 1 volume labelled app, got %d", len(volumes))
+	}
+	client.AssertExpectations(t)
+}
+
+func TestCacheEvents(t *testing.T) {
+	c, client, events, stopChan := newTestCache(t)
+	defer close(stopChan)
+	r := newRecorder()
+	c.AddHandler(r)
+
+	client.On("InspectContainer", "c1").Return(&dockerclient.ContainerInfo{
+		Id:     "c1",
+		Name:   "/web",
+		Config: &dockerclient.ContainerConfig{Labels: map[string]string{"app": "web"}},
+		State:  &dockerclient.State{ExitCode: 1},
+	}, nil).Once()
+	events <- dockerclient.EventOrError{Event: dockerclient.Event{Type: dockerclient.ContainerEventType, Action: dockerclient.ActionDie, Actor: dockerclient.Actor{ID: "c1"}}}
+	if info := receive(t, r.updated).(*dockerclient.ContainerInfo); info.State.ExitCode != 1 {
+		t.Fatalf("expected the container to be re-inspected, got %#v", info.State)
+	}
+
+	client.On("InspectContainer", "c2").Return(&dockerclient.ContainerInfo{Id: "c2", Name: "/db"}, nil).Once()
+	events <- dockerclient.EventOrError{Event: dockerclient.Event{Status: "create", ID: "c2", From: "postgres"}}
+	if info := receive(t, r.added).(*dockerclient.ContainerInfo); info.Id != "c2" {
+		t.Fatalf("expected c2 to be added, got %s", info.Id)
+	}
+
+	events <- dockerclient.EventOrError{Event: dockerclient.Event{Type: dockerclient.ContainerEventType, Action: dockerclient.ActionDestroy, Actor: dockerclient.Actor{ID: "c1"}}}
+	if info := receive(t, r.deleted).(*dockerclient.ContainerInfo); info.Id != "c1" {
+		t.Fatalf("expected c1 to be deleted, got %s", info.Id)
+	}
+	if _, ok := c.Container("web"); ok {
+		t.Fatal("deleted container is still indexed by name")
+	}
+
+	client.On("InspectImage", "sha256:aaa").Return((*dockerclient.ImageInfo)(nil), dockerclient.ErrImageNotFound).Once()
+	events <- dockerclient.EventOrError{Event: dockerclient.Event{Type: dockerclient.ImageEventType, Action: dockerclient.ActionDelete, Actor: dockerclient.Actor{ID: "sha256:aaa"}}}
+	if image := receive(t, r.deleted).(*dockerclient.ImageInfo); image.Id != "sha256:aaa" {
+		t.Fatalf("expected sha256:aaa to be deleted, got %s", image.Id)
+	}
+	client.AssertExpectations(t)
+}
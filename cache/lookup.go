@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"github.com/samalba/dockerclient"
+)
+
+// The lookup methods accept an ID, a name or a unique ID prefix. Returned
+// objects are shared with the cache and must not be modified.
+
+func (c *Cache) get(kind dockerclient.EventType, key string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stores[kind].get(key)
+}
+
+func (c *Cache) list(kind dockerclient.EventType) []interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stores[kind].list()
+}
+
+func (c *Cache) byLabel(kind dockerclient.EventType, key, value string) []interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stores[kind].byLabel(key, value)
+}
+
+// Container returns the container matching an ID or a name
+func (c *Cache) Container(key string) (*dockerclient.ContainerInfo, bool) {
+	obj, ok := c.get(dockerclient.ContainerEventType, key)
+	if !ok {
+		return nil, false
+	}
+	return obj.(*dockerclient.ContainerInfo), true
+}
+
+// Containers returns every cached container, sorted by ID
+func (c *Cache) Containers() []*dockerclient.ContainerInfo {
+	return toContainers(c.list(dockerclient.ContainerEventType))
+}
+
+// ContainersByLabel returns the containers carrying the label key. If value
+// is not empty, the label must also have that value.
+func (c *Cache) ContainersByLabel(key, value string) []*dockerclient.ContainerInfo {
+	return toContainers(c.byLabel(dockerclient.ContainerEventType, key, value))
+}
+
+func toContainers(objs []interface{}) []*dockerclient.ContainerInfo {
+	containers := make([]*dockerclient.ContainerInfo, len(objs))
+	for i, obj := range objs {
+		containers[i] = obj.(*dockerclient.ContainerInfo)
+	}
+	return containers
+}
+
+// Image returns the image matching an ID, a tag or a digest reference
+func (c *Cache) Image(key string) (*dockerclient.ImageInfo, bool) {
+	obj, ok := c.get(dockerclient.ImageEventType, key)
+	if !ok {
+		return nil, false
+	}
+	return obj.(*dockerclient.ImageInfo), true
+}
+
+// Images returns every cached image, sorted by ID
+func (c *Cache) Images() []*dockerclient.ImageInfo {
+	return toImages(c.list(dockerclient.ImageEventType))
+}
+
+// ImagesByLabel returns the images carrying the label key, with the given
+// value if it is not empty
+func (c *Cache) ImagesByLabel(key, value string) []*dockerclient.ImageInfo {
+	return toImages(c.byLabel(dockerclient.ImageEventType, key, value))
+}
+
+func toImages(objs []interface{}) []*dockerclient.ImageInfo {
+	images := make([]*dockerclient.ImageInfo, len(objs))
+	for i, obj := range objs {
+		images[i] = obj.(*dockerclient.ImageInfo)
+	}
+	return images
+}
+
+// Network returns the network matching an ID or a name
+func (c *Cache) Network(key string) (*dockerclient.NetworkResource, bool) {
+	obj, ok := c.get(dockerclient.NetworkEventType, key)
+	if !ok {
+		return nil, false
+	}
+	return obj.(*dockerclient.NetworkResource), true
+}
+
+// Networks returns every cached network, sorted by ID
+func (c *Cache) Networks() []*dockerclient.NetworkResource {
+	return toNetworks(c.list(dockerclient.NetworkEventType))
+}
+
+// NetworksByLabel returns the networks carrying the label key, with the
+// given value if it is not empty
+func (c *Cache) NetworksByLabel(key, value string) []*dockerclient.NetworkResource {
+	return toNetworks(c.byLabel(dockerclient.NetworkEventType, key, value))
+}
+
+func toNetworks(objs []interface{}) []*dockerclient.NetworkResource {
+	networks := make([]*dockerclient.NetworkResource, len(objs))
+	for i, obj := range objs {
+		networks[i] = obj.(*dockerclient.NetworkResource)
+	}
+	return networks
+}
+
+// Volume returns the volume with the given name
+func (c *Cache) Volume(name string) (*dockerclient.Volume, bool) {
+	obj, ok := c.get(dockerclient.VolumeEventType, name)
+	if !ok {
+		return nil, false
+	}
+	return obj.(*dockerclient.Volume), true
+}
+
+// Volumes returns every cached volume, sorted by name
+func (c *Cache) Volumes() []*dockerclient.Volume {
+	return toVolumes(c.list(dockerclient.VolumeEventType))
+}
+
+// VolumesByLabel returns the volumes carrying the label key, with the given
+// value if it is not empty
+func (c *Cache) VolumesByLabel(key, value string) []*dockerclient.Volume {
+	return toVolumes(c.byLabel(dockerclient.VolumeEventType, key, value))
+}
+
+func toVolumes(objs []interface{}) []*dockerclient.Volume {
+	volumes := make([]*dockerclient.Volume, len(objs))
+	for i, obj := range objs {
+		volumes[i] = obj.(*dockerclient.Volume)
+	}
+	return volumes
+}
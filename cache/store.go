@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"sort"
+)
+
+// metaFunc returns the ID, names and labels used to index an object
+type metaFunc func(obj interface{}) (id string, names []string, labels map[string]string)
+
+// store holds the objects of one resource kind, indexed by ID, name and label
+type store struct {
+	meta   metaFunc
+	items  map[string]interface{}
+	names  map[string]string
+	labels map[string]map[string]struct{}
+}
+
+func newStore(meta metaFunc) *store {
+	return &store{
+		meta:   meta,
+		items:  make(map[string]interface{}),
+		names:  make(map[string]string),
+		labels: make(map[string]map[string]struct{}),
+	}
+}
+
+// labelKeys returns the index keys for a set of labels: every label is
+// indexed both as "key" and as "key=value"
+func labelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, 2*len(labels))
+	for k, v := range labels {
+		keys = append(keys, k, k+"="+v)
+	}
+	return keys
+}
+
+func (s *store) index(id string, obj interface{}) {
+	_, names, labels := s.meta(obj)
+	for _, name := range names {
+		s.names[name] = id
+	}
+	for _, key := range labelKeys(labels) {
+		ids, ok := s.labels[key]
+		if !ok {
+			ids = make(map[string]struct{})
+			s.labels[key] = ids
+		}
+		ids[id] = struct{}{}
+	}
+}
+
+func (s *store) unindex(id string, obj interface{}) {
+	_, names, labels := s.meta(obj)
+	for _, name := range names {
+		if s.names[name] == id {
+			delete(s.names, name)
+		}
+	}
+	for _, key := range labelKeys(labels) {
+		if ids, ok := s.labels[key]; ok {
+			delete(ids, id)
+			if len(ids) == 0 {
+				delete(s.labels, key)
+			}
+		}
+	}
+}
+
+// put adds or replaces obj and returns the object it replaced, if any
+func (s *store) put(obj interface{}) (old interface{}, existed bool) {
+	id, _, _ := s.meta(obj)
+	if old, existed = s.items[id]; existed {
+		s.unindex(id, old)
+	}
+	s.items[id] = obj
+	s.index(id, obj)
+	return old, existed
+}
+
+// delete removes the object with the given ID or name and returns it
+func (s *store) delete(key string) (old interface{}, existed bool) {
+	id, ok := s.resolve(key)
+	if !ok {
+		return nil, false
+	}
+	old = s.items[id]
+	s.unindex(id, old)
+	delete(s.items, id)
+	return old, true
+}
+
+// resolve returns the ID of the object matching key, which may be an ID,
+// a name or a unique ID prefix
+func (s *store) resolve(key string) (string, bool) {
+	if _, ok := s.items[key]; ok {
+		return key, true
+	}
+	if id, ok := s.names[key]; ok {
+		return id, true
+	}
+	match := ""
+	for id := range s.items {
+		if len(key) > 0 && len(id) >= len(key) && id[:len(key)] == key {
+			if match != "" {
+				return "", false
+			}
+			match = id
+		}
+	}
+	return match, match != ""
+}
+
+func (s *store) get(key string) (interface{}, bool) {
+	id, ok := s.resolve(key)
+	if !ok {
+		return nil, false
+	}
+	return s.items[id], true
+}
+
+// byLabel returns the objects carrying the label key, or key=value if value
+// is not empty, sorted by ID
+func (s *store) byLabel(key, value string) []interface{} {
+	if value != "" {
+		key = key + "=" + value
+	}
+	ids := make([]string, 0, len(s.labels[key]))
+	for id := range s.labels[key] {
+		ids = append(ids, id)
+	}
+	return s.sorted(ids)
+}
+
+// list returns every object, sorted by ID
+func (s *store) list() []interface{} {
+	ids := make([]string, 0, len(s.items))
+	for id := range s.items {
+		ids = append(ids, id)
+	}
+	return s.sorted(ids)
+}
+
+func (s *store) sorted(ids []string) []interface{} {
+	sort.Strings(ids)
+	objs := make([]interface{}, len(ids))
+	for i, id := range ids {
+		objs[i] = s.items[id]
+	}
+	return objs
+}
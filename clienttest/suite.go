@@ -0,0 +1,253 @@
+// Package clienttest is a conformance suite for implementations of
+// dockerclient.Client, such as DockerClient and the decorators and fakes
+// built around it.
+//
+// The suite checks behaviour rather than method sets: the errors returned
+// for missing resources, that MonitorEvents stops when its stopChan is
+// closed, that ContainerStats sends nothing after an error and that Wait
+// results are correct. Each check runs against a fresh fakeengine.Engine,
+// served over HTTP by Run and as NewEngineFunc decides by RunWithEngine:
+//
+//	func TestConformance(t *testing.T) {
+//		clienttest.Run(t, func(t *testing.T, daemonURL string) dockerclient.Client {
+//			client, err := dockerclient.NewDockerClient(daemonURL, nil)
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			return NewMyWrapper(client)
+//		})
+//	}
+package clienttest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/samalba/dockerclient/fakeengine"
+)
+
+// NewClientFunc returns the Client under test, talking to the engine
+// listening at daemonURL
+type NewClientFunc func(t *testing.T, daemonURL string) dockerclient.Client
+
+// NewEngineFunc returns the engine a check runs against, set up with the
+// failures or latency to test with for instance, and the URL the Client
+// under test reaches it at, such as a unix socket or a proxy in front of
+// it. It is called for every check, which must not share engines.
+type NewEngineFunc func(t *testing.T) (*fakeengine.Engine, string)
+
+// Timeout bounds every wait of the suite
+var Timeout = 5 * time.Second
+
+// ServeEngine serves a new fakeengine.Engine over HTTP until the end of
+// the check, the NewEngineFunc of Run
+func ServeEngine(t *testing.T) (*fakeengine.Engine, string) {
+	engine := fakeengine.New()
+	server := engine.Serve()
+	t.Cleanup(server.Close)
+	return engine, server.URL
+}
+
+// Run runs the whole suite as subtests of t, against engines served by
+// ServeEngine
+func Run(t *testing.T, newClient NewClientFunc) {
+	RunWithEngine(t, ServeEngine, newClient)
+}
+
+// RunWithEngine runs the whole suite as subtests of t, against the engines
+// newEngine returns
+func RunWithEngine(t *testing.T, newEngine NewEngineFunc, newClient NewClientFunc) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, *fakeengine.Engine, dockerclient.Client)
+	}{
+		{"MissingResources", testMissingResources},
+		{"MonitorEventsStops", testMonitorEventsStops},
+		{"ContainerStatsAfterError", testContainerStatsAfterError},
+		{"Wait", testWait},
+		{"WaitCanceled", testWaitCanceled},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			engine, daemonURL := newEngine(t)
+			test.fn(t, engine, newClient(t, daemonURL))
+		})
+	}
+}
+
+func testMissingResources(t *testing.T, engine *fakeengine.Engine, client dockerclient.Client) {
+	notFound := func(call string, err error) {
+		if !dockerclient.IsNotFound(err) {
+			t.Errorf("%s: expected a not found error, got %v", call, err)
+		}
+	}
+	_, err := client.InspectContainer("missing")
+	notFound("InspectContainer", err)
+	notFound("StartContainer", client.StartContainer("missing", nil))
+	notFound("StopContainer", client.StopContainer("missing", 1))
+	notFound("KillContainer", client.KillContainer("missing", "KILL"))
+	notFound("RemoveContainer", client.RemoveContainer("missing", true, false))
+	_, err = client.InspectNetwork("missing")
+	notFound("InspectNetwork", err)
+	notFound("RemoveVolume", client.RemoveVolume("missing"))
+
+	if _, err := client.InspectImage("missing"); err != dockerclient.ErrImageNotFound {
+		t.Errorf("InspectImage: expected ErrImageNotFound, got %v", err)
+	}
+	if _, err := client.CreateContainer(&dockerclient.ContainerConfig{Image: "missing"}, "", nil); err != dockerclient.ErrImageNotFound {
+		t.Errorf("CreateContainer: expected ErrImageNotFound for a missing image, got %v", err)
+	}
+}
+
+func testMonitorEventsStops(t *testing.T, engine *fakeengine.Engine, client dockerclient.Client) {
+	engine.AddImage("busybox", nil)
+	stopChan := make(chan struct{})
+	events, err := client.MonitorEvents(&dockerclient.MonitorEventsOptions{
+		Filters: &dockerclient.MonitorEventsFilters{Type: dockerclient.ContainerEventType},
+	}, stopChan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for a first event, so that the stream is known to be flowing
+	id := createContainer(t, client, "busybox")
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("the events channel was closed before stopChan")
+		}
+		if e.Error != nil {
+			t.Fatal(e.Error)
+		}
+	case <-time.After(Timeout):
+		t.Fatal("timed out waiting for the create event")
+	}
+
+	close(stopChan)
+	// these events happen after the stop and must not be delivered
+	if err := client.StartContainer(id, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.StopContainer(id, 1); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(Timeout)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if e.Error == nil && e.Action != dockerclient.ActionCreate {
+				t.Fatalf("received %s event after stopChan was closed", e.Action)
+			}
+		case <-deadline:
+			t.Fatal("the events channel wasn't closed after stopChan")
+		}
+	}
+}
+
+func testContainerStatsAfterError(t *testing.T, engine *fakeengine.Engine, client dockerclient.Client) {
+	engine.AddImage("busybox", nil)
+	id := createContainer(t, client, "busybox")
+	if err := client.StartContainer(id, nil); err != nil {
+		t.Fatal(err)
+	}
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	stats, err := client.ContainerStats(id, stopChan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case s, ok := <-stats:
+		if !ok || s.Error != nil {
+			t.Fatalf("expected a first sample, got %+v", s)
+		}
+	case <-time.After(Timeout):
+		t.Fatal("timed out waiting for stats")
+	}
+
+	// the stream breaks when the container goes away
+	if err := client.RemoveContainer(id, true, false); err != nil {
+		t.Fatal(err)
+	}
+	failed := false
+	deadline := time.After(Timeout)
+	for {
+		select {
+		case s, ok := <-stats:
+			if !ok {
+				if !failed {
+					t.Fatal("the stats channel was closed without reporting an error")
+				}
+				return
+			}
+			if failed {
+				t.Fatalf("received %+v after an error", s)
+			}
+			failed = s.Error != nil
+		case <-deadline:
+			t.Fatal("the stats channel wasn't closed after the stream broke")
+		}
+	}
+}
+
+func testWait(t *testing.T, engine *fakeengine.Engine, client dockerclient.Client) {
+	engine.AddImage("busybox", nil)
+	engine.SetBehavior("busybox", fakeengine.Behavior{RunFor: 10 * time.Millisecond, ExitCode: 3})
+	id := createContainer(t, client, "busybox")
+	if err := client.StartContainer(id, nil); err != nil {
+		t.Fatal(err)
+	}
+	result := receiveWait(t, client.Wait(id))
+	if result.Error != nil || result.ExitCode != 3 {
+		t.Fatalf("expected exit code 3, got %+v", result)
+	}
+
+	// waiting for a container that already exited returns at once
+	result = receiveWait(t, client.WaitWithOptions(id, &dockerclient.WaitOptions{Condition: dockerclient.WaitConditionNotRunning}, nil))
+	if result.Error != nil || result.ExitCode != 3 {
+		t.Fatalf("expected exit code 3 for an exited container, got %+v", result)
+	}
+
+	result = receiveWait(t, client.Wait("missing"))
+	if result.Error == nil {
+		t.Fatalf("expected an error waiting for a missing container, got %+v", result)
+	}
+}
+
+func testWaitCanceled(t *testing.T, engine *fakeengine.Engine, client dockerclient.Client) {
+	engine.AddImage("busybox", nil)
+	id := createContainer(t, client, "busybox")
+	if err := client.StartContainer(id, nil); err != nil {
+		t.Fatal(err)
+	}
+	stopChan := make(chan struct{})
+	results := client.WaitWithOptions(id, nil, stopChan)
+	close(stopChan)
+	result := receiveWait(t, results)
+	if result.Error != dockerclient.ErrWaitCanceled {
+		t.Fatalf("expected ErrWaitCanceled, got %+v", result)
+	}
+}
+
+func createContainer(t *testing.T, client dockerclient.Client, image string) string {
+	id, err := client.CreateContainer(&dockerclient.ContainerConfig{Image: image}, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func receiveWait(t *testing.T, results <-chan dockerclient.WaitResult) dockerclient.WaitResult {
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(Timeout):
+		t.Fatal("timed out waiting for the wait result")
+	}
+	return dockerclient.WaitResult{}
+}
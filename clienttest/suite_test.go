@@ -0,0 +1,40 @@
+package clienttest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/samalba/dockerclient/fakeengine"
+)
+
+func newDockerClient(t *testing.T, daemonURL string) dockerclient.Client {
+	client, err := dockerclient.NewDockerClient(daemonURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestDockerClient(t *testing.T) {
+	Run(t, newDockerClient)
+}
+
+func TestDockerClientUnix(t *testing.T) {
+	RunWithEngine(t, func(t *testing.T) (*fakeengine.Engine, string) {
+		dir, err := ioutil.TempDir("", "clienttest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		engine := fakeengine.New()
+		l, err := engine.ServeUnix(filepath.Join(dir, "docker.sock"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { l.Close() })
+		return engine, "unix://" + l.Addr().String()
+	}, newDockerClient)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+)
+
+const header = "// Code generated by clientgen from interface.go. DO NOT EDIT.\n\n"
+
+// signature returns the parameters and results of m as declared
+func (m *Method) signature() string {
+	var params []string
+	for _, p := range m.Params {
+		switch {
+		case p.shared:
+			params = append(params, p.Name)
+		case p.Variadic:
+			params = append(params, p.Name+" ..."+p.Type)
+		default:
+			params = append(params, p.Name+" "+p.Type)
+		}
+	}
+	sig := "(" + strings.Join(params, ", ") + ")"
+	switch len(m.Results) {
+	case 0:
+	case 1:
+		sig += " " + m.Results[0]
+	default:
+		sig += " (" + strings.Join(m.Results, ", ") + ")"
+	}
+	return sig
+}
+
+// arguments returns the parameters of m as passed on to another call
+func (m *Method) arguments(spread bool) string {
+	args := make([]string, len(m.Params))
+	for i, p := range m.Params {
+		args[i] = p.Name
+		if p.Variadic && spread {
+			args[i] += "..."
+		}
+	}
+	return strings.Join(args, ", ")
+}
+
+func (m *Method) hasParam(name string) bool {
+	for _, p := range m.Params {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// writeImports writes the imports of the methods and extra, the standard
+// library first
+func writeImports(buf *bytes.Buffer, iface *Interface, extra ...string) {
+	var std, others []string
+	for _, path := range append(extra, values(iface.Imports)...) {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			others = append(others, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+	buf.WriteString("import (\n")
+	for _, path := range std {
+		fmt.Fprintf(buf, "\t%q\n", path)
+	}
+	if len(std) > 0 && len(others) > 0 {
+		buf.WriteString("\n")
+	}
+	for _, path := range others {
+		fmt.Fprintf(buf, "\t%q\n", path)
+	}
+	buf.WriteString(")\n\n")
+}
+
+func values(m map[string]string) []string {
+	var ret []string
+	for _, v := range m {
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+func generateMock(iface *Interface) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString("package mockclient\n\n")
+	writeImports(&buf, iface, iface.ImportPath, "github.com/stretchr/testify/mock")
+	buf.WriteString("type MockClient struct {\n\tmock.Mock\n}\n\n")
+	buf.WriteString("func NewMockClient() *MockClient {\n\treturn &MockClient{}\n}\n")
+
+	for _, m := range iface.Methods {
+		fmt.Fprintf(&buf, "\nfunc (client *MockClient) %s%s {\n", m.Name, m.signature())
+		if len(m.Results) == 0 {
+			fmt.Fprintf(&buf, "\tclient.Mock.Called(%s)\n}\n", m.arguments(false))
+			continue
+		}
+		args := "args"
+		if m.hasParam(args) {
+			args = "called"
+		}
+		fmt.Fprintf(&buf, "\t%s := client.Mock.Called(%s)\n", args, m.arguments(false))
+		results := make([]string, len(m.Results))
+		for i, typ := range m.Results {
+			switch typ {
+			case "error":
+				results[i] = fmt.Sprintf("%s.Error(%d)", args, i)
+			case "string":
+				results[i] = fmt.Sprintf("%s.String(%d)", args, i)
+			case "bool":
+				results[i] = fmt.Sprintf("%s.Bool(%d)", args, i)
+			case "int":
+				results[i] = fmt.Sprintf("%s.Int(%d)", args, i)
+			default:
+				results[i] = fmt.Sprintf("%s.Get(%d).(%s)", args, i, typ)
+			}
+		}
+		fmt.Fprintf(&buf, "\treturn %s\n}\n", strings.Join(results, ", "))
+	}
+	return format.Source(buf.Bytes())
+}
+
+func generateNop(iface *Interface) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString("package nopclient\n\n")
+	writeImports(&buf, iface, iface.ImportPath, "errors")
+	buf.WriteString("var (\n\tErrNoEngine = errors.New(\"Engine no longer exists\")\n)\n\n")
+	buf.WriteString("type NopClient struct {\n}\n\n")
+	buf.WriteString("func NewNopClient() *NopClient {\n\treturn &NopClient{}\n}\n")
+
+	for _, m := range iface.Methods {
+		fmt.Fprintf(&buf, "\nfunc (client *NopClient) %s%s {\n", m.Name, m.signature())
+		if len(m.Results) == 0 {
+			buf.WriteString("\treturn\n}\n")
+			continue
+		}
+		// a lone channel of results, with no error to return, carries
+		// the error instead
+		if typ := m.Results[0]; len(m.Results) == 1 && strings.HasPrefix(typ, "<-chan ") {
+			if elem := strings.TrimPrefix(typ, "<-chan "); iface.results[strings.TrimPrefix(elem, iface.Package+".")] {
+				fmt.Fprintf(&buf, "\tch := make(chan %s, 1)\n", elem)
+				fmt.Fprintf(&buf, "\tch <- %s{Error: ErrNoEngine}\n", elem)
+				buf.WriteString("\treturn ch\n}\n")
+				continue
+			}
+		}
+		results := make([]string, len(m.Results))
+		for i, typ := range m.Results {
+			results[i] = iface.zero(typ)
+		}
+		fmt.Fprintf(&buf, "\treturn %s\n}\n", strings.Join(results, ", "))
+	}
+	return format.Source(buf.Bytes())
+}
+
+// zero returns the value a NopClient returns for typ
+func (iface *Interface) zero(typ string) string {
+	switch {
+	case typ == "error":
+		return "ErrNoEngine"
+	case typ == "string":
+		return `""`
+	case typ == "bool":
+		return "false"
+	case predeclared[typ]:
+		return "0"
+	case strings.HasPrefix(typ, iface.Package+".") && iface.structs[strings.TrimPrefix(typ, iface.Package+".")]:
+		return typ + "{}"
+	case strings.HasPrefix(typ, "*"), strings.HasPrefix(typ, "[]"), strings.HasPrefix(typ, "map["),
+		strings.HasPrefix(typ, "chan"), strings.HasPrefix(typ, "<-chan"), strings.Contains(typ, "."):
+		// pointers, slices, maps, channels and, for the qualified names
+		// left, interfaces
+		return "nil"
+	}
+	return "*new(" + typ + ")"
+}
+
+func generateDecorator(iface *Interface, pkg, typeName string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	writeImports(&buf, iface, iface.ImportPath)
+	fmt.Fprintf(&buf, "// %s wraps a dockerclient.Client. Every method forwards to the wrapped\n", typeName)
+	buf.WriteString("// client; edit the ones that need to do more.\n")
+	fmt.Fprintf(&buf, "type %s struct {\n\tnext %s.Client\n}\n\n", typeName, iface.Package)
+	fmt.Fprintf(&buf, "func New%s(next %s.Client) *%s {\n\treturn &%s{next: next}\n}\n", typeName, iface.Package, typeName, typeName)
+
+	for _, m := range iface.Methods {
+		fmt.Fprintf(&buf, "\nfunc (d *%s) %s%s {\n", typeName, m.Name, m.signature())
+		call := fmt.Sprintf("d.next.%s(%s)", m.Name, m.arguments(true))
+		if len(m.Results) == 0 {
+			fmt.Fprintf(&buf, "\t%s\n}\n", call)
+		} else {
+			fmt.Fprintf(&buf, "\treturn %s\n}\n", call)
+		}
+	}
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,71 @@
+// Command clientgen generates implementations of the dockerclient.Client
+// interface from interface.go, so that they don't drift when it grows.
+//
+// It emits one of:
+//
+//	mock       the testify based MockClient of package mockclient
+//	nop        the NopClient of package nopclient, failing with ErrNoEngine
+//	decorator  a skeleton wrapping another Client, forwarding every call
+//
+// The mock and nop clients are regenerated with go generate from the root
+// of the repository. A decorator skeleton is meant to be generated once and
+// edited:
+//
+//	go run github.com/samalba/dockerclient/cmd/clientgen -kind decorator \
+//		-interface $GOPATH/src/github.com/samalba/dockerclient/interface.go \
+//		-pkg mywrapper -type Wrapper -o wrapper.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	kind := flag.String("kind", "", "what to generate: mock, nop or decorator")
+	source := flag.String("interface", "interface.go", "file declaring the Client interface")
+	output := flag.String("o", "", "output file, standard output if empty")
+	pkg := flag.String("pkg", "", "package of the generated code (decorator only)")
+	typeName := flag.String("type", "", "name of the generated type (decorator only)")
+	flag.Parse()
+
+	iface, err := parseInterface(*source, "Client")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var src []byte
+	switch *kind {
+	case "mock":
+		src, err = generateMock(iface)
+	case "nop":
+		src, err = generateNop(iface)
+	case "decorator":
+		if *pkg == "" {
+			*pkg = "main"
+		}
+		if *typeName == "" {
+			*typeName = "Decorator"
+		}
+		src, err = generateDecorator(iface, *pkg, *typeName)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "clientgen: wrote %s\n", filepath.Clean(*output))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func parseClient(t *testing.T) *Interface {
+	iface, err := parseInterface("../../interface.go", "Client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return iface
+}
+
+// TestGeneratedUpToDate fails when interface.go changed without running
+// go generate
+func TestGeneratedUpToDate(t *testing.T) {
+	iface := parseClient(t)
+	for path, generate := range map[string]func(*Interface) ([]byte, error){
+		"../../mockclient/mock.go": generateMock,
+		"../../nopclient/nop.go":   generateNop,
+	} {
+		expected, err := generate(iface)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expected, actual) {
+			t.Errorf("%s is out of date, run go generate", path)
+		}
+	}
+}
+
+func TestParseInterface(t *testing.T) {
+	iface := parseClient(t)
+	var kill *Method
+	for _, m := range iface.Methods {
+		if m.Name == "KillContainer" {
+			kill = m
+		}
+	}
+	if kill == nil {
+		t.Fatal("KillContainer not found")
+	}
+	if sig := kill.signature(); sig != "(id, signal string) error" {
+		t.Fatalf("unexpected signature %q", sig)
+	}
+	if iface.Imports["io"] != "io" {
+		t.Fatalf("expected io to be imported, got %v", iface.Imports)
+	}
+}
+
+func TestGenerateDecorator(t *testing.T) {
+	iface := parseClient(t)
+	src, err := generateDecorator(iface, "wrapper", "Logging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "wrapper.go", src, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"func NewLogging(next dockerclient.Client) *Logging",
+		"return d.next.Info()",
+		"d.next.StartMonitorEvents(cb, ec, args...)",
+	} {
+		if !strings.Contains(string(src), expected) {
+			t.Errorf("expected the decorator to contain %q", expected)
+		}
+	}
+}
+
+func TestGenerateNop(t *testing.T) {
+	src, err := generateNop(parseClient(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// waits report the error on their channel, having none to return
+	expected := "ch := make(chan dockerclient.WaitResult, 1)\n\tch <- dockerclient.WaitResult{Error: ErrNoEngine}\n\treturn ch\n"
+	if strings.Count(string(src), expected) != 2 {
+		t.Errorf("expected Wait and WaitWithOptions to send ErrNoEngine")
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Interface is the parsed Client interface
+type Interface struct {
+	Package string
+	// ImportPath is the import path of the package declaring the
+	// interface
+	ImportPath string
+	Methods    []*Method
+	// Imports maps the names of the other packages used by the methods
+	// to their import paths
+	Imports map[string]string
+	// structs are the struct types declared by the package
+	structs map[string]bool
+	// results are the structs with an Error field of type error, such as
+	// WaitResult, which methods send on the channels they return
+	results map[string]bool
+}
+
+type Method struct {
+	Name    string
+	Params  []*Param
+	Results []string
+}
+
+type Param struct {
+	Name     string
+	Type     string
+	Variadic bool
+	// shared is set when the next parameter is declared with this one,
+	// as in "id, signal string"
+	shared bool
+}
+
+// parseInterface parses the interface called name in the file at path,
+// qualifying the identifiers of its package
+func parseInterface(path, name string) (*Interface, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, filepath.Dir(path), nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	var file *ast.File
+	var pkgName string
+	for n, pkg := range pkgs {
+		if strings.HasSuffix(n, "_test") {
+			continue
+		}
+		for filename, f := range pkg.Files {
+			if filepath.Base(filename) == filepath.Base(path) {
+				file, pkgName = f, n
+			}
+		}
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%s: file not found", path)
+	}
+
+	iface := &Interface{
+		Package:    pkgName,
+		ImportPath: "github.com/samalba/dockerclient",
+		Imports:    make(map[string]string),
+		structs:    make(map[string]bool),
+		results:    make(map[string]bool),
+	}
+	for _, f := range pkgs[pkgName].Files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				iface.structs[ts.Name.Name] = true
+				for _, field := range st.Fields.List {
+					typ, ok := field.Type.(*ast.Ident)
+					if ok && typ.Name == "error" && len(field.Names) == 1 && field.Names[0].Name == "Error" {
+						iface.results[ts.Name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	imports := make(map[string]string)
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		local := filepath.Base(importPath)
+		if spec.Name != nil {
+			local = spec.Name.Name
+		}
+		imports[local] = importPath
+	}
+
+	obj := file.Scope.Lookup(name)
+	if obj == nil {
+		return nil, fmt.Errorf("%s: no type %s", path, name)
+	}
+	spec, ok := obj.Decl.(*ast.TypeSpec)
+	if !ok {
+		return nil, fmt.Errorf("%s: %s is not a type", path, name)
+	}
+	it, ok := spec.Type.(*ast.InterfaceType)
+	if !ok {
+		return nil, fmt.Errorf("%s: %s is not an interface", path, name)
+	}
+
+	p := &typePrinter{pkg: pkgName, imports: imports, used: iface.Imports}
+	for _, field := range it.Methods.List {
+		fn, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) == 0 {
+			return nil, fmt.Errorf("%s: embedded interfaces aren't supported", path)
+		}
+		m := &Method{Name: field.Names[0].Name}
+		for _, param := range fn.Params.List {
+			typ, variadic := param.Type, false
+			if ellipsis, ok := typ.(*ast.Ellipsis); ok {
+				typ, variadic = ellipsis.Elt, true
+			}
+			names := param.Names
+			if len(names) == 0 {
+				names = []*ast.Ident{ast.NewIdent(fmt.Sprintf("p%d", len(m.Params)))}
+			}
+			for i, n := range names {
+				m.Params = append(m.Params, &Param{
+					Name:     n.Name,
+					Type:     p.print(typ),
+					Variadic: variadic,
+					shared:   i < len(names)-1,
+				})
+			}
+		}
+		if fn.Results != nil {
+			for _, result := range fn.Results.List {
+				count := len(result.Names)
+				if count == 0 {
+					count = 1
+				}
+				for i := 0; i < count; i++ {
+					m.Results = append(m.Results, p.print(result.Type))
+				}
+			}
+		}
+		iface.Methods = append(iface.Methods, m)
+	}
+	return iface, nil
+}
+
+// typePrinter prints type expressions as seen from another package
+type typePrinter struct {
+	pkg     string
+	imports map[string]string
+	used    map[string]string
+}
+
+var predeclared = map[string]bool{
+	"bool": true, "byte": true, "complex64": true, "complex128": true,
+	"error": true, "float32": true, "float64": true, "int": true,
+	"int8": true, "int16": true, "int32": true, "int64": true, "rune": true,
+	"string": true, "uint": true, "uint8": true, "uint16": true,
+	"uint32": true, "uint64": true, "uintptr": true,
+}
+
+func (p *typePrinter) print(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if predeclared[t.Name] {
+			return t.Name
+		}
+		return p.pkg + "." + t.Name
+	case *ast.SelectorExpr:
+		local := t.X.(*ast.Ident).Name
+		p.used[local] = p.imports[local]
+		return local + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + p.print(t.X)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "[]" + p.print(t.Elt)
+		}
+		return "[" + t.Len.(*ast.BasicLit).Value + "]" + p.print(t.Elt)
+	case *ast.MapType:
+		return "map[" + p.print(t.Key) + "]" + p.print(t.Value)
+	case *ast.ChanType:
+		switch t.Dir {
+		case ast.SEND:
+			return "chan<- " + p.print(t.Value)
+		case ast.RECV:
+			return "<-chan " + p.print(t.Value)
+		}
+		return "chan " + p.print(t.Value)
+	case *ast.InterfaceType:
+		if len(t.Methods.List) == 0 {
+			return "interface{}"
+		}
+	case *ast.StructType:
+		if len(t.Fields.List) == 0 {
+			return "struct{}"
+		}
+	}
+	panic(fmt.Sprintf("clientgen: unsupported type %T", expr))
+}
@@ -0,0 +1,273 @@
+package compose
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/samalba/dockerclient/fakeengine"
+)
+
+const testFile = `
+version: "3.7"
+x-logging: &logging
+  driver: json-file
+  options:
+    max-size: 10m
+services:
+  web:
+    image: "nginx:${TAG:-latest}"
+    command: nginx -g "daemon off;"
+    ports:
+      - "8080:80"
+      - "127.0.0.1:9000-9001:9000-9001/udp"
+      - target: 443
+        published: 8443
+    volumes:
+      - ./html:/usr/share/nginx/html:ro
+      - data:/data
+      - /cache
+    environment:
+      LEVEL: debug
+      HOME:
+    networks:
+      front:
+        aliases: [www]
+    depends_on: [db]
+    links: ["db:database"]
+    restart: on-failure:3
+    healthcheck:
+      test: curl -f http://localhost
+      interval: 30s
+      retries: 3
+    ulimits:
+      nofile: {soft: 1024, hard: 2048}
+      nproc: 512
+    logging: *logging
+    mem_limit: 512m
+  db:
+    image: redis
+    container_name: database
+    labels:
+      tier: backend
+networks:
+  front:
+volumes:
+  data:
+    external: true
+`
+
+func testEnvironment() map[string]string {
+	return map[string]string{"HOME": "/root"}
+}
+
+func TestLoad(t *testing.T) {
+	project, err := Load([]byte(testFile), &Options{Name: "My App", WorkingDir: "/srv", Environment: testEnvironment()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Name != "myapp" {
+		t.Fatalf("expected the project to be called myapp, got %q", project.Name)
+	}
+	web, db := project.Service("web"), project.Service("db")
+	if web == nil || db == nil || web.ContainerName != "myapp_web_1" || db.ContainerName != "database" {
+		t.Fatalf("unexpected services %+v", project.Services)
+	}
+
+	c := web.Config
+	if c.Image != "nginx:latest" {
+		t.Fatalf("expected the default tag, got %s", c.Image)
+	}
+	if !reflect.DeepEqual(c.Cmd, []string{"nginx", "-g", "daemon off;"}) {
+		t.Fatalf("unexpected command %q", c.Cmd)
+	}
+	if !reflect.DeepEqual(c.Env, []string{"HOME=/root", "LEVEL=debug"}) {
+		t.Fatalf("unexpected environment %q", c.Env)
+	}
+	if c.Labels[ProjectLabel] != "myapp" || c.Labels[ServiceLabel] != "web" {
+		t.Fatalf("unexpected labels %v", c.Labels)
+	}
+
+	h := c.HostConfig
+	bindings := map[string][]dockerclient.PortBinding{
+		"80/tcp":   {{HostPort: "8080"}},
+		"443/tcp":  {{HostPort: "8443"}},
+		"9000/udp": {{HostIp: "127.0.0.1", HostPort: "9000"}},
+		"9001/udp": {{HostIp: "127.0.0.1", HostPort: "9001"}},
+	}
+	if !reflect.DeepEqual(h.PortBindings, bindings) || len(c.ExposedPorts) != 4 {
+		t.Fatalf("unexpected ports %v %v", h.PortBindings, c.ExposedPorts)
+	}
+	if !reflect.DeepEqual(h.Binds, []string{"/srv/html:/usr/share/nginx/html:ro", "data:/data"}) {
+		t.Fatalf("unexpected binds %q", h.Binds)
+	}
+	if _, ok := c.Volumes["/cache"]; !ok {
+		t.Fatalf("expected an anonymous volume, got %v", c.Volumes)
+	}
+	if h.NetworkMode != "myapp_front" {
+		t.Fatalf("unexpected network mode %s", h.NetworkMode)
+	}
+	endpoint := c.NetworkingConfig.EndpointsConfig["myapp_front"]
+	if endpoint == nil || !reflect.DeepEqual(endpoint.Aliases, []string{"web", "www"}) {
+		t.Fatalf("unexpected endpoints %v", c.NetworkingConfig.EndpointsConfig)
+	}
+	if !reflect.DeepEqual(h.Links, []string{"database:database"}) || !reflect.DeepEqual(web.DependsOn, []string{"db", "db"}) {
+		t.Fatalf("unexpected links %q and dependencies %q", h.Links, web.DependsOn)
+	}
+	if h.RestartPolicy.Name != "on-failure" || h.RestartPolicy.MaximumRetryCount != 3 {
+		t.Fatalf("unexpected restart policy %+v", h.RestartPolicy)
+	}
+	health := c.Healthcheck
+	if health == nil || !reflect.DeepEqual(health.Test, []string{"CMD-SHELL", "curl -f http://localhost"}) || health.Interval != 30*time.Second || health.Retries != 3 {
+		t.Fatalf("unexpected healthcheck %+v", health)
+	}
+	ulimits := []dockerclient.Ulimit{{Name: "nofile", Soft: 1024, Hard: 2048}, {Name: "nproc", Soft: 512, Hard: 512}}
+	if !reflect.DeepEqual(h.Ulimits, ulimits) {
+		t.Fatalf("unexpected ulimits %+v", h.Ulimits)
+	}
+	if h.LogConfig.Type != "json-file" || h.LogConfig.Config["max-size"] != "10m" {
+		t.Fatalf("unexpected logging %+v", h.LogConfig)
+	}
+	if h.Memory != 512*1024*1024 {
+		t.Fatalf("unexpected memory limit %d", h.Memory)
+	}
+
+	// db isn't on any network, it gets the default one
+	if db.Config.HostConfig.NetworkMode != "myapp_default" || db.Config.Labels["tier"] != "backend" {
+		t.Fatalf("unexpected db config %+v", db.Config)
+	}
+	var networks []string
+	for _, n := range project.Networks {
+		networks = append(networks, n.Create.Name)
+	}
+	if !reflect.DeepEqual(networks, []string{"myapp_front", "myapp_default"}) {
+		t.Fatalf("unexpected networks %q", networks)
+	}
+	if v := project.Volumes[0]; !v.External || v.Create.Name != "data" {
+		t.Fatalf("unexpected volume %+v", v)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, test := range []struct {
+		yaml string
+		err  string
+	}{
+		{"services:\n  web:\n    image: nginx\n    portz: [80]\n", `line 4: unknown key "portz" in service web`},
+		{"services:\n  web:\n    command: ls\n", "line 3: service web has no image"},
+		{"services:\n  web:\n    image: nginx\n    depends_on:\n      - db\n", `line 5: undefined service "db"`},
+		{"services:\n  web:\n    image: nginx\n    ports: [\"80:90-91\"]\n", `line 4: invalid port "80:90-91": host range 80 and container range 90-91 don't have the same length`},
+		{"services:\n  web:\n    image: nginx\n    ports: [\"99999\"]\n", `line 4: invalid port "99999": invalid container port: "99999" isn't a port number`},
+		{"services:\n  web:\n    image: nginx\n    volumes: [\"data:/data\"]\n", `line 4: undefined volume "data"`},
+		{"services:\n  web:\n    image: nginx\n    networks: [back]\n", "line 4: service web uses an undefined network"},
+		{"services:\n  web:\n    image: nginx\n    restart: sometimes\n", `line 4: invalid restart policy "sometimes"`},
+		{"services:\n  web:\n    image: ${TAG:?set a tag}\n", "line 3: invalid interpolation format in \"${TAG:?set a tag}\": required variable TAG is missing a value: set a tag"},
+		{"services:\n  web:\n    image: nginx\n    image: redis\n", `line 4: duplicate key "image"`},
+		{"services:\n  web:\n    image: nginx\n    mem_limit: lots\n", `line 4: expected a size such as 512m, got "lots"`},
+		{"version: '1'\nservices: {}\n", `line 1: unsupported version "1", only versions 2 and 3 are`},
+	} {
+		_, err := Load([]byte(test.yaml), &Options{Name: "test", Environment: map[string]string{}})
+		if err == nil || err.Error() != test.err {
+			t.Errorf("expected %q, got %v", test.err, err)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Shop")
+	files := map[string]string{
+		"docker-compose.yml": "services:\n  web:\n    image: nginx:$TAG\n    env_file: web.env\n    environment: [LEVEL=info]\n",
+		".env":               "TAG=1.19\n",
+		"web.env":            "# web\nLEVEL=debug\nPORT=80\n",
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	options := &Options{Environment: map[string]string{}}
+	project, err := LoadFile(filepath.Join(dir, "docker-compose.yml"), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options.Environment) != 0 {
+		t.Fatalf("expected the .env file to be left out of the options, got %v", options.Environment)
+	}
+	c := project.Service("web").Config
+	if project.Name != "shop" || c.Image != "nginx:1.19" {
+		t.Fatalf("unexpected project %s with image %s", project.Name, c.Image)
+	}
+	if !reflect.DeepEqual(c.Env, []string{"LEVEL=info", "PORT=80"}) {
+		t.Fatalf("unexpected environment %q", c.Env)
+	}
+
+	_, err = LoadFile(filepath.Join(dir, "web.env"), nil)
+	if err == nil || !strings.HasPrefix(err.Error(), filepath.Join(dir, "web.env")+":2: ") {
+		t.Fatalf("expected an error pointing to the file, got %v", err)
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	engine := fakeengine.New()
+	engine.AddImage("nginx:latest", nil)
+	engine.AddImage("redis", nil)
+	server := engine.Serve()
+	defer server.Close()
+	client, err := dockerclient.NewDockerClient(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateVolume(&dockerclient.VolumeCreateRequest{Name: "data"}); err != nil {
+		t.Fatal(err)
+	}
+	project, err := Load([]byte(testFile), &Options{Name: "myapp", Environment: testEnvironment()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := Up(client, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "create network myapp_front\ncreate network myapp_default\ncreate container database\ncreate container myapp_web_1\n"
+	if plan.String() != expected {
+		t.Fatalf("expected\n%sgot\n%s", expected, plan)
+	}
+	info, err := client.InspectContainer("myapp_web_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.State.Running || info.Config.Labels[ProjectLabel] != "myapp" {
+		t.Fatalf("expected web to run with the project label, got %+v", info)
+	}
+	if plan, err := Up(client, project); err != nil || !plan.Empty() {
+		t.Fatalf("expected nothing left to do, got %v %v", plan, err)
+	}
+
+	if _, err := Down(client, "myapp", true); err != nil {
+		t.Fatal(err)
+	}
+	containers, err := client.ListContainers(true, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 0 {
+		t.Fatalf("expected the containers to be removed, got %+v", containers)
+	}
+	// the external volume isn't the project's
+	volumes, err := client.ListVolumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 1 || volumes[0].Name != "data" {
+		t.Fatalf("expected the external volume to be kept, got %+v", volumes)
+	}
+}
@@ -0,0 +1,299 @@
+package compose
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"gopkg.in/yaml.v3"
+)
+
+// Error is an invalid compose file. Line is the line of the offending
+// value, counting from 1.
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// decoder reads the values of a YAML document, interpolating variables
+// and reporting errors with their line
+type decoder struct {
+	file   string
+	lookup func(string) (string, bool)
+}
+
+func (d *decoder) errorf(n *yaml.Node, format string, args ...interface{}) error {
+	return &Error{File: d.file, Line: n.Line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func resolve(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
+
+func isNull(n *yaml.Node) bool {
+	n = resolve(n)
+	return n.Kind == yaml.ScalarNode && n.Tag == "!!null"
+}
+
+type pair struct {
+	key   string
+	node  *yaml.Node
+	value *yaml.Node
+}
+
+// pairs returns the entries of mapping n, merge keys (<<) resolved
+func (d *decoder) pairs(n *yaml.Node) ([]pair, error) {
+	n = resolve(n)
+	if n.Kind != yaml.MappingNode {
+		return nil, d.errorf(n, "expected a mapping")
+	}
+	var ret []pair
+	seen := make(map[string]bool)
+	var merged []pair
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Value == "<<" {
+			sources := []*yaml.Node{value}
+			if v := resolve(value); v.Kind == yaml.SequenceNode {
+				sources = v.Content
+			}
+			for _, source := range sources {
+				entries, err := d.pairs(source)
+				if err != nil {
+					return nil, err
+				}
+				merged = append(merged, entries...)
+			}
+			continue
+		}
+		if seen[key.Value] {
+			return nil, d.errorf(key, "duplicate key %q", key.Value)
+		}
+		seen[key.Value] = true
+		ret = append(ret, pair{key: key.Value, node: key, value: value})
+	}
+	for _, p := range merged {
+		if !seen[p.key] {
+			seen[p.key] = true
+			ret = append(ret, p)
+		}
+	}
+	return ret, nil
+}
+
+// str returns the interpolated value of scalar n, "" if it is null
+func (d *decoder) str(n *yaml.Node) (string, error) {
+	n = resolve(n)
+	if n.Kind != yaml.ScalarNode {
+		return "", d.errorf(n, "expected a string")
+	}
+	if n.Tag == "!!null" {
+		return "", nil
+	}
+	s, err := interpolate(n.Value, d.lookup)
+	if err != nil {
+		return "", d.errorf(n, "%v", err)
+	}
+	return s, nil
+}
+
+// strs returns a list of strings, a single string being a list of one
+func (d *decoder) strs(n *yaml.Node) ([]string, error) {
+	n = resolve(n)
+	if n.Kind == yaml.ScalarNode {
+		s, err := d.str(n)
+		if err != nil || s == "" {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+	if n.Kind != yaml.SequenceNode {
+		return nil, d.errorf(n, "expected a string or a list of strings")
+	}
+	ret := make([]string, 0, len(n.Content))
+	for _, item := range n.Content {
+		s, err := d.str(item)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, s)
+	}
+	return ret, nil
+}
+
+// command returns a command given as a list or as a string, split as a
+// shell would
+func (d *decoder) command(n *yaml.Node) ([]string, error) {
+	if resolve(n).Kind == yaml.SequenceNode {
+		return d.strs(n)
+	}
+	s, err := d.str(n)
+	if err != nil {
+		return nil, err
+	}
+	args, err := splitCommand(s)
+	if err != nil {
+		return nil, d.errorf(n, "%v", err)
+	}
+	return args, nil
+}
+
+// strMap returns a mapping given either as a mapping or as a list of
+// "key=value". null values, and keys without a value in lists, are passed
+// to missing, which may drop them by returning false.
+func (d *decoder) strMap(n *yaml.Node, missing func(key string) (string, bool)) (map[string]string, error) {
+	ret := make(map[string]string)
+	n = resolve(n)
+	switch n.Kind {
+	case yaml.MappingNode:
+		entries, err := d.pairs(n)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range entries {
+			if isNull(p.value) {
+				if v, ok := missing(p.key); ok {
+					ret[p.key] = v
+				}
+				continue
+			}
+			if ret[p.key], err = d.str(p.value); err != nil {
+				return nil, err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			s, err := d.str(item)
+			if err != nil {
+				return nil, err
+			}
+			if i := strings.Index(s, "="); i >= 0 {
+				ret[s[:i]] = s[i+1:]
+			} else if v, ok := missing(s); ok {
+				ret[s] = v
+			}
+		}
+	default:
+		return nil, d.errorf(n, "expected a mapping or a list of key=value")
+	}
+	return ret, nil
+}
+
+func emptyValue(string) (string, bool) { return "", true }
+
+func (d *decoder) boolean(n *yaml.Node) (bool, error) {
+	s, err := d.str(n)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(s) {
+	case "true", "yes", "on", "y":
+		return true, nil
+	case "false", "no", "off", "n", "":
+		return false, nil
+	}
+	return false, d.errorf(n, "expected a boolean, got %q", s)
+}
+
+func (d *decoder) integer(n *yaml.Node) (int64, error) {
+	s, err := d.str(n)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, d.errorf(n, "expected an integer, got %q", s)
+	}
+	return i, nil
+}
+
+func (d *decoder) duration(n *yaml.Node) (time.Duration, error) {
+	s, err := d.str(n)
+	if err != nil {
+		return 0, err
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, d.errorf(n, "expected a duration such as 1m30s, got %q", s)
+	}
+	return duration, nil
+}
+
+// size returns a number of bytes, given as a number or with a unit such
+// as 512m or 1g
+func (d *decoder) size(n *yaml.Node) (int64, error) {
+	s, err := d.str(n)
+	if err != nil {
+		return 0, err
+	}
+	size, err := units.RAMInBytes(s)
+	if err != nil {
+		return 0, d.errorf(n, "expected a size such as 512m, got %q", s)
+	}
+	return size, nil
+}
+
+// splitCommand splits s into words as a shell would, honouring quotes and
+// backslashes
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteByte(c)
+			}
+		case quote == '"':
+			switch {
+			case c == '"':
+				quote = 0
+			case c == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`", s[i+1]) >= 0:
+				i++
+				word.WriteByte(s[i])
+			default:
+				word.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == '\\' && i+1 < len(s):
+			i++
+			word.WriteByte(s[i])
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
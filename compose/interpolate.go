@@ -0,0 +1,96 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+)
+
+// interpolate substitutes the variables of s, as the compose file format
+// defines them: $VAR, ${VAR}, ${VAR:-default} and ${VAR-default} for a
+// default value when VAR is unset or empty, respectively unset,
+// ${VAR:?message} and ${VAR?message} to require VAR, and $$ for a literal
+// $
+func interpolate(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			buf.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch {
+		case i == len(s):
+			return "", fmt.Errorf("invalid interpolation format in %q: a $ ends the string, use $$ for a literal $", s)
+		case s[i] == '$':
+			buf.WriteByte('$')
+		case s[i] == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("invalid interpolation format in %q: missing }", s)
+			}
+			value, err := substitute(s[i+1:i+end], lookup)
+			if err != nil {
+				return "", fmt.Errorf("invalid interpolation format in %q: %v", s, err)
+			}
+			buf.WriteString(value)
+			i += end
+		default:
+			n := variableLength(s[i:])
+			if n == 0 {
+				return "", fmt.Errorf("invalid interpolation format in %q: use $$ for a literal $", s)
+			}
+			value, _ := lookup(s[i : i+n])
+			buf.WriteString(value)
+			i += n - 1
+		}
+	}
+	return buf.String(), nil
+}
+
+// substitute evaluates the expression between the braces of ${...}
+func substitute(expr string, lookup func(string) (string, bool)) (string, error) {
+	n := variableLength(expr)
+	if n == 0 {
+		return "", fmt.Errorf("invalid variable name in ${%s}", expr)
+	}
+	name, op := expr[:n], expr[n:]
+	value, set := lookup(name)
+	switch {
+	case op == "":
+		return value, nil
+	case strings.HasPrefix(op, ":-"):
+		if value == "" {
+			return op[2:], nil
+		}
+	case strings.HasPrefix(op, "-"):
+		if !set {
+			return op[1:], nil
+		}
+	case strings.HasPrefix(op, ":?"):
+		if value == "" {
+			return "", fmt.Errorf("required variable %s is missing a value: %s", name, op[2:])
+		}
+	case strings.HasPrefix(op, "?"):
+		if !set {
+			return "", fmt.Errorf("required variable %s is missing a value: %s", name, op[1:])
+		}
+	default:
+		return "", fmt.Errorf("invalid operator in ${%s}", expr)
+	}
+	return value, nil
+}
+
+// variableLength returns the length of the variable name s starts with
+func variableLength(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		return i
+	}
+	return len(s)
+}
@@ -0,0 +1,92 @@
+package compose
+
+import (
+	"github.com/samalba/dockerclient"
+	"gopkg.in/yaml.v3"
+)
+
+func (l *loader) loadPorts(c *dockerclient.ContainerConfig, n *yaml.Node) error {
+	items := resolve(n)
+	if items.Kind != yaml.SequenceNode {
+		return l.errorf(items, "expected a list of ports")
+	}
+	for _, item := range items.Content {
+		if resolve(item).Kind == yaml.MappingNode {
+			spec, err := l.loadLongPort(item)
+			if err != nil {
+				return err
+			}
+			c.PublishPort(spec)
+			continue
+		}
+		s, err := l.str(item)
+		if err != nil {
+			return err
+		}
+		spec, err := dockerclient.ParsePortSpec(s)
+		if err != nil {
+			return l.errorf(item, "invalid port %q: %v", s, err)
+		}
+		c.PublishPort(spec)
+	}
+	return nil
+}
+
+// loadExpose loads the container ports of expose
+func (l *loader) loadExpose(c *dockerclient.ContainerConfig, n *yaml.Node) error {
+	ports, err := l.strs(n)
+	if err != nil {
+		return err
+	}
+	for _, port := range ports {
+		spec, err := dockerclient.ParsePortSpec(port)
+		if err != nil {
+			return l.errorf(n, "invalid port %q: %v", port, err)
+		}
+		for p := range spec.Bindings() {
+			c.ExposePort(p)
+		}
+	}
+	return nil
+}
+
+// loadLongPort loads the long syntax of ports, a mapping of target,
+// published, protocol and host_ip
+func (l *loader) loadLongPort(n *yaml.Node) (*dockerclient.PortSpec, error) {
+	entries, err := l.pairs(n)
+	if err != nil {
+		return nil, err
+	}
+	spec := &dockerclient.PortSpec{Proto: "tcp"}
+	for _, p := range entries {
+		var s string
+		if s, err = l.str(p.value); err != nil {
+			return nil, err
+		}
+		switch p.key {
+		case "target":
+			spec.ContainerPorts, err = dockerclient.ParsePortRange(s)
+		case "published":
+			spec.HostPorts, err = dockerclient.ParsePortRange(s)
+		case "protocol":
+			spec.Proto = s
+		case "host_ip":
+			spec.HostIP = s
+		case "mode":
+			// swarm ingress or host, a single engine only has the latter
+		default:
+			return nil, l.errorf(p.node, "unknown key %q in port", p.key)
+		}
+		if err != nil {
+			return nil, l.errorf(p.value, "%v", err)
+		}
+	}
+	if spec.ContainerPorts.Len() == 0 {
+		return nil, l.errorf(n, "port has no target")
+	}
+	// check the protocol, address and ranges as in the short syntax
+	if _, err := dockerclient.ParsePortSpec(spec.String()); err != nil {
+		return nil, l.errorf(n, "invalid port: %v", err)
+	}
+	return spec, nil
+}
@@ -0,0 +1,493 @@
+// Package compose loads docker-compose files, versions 2 and 3, into the
+// types of dockerclient, and brings the projects they describe up and
+// down.
+//
+// Variables are interpolated from the environment, and from the .env file
+// next to the compose file when using LoadFile. Invalid files are reported
+// with an *Error pointing to the offending line:
+//
+//	project, err := compose.LoadFile("docker-compose.yml", nil)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	plan, err := compose.Up(client, project)
+//
+// Only the single engine part of the format is supported: build, secrets
+// and configs are rejected, deploy is ignored.
+package compose
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ProjectLabel is set to the name of the project on every resource
+	ProjectLabel = "com.docker.compose.project"
+	// ServiceLabel is set to the name of the service on its container
+	ServiceLabel = "com.docker.compose.service"
+)
+
+// Project is the content of a compose file
+type Project struct {
+	Name string
+	// Services are sorted as declared
+	Services []*Service
+	Networks []*Network
+	Volumes  []*Volume
+}
+
+// Service is a service of the project, run as a single container
+type Service struct {
+	Name string
+	// ContainerName is the name of the container, <project>_<service>_1
+	// unless container_name is set
+	ContainerName string
+	// Config is the config to create the container with, HostConfig and
+	// NetworkingConfig included
+	Config *dockerclient.ContainerConfig
+	// DependsOn are the services to start first, those referred to by
+	// links, volumes_from and network_mode included
+	DependsOn []string
+}
+
+// Network is a network of the project
+type Network struct {
+	// Name is the name in the compose file, Create.Name the name on the
+	// engine
+	Name     string
+	Create   dockerclient.NetworkCreate
+	External bool
+}
+
+// Volume is a named volume of the project
+type Volume struct {
+	// Name is the name in the compose file, Create.Name the name on the
+	// engine
+	Name     string
+	Create   dockerclient.VolumeCreateRequest
+	External bool
+}
+
+// Service returns the service called name, nil if there is none
+func (p *Project) Service(name string) *Service {
+	for _, s := range p.Services {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Options tune the loading of a compose file
+type Options struct {
+	// Name is the name of the project. LoadFile defaults to the name of
+	// the directory of the file.
+	Name string
+	// WorkingDir is the directory relative paths are resolved against,
+	// the current directory if empty
+	WorkingDir string
+	// Environment holds the variables to interpolate. The environment of
+	// the process is used when nil.
+	Environment map[string]string
+}
+
+// LoadFile loads the compose file at path. Variables missing from the
+// environment are looked for in the .env file of the same directory.
+func LoadFile(path string, options *Options) (*Project, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	if opts.WorkingDir == "" {
+		opts.WorkingDir = dir
+	}
+	if opts.Name == "" {
+		opts.Name = filepath.Base(dir)
+	}
+	// merged into a copy, not to leak into the next project loaded with
+	// the same options
+	if opts.Environment == nil {
+		opts.Environment = environ()
+	} else {
+		environment := make(map[string]string, len(opts.Environment))
+		for k, v := range opts.Environment {
+			environment[k] = v
+		}
+		opts.Environment = environment
+	}
+	if dotEnv, err := readEnvFile(filepath.Join(dir, ".env")); err == nil {
+		for k, v := range dotEnv {
+			if _, ok := opts.Environment[k]; !ok {
+				opts.Environment[k] = v
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return load(data, path, &opts)
+}
+
+// Load loads a compose file from data
+func Load(data []byte, options *Options) (*Project, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Environment == nil {
+		opts.Environment = environ()
+	}
+	if opts.WorkingDir == "" {
+		dir, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		opts.WorkingDir = dir
+	}
+	return load(data, "", &opts)
+}
+
+var invalidNameChars = regexp.MustCompile("[^a-z0-9_-]")
+
+func load(data []byte, file string, opts *Options) (*Project, error) {
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(opts.Name), "")
+	if name == "" {
+		return nil, fmt.Errorf("compose: invalid project name %q", opts.Name)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		if file != "" {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, &Error{File: file, Line: 1, Msg: "empty compose file"}
+	}
+	d := &decoder{
+		file: file,
+		lookup: func(key string) (string, bool) {
+			v, ok := opts.Environment[key]
+			return v, ok
+		},
+	}
+	l := &loader{decoder: d, project: &Project{Name: name}, workingDir: opts.WorkingDir}
+	if err := l.load(doc.Content[0]); err != nil {
+		return nil, err
+	}
+	return l.project, nil
+}
+
+type loader struct {
+	*decoder
+	project    *Project
+	workingDir string
+}
+
+func (l *loader) load(root *yaml.Node) error {
+	entries, err := l.pairs(root)
+	if err != nil {
+		return err
+	}
+	var services *yaml.Node
+	for _, p := range entries {
+		switch {
+		case p.key == "version":
+			version, err := l.str(p.value)
+			if err != nil {
+				return err
+			}
+			if !strings.HasPrefix(version, "2") && !strings.HasPrefix(version, "3") {
+				return l.errorf(p.value, "unsupported version %q, only versions 2 and 3 are", version)
+			}
+		case p.key == "services":
+			services = p.value
+		case p.key == "networks":
+			if err := l.loadNetworks(p.value); err != nil {
+				return err
+			}
+		case p.key == "volumes":
+			if err := l.loadVolumes(p.value); err != nil {
+				return err
+			}
+		case p.key == "secrets", p.key == "configs":
+			return l.errorf(p.node, "%s aren't supported", p.key)
+		case strings.HasPrefix(p.key, "x-"):
+			// extension fields, used as anchors
+		default:
+			return l.errorf(p.node, "unknown top-level key %q", p.key)
+		}
+	}
+	if services == nil {
+		return l.errorf(root, "no services defined")
+	}
+	return l.loadServices(services)
+}
+
+func (l *loader) loadNetworks(n *yaml.Node) error {
+	entries, err := l.pairs(n)
+	if err != nil {
+		return err
+	}
+	for _, p := range entries {
+		network := &Network{
+			Name: p.key,
+			Create: dockerclient.NetworkCreate{
+				Name:           l.project.Name + "_" + p.key,
+				CheckDuplicate: true,
+				Labels:         map[string]string{},
+			},
+		}
+		if !isNull(p.value) {
+			if err := l.loadNetwork(network, p.value); err != nil {
+				return err
+			}
+		}
+		if !network.External {
+			network.Create.Labels[ProjectLabel] = l.project.Name
+		}
+		l.project.Networks = append(l.project.Networks, network)
+	}
+	return nil
+}
+
+func (l *loader) loadNetwork(network *Network, n *yaml.Node) error {
+	entries, err := l.pairs(n)
+	if err != nil {
+		return err
+	}
+	for _, p := range entries {
+		c := &network.Create
+		switch p.key {
+		case "driver":
+			c.Driver, err = l.str(p.value)
+		case "driver_opts":
+			c.Options, err = l.strMap(p.value, emptyValue)
+		case "internal":
+			c.Internal, err = l.boolean(p.value)
+		case "labels":
+			var labels map[string]string
+			labels, err = l.strMap(p.value, emptyValue)
+			for k, v := range labels {
+				c.Labels[k] = v
+			}
+		case "name":
+			c.Name, err = l.str(p.value)
+		case "external":
+			network.External, c.Name, err = l.external(p.value, c.Name, network.Name)
+		case "ipam":
+			err = l.loadIPAM(&c.IPAM, p.value)
+		case "attachable", "enable_ipv6":
+			// swarm and daemon level settings
+		default:
+			if !strings.HasPrefix(p.key, "x-") {
+				err = l.errorf(p.node, "unknown key %q in network %s", p.key, network.Name)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// external decodes the external setting of a network or volume, either a
+// boolean or a mapping giving the name of the resource
+func (l *loader) external(n *yaml.Node, name, short string) (bool, string, error) {
+	if resolve(n).Kind != yaml.MappingNode {
+		external, err := l.boolean(n)
+		if external {
+			// the resource isn't prefixed with the project
+			name = short
+		}
+		return external, name, err
+	}
+	entries, err := l.pairs(n)
+	if err != nil {
+		return false, "", err
+	}
+	name = short
+	for _, p := range entries {
+		if p.key != "name" {
+			return false, "", l.errorf(p.node, "unknown key %q in external", p.key)
+		}
+		if name, err = l.str(p.value); err != nil {
+			return false, "", err
+		}
+	}
+	return true, name, nil
+}
+
+func (l *loader) loadIPAM(ipam *dockerclient.IPAM, n *yaml.Node) error {
+	entries, err := l.pairs(n)
+	if err != nil {
+		return err
+	}
+	for _, p := range entries {
+		switch p.key {
+		case "driver":
+			ipam.Driver, err = l.str(p.value)
+		case "options":
+			ipam.Options, err = l.strMap(p.value, emptyValue)
+		case "config":
+			configs := resolve(p.value)
+			if configs.Kind != yaml.SequenceNode {
+				return l.errorf(configs, "expected a list of IPAM configs")
+			}
+			for _, item := range configs.Content {
+				var config dockerclient.IPAMConfig
+				fields, err := l.pairs(item)
+				if err != nil {
+					return err
+				}
+				for _, f := range fields {
+					switch f.key {
+					case "subnet":
+						config.Subnet, err = l.str(f.value)
+					case "ip_range":
+						config.IPRange, err = l.str(f.value)
+					case "gateway":
+						config.Gateway, err = l.str(f.value)
+					case "aux_addresses":
+						config.AuxAddress, err = l.strMap(f.value, emptyValue)
+					default:
+						err = l.errorf(f.node, "unknown key %q in IPAM config", f.key)
+					}
+					if err != nil {
+						return err
+					}
+				}
+				ipam.Config = append(ipam.Config, config)
+			}
+		default:
+			err = l.errorf(p.node, "unknown key %q in ipam", p.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *loader) loadVolumes(n *yaml.Node) error {
+	entries, err := l.pairs(n)
+	if err != nil {
+		return err
+	}
+	for _, p := range entries {
+		volume := &Volume{
+			Name: p.key,
+			Create: dockerclient.VolumeCreateRequest{
+				Name:   l.project.Name + "_" + p.key,
+				Labels: map[string]string{},
+			},
+		}
+		if !isNull(p.value) {
+			fields, err := l.pairs(p.value)
+			if err != nil {
+				return err
+			}
+			c := &volume.Create
+			for _, f := range fields {
+				switch f.key {
+				case "driver":
+					c.Driver, err = l.str(f.value)
+				case "driver_opts":
+					c.DriverOpts, err = l.strMap(f.value, emptyValue)
+				case "labels":
+					var labels map[string]string
+					labels, err = l.strMap(f.value, emptyValue)
+					for k, v := range labels {
+						c.Labels[k] = v
+					}
+				case "name":
+					c.Name, err = l.str(f.value)
+				case "external":
+					volume.External, c.Name, err = l.external(f.value, c.Name, volume.Name)
+				default:
+					if !strings.HasPrefix(f.key, "x-") {
+						err = l.errorf(f.node, "unknown key %q in volume %s", f.key, volume.Name)
+					}
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if !volume.External {
+			volume.Create.Labels[ProjectLabel] = l.project.Name
+		}
+		l.project.Volumes = append(l.project.Volumes, volume)
+	}
+	return nil
+}
+
+func (l *loader) network(name string) *Network {
+	for _, n := range l.project.Networks {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+func (l *loader) volume(name string) *Volume {
+	for _, v := range l.project.Volumes {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// environ returns the environment of the process
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	return env
+}
+
+// readEnvFile reads a file of KEY=value lines. Blank lines and lines
+// starting with # are ignored, and a KEY alone is set to the empty string.
+func readEnvFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.Index(line, "="); i >= 0 {
+			env[strings.TrimSpace(line[:i])] = line[i+1:]
+		} else {
+			env[line] = ""
+		}
+	}
+	return env, scanner.Err()
+}
@@ -0,0 +1,598 @@
+package compose
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+	"gopkg.in/yaml.v3"
+)
+
+func (l *loader) loadServices(n *yaml.Node) error {
+	entries, err := l.pairs(n)
+	if err != nil {
+		return err
+	}
+	// container names first, services refer to each other by them
+	for _, p := range entries {
+		s := &Service{
+			Name:          p.key,
+			ContainerName: fmt.Sprintf("%s_%s_1", l.project.Name, p.key),
+			Config: &dockerclient.ContainerConfig{
+				Labels: map[string]string{ProjectLabel: l.project.Name, ServiceLabel: p.key},
+			},
+		}
+		fields, err := l.pairs(p.value)
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if f.key == "container_name" {
+				if s.ContainerName, err = l.str(f.value); err != nil {
+					return err
+				}
+			}
+		}
+		l.project.Services = append(l.project.Services, s)
+	}
+	for i, p := range entries {
+		if err := l.loadService(l.project.Services[i], p.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// service returns the service referred to by n
+func (l *loader) service(n *yaml.Node, name string) (*Service, error) {
+	if s := l.project.Service(name); s != nil {
+		return s, nil
+	}
+	return nil, l.errorf(n, "undefined service %q", name)
+}
+
+func (l *loader) loadService(s *Service, n *yaml.Node) error {
+	fields, err := l.pairs(n)
+	if err != nil {
+		return err
+	}
+	c := s.Config
+	h := &c.HostConfig
+	var env map[string]string
+	var envFiles []map[string]string
+	var networks *yaml.Node
+	for _, f := range fields {
+		switch f.key {
+		case "image":
+			c.Image, err = l.str(f.value)
+		case "command":
+			c.Cmd, err = l.command(f.value)
+		case "entrypoint":
+			c.Entrypoint, err = l.command(f.value)
+		case "environment":
+			env, err = l.strMap(f.value, l.lookup)
+		case "env_file":
+			var files []string
+			if files, err = l.strs(f.value); err != nil {
+				return err
+			}
+			for _, file := range files {
+				vars, err := readEnvFile(l.path(file))
+				if err != nil {
+					return l.errorf(f.value, "%v", err)
+				}
+				envFiles = append(envFiles, vars)
+			}
+		case "labels":
+			var labels map[string]string
+			labels, err = l.strMap(f.value, emptyValue)
+			for k, v := range labels {
+				c.Labels[k] = v
+			}
+		case "container_name":
+			// already loaded
+		case "hostname":
+			c.Hostname, err = l.str(f.value)
+		case "domainname":
+			c.Domainname, err = l.str(f.value)
+		case "user":
+			c.User, err = l.str(f.value)
+		case "working_dir":
+			c.WorkingDir, err = l.str(f.value)
+		case "stop_signal":
+			c.StopSignal, err = l.str(f.value)
+		case "mac_address":
+			c.MacAddress, err = l.str(f.value)
+		case "tty":
+			c.Tty, err = l.boolean(f.value)
+		case "stdin_open":
+			c.OpenStdin, err = l.boolean(f.value)
+		case "ports":
+			err = l.loadPorts(c, f.value)
+		case "expose":
+			err = l.loadExpose(c, f.value)
+		case "volumes":
+			err = l.loadServiceVolumes(c, f.value)
+		case "tmpfs":
+			var mounts []string
+			if mounts, err = l.strs(f.value); err != nil {
+				return err
+			}
+			if h.Tmpfs == nil {
+				h.Tmpfs = make(map[string]string)
+			}
+			for _, m := range mounts {
+				parts := strings.SplitN(m, ":", 2)
+				h.Tmpfs[parts[0]] = ""
+				if len(parts) == 2 {
+					h.Tmpfs[parts[0]] = parts[1]
+				}
+			}
+		case "networks":
+			networks = f.value
+		case "network_mode":
+			var mode string
+			if mode, err = l.str(f.value); err != nil {
+				return err
+			}
+			if strings.HasPrefix(mode, "service:") {
+				other, err := l.service(f.value, strings.TrimPrefix(mode, "service:"))
+				if err != nil {
+					return err
+				}
+				mode = "container:" + other.ContainerName
+				s.DependsOn = append(s.DependsOn, other.Name)
+			}
+			h.NetworkMode = mode
+		case "depends_on":
+			err = l.loadDependsOn(s, f.value)
+		case "links":
+			var links []string
+			if links, err = l.strs(f.value); err != nil {
+				return err
+			}
+			for _, link := range links {
+				parts := strings.SplitN(link, ":", 2)
+				other, err := l.service(f.value, parts[0])
+				if err != nil {
+					return err
+				}
+				alias := parts[0]
+				if len(parts) == 2 {
+					alias = parts[1]
+				}
+				h.Links = append(h.Links, other.ContainerName+":"+alias)
+				s.DependsOn = append(s.DependsOn, other.Name)
+			}
+		case "volumes_from":
+			var sources []string
+			if sources, err = l.strs(f.value); err != nil {
+				return err
+			}
+			for _, source := range sources {
+				if strings.HasPrefix(source, "container:") {
+					h.VolumesFrom = append(h.VolumesFrom, strings.TrimPrefix(source, "container:"))
+					continue
+				}
+				parts := strings.SplitN(source, ":", 2)
+				other, err := l.service(f.value, parts[0])
+				if err != nil {
+					return err
+				}
+				parts[0] = other.ContainerName
+				h.VolumesFrom = append(h.VolumesFrom, strings.Join(parts, ":"))
+				s.DependsOn = append(s.DependsOn, other.Name)
+			}
+		case "restart":
+			err = l.loadRestart(h, f.value)
+		case "healthcheck":
+			c.Healthcheck, err = l.loadHealthcheck(f.value)
+		case "ulimits":
+			h.Ulimits, err = l.loadUlimits(f.value)
+		case "logging":
+			err = l.loadLogging(h, f.value)
+		case "privileged":
+			h.Privileged, err = l.boolean(f.value)
+		case "read_only":
+			h.ReadonlyRootfs, err = l.boolean(f.value)
+		case "cap_add":
+			h.CapAdd, err = l.strs(f.value)
+		case "cap_drop":
+			h.CapDrop, err = l.strs(f.value)
+		case "dns":
+			h.Dns, err = l.strs(f.value)
+		case "dns_search":
+			h.DnsSearch, err = l.strs(f.value)
+		case "dns_opt":
+			h.DNSOptions, err = l.strs(f.value)
+		case "security_opt":
+			h.SecurityOpt, err = l.strs(f.value)
+		case "group_add":
+			h.GroupAdd, err = l.strs(f.value)
+		case "extra_hosts":
+			if resolve(f.value).Kind == yaml.MappingNode {
+				var hosts map[string]string
+				hosts, err = l.strMap(f.value, emptyValue)
+				for host, ip := range hosts {
+					h.ExtraHosts = append(h.ExtraHosts, host+":"+ip)
+				}
+			} else {
+				h.ExtraHosts, err = l.strs(f.value)
+			}
+		case "devices":
+			var devices []string
+			if devices, err = l.strs(f.value); err != nil {
+				return err
+			}
+			for _, device := range devices {
+				parts := strings.Split(device, ":")
+				mapping := dockerclient.DeviceMapping{PathOnHost: parts[0], PathInContainer: parts[0], CgroupPermissions: "rwm"}
+				if len(parts) > 1 {
+					mapping.PathInContainer = parts[1]
+				}
+				if len(parts) > 2 {
+					mapping.CgroupPermissions = parts[2]
+				}
+				h.Devices = append(h.Devices, mapping)
+			}
+		case "mem_limit":
+			h.Memory, err = l.size(f.value)
+		case "memswap_limit":
+			h.MemorySwap, err = l.size(f.value)
+		case "mem_reservation":
+			h.MemoryReservation, err = l.size(f.value)
+		case "shm_size":
+			h.ShmSize, err = l.size(f.value)
+		case "cpu_shares":
+			h.CpuShares, err = l.integer(f.value)
+		case "cpu_quota":
+			h.CpuQuota, err = l.integer(f.value)
+		case "cpu_period":
+			h.CpuPeriod, err = l.integer(f.value)
+		case "cpuset":
+			h.CpusetCpus, err = l.str(f.value)
+		case "oom_score_adj":
+			var score int64
+			score, err = l.integer(f.value)
+			h.OomScoreAdj = int(score)
+		case "pid":
+			h.PidMode, err = l.str(f.value)
+		case "ipc":
+			h.IpcMode, err = l.str(f.value)
+		case "build":
+			err = l.errorf(f.node, "build isn't supported, build the image first")
+		case "deploy", "stop_grace_period":
+			// swarm settings, and settings the container config has no
+			// room for
+		default:
+			if !strings.HasPrefix(f.key, "x-") {
+				err = l.errorf(f.node, "unknown key %q in service %s", f.key, s.Name)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if c.Image == "" {
+		return l.errorf(n, "service %s has no image", s.Name)
+	}
+
+	// env_file comes first, environment overrides it
+	merged := make(map[string]string)
+	for _, vars := range envFiles {
+		for k, v := range vars {
+			merged[k] = v
+		}
+	}
+	for k, v := range env {
+		merged[k] = v
+	}
+	for k, v := range merged {
+		c.Env = append(c.Env, k+"="+v)
+	}
+	sort.Strings(c.Env)
+
+	return l.loadServiceNetworks(s, networks)
+}
+
+func (l *loader) loadDependsOn(s *Service, n *yaml.Node) error {
+	var names []string
+	var nodes []*yaml.Node
+	if resolve(n).Kind == yaml.MappingNode {
+		// the long syntax sets conditions, the order is what matters here
+		entries, err := l.pairs(n)
+		if err != nil {
+			return err
+		}
+		for _, p := range entries {
+			names, nodes = append(names, p.key), append(nodes, p.node)
+		}
+	} else {
+		items := resolve(n)
+		if items.Kind != yaml.SequenceNode {
+			return l.errorf(items, "expected a list of services")
+		}
+		for _, item := range items.Content {
+			name, err := l.str(item)
+			if err != nil {
+				return err
+			}
+			names, nodes = append(names, name), append(nodes, item)
+		}
+	}
+	for i, name := range names {
+		other, err := l.service(nodes[i], name)
+		if err != nil {
+			return err
+		}
+		s.DependsOn = append(s.DependsOn, other.Name)
+	}
+	return nil
+}
+
+// path resolves a path of the compose file
+func (l *loader) path(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		p = filepath.Join(os.Getenv("HOME"), p[2:])
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(l.workingDir, p)
+	}
+	return p
+}
+
+func (l *loader) loadRestart(h *dockerclient.HostConfig, n *yaml.Node) error {
+	s, err := l.str(n)
+	if err != nil {
+		return err
+	}
+	parts := strings.SplitN(s, ":", 2)
+	switch parts[0] {
+	case "no", "always", "unless-stopped":
+		if len(parts) == 2 {
+			return l.errorf(n, "restart policy %s takes no maximum retry count", parts[0])
+		}
+	case "on-failure":
+		if len(parts) == 2 {
+			count, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return l.errorf(n, "invalid maximum retry count %q", parts[1])
+			}
+			h.RestartPolicy.MaximumRetryCount = count
+		}
+	default:
+		return l.errorf(n, "invalid restart policy %q", s)
+	}
+	h.RestartPolicy.Name = parts[0]
+	return nil
+}
+
+func (l *loader) loadHealthcheck(n *yaml.Node) (*dockerclient.HealthConfig, error) {
+	entries, err := l.pairs(n)
+	if err != nil {
+		return nil, err
+	}
+	health := &dockerclient.HealthConfig{}
+	disable := false
+	for _, p := range entries {
+		switch p.key {
+		case "test":
+			if resolve(p.value).Kind == yaml.SequenceNode {
+				if health.Test, err = l.strs(p.value); err != nil {
+					return nil, err
+				}
+				if len(health.Test) > 0 {
+					switch health.Test[0] {
+					case "NONE", "CMD", "CMD-SHELL":
+					default:
+						return nil, l.errorf(p.value, "a test list must start with NONE, CMD or CMD-SHELL")
+					}
+				}
+			} else {
+				var cmd string
+				if cmd, err = l.str(p.value); err != nil {
+					return nil, err
+				}
+				health.Test = []string{"CMD-SHELL", cmd}
+			}
+		case "interval":
+			health.Interval, err = l.duration(p.value)
+		case "timeout":
+			health.Timeout, err = l.duration(p.value)
+		case "start_period":
+			health.StartPeriod, err = l.duration(p.value)
+		case "retries":
+			var retries int64
+			retries, err = l.integer(p.value)
+			health.Retries = int(retries)
+		case "disable":
+			disable, err = l.boolean(p.value)
+		default:
+			err = l.errorf(p.node, "unknown key %q in healthcheck", p.key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if disable {
+		health.Test = []string{"NONE"}
+	}
+	return health, nil
+}
+
+func (l *loader) loadUlimits(n *yaml.Node) ([]dockerclient.Ulimit, error) {
+	entries, err := l.pairs(n)
+	if err != nil {
+		return nil, err
+	}
+	var ulimits []dockerclient.Ulimit
+	for _, p := range entries {
+		ulimit := dockerclient.Ulimit{Name: p.key}
+		if resolve(p.value).Kind == yaml.MappingNode {
+			fields, err := l.pairs(p.value)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range fields {
+				value, err := l.integer(f.value)
+				if err != nil {
+					return nil, err
+				}
+				switch f.key {
+				case "soft":
+					ulimit.Soft = uint64(value)
+				case "hard":
+					ulimit.Hard = uint64(value)
+				default:
+					return nil, l.errorf(f.node, "unknown key %q in ulimit %s", f.key, p.key)
+				}
+			}
+			if ulimit.Soft > ulimit.Hard {
+				return nil, l.errorf(p.value, "soft limit of %s is greater than its hard limit", p.key)
+			}
+		} else {
+			value, err := l.integer(p.value)
+			if err != nil {
+				return nil, err
+			}
+			ulimit.Soft, ulimit.Hard = uint64(value), uint64(value)
+		}
+		ulimits = append(ulimits, ulimit)
+	}
+	return ulimits, nil
+}
+
+func (l *loader) loadLogging(h *dockerclient.HostConfig, n *yaml.Node) error {
+	entries, err := l.pairs(n)
+	if err != nil {
+		return err
+	}
+	for _, p := range entries {
+		switch p.key {
+		case "driver":
+			h.LogConfig.Type, err = l.str(p.value)
+		case "options":
+			h.LogConfig.Config, err = l.strMap(p.value, emptyValue)
+		default:
+			err = l.errorf(p.node, "unknown key %q in logging", p.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *loader) loadServiceNetworks(s *Service, n *yaml.Node) error {
+	h := &s.Config.HostConfig
+	if n == nil {
+		if h.NetworkMode != "" {
+			return nil
+		}
+		return l.connect(s, l.defaultNetwork(), nil, dockerclient.EndpointSettings{})
+	}
+	if h.NetworkMode != "" {
+		return l.errorf(n, "network_mode and networks can't be used together")
+	}
+	if resolve(n).Kind == yaml.SequenceNode {
+		for _, item := range resolve(n).Content {
+			name, err := l.str(item)
+			if err != nil {
+				return err
+			}
+			if err := l.connect(s, l.serviceNetwork(name), item, dockerclient.EndpointSettings{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	entries, err := l.pairs(n)
+	if err != nil {
+		return err
+	}
+	for _, p := range entries {
+		var settings dockerclient.EndpointSettings
+		if !isNull(p.value) {
+			fields, err := l.pairs(p.value)
+			if err != nil {
+				return err
+			}
+			for _, f := range fields {
+				switch f.key {
+				case "aliases":
+					settings.Aliases, err = l.strs(f.value)
+				case "ipv4_address", "ipv6_address":
+					var address string
+					if address, err = l.str(f.value); err != nil {
+						return err
+					}
+					if settings.IPAMConfig == nil {
+						settings.IPAMConfig = &dockerclient.EndpointIPAMConfig{}
+					}
+					if f.key == "ipv4_address" {
+						settings.IPAMConfig.IPv4Address = address
+					} else {
+						settings.IPAMConfig.IPv6Address = address
+					}
+				default:
+					err = l.errorf(f.node, "unknown key %q in network %s of service %s", f.key, p.key, s.Name)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if err := l.connect(s, l.serviceNetwork(p.key), p.node, settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serviceNetwork returns the network called name, nil if it isn't declared
+func (l *loader) serviceNetwork(name string) *Network {
+	if name == "default" {
+		return l.defaultNetwork()
+	}
+	return l.network(name)
+}
+
+// defaultNetwork returns the network of the services that don't list any,
+// declaring it if needed
+func (l *loader) defaultNetwork() *Network {
+	if network := l.network("default"); network != nil {
+		return network
+	}
+	network := &Network{
+		Name: "default",
+		Create: dockerclient.NetworkCreate{
+			Name:           l.project.Name + "_default",
+			CheckDuplicate: true,
+			Labels:         map[string]string{ProjectLabel: l.project.Name},
+		},
+	}
+	l.project.Networks = append(l.project.Networks, network)
+	return network
+}
+
+// connect adds network to the networks of s, the service name being one
+// of its aliases
+func (l *loader) connect(s *Service, network *Network, n *yaml.Node, settings dockerclient.EndpointSettings) error {
+	if network == nil {
+		return l.errorf(n, "service %s uses an undefined network", s.Name)
+	}
+	c := s.Config
+	if c.HostConfig.NetworkMode == "" {
+		c.HostConfig.NetworkMode = network.Create.Name
+	}
+	if c.NetworkingConfig.EndpointsConfig == nil {
+		c.NetworkingConfig.EndpointsConfig = make(map[string]*dockerclient.EndpointSettings)
+	}
+	settings.Aliases = append([]string{s.Name}, settings.Aliases...)
+	c.NetworkingConfig.EndpointsConfig[network.Create.Name] = &settings
+	return nil
+}
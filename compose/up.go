@@ -0,0 +1,61 @@
+package compose
+
+import (
+	"github.com/samalba/dockerclient"
+	"github.com/samalba/dockerclient/reconcile"
+)
+
+// State returns the desired state of project, its containers named after
+// ContainerName. External networks and volumes are left out, they are
+// expected to exist.
+func (p *Project) State() *reconcile.State {
+	state := &reconcile.State{}
+	for _, v := range p.Volumes {
+		if !v.External {
+			state.Volumes = append(state.Volumes, v.Create)
+		}
+	}
+	for _, n := range p.Networks {
+		if !n.External {
+			state.Networks = append(state.Networks, n.Create)
+		}
+	}
+	for _, s := range p.Services {
+		spec := reconcile.ContainerSpec{Name: s.ContainerName, Config: s.Config}
+		for _, name := range s.DependsOn {
+			if other := p.Service(name); other != nil {
+				spec.DependsOn = append(spec.DependsOn, other.ContainerName)
+			}
+		}
+		state.Containers = append(state.Containers, spec)
+	}
+	return state
+}
+
+// Up creates, or recreates when their configuration changed, the
+// resources of project and starts its containers, dependencies first. It
+// returns the applied plan.
+func Up(client dockerclient.Client, project *Project) (*reconcile.Plan, error) {
+	return reconcile.NewReconciler(client, project.Name).Reconcile(project.State(), false)
+}
+
+// Down stops and removes the containers and networks of the project
+// called name, and its volumes if removeVolumes is set. It returns the
+// applied plan.
+func Down(client dockerclient.Client, name string, removeVolumes bool) (*reconcile.Plan, error) {
+	r := reconcile.NewReconciler(client, name)
+	plan, err := r.Plan(&reconcile.State{})
+	if err != nil {
+		return nil, err
+	}
+	if !removeVolumes {
+		actions := plan.Actions[:0]
+		for _, a := range plan.Actions {
+			if a.Kind != reconcile.KindVolume {
+				actions = append(actions, a)
+			}
+		}
+		plan.Actions = actions
+	}
+	return plan, r.Apply(plan)
+}
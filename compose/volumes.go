@@ -0,0 +1,197 @@
+package compose
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+	"gopkg.in/yaml.v3"
+)
+
+func (l *loader) loadServiceVolumes(c *dockerclient.ContainerConfig, n *yaml.Node) error {
+	items := resolve(n)
+	if items.Kind != yaml.SequenceNode {
+		return l.errorf(items, "expected a list of volumes")
+	}
+	for _, item := range items.Content {
+		var err error
+		if resolve(item).Kind == yaml.MappingNode {
+			err = l.loadLongVolume(c, item)
+		} else {
+			err = l.loadShortVolume(c, item)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadShortVolume loads [source:]target[:mode], source being a path or a
+// named volume
+func (l *loader) loadShortVolume(c *dockerclient.ContainerConfig, n *yaml.Node) error {
+	s, err := l.str(n)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(s, ":")
+	switch len(parts) {
+	case 1:
+		anonymous(c, parts[0])
+		return nil
+	case 2, 3:
+	default:
+		return l.errorf(n, "invalid volume %q", s)
+	}
+	if parts[0] == "" || parts[1] == "" {
+		return l.errorf(n, "invalid volume %q", s)
+	}
+	if parts[0], err = l.volumeSource(n, parts[0]); err != nil {
+		return err
+	}
+	c.HostConfig.Binds = append(c.HostConfig.Binds, strings.Join(parts, ":"))
+	return nil
+}
+
+// volumeSource returns the path a bind mounts, or the engine name of a
+// named volume
+func (l *loader) volumeSource(n *yaml.Node, source string) (string, error) {
+	if isPath(source) {
+		return l.path(source), nil
+	}
+	volume := l.volume(source)
+	if volume == nil {
+		return "", l.errorf(n, "undefined volume %q", source)
+	}
+	return volume.Create.Name, nil
+}
+
+func isPath(s string) bool {
+	return strings.HasPrefix(s, "/") || strings.HasPrefix(s, ".") || strings.HasPrefix(s, "~")
+}
+
+func anonymous(c *dockerclient.ContainerConfig, target string) {
+	if c.Volumes == nil {
+		c.Volumes = make(map[string]struct{})
+	}
+	c.Volumes[target] = struct{}{}
+}
+
+// loadLongVolume loads a mapping of type, source, target, read_only and
+// the options of the type
+func (l *loader) loadLongVolume(c *dockerclient.ContainerConfig, n *yaml.Node) error {
+	entries, err := l.pairs(n)
+	if err != nil {
+		return err
+	}
+	var kind, source, target string
+	var readOnly, nocopy bool
+	var options []string
+	var tmpfsSize int64
+	for _, p := range entries {
+		switch p.key {
+		case "type":
+			kind, err = l.str(p.value)
+		case "source":
+			source, err = l.str(p.value)
+		case "target":
+			target, err = l.str(p.value)
+		case "read_only":
+			readOnly, err = l.boolean(p.value)
+		case "consistency":
+			// only meaningful on Docker for Mac
+		case "bind":
+			var fields []pair
+			if fields, err = l.pairs(p.value); err != nil {
+				return err
+			}
+			for _, f := range fields {
+				if f.key != "propagation" {
+					return l.errorf(f.node, "unknown key %q in bind", f.key)
+				}
+				var propagation string
+				if propagation, err = l.str(f.value); err != nil {
+					return err
+				}
+				options = append(options, propagation)
+			}
+		case "volume":
+			var fields []pair
+			if fields, err = l.pairs(p.value); err != nil {
+				return err
+			}
+			for _, f := range fields {
+				if f.key != "nocopy" {
+					return l.errorf(f.node, "unknown key %q in volume", f.key)
+				}
+				if nocopy, err = l.boolean(f.value); err != nil {
+					return err
+				}
+			}
+		case "tmpfs":
+			var fields []pair
+			if fields, err = l.pairs(p.value); err != nil {
+				return err
+			}
+			for _, f := range fields {
+				if f.key != "size" {
+					return l.errorf(f.node, "unknown key %q in tmpfs", f.key)
+				}
+				if tmpfsSize, err = l.size(f.value); err != nil {
+					return err
+				}
+			}
+		default:
+			err = l.errorf(p.node, "unknown key %q in volume", p.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if target == "" {
+		return l.errorf(n, "volume has no target")
+	}
+
+	switch kind {
+	case "tmpfs":
+		h := &c.HostConfig
+		if h.Tmpfs == nil {
+			h.Tmpfs = make(map[string]string)
+		}
+		h.Tmpfs[target] = ""
+		if tmpfsSize > 0 {
+			h.Tmpfs[target] = "size=" + strconv.FormatInt(tmpfsSize, 10)
+		}
+		return nil
+	case "volume":
+		if source == "" {
+			anonymous(c, target)
+			return nil
+		}
+		if nocopy {
+			options = append(options, "nocopy")
+		}
+	case "bind":
+		if source == "" {
+			return l.errorf(n, "bind mount of %s has no source", target)
+		}
+	default:
+		return l.errorf(n, "invalid volume type %q", kind)
+	}
+	if kind == "bind" {
+		source = l.path(source)
+	} else if isPath(source) {
+		return l.errorf(n, "source of a volume can't be a path, use a bind mount")
+	} else if source, err = l.volumeSource(n, source); err != nil {
+		return err
+	}
+	if readOnly {
+		options = append([]string{"ro"}, options...)
+	}
+	bind := source + ":" + target
+	if len(options) > 0 {
+		bind += ":" + strings.Join(options, ",")
+	}
+	c.HostConfig.Binds = append(c.HostConfig.Binds, bind)
+	return nil
+}
@@ -2,6 +2,7 @@ package dockerclient
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -19,13 +20,8 @@ import (
 var _ Client = (*DockerClient)(nil)
 
 const (
-	// APIVersion is currently hardcoded to v1.15
-	// TODO: bump the API version or allow users to choose which API version to
-	// use the client with. The current value does not make sense for many
-	// methods, such as ContainerStats, StartMonitorStats, and StopAllMonitorStats
-	// (v1.17) and
-	// ListVolumes, {Remove,Create}Volume, ListNetworks,
-	// {Inspect,Create,Connect,Disconnect,Remove}Network (v1.21)
+	// APIVersion is the API version of the requests until another one is
+	// set with SetAPIVersion or NegotiateAPIVersion
 	APIVersion = "v1.15"
 )
 
@@ -33,6 +29,7 @@ var (
 	ErrImageNotFound     = errors.New("Image not found")
 	ErrNotFound          = errors.New("Not found")
 	ErrConnectionRefused = errors.New("Cannot connect to the docker engine endpoint")
+	ErrWaitCanceled      = errors.New("Wait canceled")
 
 	defaultTimeout = 30 * time.Second
 )
@@ -43,6 +40,13 @@ type DockerClient struct {
 	TLSConfig     *tls.Config
 	monitorStats  int32
 	eventStopChan chan (struct{})
+	middlewares   []Middleware
+	version       string
+
+	// OnWarning, if set, is called with the warnings of the engine on
+	// creation, and with those about fields left out of requests because
+	// the API version doesn't support them
+	OnWarning func(warning string)
 }
 
 type Error struct {
@@ -55,6 +59,25 @@ func (e Error) Error() string {
 	return fmt.Sprintf("%s: %s", e.Status, e.msg)
 }
 
+// notFoundError is a 404 with the message of the engine, such as "No such
+// container: foo"
+type notFoundError string
+
+func (e notFoundError) Error() string {
+	return string(e)
+}
+
+// IsNotFound reports whether err means that a container, image, network or
+// volume doesn't exist. Depending on the resource and the engine version,
+// that is ErrNotFound, ErrImageNotFound or a 404 carrying the message of
+// the engine.
+func IsNotFound(err error) bool {
+	if _, ok := err.(notFoundError); ok {
+		return true
+	}
+	return err == ErrNotFound || err == ErrImageNotFound
+}
+
 func NewDockerClient(daemonUrl string, tlsConfig *tls.Config) (*DockerClient, error) {
 	return NewDockerClientTimeout(daemonUrl, tlsConfig, time.Duration(defaultTimeout), nil)
 }
@@ -72,13 +95,20 @@ func NewDockerClientTimeout(daemonUrl string, tlsConfig *tls.Config, timeout tim
 		}
 	}
 	httpClient := newHTTPClient(u, tlsConfig, timeout, setUserTimeout)
-	return &DockerClient{u, httpClient, tlsConfig, 0, nil}, nil
+	return &DockerClient{URL: u, HTTPClient: httpClient, TLSConfig: tlsConfig}, nil
 }
 
-func (client *DockerClient) doRequest(method string, path string, body []byte, headers map[string]string) ([]byte, error) {
+// doRequest sends a request for the operation op, the name of the calling
+// method, which middlewares get to see
+func (client *DockerClient) doRequest(op string, method string, path string, body []byte, headers map[string]string) ([]byte, error) {
+	return client.doCancelableRequest(op, method, path, body, headers, nil)
+}
+
+// doCancelableRequest is doRequest, aborted as soon as stopChan is closed
+func (client *DockerClient) doCancelableRequest(op string, method string, path string, body []byte, headers map[string]string, stopChan <-chan struct{}) ([]byte, error) {
 	b := bytes.NewBuffer(body)
 
-	reader, err := client.doStreamRequest(method, path, b, headers)
+	reader, err := client.doCancelableStreamRequest(op, method, path, b, headers, stopChan)
 	if err != nil {
 		return nil, err
 	}
@@ -91,7 +121,13 @@ func (client *DockerClient) doRequest(method string, path string, body []byte, h
 	return data, nil
 }
 
-func (client *DockerClient) doStreamRequest(method string, path string, in io.Reader, headers map[string]string) (io.ReadCloser, error) {
+func (client *DockerClient) doStreamRequest(op string, method string, path string, in io.Reader, headers map[string]string) (io.ReadCloser, error) {
+	return client.doCancelableStreamRequest(op, method, path, in, headers, nil)
+}
+
+// doCancelableStreamRequest is doStreamRequest, aborted as soon as stopChan
+// is closed. Closing the returned stream releases the cancellation watcher.
+func (client *DockerClient) doCancelableStreamRequest(op string, method string, path string, in io.Reader, headers map[string]string, stopChan <-chan struct{}) (io.ReadCloser, error) {
 	if (method == "POST" || method == "PUT") && in == nil {
 		in = bytes.NewReader(nil)
 	}
@@ -99,13 +135,34 @@ func (client *DockerClient) doStreamRequest(method string, path string, in io.Re
 	if err != nil {
 		return nil, err
 	}
+	if stopChan == nil {
+		return client.sendRequest(op, req, headers)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	body, err := client.sendRequest(op, req.WithContext(ctx), headers)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &cancelOnClose{ReadCloser: body, cancel: cancel}, nil
+}
+
+func (client *DockerClient) sendRequest(op string, req *http.Request, headers map[string]string) (io.ReadCloser, error) {
 	req.Header.Add("Content-Type", "application/json")
 	if headers != nil {
 		for header, value := range headers {
 			req.Header.Add(header, value)
 		}
 	}
-	resp, err := client.HTTPClient.Do(req)
+	resp, err := client.do(op, req)
 	if err != nil {
 		if !strings.Contains(err.Error(), "connection refused") && client.TLSConfig == nil {
 			return nil, fmt.Errorf("%v. Are you trying to connect to a TLS-enabled daemon without TLS?", err)
@@ -126,7 +183,7 @@ func (client *DockerClient) doStreamRequest(method string, path string, in io.Re
 			if strings.Index(string(data), "No such image") != -1 {
 				return nil, ErrImageNotFound
 			}
-			return nil, errors.New(string(data))
+			return nil, notFoundError(data)
 		}
 		return nil, ErrNotFound
 	}
@@ -143,8 +200,8 @@ func (client *DockerClient) doStreamRequest(method string, path string, in io.Re
 }
 
 func (client *DockerClient) Info() (*Info, error) {
-	uri := fmt.Sprintf("/%s/info", APIVersion)
-	data, err := client.doRequest("GET", uri, nil, nil)
+	uri := client.apiPath("/info")
+	data, err := client.doRequest("Info", "GET", uri, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -165,13 +222,13 @@ func (client *DockerClient) ListContainers(all bool, size bool, filters string)
 	if size == true {
 		showSize = 1
 	}
-	uri := fmt.Sprintf("/%s/containers/json?all=%d&size=%d", APIVersion, argAll, showSize)
+	uri := client.apiPath("/containers/json?all=%d&size=%d", argAll, showSize)
 
 	if filters != "" {
 		uri += "&filters=" + filters
 	}
 
-	data, err := client.doRequest("GET", uri, nil, nil)
+	data, err := client.doRequest("ListContainers", "GET", uri, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -184,8 +241,8 @@ func (client *DockerClient) ListContainers(all bool, size bool, filters string)
 }
 
 func (client *DockerClient) InspectContainer(id string) (*ContainerInfo, error) {
-	uri := fmt.Sprintf("/%s/containers/%s/json", APIVersion, id)
-	data, err := client.doRequest("GET", uri, nil, nil)
+	uri := client.apiPath("/containers/%s/json", id)
+	data, err := client.doRequest("InspectContainer", "GET", uri, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -198,11 +255,15 @@ func (client *DockerClient) InspectContainer(id string) (*ContainerInfo, error)
 }
 
 func (client *DockerClient) CreateContainer(config *ContainerConfig, name string, auth *AuthConfig) (string, error) {
-	data, err := json.Marshal(config)
+	if err := config.HostConfig.Validate(); err != nil {
+		return "", err
+	}
+	data, warnings, err := MarshalContainerConfig(config, client.version)
 	if err != nil {
 		return "", err
 	}
-	uri := fmt.Sprintf("/%s/containers/create", APIVersion)
+	client.warn(warnings...)
+	uri := client.apiPath("/containers/create")
 	if name != "" {
 		v := url.Values{}
 		v.Set("name", name)
@@ -216,7 +277,7 @@ func (client *DockerClient) CreateContainer(config *ContainerConfig, name string
 		}
 		headers["X-Registry-Auth"] = encoded_auth
 	}
-	data, err = client.doRequest("POST", uri, data, headers)
+	data, err = client.doRequest("CreateContainer", "POST", uri, data, headers)
 	if err != nil {
 		return "", err
 	}
@@ -225,6 +286,7 @@ func (client *DockerClient) CreateContainer(config *ContainerConfig, name string
 	if err != nil {
 		return "", fmt.Errorf(string(data))
 	}
+	client.warn(result.Warnings...)
 	return result.Id, nil
 }
 
@@ -238,13 +300,13 @@ func (client *DockerClient) ContainerLogs(id string, options *LogOptions) (io.Re
 		v.Add("tail", strconv.FormatInt(options.Tail, 10))
 	}
 
-	uri := fmt.Sprintf("/%s/containers/%s/logs?%s", APIVersion, id, v.Encode())
+	uri := client.apiPath("/containers/%s/logs?%s", id, v.Encode())
 	req, err := http.NewRequest("GET", client.URL.String()+uri, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.HTTPClient.Do(req)
+	resp, err := client.do("ContainerLogs", req)
 	if err != nil {
 		return nil, err
 	}
@@ -252,8 +314,8 @@ func (client *DockerClient) ContainerLogs(id string, options *LogOptions) (io.Re
 }
 
 func (client *DockerClient) ContainerChanges(id string) ([]*ContainerChanges, error) {
-	uri := fmt.Sprintf("/%s/containers/%s/changes", APIVersion, id)
-	data, err := client.doRequest("GET", uri, nil, nil)
+	uri := client.apiPath("/containers/%s/changes", id)
+	data, err := client.doRequest("ContainerChanges", "GET", uri, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -266,8 +328,12 @@ func (client *DockerClient) ContainerChanges(id string) ([]*ContainerChanges, er
 }
 
 func (client *DockerClient) ContainerStats(id string, stopChan <-chan struct{}) (<-chan StatsOrError, error) {
-	uri := fmt.Sprintf("/%s/containers/%s/stats", APIVersion, id)
-	resp, err := client.HTTPClient.Get(client.URL.String() + uri)
+	uri := client.apiPath("/containers/%s/stats", id)
+	req, err := http.NewRequest("GET", client.URL.String()+uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.do("ContainerStats", req)
 	if err != nil {
 		return nil, err
 	}
@@ -323,7 +389,23 @@ func (client *DockerClient) readJSONStream(stream io.ReadCloser, decode func(*js
 				}
 				return
 			case decodeResult := <-decodeChan:
-				resultChan <- decodeResult
+				// don't hand out anything else once stopChan is closed,
+				// even if the decoder is already ahead of the consumer;
+				// select alone picks at random when both are ready
+				if isClosed(stopChan) {
+					stream.Close()
+					for range decodeChan {
+					}
+					return
+				}
+				select {
+				case <-stopChan:
+					stream.Close()
+					for range decodeChan {
+					}
+					return
+				case resultChan <- decodeResult:
+				}
 				if decodeResult.err != nil {
 					stream.Close()
 					return
@@ -341,8 +423,8 @@ func (client *DockerClient) ExecCreate(config *ExecConfig) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	uri := fmt.Sprintf("/%s/containers/%s/exec", APIVersion, config.Container)
-	resp, err := client.doRequest("POST", uri, data, nil)
+	uri := client.apiPath("/containers/%s/exec", config.Container)
+	resp, err := client.doRequest("ExecCreate", "POST", uri, data, nil)
 	if err != nil {
 		return "", err
 	}
@@ -361,8 +443,8 @@ func (client *DockerClient) ExecStart(id string, config *ExecConfig) error {
 		return err
 	}
 
-	uri := fmt.Sprintf("/%s/exec/%s/start", APIVersion, id)
-	if _, err := client.doRequest("POST", uri, data, nil); err != nil {
+	uri := client.apiPath("/exec/%s/start", id)
+	if _, err := client.doRequest("ExecStart", "POST", uri, data, nil); err != nil {
 		return err
 	}
 
@@ -378,8 +460,8 @@ func (client *DockerClient) ExecResize(id string, width, height int) error {
 	v.Set("w", w)
 	v.Set("h", h)
 
-	uri := fmt.Sprintf("/%s/exec/%s/resize?%s", APIVersion, id, v.Encode())
-	if _, err := client.doRequest("POST", client.URL.String()+uri, nil, nil); err != nil {
+	uri := client.apiPath("/exec/%s/resize?%s", id, v.Encode())
+	if _, err := client.doRequest("ExecResize", "POST", client.URL.String()+uri, nil, nil); err != nil {
 		return err
 	}
 
@@ -405,17 +487,18 @@ func (client *DockerClient) AttachContainer(id string, options *AttachOptions) (
 			v.Set("stderr", "1")
 		}
 	}
-	uri := fmt.Sprintf("/%s/containers/%s/attach?%s", APIVersion, id, v.Encode())
-	return client.doStreamRequest("POST", uri, nil, nil)
+	uri := client.apiPath("/containers/%s/attach?%s", id, v.Encode())
+	return client.doStreamRequest("AttachContainer", "POST", uri, nil, nil)
 }
 
 func (client *DockerClient) StartContainer(id string, config *HostConfig) error {
-	data, err := json.Marshal(config)
+	data, warnings, err := MarshalHostConfig(config, client.version)
 	if err != nil {
 		return err
 	}
-	uri := fmt.Sprintf("/%s/containers/%s/start", APIVersion, id)
-	_, err = client.doRequest("POST", uri, data, nil)
+	client.warn(warnings...)
+	uri := client.apiPath("/containers/%s/start", id)
+	_, err = client.doRequest("StartContainer", "POST", uri, data, nil)
 	if err != nil {
 		return err
 	}
@@ -423,8 +506,8 @@ func (client *DockerClient) StartContainer(id string, config *HostConfig) error
 }
 
 func (client *DockerClient) StopContainer(id string, timeout int) error {
-	uri := fmt.Sprintf("/%s/containers/%s/stop?t=%d", APIVersion, id, timeout)
-	_, err := client.doRequest("POST", uri, nil, nil)
+	uri := client.apiPath("/containers/%s/stop?t=%d", id, timeout)
+	_, err := client.doRequest("StopContainer", "POST", uri, nil, nil)
 	if err != nil {
 		return err
 	}
@@ -432,8 +515,8 @@ func (client *DockerClient) StopContainer(id string, timeout int) error {
 }
 
 func (client *DockerClient) RestartContainer(id string, timeout int) error {
-	uri := fmt.Sprintf("/%s/containers/%s/restart?t=%d", APIVersion, id, timeout)
-	_, err := client.doRequest("POST", uri, nil, nil)
+	uri := client.apiPath("/containers/%s/restart?t=%d", id, timeout)
+	_, err := client.doRequest("RestartContainer", "POST", uri, nil, nil)
 	if err != nil {
 		return err
 	}
@@ -441,8 +524,8 @@ func (client *DockerClient) RestartContainer(id string, timeout int) error {
 }
 
 func (client *DockerClient) KillContainer(id, signal string) error {
-	uri := fmt.Sprintf("/%s/containers/%s/kill?signal=%s", APIVersion, id, signal)
-	_, err := client.doRequest("POST", uri, nil, nil)
+	uri := client.apiPath("/containers/%s/kill?signal=%s", id, signal)
+	_, err := client.doRequest("KillContainer", "POST", uri, nil, nil)
 	if err != nil {
 		return err
 	}
@@ -450,21 +533,46 @@ func (client *DockerClient) KillContainer(id, signal string) error {
 }
 
 func (client *DockerClient) Wait(id string) <-chan WaitResult {
-	ch := make(chan WaitResult)
-	uri := fmt.Sprintf("/%s/containers/%s/wait", APIVersion, id)
+	return client.wait("Wait", id, nil, nil)
+}
+
+func (client *DockerClient) WaitWithOptions(id string, options *WaitOptions, stopChan <-chan struct{}) <-chan WaitResult {
+	return client.wait("WaitWithOptions", id, options, stopChan)
+}
+
+// wait implements Wait and WaitWithOptions, op being the operation the
+// middlewares and retry policies see
+func (client *DockerClient) wait(op, id string, options *WaitOptions, stopChan <-chan struct{}) <-chan WaitResult {
+	// buffered, so that the goroutine doesn't leak when nobody receives
+	ch := make(chan WaitResult, 1)
+	uri := client.apiPath("/containers/%s/wait", id)
+	if options != nil && options.Condition != "" {
+		v := url.Values{}
+		v.Set("condition", string(options.Condition))
+		uri = fmt.Sprintf("%s?%s", uri, v.Encode())
+	}
 
 	go func() {
-		data, err := client.doRequest("POST", uri, nil, nil)
+		data, err := client.doCancelableRequest(op, "POST", uri, nil, nil, stopChan)
 		if err != nil {
-			ch <- WaitResult{ExitCode: -1, Error: err}
+			if isClosed(stopChan) {
+				err = ErrWaitCanceled
+			}
+			ch <- WaitResult{ID: id, ExitCode: -1, Error: err}
 			return
 		}
 
 		var result struct {
 			StatusCode int `json:"StatusCode"`
+			Error      *struct {
+				Message string
+			} `json:"Error"`
 		}
 		err = json.Unmarshal(data, &result)
-		ch <- WaitResult{ExitCode: result.StatusCode, Error: err}
+		if err == nil && result.Error != nil && result.Error.Message != "" {
+			err = errors.New(result.Error.Message)
+		}
+		ch <- WaitResult{ID: id, ExitCode: result.StatusCode, Error: err}
 	}()
 	return ch
 }
@@ -479,40 +587,7 @@ func (client *DockerClient) MonitorEvents(options *MonitorEventsOptions, stopCha
 			v.Add("until", strconv.Itoa(options.Until))
 		}
 		if options.Filters != nil {
-			filterMap := make(map[string][]string)
-			events := []string{}
-			if options.Filters.Event != "" {
-				events = append(events, options.Filters.Event)
-			}
-			if len(options.Filters.Events) > 0 {
-				events = append(events, options.Filters.Events...)
-			}
-			if len(events) > 0 {
-				filterMap["event"] = events
-			}
-
-			images := []string{}
-			if options.Filters.Image != "" {
-				images = append(images, options.Filters.Image)
-			}
-			if len(options.Filters.Images) > 0 {
-				images = append(images, options.Filters.Images...)
-			}
-			if len(images) > 0 {
-				filterMap["image"] = images
-			}
-
-			containers := []string{}
-			if options.Filters.Container != "" {
-				containers = append(containers, options.Filters.Container)
-			}
-			if len(options.Filters.Containers) > 0 {
-				containers = append(containers, options.Filters.Containers...)
-			}
-			if len(containers) > 0 {
-				filterMap["container"] = containers
-			}
-
+			filterMap := options.Filters.filterMap()
 			if len(filterMap) > 0 {
 				filterJSONBytes, err := json.Marshal(filterMap)
 				if err != nil {
@@ -522,8 +597,12 @@ func (client *DockerClient) MonitorEvents(options *MonitorEventsOptions, stopCha
 			}
 		}
 	}
-	uri := fmt.Sprintf("%s/%s/events?%s", client.URL.String(), APIVersion, v.Encode())
-	resp, err := client.HTTPClient.Get(uri)
+	uri := client.URL.String() + client.apiPath("/events?%s", v.Encode())
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.do("MonitorEvents", req)
 	if err != nil {
 		return nil, err
 	}
@@ -533,6 +612,7 @@ func (client *DockerClient) MonitorEvents(options *MonitorEventsOptions, stopCha
 		if err := decoder.Decode(&event); err != nil {
 			return decodingResult{err: err}
 		} else {
+			event.Normalize()
 			return decodingResult{result: event}
 		}
 	}
@@ -588,8 +668,13 @@ func (client *DockerClient) StartMonitorStats(id string, cb StatCallback, ec cha
 }
 
 func (client *DockerClient) getStats(id string, cb StatCallback, ec chan error, args ...interface{}) {
-	uri := fmt.Sprintf("%s/%s/containers/%s/stats", client.URL.String(), APIVersion, id)
-	resp, err := client.HTTPClient.Get(uri)
+	uri := client.URL.String() + client.apiPath("/containers/%s/stats", id)
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		ec <- err
+		return
+	}
+	resp, err := client.do("StartMonitorStats", req)
 	if err != nil {
 		ec <- err
 		return
@@ -618,16 +703,16 @@ func (client *DockerClient) TagImage(nameOrID string, repo string, tag string, f
 	if force {
 		v.Set("force", "1")
 	}
-	uri := fmt.Sprintf("/%s/images/%s/tag?%s", APIVersion, nameOrID, v.Encode())
-	if _, err := client.doRequest("POST", uri, nil, nil); err != nil {
+	uri := client.apiPath("/images/%s/tag?%s", nameOrID, v.Encode())
+	if _, err := client.doRequest("TagImage", "POST", uri, nil, nil); err != nil {
 		return err
 	}
 	return nil
 }
 
 func (client *DockerClient) Version() (*Version, error) {
-	uri := fmt.Sprintf("/%s/version", APIVersion)
-	data, err := client.doRequest("GET", uri, nil, nil)
+	uri := client.apiPath("/version")
+	data, err := client.doRequest("Version", "GET", uri, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -644,7 +729,7 @@ func (client *DockerClient) PushImage(name string, tag string, auth *AuthConfig)
 	if tag != "" {
 		v.Set("tag", tag)
 	}
-	uri := fmt.Sprintf("/%s/images/%s/push?%s", APIVersion, url.QueryEscape(name), v.Encode())
+	uri := client.apiPath("/images/%s/push?%s", url.QueryEscape(name), v.Encode())
 	req, err := http.NewRequest("POST", client.URL.String()+uri, nil)
 	if auth != nil {
 		if encodedAuth, err := auth.encode(); err != nil {
@@ -653,7 +738,7 @@ func (client *DockerClient) PushImage(name string, tag string, auth *AuthConfig)
 			req.Header.Add("X-Registry-Auth", encodedAuth)
 		}
 	}
-	resp, err := client.HTTPClient.Do(req)
+	resp, err := client.do("PushImage", req)
 	if err != nil {
 		return err
 	}
@@ -673,7 +758,7 @@ func (client *DockerClient) PushImage(name string, tag string, auth *AuthConfig)
 func (client *DockerClient) PullImage(name string, auth *AuthConfig) error {
 	v := url.Values{}
 	v.Set("fromImage", name)
-	uri := fmt.Sprintf("/%s/images/create?%s", APIVersion, v.Encode())
+	uri := client.apiPath("/images/create?%s", v.Encode())
 	req, err := http.NewRequest("POST", client.URL.String()+uri, nil)
 	if auth != nil {
 		encoded_auth, err := auth.encode()
@@ -682,7 +767,7 @@ func (client *DockerClient) PullImage(name string, auth *AuthConfig) error {
 		}
 		req.Header.Add("X-Registry-Auth", encoded_auth)
 	}
-	resp, err := client.HTTPClient.Do(req)
+	resp, err := client.do("PullImage", req)
 	if err != nil {
 		return err
 	}
@@ -712,8 +797,8 @@ func (client *DockerClient) PullImage(name string, auth *AuthConfig) error {
 }
 
 func (client *DockerClient) InspectImage(id string) (*ImageInfo, error) {
-	uri := fmt.Sprintf("/%s/images/%s/json", APIVersion, id)
-	data, err := client.doRequest("GET", uri, nil, nil)
+	uri := client.apiPath("/images/%s/json", id)
+	data, err := client.doRequest("InspectImage", "GET", uri, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -726,8 +811,8 @@ func (client *DockerClient) InspectImage(id string) (*ImageInfo, error) {
 }
 
 func (client *DockerClient) LoadImage(reader io.Reader) error {
-	uri := fmt.Sprintf("/%s/images/load", APIVersion)
-	_, err := client.doStreamRequest("POST", uri, reader, nil)
+	uri := client.apiPath("/images/load")
+	_, err := client.doStreamRequest("LoadImage", "POST", uri, reader, nil)
 	return err
 }
 
@@ -741,8 +826,8 @@ func (client *DockerClient) RemoveContainer(id string, force, volumes bool) erro
 		argVolumes = 1
 	}
 	args := fmt.Sprintf("force=%d&v=%d", argForce, argVolumes)
-	uri := fmt.Sprintf("/%s/containers/%s?%s", APIVersion, id, args)
-	_, err := client.doRequest("DELETE", uri, nil, nil)
+	uri := client.apiPath("/containers/%s?%s", id, args)
+	_, err := client.doRequest("RemoveContainer", "DELETE", uri, nil, nil)
 	return err
 }
 
@@ -751,8 +836,8 @@ func (client *DockerClient) ListImages(all bool) ([]*Image, error) {
 	if all {
 		argAll = 1
 	}
-	uri := fmt.Sprintf("/%s/images/json?all=%d", APIVersion, argAll)
-	data, err := client.doRequest("GET", uri, nil, nil)
+	uri := client.apiPath("/images/json?all=%d", argAll)
+	data, err := client.doRequest("ListImages", "GET", uri, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -770,8 +855,8 @@ func (client *DockerClient) RemoveImage(name string, force bool) ([]*ImageDelete
 	}
 
 	args := fmt.Sprintf("force=%d", argForce)
-	uri := fmt.Sprintf("/%s/images/%s?%s", APIVersion, name, args)
-	data, err := client.doRequest("DELETE", uri, nil, nil)
+	uri := client.apiPath("/images/%s?%s", name, args)
+	data, err := client.doRequest("RemoveImage", "DELETE", uri, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -787,7 +872,7 @@ func (client *DockerClient) SearchImages(query, registry string, auth *AuthConfi
 	if registry != "" {
 		term = registry + "/" + term
 	}
-	uri := fmt.Sprintf("/%s/images/search?term=%s", APIVersion, term)
+	uri := client.apiPath("/images/search?term=%s", term)
 	headers := map[string]string{}
 	if auth != nil {
 		if encodedAuth, err := auth.encode(); err != nil {
@@ -796,7 +881,7 @@ func (client *DockerClient) SearchImages(query, registry string, auth *AuthConfi
 			headers["X-Registry-Auth"] = encodedAuth
 		}
 	}
-	data, err := client.doRequest("GET", uri, nil, headers)
+	data, err := client.doRequest("SearchImages", "GET", uri, nil, headers)
 	if err != nil {
 		return nil, err
 	}
@@ -807,17 +892,43 @@ func (client *DockerClient) SearchImages(query, registry string, auth *AuthConfi
 	return imageSearches, nil
 }
 
+// Login checks the credentials of auth against the registry at
+// serverAddress, the Docker Hub if empty, through the engine. The identity
+// token of the response, if any, can be used instead of the password: see
+// LoginAndSave to keep it.
+func (client *DockerClient) Login(auth *AuthConfig, serverAddress string) (*AuthResponse, error) {
+	if auth == nil {
+		auth = &AuthConfig{}
+	}
+	body := *auth
+	body.ServerAddress = serverAddress
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	uri := client.apiPath("/auth")
+	data, err = client.doRequest("Login", "POST", uri, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	ret := &AuthResponse{}
+	if err := json.Unmarshal(data, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
 func (client *DockerClient) PauseContainer(id string) error {
-	uri := fmt.Sprintf("/%s/containers/%s/pause", APIVersion, id)
-	_, err := client.doRequest("POST", uri, nil, nil)
+	uri := client.apiPath("/containers/%s/pause", id)
+	_, err := client.doRequest("PauseContainer", "POST", uri, nil, nil)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 func (client *DockerClient) UnpauseContainer(id string) error {
-	uri := fmt.Sprintf("/%s/containers/%s/unpause", APIVersion, id)
-	_, err := client.doRequest("POST", uri, nil, nil)
+	uri := client.apiPath("/containers/%s/unpause", id)
+	_, err := client.doRequest("UnpauseContainer", "POST", uri, nil, nil)
 	if err != nil {
 		return err
 	}
@@ -825,8 +936,8 @@ func (client *DockerClient) UnpauseContainer(id string) error {
 }
 
 func (client *DockerClient) RenameContainer(oldName string, newName string) error {
-	uri := fmt.Sprintf("/containers/%s/rename?name=%s", oldName, newName)
-	_, err := client.doRequest("POST", uri, nil, nil)
+	uri := client.apiPath("/containers/%s/rename?name=%s", oldName, url.QueryEscape(newName))
+	_, err := client.doRequest("RenameContainer", "POST", uri, nil, nil)
 	return err
 }
 
@@ -849,7 +960,7 @@ func (client *DockerClient) ImportImage(source string, repository string, tag st
 	if fromSrc == "-" {
 		in = tar
 	}
-	return client.doStreamRequest("POST", "/images/create?"+v.Encode(), in, nil)
+	return client.doStreamRequest("ImportImage", "POST", "/images/create?"+v.Encode(), in, nil)
 }
 
 func (client *DockerClient) BuildImage(image *BuildImage) (io.ReadCloser, error) {
@@ -910,13 +1021,13 @@ func (client *DockerClient) BuildImage(image *BuildImage) (io.ReadCloser, error)
 		headers["Content-Type"] = "application/tar"
 	}
 
-	uri := fmt.Sprintf("/%s/build?%s", APIVersion, v.Encode())
-	return client.doStreamRequest("POST", uri, image.Context, headers)
+	uri := client.apiPath("/build?%s", v.Encode())
+	return client.doStreamRequest("BuildImage", "POST", uri, image.Context, headers)
 }
 
 func (client *DockerClient) ListVolumes() ([]*Volume, error) {
-	uri := fmt.Sprintf("/%s/volumes", APIVersion)
-	data, err := client.doRequest("GET", uri, nil, nil)
+	uri := client.apiPath("/volumes")
+	data, err := client.doRequest("ListVolumes", "GET", uri, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -928,8 +1039,8 @@ func (client *DockerClient) ListVolumes() ([]*Volume, error) {
 }
 
 func (client *DockerClient) RemoveVolume(name string) error {
-	uri := fmt.Sprintf("/%s/volumes/%s", APIVersion, name)
-	_, err := client.doRequest("DELETE", uri, nil, nil)
+	uri := client.apiPath("/volumes/%s", name)
+	_, err := client.doRequest("RemoveVolume", "DELETE", uri, nil, nil)
 	return err
 }
 
@@ -938,8 +1049,8 @@ func (client *DockerClient) CreateVolume(request *VolumeCreateRequest) (*Volume,
 	if err != nil {
 		return nil, err
 	}
-	uri := fmt.Sprintf("/%s/volumes/create", APIVersion)
-	data, err = client.doRequest("POST", uri, data, nil)
+	uri := client.apiPath("/volumes/create")
+	data, err = client.doRequest("CreateVolume", "POST", uri, data, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -949,13 +1060,13 @@ func (client *DockerClient) CreateVolume(request *VolumeCreateRequest) (*Volume,
 }
 
 func (client *DockerClient) ListNetworks(filters string) ([]*NetworkResource, error) {
-	uri := fmt.Sprintf("/%s/networks", APIVersion)
+	uri := client.apiPath("/networks")
 
 	if filters != "" {
-		uri += "&filters=" + filters
+		uri += "?filters=" + url.QueryEscape(filters)
 	}
 
-	data, err := client.doRequest("GET", uri, nil, nil)
+	data, err := client.doRequest("ListNetworks", "GET", uri, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -968,9 +1079,9 @@ func (client *DockerClient) ListNetworks(filters string) ([]*NetworkResource, er
 }
 
 func (client *DockerClient) InspectNetwork(id string) (*NetworkResource, error) {
-	uri := fmt.Sprintf("/%s/networks/%s", APIVersion, id)
+	uri := client.apiPath("/networks/%s", id)
 
-	data, err := client.doRequest("GET", uri, nil, nil)
+	data, err := client.doRequest("InspectNetwork", "GET", uri, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -988,8 +1099,8 @@ func (client *DockerClient) CreateNetwork(config *NetworkCreate) (*NetworkCreate
 	if err != nil {
 		return nil, err
 	}
-	uri := fmt.Sprintf("/%s/networks/create", APIVersion)
-	data, err = client.doRequest("POST", uri, data, nil)
+	uri := client.apiPath("/networks/create")
+	data, err = client.doRequest("CreateNetwork", "POST", uri, data, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -999,12 +1110,22 @@ func (client *DockerClient) CreateNetwork(config *NetworkCreate) (*NetworkCreate
 }
 
 func (client *DockerClient) ConnectNetwork(id, container string) error {
-	data, err := json.Marshal(NetworkConnect{Container: container})
+	return client.connectNetwork("ConnectNetwork", id, container, nil)
+}
+
+// ConnectNetworkEndpoint connects container to the network id with the
+// aliases, links and IPAM config of settings
+func (client *DockerClient) ConnectNetworkEndpoint(id, container string, settings *EndpointSettings) error {
+	return client.connectNetwork("ConnectNetworkEndpoint", id, container, settings)
+}
+
+func (client *DockerClient) connectNetwork(op, id, container string, settings *EndpointSettings) error {
+	data, err := json.Marshal(NetworkConnect{Container: container, EndpointConfig: settings})
 	if err != nil {
 		return err
 	}
-	uri := fmt.Sprintf("/%s/networks/%s/connect", APIVersion, id)
-	_, err = client.doRequest("POST", uri, data, nil)
+	uri := client.apiPath("/networks/%s/connect", id)
+	_, err = client.doRequest(op, "POST", uri, data, nil)
 	return err
 }
 
@@ -1013,13 +1134,13 @@ func (client *DockerClient) DisconnectNetwork(id, container string, force bool)
 	if err != nil {
 		return err
 	}
-	uri := fmt.Sprintf("/%s/networks/%s/disconnect", APIVersion, id)
-	_, err = client.doRequest("POST", uri, data, nil)
+	uri := client.apiPath("/networks/%s/disconnect", id)
+	_, err = client.doRequest("DisconnectNetwork", "POST", uri, data, nil)
 	return err
 }
 
 func (client *DockerClient) RemoveNetwork(id string) error {
-	uri := fmt.Sprintf("/%s/networks/%s", APIVersion, id)
-	_, err := client.doRequest("DELETE", uri, nil, nil)
+	uri := client.apiPath("/networks/%s", id)
+	_, err := client.doRequest("RemoveNetwork", "DELETE", uri, nil, nil)
 	return err
 }
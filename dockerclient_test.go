@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"strings"
@@ -103,6 +105,54 @@ func TestWait(t *testing.T) {
 	}
 }
 
+func TestWaitWithOptions(t *testing.T) {
+	client := testDockerClient(t)
+
+	wr := <-client.WaitWithOptions("next-exit-id", &WaitOptions{Condition: WaitConditionNextExit}, nil)
+	if wr.Error != nil {
+		t.Fatal(wr.Error)
+	}
+	assertEqual(t, wr.ExitCode, 3, "")
+	assertEqual(t, wr.ID, "next-exit-id", "")
+
+	wr = <-client.WaitWithOptions("remove-failed-id", nil, nil)
+	assertEqual(t, wr.ExitCode, 1, "")
+	if wr.Error == nil || !strings.Contains(wr.Error.Error(), "already in progress") {
+		t.Fatalf("expected the daemon's error message, got %v", wr.Error)
+	}
+
+	stopChan := make(chan struct{})
+	ch := client.WaitWithOptions("running-id", nil, stopChan)
+	close(stopChan)
+	select {
+	case wr := <-ch:
+		assertEqual(t, wr.Error, ErrWaitCanceled, "")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out!")
+	}
+}
+
+func TestWaitMany(t *testing.T) {
+	client := testDockerClient(t)
+	stopChan := make(chan struct{})
+	results := WaitMany(client, []string{"valid-id", "running-id"}, nil, stopChan)
+
+	select {
+	case wr := <-results:
+		assertEqual(t, wr.ID, "valid-id", "")
+		assertEqual(t, wr.ExitCode, 0, "")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out!")
+	}
+	close(stopChan)
+	wr := <-results
+	assertEqual(t, wr.ID, "running-id", "")
+	assertEqual(t, wr.Error, ErrWaitCanceled, "")
+	if _, ok := <-results; ok {
+		t.Fatal("results should be closed once every container is done")
+	}
+}
+
 func TestPullImage(t *testing.T) {
 	client := testDockerClient(t)
 	err := client.PullImage("busybox", nil)
@@ -141,7 +191,9 @@ func TestContainerChanges(t *testing.T) {
 	assertEqual(t, len(changes), 3, "unexpected number of changes")
 	c := changes[0]
 	assertEqual(t, c.Path, "/dev", "unexpected")
-	assertEqual(t, c.Kind, 0, "unexpected")
+	assertEqual(t, c.Kind, ChangeModify, "unexpected")
+	assertEqual(t, changes[1].Kind, ChangeAdd, "unexpected")
+	assertEqual(t, changes[1].Kind.String(), "added", "unexpected")
 }
 
 func TestListContainersWithSize(t *testing.T) {
@@ -170,6 +222,44 @@ func TestListContainersWithFilters(t *testing.T) {
 	assertEqual(t, len(containers), 0, "")
 }
 
+func TestListNetworksWithFilters(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("filters")
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+	client, err := NewDockerClient(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filters := `{"label":["com.example.app=web&db"]}`
+	if _, err := client.ListNetworks(filters); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, query, filters, fmt.Sprintf("expected the filters %s, got %q", filters, query))
+}
+
+func TestRenameContainer(t *testing.T) {
+	var path, name string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		name = r.URL.Query().Get("name")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	client, err := NewDockerClient(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RenameContainer("web", "web&old"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "/v" + client.ClientVersion() + "/containers/web/rename"
+	assertEqual(t, path, expected, fmt.Sprintf("expected the path %s, got %q", expected, path))
+	assertEqual(t, name, "web&old", fmt.Sprintf("expected the name web&old, got %q", name))
+}
+
 func TestContainerLogs(t *testing.T) {
 	client := testDockerClient(t)
 	containerId := "foobar"
@@ -271,6 +361,7 @@ func TestMonitorEvents(t *testing.T) {
 				t.Fatalf("cannot parse expected resp: %s", err.Error())
 			}
 		} else {
+			event.Normalize()
 			expectedEvents = append(expectedEvents, event)
 		}
 	}
@@ -283,7 +374,7 @@ func TestMonitorEvents(t *testing.T) {
 	}
 
 	eventInfo := <-eventInfoChan
-	if eventInfo.Error != nil || eventInfo.Event != expectedEvents[0] {
+	if eventInfo.Error != nil || !reflect.DeepEqual(eventInfo.Event, expectedEvents[0]) {
 		t.Fatalf("got:\n%#v\nexpected:\n%#v", eventInfo, expectedEvents[0])
 	}
 	close(stopChan)
@@ -303,7 +394,7 @@ func TestMonitorEvents(t *testing.T) {
 	for i, expectedEvent := range expectedEvents {
 		t.Logf("on iter %d\n", i)
 		eventInfo := <-eventInfoChan
-		if eventInfo.Error != nil || eventInfo.Event != expectedEvent {
+		if eventInfo.Error != nil || !reflect.DeepEqual(eventInfo.Event, expectedEvent) {
 			t.Fatalf("index %d, got:\n%#v\nexpected:\n%#v", i, eventInfo, expectedEvent)
 		}
 		t.Logf("done with iter %d\n", i)
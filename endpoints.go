@@ -0,0 +1,61 @@
+package dockerclient
+
+import "sort"
+
+// SplitEndpoints splits the endpoints of config between the one it can be
+// created with and those to connect it to afterwards, engines before API
+// 1.44 refusing to create a container with more than one endpoint. It
+// returns a copy of config keeping a single endpoint, if any, and the
+// sorted names of the other networks, whose settings are to be passed to
+// ConnectNetworkEndpoint.
+//
+// The endpoint kept is the one of HostConfig.NetworkMode. Without a network
+// mode, it is the first endpoint in name order, which becomes the network
+// mode so that the container doesn't join the default bridge as well.
+// Other network modes, such as bridge or host, keep none.
+func SplitEndpoints(config *ContainerConfig) (*ContainerConfig, []string) {
+	endpoints := config.NetworkingConfig.EndpointsConfig
+	if len(endpoints) == 0 {
+		return config, nil
+	}
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	create := *config
+	create.NetworkingConfig = NetworkingConfig{}
+	mode := config.HostConfig.NetworkMode
+	if _, ok := endpoints[mode]; !ok && (mode == "" || mode == "default") {
+		mode = names[0]
+		create.HostConfig.NetworkMode = mode
+	}
+	var others []string
+	for _, name := range names {
+		if name == mode {
+			create.NetworkingConfig.EndpointsConfig = map[string]*EndpointSettings{name: endpoints[name]}
+			continue
+		}
+		others = append(others, name)
+	}
+	return &create, others
+}
+
+// CreateConnectedContainer creates a container with the endpoint of its
+// network mode, as split by SplitEndpoints, then connects it to the other
+// networks of config. The ID of the container is returned whenever it was
+// created, for the caller to remove it if connecting failed.
+func CreateConnectedContainer(client Client, config *ContainerConfig, name string, auth *AuthConfig) (string, error) {
+	create, others := SplitEndpoints(config)
+	id, err := client.CreateContainer(create, name, auth)
+	if err != nil {
+		return "", err
+	}
+	for _, network := range others {
+		if err := client.ConnectNetworkEndpoint(network, id, config.NetworkingConfig.EndpointsConfig[network]); err != nil {
+			return id, err
+		}
+	}
+	return id, nil
+}
@@ -0,0 +1,38 @@
+package dockerclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitEndpoints(t *testing.T) {
+	endpoints := map[string]*EndpointSettings{
+		"front": {Aliases: []string{"www"}},
+		"back":  {Aliases: []string{"api"}},
+	}
+	for _, test := range []struct {
+		mode, createMode, created string
+		others                    []string
+	}{
+		{"front", "front", "front", []string{"back"}},
+		// the container doesn't join the default bridge
+		{"", "back", "back", []string{"front"}},
+		{"default", "back", "back", []string{"front"}},
+		{"bridge", "bridge", "", []string{"back", "front"}},
+	} {
+		config := &ContainerConfig{Image: "busybox"}
+		config.HostConfig.NetworkMode = test.mode
+		config.NetworkingConfig.EndpointsConfig = endpoints
+		create, others := SplitEndpoints(config)
+		if create.HostConfig.NetworkMode != test.createMode || !reflect.DeepEqual(others, test.others) {
+			t.Fatalf("%q: unexpected network mode %q and other networks %q", test.mode, create.HostConfig.NetworkMode, others)
+		}
+		created := create.NetworkingConfig.EndpointsConfig
+		if test.created == "" && len(created) != 0 || test.created != "" && (len(created) != 1 || created[test.created] != endpoints[test.created]) {
+			t.Fatalf("%q: unexpected endpoints %v", test.mode, created)
+		}
+		if config.HostConfig.NetworkMode != test.mode || len(config.NetworkingConfig.EndpointsConfig) != 2 {
+			t.Fatalf("%q: expected config to be left as is", test.mode)
+		}
+	}
+}
@@ -25,6 +25,11 @@ func init() {
 	baseURL := "/" + APIVersion
 	r.HandleFunc(baseURL+"/info", handlerGetInfo).Methods("GET")
 	r.HandleFunc(baseURL+"/containers/json", handlerGetContainers).Methods("GET")
+	r.HandleFunc(baseURL+"/containers/{id}/json", handleContainerInspect).Methods("GET")
+	r.HandleFunc(baseURL+"/containers/create", handleContainerCreate).Methods("POST")
+	r.HandleFunc(baseURL+"/containers/{id}/attach", handleContainerAttach).Methods("POST")
+	r.HandleFunc(baseURL+"/containers/{id}/start", handleContainerStart).Methods("POST")
+	r.HandleFunc(baseURL+"/containers/{id}", handleContainerRemove).Methods("DELETE")
 	r.HandleFunc(baseURL+"/containers/{id}/logs", handleContainerLogs).Methods("GET")
 	r.HandleFunc(baseURL+"/containers/{id}/changes", handleContainerChanges).Methods("GET")
 	r.HandleFunc(baseURL+"/containers/{id}/stats", handleContainerStats).Methods("GET")
@@ -49,13 +54,91 @@ func handleContainerKill(w http.ResponseWriter, r *http.Request) {
 
 func handleWait(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	if vars["id"] == "valid-id" {
+	switch vars["id"] {
+	case "valid-id":
 		fmt.Fprintf(w, `{"StatusCode":0}`)
-	} else {
+	case "next-exit-id":
+		if r.URL.Query().Get("condition") != "next-exit" {
+			http.Error(w, "unexpected condition", 400)
+			return
+		}
+		fmt.Fprintf(w, `{"StatusCode":3}`)
+	case "remove-failed-id":
+		fmt.Fprintf(w, `{"StatusCode":1,"Error":{"Message":"removal of container remove-failed-id is already in progress"}}`)
+	case "notpulled-run-id", "busybox-run-id":
+		fmt.Fprintf(w, `{"StatusCode":2}`)
+	case "running-id", "sleep-run-id":
+		// never exits, the client has to cancel the wait
+		<-r.Context().Done()
+	default:
 		http.Error(w, "failed", 500)
 	}
 }
 
+// inspectCalls counts the inspections of each container so that health
+// transitions can be simulated
+var inspectCalls = make(map[string]int)
+
+func handleContainerInspect(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	inspectCalls[id]++
+	var state string
+	switch id {
+	case "healthy-id":
+		state = `{"Running":true,"Health":{"Status":"healthy"}}`
+	case "starting-id":
+		state = `{"Running":true,"Health":{"Status":"starting"}}`
+		if inspectCalls[id] > 2 {
+			state = `{"Running":true,"Health":{"Status":"healthy","FailingStreak":0,"Log":[{"ExitCode":0,"Output":"ok"}]}}`
+		}
+	case "nohealth-id":
+		state = `{"Running":true}`
+	case "exited-id":
+		state = `{"Running":false,"ExitCode":1,"Health":{"Status":"unhealthy","FailingStreak":3}}`
+	default:
+		http.Error(w, "No such container: "+id, 404)
+		return
+	}
+	writeHeaders(w, 200, "inspect")
+	fmt.Fprintf(w, `{"Id":%q,"State":%s}`, id, state)
+}
+
+var (
+	// pulledImages records the images pulled through handleImagePull
+	pulledImages = make(map[string]bool)
+	// removedContainers records the containers removed through handleContainerRemove
+	removedContainers = make(map[string]bool)
+)
+
+func handleContainerCreate(w http.ResponseWriter, r *http.Request) {
+	var config ContainerConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if config.Image == "wrongimg" || (config.Image == "notpulled" && !pulledImages[config.Image]) {
+		http.Error(w, "No such image: "+config.Image, 404)
+		return
+	}
+	writeHeaders(w, 201, "create")
+	fmt.Fprintf(w, `{"Id":%q}`, config.Image+"-run-id")
+}
+
+func handleContainerAttach(w http.ResponseWriter, r *http.Request) {
+	outStream := ioutils.NewWriteFlusher(w)
+	fmt.Fprintln(stdcopy.NewStdWriter(outStream, stdcopy.Stdout), "hello")
+	fmt.Fprintln(stdcopy.NewStdWriter(outStream, stdcopy.Stderr), "oops")
+}
+
+func handleContainerStart(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(204)
+}
+
+func handleContainerRemove(w http.ResponseWriter, r *http.Request) {
+	removedContainers[mux.Vars(r)["id"]] = true
+	w.WriteHeader(204)
+}
+
 func handleImagePull(w http.ResponseWriter, r *http.Request) {
 	imageName := r.URL.Query()["fromImage"][0]
 	responses := []map[string]interface{}{{
@@ -69,6 +152,11 @@ func handleImagePull(w http.ResponseWriter, r *http.Request) {
 	case "haproxy":
 		fmt.Fprintf(w, haproxyPullOutput)
 		return
+	case "notpulled":
+		pulledImages[imageName] = true
+		responses = append(responses, map[string]interface{}{
+			"status": "Status: Downloaded newer image for notpulled",
+		})
 	default:
 		errorMsg := fmt.Sprintf("Error: image %s not found", imageName)
 		responses = append(responses, map[string]interface{}{
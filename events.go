@@ -0,0 +1,204 @@
+package dockerclient
+
+import (
+	"strconv"
+	"strings"
+)
+
+// EventType is the kind of object an event refers to
+type EventType string
+
+const (
+	ContainerEventType EventType = "container"
+	ImageEventType     EventType = "image"
+	VolumeEventType    EventType = "volume"
+	NetworkEventType   EventType = "network"
+	DaemonEventType    EventType = "daemon"
+	PluginEventType    EventType = "plugin"
+)
+
+// EventAction is the action reported by an event. Some actions, such as
+// health_status and exec_start, carry a ": <detail>" suffix; use
+// Event.BaseAction to compare them against the constants below.
+type EventAction string
+
+const (
+	// Container actions
+	ActionAttach       EventAction = "attach"
+	ActionCommit       EventAction = "commit"
+	ActionCopy         EventAction = "copy"
+	ActionCreate       EventAction = "create"
+	ActionDestroy      EventAction = "destroy"
+	ActionDetach       EventAction = "detach"
+	ActionDie          EventAction = "die"
+	ActionExecCreate   EventAction = "exec_create"
+	ActionExecDetach   EventAction = "exec_detach"
+	ActionExecDie      EventAction = "exec_die"
+	ActionExecStart    EventAction = "exec_start"
+	ActionExport       EventAction = "export"
+	ActionHealthStatus EventAction = "health_status"
+	ActionKill         EventAction = "kill"
+	ActionOOM          EventAction = "oom"
+	ActionPause        EventAction = "pause"
+	ActionRename       EventAction = "rename"
+	ActionResize       EventAction = "resize"
+	ActionRestart      EventAction = "restart"
+	ActionStart        EventAction = "start"
+	ActionStop         EventAction = "stop"
+	ActionTop          EventAction = "top"
+	ActionUnpause      EventAction = "unpause"
+	ActionUpdate       EventAction = "update"
+
+	// Image actions
+	ActionDelete EventAction = "delete"
+	ActionImport EventAction = "import"
+	ActionLoad   EventAction = "load"
+	ActionPull   EventAction = "pull"
+	ActionPush   EventAction = "push"
+	ActionSave   EventAction = "save"
+	ActionTag    EventAction = "tag"
+	ActionUntag  EventAction = "untag"
+
+	// Volume and network actions
+	ActionMount      EventAction = "mount"
+	ActionUnmount    EventAction = "unmount"
+	ActionConnect    EventAction = "connect"
+	ActionDisconnect EventAction = "disconnect"
+	ActionRemove     EventAction = "remove"
+
+	// Daemon actions
+	ActionReload EventAction = "reload"
+)
+
+// legacyImageActions are the statuses that pre-1.22 daemons reported for
+// images; every other legacy status refers to a container.
+var legacyImageActions = map[EventAction]bool{
+	ActionDelete: true,
+	ActionImport: true,
+	ActionLoad:   true,
+	ActionPull:   true,
+	ActionPush:   true,
+	ActionSave:   true,
+	ActionTag:    true,
+	ActionUntag:  true,
+}
+
+// Normalize fills in Type, Action and Actor for events sent by daemons
+// older than API v1.22, which only set Status, ID and From, and fills in
+// the legacy fields for container and image events sent by newer daemons.
+func (e *Event) Normalize() {
+	if e.Type == "" && e.Status != "" {
+		e.Action = EventAction(e.Status)
+		e.Type = ContainerEventType
+		if legacyImageActions[e.BaseAction()] {
+			e.Type = ImageEventType
+		}
+		e.Actor.ID = e.ID
+		if e.From != "" {
+			if e.Actor.Attributes == nil {
+				e.Actor.Attributes = make(map[string]string)
+			}
+			e.Actor.Attributes["image"] = e.From
+		}
+	}
+	if e.Type == ContainerEventType || e.Type == ImageEventType {
+		if e.Status == "" {
+			e.Status = string(e.Action)
+		}
+		if e.ID == "" {
+			e.ID = e.Actor.ID
+		}
+		if e.From == "" && e.Type == ContainerEventType {
+			e.From = e.Actor.Attributes["image"]
+		}
+	}
+}
+
+// BaseAction returns the action without its ": <detail>" suffix, so that
+// "health_status: healthy" becomes ActionHealthStatus
+func (e *Event) BaseAction() EventAction {
+	if i := strings.Index(string(e.Action), ":"); i >= 0 {
+		return EventAction(e.Action[:i])
+	}
+	return e.Action
+}
+
+// Attribute returns the actor attribute for key, or "" if it is not set
+func (e *Event) Attribute(key string) string {
+	return e.Actor.Attributes[key]
+}
+
+// ExitCode returns the exit code carried by die and exec_die events. ok is
+// false if the event does not carry one.
+func (e *Event) ExitCode() (code int, ok bool) {
+	s, ok := e.Actor.Attributes["exitCode"]
+	if !ok {
+		return 0, false
+	}
+	code, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+// ContainerName returns the name of the container a container event refers to
+func (e *Event) ContainerName() string {
+	if e.Type != ContainerEventType {
+		return ""
+	}
+	return e.Actor.Attributes["name"]
+}
+
+// Image returns the image a container event refers to, or the image name
+// for image events
+func (e *Event) Image() string {
+	if image := e.Actor.Attributes["image"]; image != "" {
+		return image
+	}
+	if e.Type == ImageEventType {
+		if name := e.Actor.Attributes["name"]; name != "" {
+			return name
+		}
+		return e.Actor.ID
+	}
+	return e.From
+}
+
+// HealthStatus returns the status reported by a health_status event, e.g.
+// "healthy" or "unhealthy", or "" for any other event
+func (e *Event) HealthStatus() string {
+	if e.BaseAction() != ActionHealthStatus {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(string(e.Action), string(ActionHealthStatus)+":"))
+}
+
+// filterMap converts the filters into the map expected by the events
+// endpoint. The singular and plural form of each filter are merged.
+func (f *MonitorEventsFilters) filterMap() map[string][]string {
+	filterMap := make(map[string][]string)
+	add := func(key string, value string, values []string) {
+		all := []string{}
+		if value != "" {
+			all = append(all, value)
+		}
+		all = append(all, values...)
+		if len(all) > 0 {
+			filterMap[key] = all
+		}
+	}
+	types := []string{}
+	for _, t := range f.Types {
+		types = append(types, string(t))
+	}
+	add("event", f.Event, f.Events)
+	add("type", string(f.Type), types)
+	add("image", f.Image, f.Images)
+	add("container", f.Container, f.Containers)
+	add("volume", f.Volume, f.Volumes)
+	add("network", f.Network, f.Networks)
+	add("daemon", f.Daemon, f.Daemons)
+	add("label", f.Label, f.Labels)
+	return filterMap
+}
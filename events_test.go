@@ -0,0 +1,72 @@
+package dockerclient
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEventNormalizeLegacy(t *testing.T) {
+	var e Event
+	if err := json.Unmarshal([]byte(`{"status":"die","id":"9b818c3b8291","from":"nginx:latest","time":1428620442}`), &e); err != nil {
+		t.Fatal(err)
+	}
+	e.Normalize()
+	assertEqual(t, e.Type, ContainerEventType, "")
+	assertEqual(t, e.Action, ActionDie, "")
+	assertEqual(t, e.Actor.ID, "9b818c3b8291", "")
+	assertEqual(t, e.Image(), "nginx:latest", "")
+
+	e = Event{Status: "pull", ID: "debian:latest"}
+	e.Normalize()
+	assertEqual(t, e.Type, ImageEventType, "")
+	assertEqual(t, e.Image(), "debian:latest", "")
+}
+
+func TestEventNormalizeModern(t *testing.T) {
+	var e Event
+	body := `{"Type":"container","Action":"die","Actor":{"ID":"9b818c3b8291","Attributes":{"exitCode":"137","image":"redis","name":"cache"}},"time":1461943101}`
+	if err := json.Unmarshal([]byte(body), &e); err != nil {
+		t.Fatal(err)
+	}
+	e.Normalize()
+	assertEqual(t, e.Status, "die", "")
+	assertEqual(t, e.ID, "9b818c3b8291", "")
+	assertEqual(t, e.From, "redis", "")
+	assertEqual(t, e.ContainerName(), "cache", "")
+	code, ok := e.ExitCode()
+	if !ok || code != 137 {
+		t.Fatalf("expected exit code 137, got %d (%v)", code, ok)
+	}
+}
+
+func TestEventHealthStatus(t *testing.T) {
+	e := Event{Type: ContainerEventType, Action: "health_status: unhealthy"}
+	assertEqual(t, e.BaseAction(), ActionHealthStatus, "")
+	assertEqual(t, e.HealthStatus(), "unhealthy", "")
+
+	e = Event{Type: ContainerEventType, Action: ActionStart}
+	assertEqual(t, e.HealthStatus(), "", "")
+	if _, ok := e.ExitCode(); ok {
+		t.Fatal("start event should not carry an exit code")
+	}
+}
+
+func TestMonitorEventsFiltersMap(t *testing.T) {
+	filters := &MonitorEventsFilters{
+		Event:   "start",
+		Events:  []string{"die"},
+		Types:   []EventType{ContainerEventType, NetworkEventType},
+		Label:   "com.example.app=web",
+		Network: "backend",
+	}
+	expected := map[string][]string{
+		"event":   {"start", "die"},
+		"type":    {"container", "network"},
+		"label":   {"com.example.app=web"},
+		"network": {"backend"},
+	}
+	if got := filters.filterMap(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %v, expected %v", got, expected)
+	}
+}
@@ -0,0 +1,844 @@
+package fakeengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+)
+
+// statsInterval is the time between two samples of a stats stream
+var statsInterval = 100 * time.Millisecond
+
+type frame struct {
+	stream stdcopy.StdType
+	data   []byte
+}
+
+type container struct {
+	info      *dockerclient.ContainerInfo
+	behavior  Behavior
+	output    []frame
+	runs      int
+	removed   bool
+	anonymous []string // volumes created for Config.Volumes
+	changed   chan struct{}
+	timers    []*time.Timer
+}
+
+func (c *container) name() string {
+	return strings.TrimPrefix(c.info.Name, "/")
+}
+
+// notify wakes up everyone waiting for a change of the container
+func (c *container) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *container) stopTimers() {
+	for _, t := range c.timers {
+		t.Stop()
+	}
+	c.timers = nil
+}
+
+func (c *container) hasHealthcheck() bool {
+	hc := c.info.Config.Healthcheck
+	return hc != nil && len(hc.Test) > 0 && hc.Test[0] != "NONE"
+}
+
+// lookupContainer finds a container by ID, name or unique ID prefix. It
+// must be called with e.mu held.
+func (e *Engine) lookupContainer(key string) *container {
+	if c, ok := e.containers[key]; ok {
+		return c
+	}
+	key = strings.TrimPrefix(key, "/")
+	var match *container
+	for id, c := range e.containers {
+		if c.name() == key {
+			return c
+		}
+		if strings.HasPrefix(id, key) {
+			if match != nil {
+				return nil
+			}
+			match = c
+		}
+	}
+	return match
+}
+
+// containerOr404 looks up the container of the request, writing a 404 if
+// it doesn't exist. It must be called with e.mu held.
+func (e *Engine) containerOr404(w http.ResponseWriter, r *http.Request) *container {
+	id := mux.Vars(r)["id"]
+	c := e.lookupContainer(id)
+	if c == nil {
+		writeError(w, http.StatusNotFound, "No such container: %s", id)
+	}
+	return c
+}
+
+// ExitContainer makes a running container exit with code, as if its
+// process had ended
+func (e *Engine) ExitContainer(id string, code int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.lookupContainer(id)
+	if c == nil {
+		return fmt.Errorf("No such container: %s", id)
+	}
+	if !c.info.State.Running {
+		return fmt.Errorf("Container %s is not running", id)
+	}
+	e.exit(c, code)
+	return nil
+}
+
+// SetHealth changes the health status of a running container with a
+// healthcheck and emits the matching health_status event
+func (e *Engine) SetHealth(id string, status string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.lookupContainer(id)
+	if c == nil {
+		return fmt.Errorf("No such container: %s", id)
+	}
+	if c.info.State.Health == nil {
+		return fmt.Errorf("Container %s has no healthcheck", id)
+	}
+	e.setHealth(c, status)
+	return nil
+}
+
+func (e *Engine) setHealth(c *container, status string) {
+	health := c.info.State.Health
+	if health.Status == status {
+		return
+	}
+	exitCode := 0
+	if status == dockerclient.HealthUnhealthy {
+		exitCode = 1
+		health.FailingStreak++
+	} else {
+		health.FailingStreak = 0
+	}
+	now := time.Now().UTC()
+	health.Status = status
+	health.Log = append(health.Log, &dockerclient.HealthcheckResult{Start: now, End: now, ExitCode: exitCode})
+	e.emitContainer(c, dockerclient.ActionHealthStatus+": "+dockerclient.EventAction(status), nil)
+	c.notify()
+}
+
+func (e *Engine) handleContainerList(w http.ResponseWriter, r *http.Request) {
+	filters, err := decodeFilters(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid filters: %v", err)
+		return
+	}
+	all := boolValue(r, "all")
+	size := boolValue(r, "size")
+
+	e.mu.Lock()
+	containers := []*container{}
+	for _, c := range e.containers {
+		state := c.info.State.StateString()
+		if !all && !c.info.State.Running && len(filters["status"]) == 0 {
+			continue
+		}
+		if !matchAny(state, filters["status"]) ||
+			!matchLabels(c.info.Config.Labels, filters["label"]) {
+			continue
+		}
+		if len(filters["id"]) > 0 && !matchPrefix(c.info.Id, filters["id"]) {
+			continue
+		}
+		if len(filters["name"]) > 0 && !matchSubstring(c.name(), filters["name"]) {
+			continue
+		}
+		if len(filters["ancestor"]) > 0 && !matchAny(c.info.Config.Image, filters["ancestor"]) && !matchAny(c.info.Image, filters["ancestor"]) {
+			continue
+		}
+		containers = append(containers, c)
+	}
+	// newest first, like the engine
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].info.Created.After(containers[j].info.Created)
+	})
+	ret := []dockerclient.Container{}
+	for _, c := range containers {
+		ret = append(ret, e.listEntry(c, size))
+	}
+	e.mu.Unlock()
+	writeJSON(w, http.StatusOK, ret)
+}
+
+func matchPrefix(value string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(value, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSubstring(value string, substrings []string) bool {
+	for _, s := range substrings {
+		if strings.Contains(value, strings.TrimPrefix(s, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Engine) listEntry(c *container, size bool) dockerclient.Container {
+	entry := dockerclient.Container{
+		Id:      c.info.Id,
+		Names:   []string{c.info.Name},
+		Image:   c.info.Config.Image,
+		ImageID: c.info.Image,
+		Command: strings.Join(append([]string{c.info.Path}, c.info.Args...), " "),
+		Created: c.info.Created,
+		State:   c.info.State.StateString(),
+		Status:  c.info.State.String(),
+		Labels:  c.info.Config.Labels,
+	}
+	for port, bindings := range c.info.HostConfig.PortBindings {
+		number, proto, _ := cut(port, "/")
+		private, _ := strconv.Atoi(number)
+		if proto == "" {
+			proto = "tcp"
+		}
+		for _, b := range bindings {
+			public, _ := strconv.Atoi(b.HostPort)
+			ip := b.HostIp
+			if ip == "" {
+				ip = "0.0.0.0"
+			}
+			entry.Ports = append(entry.Ports, dockerclient.Port{IP: ip, PrivatePort: private, PublicPort: public, Type: proto})
+		}
+	}
+	entry.NetworkSettings.Networks = make(map[string]dockerclient.EndpointSettings)
+	for name, settings := range c.info.NetworkSettings.Networks {
+		entry.NetworkSettings.Networks[name] = *settings
+	}
+	if size {
+		entry.SizeRw = 0
+		if image := e.lookupImage(c.info.Image); image != nil {
+			entry.SizeRootFs = image.Size
+		}
+	}
+	return entry
+}
+
+func (e *Engine) handleContainerCreate(w http.ResponseWriter, r *http.Request) {
+	config := &dockerclient.ContainerConfig{}
+	if err := json.NewDecoder(r.Body).Decode(config); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid config: %v", err)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Query().Get("name"), "/")
+	// like engines before API 1.44, which only create the endpoint of the
+	// network mode and have the others connected afterwards
+	if endpoints := config.NetworkingConfig.EndpointsConfig; len(endpoints) > 1 {
+		names := make([]string, 0, len(endpoints))
+		for network := range endpoints {
+			names = append(names, network)
+		}
+		sort.Strings(names)
+		writeError(w, http.StatusBadRequest, "Container cannot be connected to network endpoints: %s", strings.Join(names, ", "))
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	image := e.lookupImage(config.Image)
+	if image == nil {
+		writeError(w, http.StatusNotFound, "No such image: %s", config.Image)
+		return
+	}
+	id := newID()
+	if name == "" {
+		name = "fake_" + id[:12]
+	} else if c := e.lookupContainer(name); c != nil && c.name() == name {
+		writeError(w, http.StatusConflict, "Conflict. The name \"/%s\" is already in use by container %s. You have to remove (or rename) that container to be able to reuse that name.", name, c.info.Id)
+		return
+	}
+	mergeImageConfig(config, image.Config)
+
+	hostConfig := config.HostConfig
+	path, args := "", []string{}
+	if cmd := append(append([]string{}, config.Entrypoint...), config.Cmd...); len(cmd) > 0 {
+		path, args = cmd[0], cmd[1:]
+	}
+	c := &container{
+		info: &dockerclient.ContainerInfo{
+			Id:         id,
+			Created:    time.Now().UTC(),
+			Path:       path,
+			Args:       args,
+			Name:       "/" + name,
+			Config:     config,
+			State:      &dockerclient.State{},
+			Image:      image.Id,
+			HostConfig: &hostConfig,
+			Volumes:    make(map[string]string),
+		},
+		behavior: e.behaviors[normalizeRef(config.Image)],
+		changed:  make(chan struct{}),
+	}
+	c.info.NetworkSettings.Networks = make(map[string]*dockerclient.EndpointSettings)
+	c.info.NetworkSettings.Ports = hostConfig.PortBindings
+
+	// named volumes are created on the fly, like the engine does
+	for _, bind := range hostConfig.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) >= 2 && !strings.HasPrefix(parts[0], "/") {
+			volume := e.ensureVolume(parts[0], hostConfig.VolumeDriver)
+			c.mountVolume(volume, parts[1], !(len(parts) == 3 && strings.Contains(parts[2], "ro")))
+		}
+	}
+	for target := range config.Volumes {
+		if _, ok := c.info.Volumes[target]; ok {
+			continue
+		}
+		volume := e.ensureVolume(newID(), "")
+		c.anonymous = append(c.anonymous, volume.Name)
+		c.mountVolume(volume, target, true)
+	}
+
+	e.containers[id] = c
+	e.emitContainer(c, dockerclient.ActionCreate, nil)
+
+	mode := hostConfig.NetworkMode
+	if mode == "" || mode == "default" {
+		mode = "bridge"
+	}
+	if network := e.lookupNetwork(mode); network != nil {
+		e.connect(network, c, config.NetworkingConfig.EndpointsConfig[mode])
+	}
+	writeJSON(w, http.StatusCreated, dockerclient.RespContainersCreate{Id: id, Warnings: []string{}})
+}
+
+// mountVolume mounts volume at target, as reported by inspect
+func (c *container) mountVolume(volume *dockerclient.Volume, target string, rw bool) {
+	c.info.Volumes[target] = volume.Mountpoint
+	c.info.Mounts = append(c.info.Mounts, dockerclient.MountPoint{
+		Type:        dockerclient.MountTypeVolume,
+		Name:        volume.Name,
+		Source:      volume.Mountpoint,
+		Destination: target,
+		Driver:      volume.Driver,
+		RW:          rw,
+	})
+}
+
+// mergeImageConfig fills the fields of config left empty with the defaults
+// of the image
+func mergeImageConfig(config *dockerclient.ContainerConfig, image *dockerclient.ContainerConfig) {
+	if image == nil {
+		return
+	}
+	if len(config.Cmd) == 0 && len(config.Entrypoint) == 0 {
+		config.Cmd = image.Cmd
+	}
+	if len(config.Entrypoint) == 0 {
+		config.Entrypoint = image.Entrypoint
+	}
+	if config.WorkingDir == "" {
+		config.WorkingDir = image.WorkingDir
+	}
+	if config.User == "" {
+		config.User = image.User
+	}
+	if config.Healthcheck == nil {
+		config.Healthcheck = image.Healthcheck
+	}
+	if config.StopSignal == "" {
+		config.StopSignal = image.StopSignal
+	}
+	env := append([]string{}, image.Env...)
+	config.Env = append(env, config.Env...)
+	if len(image.Labels) > 0 {
+		labels := make(map[string]string)
+		for k, v := range image.Labels {
+			labels[k] = v
+		}
+		for k, v := range config.Labels {
+			labels[k] = v
+		}
+		config.Labels = labels
+	}
+	if len(image.ExposedPorts) > 0 {
+		ports := make(map[string]struct{})
+		for p := range image.ExposedPorts {
+			ports[p] = struct{}{}
+		}
+		for p := range config.ExposedPorts {
+			ports[p] = struct{}{}
+		}
+		config.ExposedPorts = ports
+	}
+	if len(image.Volumes) > 0 {
+		volumes := make(map[string]struct{})
+		for v := range image.Volumes {
+			volumes[v] = struct{}{}
+		}
+		for v := range config.Volumes {
+			volumes[v] = struct{}{}
+		}
+		config.Volumes = volumes
+	}
+}
+
+func (e *Engine) handleContainerInspect(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.containerOr404(w, r)
+	if c == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, c.info)
+}
+
+func (e *Engine) handleContainerStart(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.containerOr404(w, r)
+	if c == nil {
+		return
+	}
+	if c.info.State.Running {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	e.start(c)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Engine) start(c *container) {
+	state := c.info.State
+	state.Running = true
+	state.Paused = false
+	state.Restarting = false
+	state.Pid = 1000 + len(e.events)
+	state.ExitCode = 0
+	state.Error = ""
+	state.StartedAt = time.Now().UTC()
+	state.FinishedAt = time.Time{}
+	c.runs++
+	if c.behavior.Stdout != "" {
+		c.output = append(c.output, frame{stdcopy.Stdout, []byte(c.behavior.Stdout)})
+	}
+	if c.behavior.Stderr != "" {
+		c.output = append(c.output, frame{stdcopy.Stderr, []byte(c.behavior.Stderr)})
+	}
+	e.emitContainer(c, dockerclient.ActionStart, nil)
+
+	if c.hasHealthcheck() {
+		state.Health = &dockerclient.Health{Status: dockerclient.HealthStarting}
+		status := c.behavior.Health
+		if status == "" {
+			status = dockerclient.HealthHealthy
+		}
+		c.timers = append(c.timers, time.AfterFunc(c.behavior.HealthDelay, func() {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			if c.info.State.Running {
+				e.setHealth(c, status)
+			}
+		}))
+	}
+	if c.behavior.RunFor > 0 {
+		runs := c.runs
+		c.timers = append(c.timers, time.AfterFunc(c.behavior.RunFor, func() {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			if c.info.State.Running && c.runs == runs {
+				e.exit(c, c.behavior.ExitCode)
+			}
+		}))
+	}
+	c.notify()
+}
+
+// exit stops a running container. It must be called with e.mu held.
+func (e *Engine) exit(c *container, code int) {
+	c.stopTimers()
+	state := c.info.State
+	state.Running = false
+	state.Paused = false
+	state.Pid = 0
+	state.ExitCode = code
+	state.FinishedAt = time.Now().UTC()
+	e.emitContainer(c, dockerclient.ActionDie, map[string]string{"exitCode": strconv.Itoa(code)})
+	c.notify()
+}
+
+func (e *Engine) handleContainerStop(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.containerOr404(w, r)
+	if c == nil {
+		return
+	}
+	if !c.info.State.Running {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	e.emitContainer(c, dockerclient.ActionKill, map[string]string{"signal": "15"})
+	e.exit(c, 0)
+	e.emitContainer(c, dockerclient.ActionStop, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Engine) handleContainerRestart(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.containerOr404(w, r)
+	if c == nil {
+		return
+	}
+	if c.info.State.Running {
+		e.exit(c, 0)
+	}
+	e.start(c)
+	e.emitContainer(c, dockerclient.ActionRestart, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Engine) handleContainerKill(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.containerOr404(w, r)
+	if c == nil {
+		return
+	}
+	if !c.info.State.Running {
+		writeError(w, http.StatusConflict, "Container %s is not running", c.info.Id)
+		return
+	}
+	signal := r.URL.Query().Get("signal")
+	if signal == "" {
+		signal = "KILL"
+	}
+	e.emitContainer(c, dockerclient.ActionKill, map[string]string{"signal": signal})
+	e.exit(c, 137)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Engine) handleContainerPause(w http.ResponseWriter, r *http.Request) {
+	e.setPaused(w, r, true)
+}
+
+func (e *Engine) handleContainerUnpause(w http.ResponseWriter, r *http.Request) {
+	e.setPaused(w, r, false)
+}
+
+func (e *Engine) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.containerOr404(w, r)
+	if c == nil {
+		return
+	}
+	if !c.info.State.Running {
+		writeError(w, http.StatusConflict, "Container %s is not running", c.info.Id)
+		return
+	}
+	if c.info.State.Paused == paused {
+		writeError(w, http.StatusConflict, "Container %s is already in the requested state", c.info.Id)
+		return
+	}
+	c.info.State.Paused = paused
+	action := dockerclient.ActionPause
+	if !paused {
+		action = dockerclient.ActionUnpause
+	}
+	e.emitContainer(c, action, nil)
+	c.notify()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Engine) handleContainerRename(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.containerOr404(w, r)
+	if c == nil {
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Query().Get("name"), "/")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "Neither old nor new names may be empty")
+		return
+	}
+	if other := e.lookupContainer(name); other != nil && other.name() == name {
+		writeError(w, http.StatusConflict, "Conflict. The name \"/%s\" is already in use by container %s.", name, other.info.Id)
+		return
+	}
+	oldName := c.info.Name
+	c.info.Name = "/" + name
+	e.emitContainer(c, dockerclient.ActionRename, map[string]string{"oldName": oldName})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Engine) handleContainerWait(w http.ResponseWriter, r *http.Request) {
+	condition := dockerclient.WaitCondition(r.URL.Query().Get("condition"))
+	e.mu.Lock()
+	c := e.containerOr404(w, r)
+	if c == nil {
+		e.mu.Unlock()
+		return
+	}
+	runs := c.runs
+	wasRunning := c.info.State.Running
+	e.mu.Unlock()
+
+	for {
+		e.mu.Lock()
+		done := false
+		switch condition {
+		case dockerclient.WaitConditionRemoved:
+			done = c.removed
+		case dockerclient.WaitConditionNextExit:
+			done = !c.info.State.Running && (c.runs > runs || wasRunning)
+		default:
+			done = !c.info.State.Running
+		}
+		code := c.info.State.ExitCode
+		changed := c.changed
+		e.mu.Unlock()
+
+		if done {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"StatusCode": code, "Error": nil})
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (e *Engine) handleContainerRemove(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.containerOr404(w, r)
+	if c == nil {
+		return
+	}
+	if c.info.State.Running {
+		if !boolValue(r, "force") {
+			writeError(w, http.StatusConflict, "You cannot remove a running container %s. Stop the container before attempting removal or use -f", c.info.Id)
+			return
+		}
+		e.emitContainer(c, dockerclient.ActionKill, map[string]string{"signal": "9"})
+		e.exit(c, 137)
+	}
+	for _, network := range e.networks {
+		if _, ok := network.Containers[c.info.Id]; ok {
+			e.disconnect(network, c)
+		}
+	}
+	if boolValue(r, "v") {
+		for _, name := range c.anonymous {
+			delete(e.volumes, name)
+			e.emitResource(dockerclient.VolumeEventType, dockerclient.ActionDestroy, name, nil)
+		}
+	}
+	delete(e.containers, c.info.Id)
+	c.removed = true
+	e.emitContainer(c, dockerclient.ActionDestroy, nil)
+	c.notify()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// streamOutput writes the output of c to w, starting with frame start and
+// until the container stops if follow is set. It returns once done or when
+// the client goes away.
+func (e *Engine) streamOutput(w http.ResponseWriter, r *http.Request, c *container, start int, stdout, stderr, follow bool) {
+	w.Header().Set("Content-Type", "application/vnd.docker.raw-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	outStream := stdcopy.NewStdWriter(w, stdcopy.Stdout)
+	errStream := stdcopy.NewStdWriter(w, stdcopy.Stderr)
+
+	e.mu.Lock()
+	runs := c.runs
+	wasRunning := c.info.State.Running
+	e.mu.Unlock()
+	sent := start
+	for {
+		e.mu.Lock()
+		frames := c.output[sent:]
+		sent = len(c.output)
+		// a stream ends when the run it started with, or the next one if
+		// the container wasn't running yet, is over
+		ended := c.removed || (!c.info.State.Running && (c.runs > runs || wasRunning))
+		changed := c.changed
+		e.mu.Unlock()
+
+		for _, f := range frames {
+			if f.stream == stdcopy.Stdout && stdout {
+				outStream.Write(f.data)
+			} else if f.stream == stdcopy.Stderr && stderr {
+				errStream.Write(f.data)
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if !follow || ended {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (e *Engine) handleContainerAttach(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	c := e.containerOr404(w, r)
+	if c == nil {
+		e.mu.Unlock()
+		return
+	}
+	// without logs, only what is written from now on is streamed
+	start := len(c.output)
+	if boolValue(r, "logs") {
+		start = 0
+	}
+	e.emitContainer(c, dockerclient.ActionAttach, nil)
+	e.mu.Unlock()
+	e.streamOutput(w, r, c, start, boolValue(r, "stdout"), boolValue(r, "stderr"), boolValue(r, "stream"))
+}
+
+func (e *Engine) handleContainerLogs(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	c := e.containerOr404(w, r)
+	e.mu.Unlock()
+	if c == nil {
+		return
+	}
+	e.streamOutput(w, r, c, 0, boolValue(r, "stdout"), boolValue(r, "stderr"), boolValue(r, "follow"))
+}
+
+func (e *Engine) handleContainerChanges(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if c := e.containerOr404(w, r); c == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, []dockerclient.ContainerChanges{})
+}
+
+func (e *Engine) handleContainerStats(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	c := e.containerOr404(w, r)
+	e.mu.Unlock()
+	if c == nil {
+		return
+	}
+	stream := r.URL.Query().Get("stream") == "" || boolValue(r, "stream")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	var total uint64
+	for {
+		total += 1000000
+		stats := dockerclient.Stats{
+			Read: time.Now().UTC(),
+			CpuStats: dockerclient.CpuStats{
+				CpuUsage:    dockerclient.CpuUsage{TotalUsage: total, PercpuUsage: []uint64{total}},
+				SystemUsage: total * 10,
+			},
+			MemoryStats: dockerclient.MemoryStats{Usage: 4 << 20, MaxUsage: 8 << 20, Limit: 1 << 30},
+		}
+		if err := encoder.Encode(stats); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if !stream {
+			return
+		}
+		select {
+		case <-time.After(statsInterval):
+			// the stream ends with the container
+			e.mu.Lock()
+			removed := c.removed
+			e.mu.Unlock()
+			if removed {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (e *Engine) handleExecCreate(w http.ResponseWriter, r *http.Request) {
+	config := &dockerclient.ExecConfig{}
+	if err := json.NewDecoder(r.Body).Decode(config); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid config: %v", err)
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.containerOr404(w, r)
+	if c == nil {
+		return
+	}
+	if !c.info.State.Running {
+		writeError(w, http.StatusConflict, "Container %s is not running", c.info.Id)
+		return
+	}
+	id := newID()
+	config.Container = c.info.Id
+	e.execs[id] = config
+	c.info.ExecIDs = append(c.info.ExecIDs, id)
+	e.emitContainer(c, dockerclient.ActionExecCreate+": "+dockerclient.EventAction(strings.Join(config.Cmd, " ")), map[string]string{"execID": id})
+	writeJSON(w, http.StatusCreated, map[string]string{"Id": id})
+}
+
+func (e *Engine) handleExecStart(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	id := mux.Vars(r)["id"]
+	config, ok := e.execs[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "No such exec instance: %s", id)
+		return
+	}
+	if c := e.lookupContainer(config.Container); c != nil {
+		cmd := dockerclient.EventAction(strings.Join(config.Cmd, " "))
+		e.emitContainer(c, dockerclient.ActionExecStart+": "+cmd, map[string]string{"execID": id})
+		e.emitContainer(c, dockerclient.ActionExecDie, map[string]string{"execID": id, "exitCode": "0"})
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (e *Engine) handleExecResize(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.execs[mux.Vars(r)["id"]]; !ok {
+		writeError(w, http.StatusNotFound, "No such exec instance: %s", mux.Vars(r)["id"])
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
@@ -0,0 +1,398 @@
+// Package fakeengine is an in-memory Docker engine for tests.
+//
+// An Engine serves the Engine API over HTTP and keeps real state:
+// containers created from a ContainerConfig can be started, stopped, waited
+// for and removed, images can be pulled, tagged and removed, networks and
+// volumes can be managed, and every change is reported on the events
+// stream. This makes it possible to exercise dockerclient.DockerClient, and
+// code built on top of it, end to end without a daemon.
+//
+// Containers don't run anything. What they do once started (output, exit
+// code, health) is scripted per image with SetBehavior. Failures and latency
+// can be injected with InjectFailure and SetLatency.
+package fakeengine
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+)
+
+// versionPrefix matches the optional API version at the start of a path
+var versionPrefix = regexp.MustCompile(`^/v[0-9]+\.[0-9]+`)
+
+// Failure describes an error to return instead of handling a request
+type Failure struct {
+	// Method and Path select the requests that fail. An empty Method
+	// matches every method. Path is a regular expression matched against
+	// the path without its API version prefix, e.g. "^/containers/create$";
+	// an empty Path matches every path.
+	Method string
+	Path   string
+	// StatusCode and Message make up the response
+	StatusCode int
+	Message    string
+	// Times is the number of requests to fail, or 0 to fail them all
+	Times int
+
+	path *regexp.Regexp
+}
+
+// Behavior scripts what the containers created from an image do once they
+// are started
+type Behavior struct {
+	// Stdout and Stderr are written by the container when it starts
+	Stdout string
+	Stderr string
+	// RunFor is how long the container runs before exiting with
+	// ExitCode. If it is zero, the container runs until it is stopped.
+	RunFor   time.Duration
+	ExitCode int
+	// Health is the status reported HealthDelay after the start by
+	// containers that have a healthcheck; it defaults to healthy
+	Health      string
+	HealthDelay time.Duration
+}
+
+type Engine struct {
+	mu          sync.Mutex
+	router      *mux.Router
+	info        dockerclient.Info
+	version     dockerclient.Version
+	latency     time.Duration
+	failures    []*Failure
+	behaviors   map[string]Behavior
+	containers  map[string]*container
+	images      map[string]*image
+	networks    map[string]*dockerclient.NetworkResource
+	volumes     map[string]*dockerclient.Volume
+	execs       map[string]*dockerclient.ExecConfig
+	events      []dockerclient.Event
+	subscribers map[chan dockerclient.Event]struct{}
+	lastIP      int
+}
+
+// New returns an engine with the default bridge, host and none networks
+// and no containers, images or volumes
+func New() *Engine {
+	e := &Engine{
+		info: dockerclient.Info{
+			ID:              "FAKE:ENGINE",
+			Name:            "fakeengine",
+			Driver:          "fake",
+			OperatingSystem: "fakeengine",
+			KernelVersion:   "0.0.0",
+			NCPU:            4,
+			MemTotal:        8 << 30,
+		},
+		version: dockerclient.Version{
+			ApiVersion:    "1.41",
+			Version:       "20.10.0-fake",
+			Os:            "linux",
+			Arch:          "amd64",
+			KernelVersion: "0.0.0",
+			GoVersion:     "go1.x",
+			GitCommit:     "fake",
+		},
+		behaviors:   make(map[string]Behavior),
+		containers:  make(map[string]*container),
+		images:      make(map[string]*image),
+		networks:    make(map[string]*dockerclient.NetworkResource),
+		volumes:     make(map[string]*dockerclient.Volume),
+		execs:       make(map[string]*dockerclient.ExecConfig),
+		subscribers: make(map[chan dockerclient.Event]struct{}),
+	}
+	for _, name := range []string{"bridge", "host", "none"} {
+		driver := name
+		if name == "none" {
+			driver = "null"
+		}
+		id := newID()
+		e.networks[id] = &dockerclient.NetworkResource{
+			Name:       name,
+			ID:         id,
+			Scope:      "local",
+			Driver:     driver,
+			Containers: make(map[string]dockerclient.EndpointResource),
+			Options:    map[string]string{},
+			Labels:     map[string]string{},
+		}
+	}
+	e.router = e.routes()
+	return e
+}
+
+// Serve starts an httptest server for the engine. The caller must close it.
+func (e *Engine) Serve() *httptest.Server {
+	return httptest.NewServer(e)
+}
+
+// ServeUnix serves the engine on a unix socket at path until the returned
+// listener is closed
+func (e *Engine) ServeUnix(path string) (net.Listener, error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	go http.Serve(l, e)
+	return l, nil
+}
+
+// SetInfo replaces the information returned by /info. Container and image
+// counts are always computed from the state of the engine.
+func (e *Engine) SetInfo(info dockerclient.Info) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.info = info
+}
+
+// SetVersion replaces the information returned by /version
+func (e *Engine) SetVersion(version dockerclient.Version) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.version = version
+}
+
+// SetLatency delays every response by d
+func (e *Engine) SetLatency(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.latency = d
+}
+
+// InjectFailure makes the engine fail the requests matching f
+func (e *Engine) InjectFailure(f Failure) error {
+	if f.Path != "" {
+		re, err := regexp.Compile(f.Path)
+		if err != nil {
+			return err
+		}
+		f.path = re
+	}
+	if f.StatusCode == 0 {
+		f.StatusCode = http.StatusInternalServerError
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures = append(e.failures, &f)
+	return nil
+}
+
+// ClearFailures removes every injected failure
+func (e *Engine) ClearFailures() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures = nil
+}
+
+// SetBehavior scripts the containers created from image from now on
+func (e *Engine) SetBehavior(image string, b Behavior) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.behaviors[normalizeRef(image)] = b
+}
+
+func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.URL.Path = versionPrefix.ReplaceAllString(r.URL.Path, "")
+
+	e.mu.Lock()
+	latency := e.latency
+	failure := e.matchFailure(r)
+	e.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if failure != nil {
+		http.Error(w, failure.Message, failure.StatusCode)
+		return
+	}
+	e.router.ServeHTTP(w, r)
+}
+
+// matchFailure returns the first failure matching r and consumes it
+func (e *Engine) matchFailure(r *http.Request) *Failure {
+	for i, f := range e.failures {
+		if f.Method != "" && f.Method != r.Method {
+			continue
+		}
+		if f.path != nil && !f.path.MatchString(r.URL.Path) {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				e.failures = append(e.failures[:i], e.failures[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func (e *Engine) routes() *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/_ping", e.handlePing).Methods("GET", "HEAD")
+	r.HandleFunc("/info", e.handleInfo).Methods("GET")
+	r.HandleFunc("/version", e.handleVersion).Methods("GET")
+	r.HandleFunc("/events", e.handleEvents).Methods("GET")
+
+	r.HandleFunc("/containers/json", e.handleContainerList).Methods("GET")
+	r.HandleFunc("/containers/create", e.handleContainerCreate).Methods("POST")
+	r.HandleFunc("/containers/{id}/json", e.handleContainerInspect).Methods("GET")
+	r.HandleFunc("/containers/{id}/start", e.handleContainerStart).Methods("POST")
+	r.HandleFunc("/containers/{id}/stop", e.handleContainerStop).Methods("POST")
+	r.HandleFunc("/containers/{id}/restart", e.handleContainerRestart).Methods("POST")
+	r.HandleFunc("/containers/{id}/kill", e.handleContainerKill).Methods("POST")
+	r.HandleFunc("/containers/{id}/pause", e.handleContainerPause).Methods("POST")
+	r.HandleFunc("/containers/{id}/unpause", e.handleContainerUnpause).Methods("POST")
+	r.HandleFunc("/containers/{id}/rename", e.handleContainerRename).Methods("POST")
+	r.HandleFunc("/containers/{id}/wait", e.handleContainerWait).Methods("POST")
+	r.HandleFunc("/containers/{id}/attach", e.handleContainerAttach).Methods("POST")
+	r.HandleFunc("/containers/{id}/logs", e.handleContainerLogs).Methods("GET")
+	r.HandleFunc("/containers/{id}/changes", e.handleContainerChanges).Methods("GET")
+	r.HandleFunc("/containers/{id}/stats", e.handleContainerStats).Methods("GET")
+	r.HandleFunc("/containers/{id}/exec", e.handleExecCreate).Methods("POST")
+	r.HandleFunc("/containers/{id}", e.handleContainerRemove).Methods("DELETE")
+	r.HandleFunc("/exec/{id}/start", e.handleExecStart).Methods("POST")
+	r.HandleFunc("/exec/{id}/resize", e.handleExecResize).Methods("POST")
+
+	r.HandleFunc("/images/json", e.handleImageList).Methods("GET")
+	r.HandleFunc("/images/create", e.handleImageCreate).Methods("POST")
+	r.HandleFunc("/images/load", e.handleImageLoad).Methods("POST")
+	r.HandleFunc("/images/search", e.handleImageSearch).Methods("GET")
+	r.HandleFunc("/images/{name:.+}/json", e.handleImageInspect).Methods("GET")
+	r.HandleFunc("/images/{name:.+}/tag", e.handleImageTag).Methods("POST")
+	r.HandleFunc("/images/{name:.+}/push", e.handleImagePush).Methods("POST")
+	r.HandleFunc("/images/{name:.+}", e.handleImageRemove).Methods("DELETE")
+	r.HandleFunc("/build", e.handleBuild).Methods("POST")
+
+	r.HandleFunc("/networks", e.handleNetworkList).Methods("GET")
+	r.HandleFunc("/networks/create", e.handleNetworkCreate).Methods("POST")
+	r.HandleFunc("/networks/{id}", e.handleNetworkInspect).Methods("GET")
+	r.HandleFunc("/networks/{id}/connect", e.handleNetworkConnect).Methods("POST")
+	r.HandleFunc("/networks/{id}/disconnect", e.handleNetworkDisconnect).Methods("POST")
+	r.HandleFunc("/networks/{id}", e.handleNetworkRemove).Methods("DELETE")
+
+	r.HandleFunc("/volumes", e.handleVolumeList).Methods("GET")
+	r.HandleFunc("/volumes/create", e.handleVolumeCreate).Methods("POST")
+	r.HandleFunc("/volumes/{name}", e.handleVolumeRemove).Methods("DELETE")
+	return r
+}
+
+func (e *Engine) handlePing(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+}
+
+func (e *Engine) handleInfo(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	info := e.info
+	info.Containers = int64(len(e.containers))
+	info.Images = int64(len(e.images))
+	e.mu.Unlock()
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (e *Engine) handleVersion(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	version := e.version
+	e.mu.Unlock()
+	writeJSON(w, http.StatusOK, version)
+}
+
+// writeJSON writes v as the JSON body of the response
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a plain text error, the way the engine did up to API
+// v1.23, which is what dockerclient expects
+func writeError(w http.ResponseWriter, code int, format string, args ...interface{}) {
+	http.Error(w, fmt.Sprintf(format, args...), code)
+}
+
+func newID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// boolValue parses the boolean query parameters of the API
+func boolValue(r *http.Request, key string) bool {
+	switch r.URL.Query().Get(key) {
+	case "1", "true", "True":
+		return true
+	}
+	return false
+}
+
+// decodeFilters parses the filters query parameter, accepting both the
+// {"key":["value"]} and the {"key":{"value":true}} forms
+func decodeFilters(r *http.Request) (map[string][]string, error) {
+	filters := make(map[string][]string)
+	raw := r.URL.Query().Get("filters")
+	if raw == "" {
+		return filters, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &filters); err == nil {
+		return filters, nil
+	}
+	sets := make(map[string]map[string]bool)
+	if err := json.Unmarshal([]byte(raw), &sets); err != nil {
+		return nil, err
+	}
+	for key, values := range sets {
+		for value := range values {
+			filters[key] = append(filters[key], value)
+		}
+	}
+	return filters, nil
+}
+
+// matchLabels reports whether labels match every "key" or "key=value"
+// filter
+func matchLabels(labels map[string]string, filters []string) bool {
+	for _, f := range filters {
+		key, value, hasValue := cut(f, "=")
+		v, ok := labels[key]
+		if !ok || (hasValue && v != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny reports whether value is in filters, or filters is empty
+func matchAny(value string, filters []string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, f := range filters {
+		if f == value {
+			return true
+		}
+	}
+	return false
+}
+
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
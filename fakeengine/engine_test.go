@@ -0,0 +1,312 @@
+package fakeengine
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+func testClient(t *testing.T, e *Engine) *dockerclient.DockerClient {
+	server := e.Serve()
+	t.Cleanup(server.Close)
+	client, err := dockerclient.NewDockerClient(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestContainerLifecycle(t *testing.T) {
+	e := New()
+	client := testClient(t, e)
+
+	config := &dockerclient.ContainerConfig{
+		Image:  "busybox",
+		Cmd:    []string{"true"},
+		Labels: map[string]string{"app": "web"},
+	}
+	if _, err := client.CreateContainer(config, "web", nil); err != dockerclient.ErrImageNotFound {
+		t.Fatalf("expected ErrImageNotFound, got %v", err)
+	}
+	if err := client.PullImage("busybox", nil); err != nil {
+		t.Fatal(err)
+	}
+	id, err := client.CreateContainer(config, "web", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateContainer(config, "web", nil); err == nil {
+		t.Fatal("expected a name conflict")
+	}
+
+	info, err := client.InspectContainer("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Id != id || info.State.Running {
+		t.Fatalf("unexpected state after create: %+v", info.State)
+	}
+	if _, ok := info.NetworkSettings.Networks["bridge"]; !ok {
+		t.Fatal("the container should be connected to the bridge network")
+	}
+
+	if err := client.StartContainer(id, nil); err != nil {
+		t.Fatal(err)
+	}
+	containers, err := client.ListContainers(false, false, `{"label":["app=web"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 1 || containers[0].Id != id {
+		t.Fatalf("expected the running container to be listed, got %+v", containers)
+	}
+
+	if err := client.RemoveContainer(id, false, false); err == nil {
+		t.Fatal("removing a running container without force should fail")
+	}
+	if err := client.StopContainer(id, 1); err != nil {
+		t.Fatal(err)
+	}
+	result := <-client.WaitWithOptions(id, nil, nil)
+	if result.Error != nil || result.ExitCode != 0 {
+		t.Fatalf("unexpected wait result %+v", result)
+	}
+	if err := client.RemoveContainer(id, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.InspectContainer(id); err == nil {
+		t.Fatal("the container should be gone")
+	}
+
+	var actions []dockerclient.EventAction
+	for _, event := range e.Events() {
+		if event.Type == dockerclient.ContainerEventType {
+			actions = append(actions, event.Action)
+		}
+	}
+	expected := []dockerclient.EventAction{"create", "start", "kill", "die", "stop", "destroy"}
+	if len(actions) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, actions)
+	}
+	for i := range expected {
+		if actions[i] != expected[i] {
+			t.Fatalf("expected events %v, got %v", expected, actions)
+		}
+	}
+}
+
+func TestRunWithBehavior(t *testing.T) {
+	e := New()
+	e.AddImage("busybox", nil)
+	e.SetBehavior("busybox", Behavior{Stdout: "hello\n", Stderr: "oops\n", RunFor: 10 * time.Millisecond, ExitCode: 3})
+	client := testClient(t, e)
+
+	var stdout, stderr bytes.Buffer
+	code, err := dockerclient.Run(client, &dockerclient.RunOptions{
+		Config:     &dockerclient.ContainerConfig{Image: "busybox"},
+		Stdout:     &stdout,
+		Stderr:     &stderr,
+		AutoRemove: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 3 {
+		t.Fatalf("expected exit code 3, got %d", code)
+	}
+	if stdout.String() != "hello\n" || stderr.String() != "oops\n" {
+		t.Fatalf("unexpected output %q %q", stdout.String(), stderr.String())
+	}
+	containers, err := client.ListContainers(true, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 0 {
+		t.Fatalf("the container should have been removed, got %+v", containers)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	e := New()
+	e.AddImage("web", &dockerclient.ContainerConfig{
+		Healthcheck: &dockerclient.HealthConfig{Test: []string{"CMD", "true"}},
+	})
+	e.SetBehavior("web", Behavior{HealthDelay: 10 * time.Millisecond})
+	client := testClient(t, e)
+
+	id, err := client.CreateContainer(&dockerclient.ContainerConfig{Image: "web"}, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.StartContainer(id, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := dockerclient.WaitForHealthy(client, id, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetHealth(id, dockerclient.HealthUnhealthy); err != nil {
+		t.Fatal(err)
+	}
+	info, err := client.InspectContainer(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Health.Status != dockerclient.HealthUnhealthy {
+		t.Fatalf("expected an unhealthy container, got %q", info.State.Health.Status)
+	}
+}
+
+func TestMonitorEvents(t *testing.T) {
+	e := New()
+	e.AddImage("busybox", nil)
+	client := testClient(t, e)
+
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	events, err := client.MonitorEvents(&dockerclient.MonitorEventsOptions{
+		Filters: &dockerclient.MonitorEventsFilters{Type: dockerclient.ContainerEventType, Event: "start"},
+	}, stopChan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// make sure the subscription is registered before emitting
+	for {
+		e.mu.Lock()
+		n := len(e.subscribers)
+		e.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	id, err := client.CreateContainer(&dockerclient.ContainerConfig{Image: "busybox"}, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.StartContainer(id, nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if event.Error != nil {
+			t.Fatal(event.Error)
+		}
+		if event.Actor.ID != id || event.Action != dockerclient.ActionStart {
+			t.Fatalf("unexpected event %+v", event.Event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the start event")
+	}
+}
+
+func TestImages(t *testing.T) {
+	e := New()
+	id := e.AddImage("busybox:1.36", nil)
+	client := testClient(t, e)
+
+	if err := client.TagImage("busybox:1.36", "busybox", "latest", false); err != nil {
+		t.Fatal(err)
+	}
+	info, err := client.InspectImage("busybox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Id != id || len(info.RepoTags) != 2 {
+		t.Fatalf("unexpected image %+v", info)
+	}
+
+	deleted, err := client.RemoveImage("busybox:latest", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].Untagged != "busybox:latest" {
+		t.Fatalf("expected only an untag, got %+v", deleted)
+	}
+	if _, err := client.CreateContainer(&dockerclient.ContainerConfig{Image: "busybox:1.36"}, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.RemoveImage(id, false); err == nil {
+		t.Fatal("removing an image used by a container should fail")
+	}
+	if _, err := client.RemoveImage(id, true); err != nil {
+		t.Fatal(err)
+	}
+	images, err := client.ListImages(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 0 {
+		t.Fatalf("expected no images, got %+v", images)
+	}
+}
+
+func TestNetworksAndVolumes(t *testing.T) {
+	e := New()
+	e.AddImage("busybox", nil)
+	client := testClient(t, e)
+
+	network, err := client.CreateNetwork(&dockerclient.NetworkCreate{Name: "backend", CheckDuplicate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &dockerclient.ContainerConfig{Image: "busybox"}
+	config.HostConfig.Binds = []string{"data:/data"}
+	config.NetworkingConfig.EndpointsConfig = map[string]*dockerclient.EndpointSettings{"bridge": {}, "backend": {}}
+	if _, err := client.CreateContainer(config, "db", nil); err == nil || !strings.Contains(err.Error(), "Container cannot be connected to network endpoints: backend, bridge") {
+		t.Fatalf("expected a container with several endpoints to be refused, got %v", err)
+	}
+	config.NetworkingConfig.EndpointsConfig = nil
+	id, err := client.CreateContainer(config, "db", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ConnectNetwork("backend", id); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RemoveNetwork(network.ID); err == nil {
+		t.Fatal("removing a network with endpoints should fail")
+	}
+	if err := client.RemoveVolume("data"); err == nil {
+		t.Fatal("removing a volume in use should fail")
+	}
+	if err := client.DisconnectNetwork("backend", id, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RemoveNetwork(network.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RemoveContainer(id, true, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RemoveVolume("data"); err != nil {
+		t.Fatal(err)
+	}
+	volumes, err := client.ListVolumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 0 {
+		t.Fatalf("expected no volumes, got %+v", volumes)
+	}
+}
+
+func TestInjectFailure(t *testing.T) {
+	e := New()
+	client := testClient(t, e)
+	if err := e.InjectFailure(Failure{Method: "GET", Path: "^/info$", StatusCode: http.StatusServiceUnavailable, Message: "down", Times: 1}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.Info()
+	dockerErr, ok := err.(dockerclient.Error)
+	if !ok || dockerErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected a 503 error, got %#v", err)
+	}
+	if _, err := client.Info(); err != nil {
+		t.Fatalf("the failure should only happen once, got %v", err)
+	}
+}
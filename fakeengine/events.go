@@ -0,0 +1,182 @@
+package fakeengine
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+// Emit records an event and sends it to the clients following the events
+// stream. The engine emits events for every change it makes; Emit can be
+// used to simulate anything else. Time is set if it is zero.
+func (e *Engine) Emit(event dockerclient.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.emit(event)
+}
+
+// emit must be called with e.mu held
+func (e *Engine) emit(event dockerclient.Event) {
+	if event.TimeNano == 0 {
+		now := time.Now()
+		event.Time = now.Unix()
+		event.TimeNano = now.UnixNano()
+	}
+	event.Normalize()
+	e.events = append(e.events, event)
+	for ch := range e.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// a subscriber that doesn't keep up loses events, like it
+			// would with a real engine
+		}
+	}
+}
+
+// emitContainer emits an event about c, with its name, image and labels as
+// attributes
+func (e *Engine) emitContainer(c *container, action dockerclient.EventAction, extra map[string]string) {
+	attributes := map[string]string{
+		"name":  c.name(),
+		"image": c.info.Config.Image,
+	}
+	for k, v := range c.info.Config.Labels {
+		attributes[k] = v
+	}
+	for k, v := range extra {
+		attributes[k] = v
+	}
+	e.emit(dockerclient.Event{
+		Type:   dockerclient.ContainerEventType,
+		Action: action,
+		Actor:  dockerclient.Actor{ID: c.info.Id, Attributes: attributes},
+	})
+}
+
+func (e *Engine) emitResource(typ dockerclient.EventType, action dockerclient.EventAction, id string, attributes map[string]string) {
+	e.emit(dockerclient.Event{
+		Type:   typ,
+		Action: action,
+		Actor:  dockerclient.Actor{ID: id, Attributes: attributes},
+	})
+}
+
+// Events returns every event emitted so far
+func (e *Engine) Events() []dockerclient.Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]dockerclient.Event{}, e.events...)
+}
+
+func matchEvent(event dockerclient.Event, filters map[string][]string) bool {
+	if !matchAny(string(event.Type), filters["type"]) {
+		return false
+	}
+	if len(filters["event"]) > 0 &&
+		!matchAny(string(event.Action), filters["event"]) &&
+		!matchAny(string(event.BaseAction()), filters["event"]) {
+		return false
+	}
+	if len(filters["container"]) > 0 {
+		if event.Type != dockerclient.ContainerEventType ||
+			(!matchAny(event.Actor.ID, filters["container"]) && !matchAny(event.ContainerName(), filters["container"])) {
+			return false
+		}
+	}
+	if len(filters["image"]) > 0 && !matchAny(event.Image(), filters["image"]) {
+		return false
+	}
+	for _, typ := range []dockerclient.EventType{dockerclient.NetworkEventType, dockerclient.VolumeEventType} {
+		values := filters[string(typ)]
+		if len(values) > 0 && (event.Type != typ ||
+			(!matchAny(event.Actor.ID, values) && !matchAny(event.Attribute("name"), values))) {
+			return false
+		}
+	}
+	if len(filters["daemon"]) > 0 && event.Type != dockerclient.DaemonEventType {
+		return false
+	}
+	return matchLabels(event.Actor.Attributes, filters["label"])
+}
+
+func (e *Engine) handleEvents(w http.ResponseWriter, r *http.Request) {
+	filters, err := decodeFilters(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid filters: %v", err)
+		return
+	}
+	query := r.URL.Query()
+	var since, until int64
+	if s := query.Get("since"); s != "" {
+		since, _ = strconv.ParseInt(s, 10, 64)
+	}
+	if u := query.Get("until"); u != "" {
+		until, _ = strconv.ParseInt(u, 10, 64)
+	}
+
+	ch := make(chan dockerclient.Event, 100)
+	e.mu.Lock()
+	var history []dockerclient.Event
+	if since > 0 {
+		for _, event := range e.events {
+			if event.Time >= since {
+				history = append(history, event)
+			}
+		}
+	}
+	e.subscribers[ch] = struct{}{}
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.subscribers, ch)
+		e.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	send := func(event dockerclient.Event) bool {
+		if until > 0 && event.Time > until {
+			return false
+		}
+		if matchEvent(event, filters) {
+			if err := encoder.Encode(event); err != nil {
+				return false
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return true
+	}
+	for _, event := range history {
+		if !send(event) {
+			return
+		}
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	var deadline <-chan time.Time
+	if until > 0 {
+		deadline = time.After(time.Until(time.Unix(until, 0)))
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline:
+			return
+		case event := <-ch:
+			if !send(event) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package fakeengine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+)
+
+type image struct {
+	*dockerclient.ImageInfo
+}
+
+func (img *image) labels() map[string]string {
+	if img.Config == nil {
+		return nil
+	}
+	return img.Config.Labels
+}
+
+// normalizeRef adds the implicit latest tag to an image reference and
+// strips the implicit registry
+func normalizeRef(ref string) string {
+	ref = strings.TrimPrefix(ref, "docker.io/")
+	ref = strings.TrimPrefix(ref, "library/")
+	if strings.Contains(ref, "@") {
+		return ref
+	}
+	if i := strings.LastIndex(ref, ":"); i < 0 || strings.Contains(ref[i:], "/") {
+		ref += ":latest"
+	}
+	return ref
+}
+
+// repository returns the name part of a reference, without tag or digest
+func repository(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		return ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i >= 0 && !strings.Contains(ref[i:], "/") {
+		return ref[:i]
+	}
+	return ref
+}
+
+// lookupImage finds an image by ID, unique ID prefix, tag or digest. It
+// must be called with e.mu held.
+func (e *Engine) lookupImage(ref string) *image {
+	if ref == "" {
+		return nil
+	}
+	if img, ok := e.images[ref]; ok {
+		return img
+	}
+	if img, ok := e.images["sha256:"+ref]; ok {
+		return img
+	}
+	normalized := normalizeRef(ref)
+	var match *image
+	for id, img := range e.images {
+		for _, tag := range img.RepoTags {
+			if tag == normalized {
+				return img
+			}
+		}
+		for _, digest := range img.RepoDigests {
+			if digest == normalized {
+				return img
+			}
+		}
+		if len(ref) >= 4 && strings.HasPrefix(strings.TrimPrefix(id, "sha256:"), strings.TrimPrefix(ref, "sha256:")) {
+			if match != nil {
+				return nil
+			}
+			match = img
+		}
+	}
+	return match
+}
+
+// AddImage adds an image tagged ref as if it had been pulled, without
+// emitting events, and returns its ID. The config provides the defaults of
+// the containers created from the image; it may be nil.
+func (e *Engine) AddImage(ref string, config *dockerclient.ContainerConfig) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.addImage(ref, config).Id
+}
+
+// addImage creates an image or moves ref to a new one. It must be called
+// with e.mu held.
+func (e *Engine) addImage(ref string, config *dockerclient.ContainerConfig) *image {
+	if config == nil {
+		config = &dockerclient.ContainerConfig{Cmd: []string{"/bin/sh"}}
+	}
+	id := "sha256:" + newID()
+	img := &image{
+		ImageInfo: &dockerclient.ImageInfo{
+			Id:           id,
+			Architecture: "amd64",
+			Os:           "linux",
+			Config:       config,
+			Created:      time.Now().UTC(),
+			Size:         1 << 20,
+			VirtualSize:  1 << 20,
+		},
+	}
+	// an empty ref makes a dangling image
+	if ref != "" {
+		ref = normalizeRef(ref)
+		if strings.Contains(ref, "@") {
+			img.RepoDigests = []string{ref}
+		} else {
+			sum := sha256.Sum256([]byte(id))
+			e.untag(ref)
+			img.RepoTags = []string{ref}
+			img.RepoDigests = []string{repository(ref) + "@sha256:" + hex.EncodeToString(sum[:])}
+		}
+	}
+	e.images[id] = img
+	return img
+}
+
+// untag removes ref from the image it points to, if any
+func (e *Engine) untag(ref string) {
+	for _, img := range e.images {
+		for i, tag := range img.RepoTags {
+			if tag == ref {
+				img.RepoTags = append(img.RepoTags[:i:i], img.RepoTags[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// imageOr404 looks up the image of the request, writing a 404 if it
+// doesn't exist. It must be called with e.mu held.
+func (e *Engine) imageOr404(w http.ResponseWriter, r *http.Request) *image {
+	name := mux.Vars(r)["name"]
+	img := e.lookupImage(name)
+	if img == nil {
+		writeError(w, http.StatusNotFound, "No such image: %s", name)
+	}
+	return img
+}
+
+func (e *Engine) handleImageList(w http.ResponseWriter, r *http.Request) {
+	filters, err := decodeFilters(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid filters: %v", err)
+		return
+	}
+	e.mu.Lock()
+	ret := []*dockerclient.Image{}
+	for _, img := range e.images {
+		if !matchLabels(img.labels(), filters["label"]) {
+			continue
+		}
+		if len(filters["dangling"]) > 0 && (filters["dangling"][0] == "true") != (len(img.RepoTags) == 0) {
+			continue
+		}
+		tags := img.RepoTags
+		if len(tags) == 0 {
+			tags = []string{"<none>:<none>"}
+		}
+		ret = append(ret, &dockerclient.Image{
+			Created:     img.Created.Unix(),
+			Id:          img.Id,
+			Labels:      img.labels(),
+			RepoDigests: img.RepoDigests,
+			RepoTags:    tags,
+			Size:        img.Size,
+			VirtualSize: img.VirtualSize,
+		})
+	}
+	e.mu.Unlock()
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Created > ret[j].Created })
+	writeJSON(w, http.StatusOK, ret)
+}
+
+func digestOf(img *image) string {
+	for _, d := range img.RepoDigests {
+		return d[strings.Index(d, "@")+1:]
+	}
+	return ""
+}
+
+// writeProgress writes the JSON messages of a pull or push
+func writeProgress(w http.ResponseWriter, messages ...map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	for _, m := range messages {
+		encoder.Encode(m)
+	}
+}
+
+func (e *Engine) handleImageCreate(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if src := query.Get("fromSrc"); src != "" {
+		e.handleImageImport(w, r)
+		return
+	}
+	ref := query.Get("fromImage")
+	if tag := query.Get("tag"); tag != "" {
+		ref += ":" + tag
+	}
+	if ref == "" {
+		writeError(w, http.StatusBadRequest, "fromImage or fromSrc is required")
+		return
+	}
+	ref = normalizeRef(ref)
+
+	e.mu.Lock()
+	img := e.lookupImage(ref)
+	upToDate := img != nil
+	if img == nil {
+		img = e.addImage(ref, nil)
+	}
+	e.emitResource(dockerclient.ImageEventType, dockerclient.ActionPull, ref, map[string]string{"name": ref})
+	digest := digestOf(img)
+	e.mu.Unlock()
+
+	status := "Status: Downloaded newer image for " + ref
+	if upToDate {
+		status = "Status: Image is up to date for " + ref
+	}
+	writeProgress(w,
+		map[string]string{"status": "Pulling from " + repository(ref), "id": strings.TrimPrefix(ref[len(repository(ref)):], ":")},
+		map[string]string{"status": "Digest: " + digest},
+		map[string]string{"status": status},
+	)
+}
+
+func (e *Engine) handleImageImport(w http.ResponseWriter, r *http.Request) {
+	io.Copy(ioutil.Discard, r.Body)
+	query := r.URL.Query()
+	ref := query.Get("repo")
+	if tag := query.Get("tag"); tag != "" {
+		ref += ":" + tag
+	}
+	e.mu.Lock()
+	img := e.addImage(ref, nil)
+	// images that weren't pulled have no digest
+	img.RepoDigests = nil
+	e.emitResource(dockerclient.ImageEventType, dockerclient.ActionImport, img.Id, nil)
+	e.mu.Unlock()
+	writeProgress(w, map[string]string{"status": img.Id})
+}
+
+func (e *Engine) handleImageLoad(w http.ResponseWriter, r *http.Request) {
+	io.Copy(ioutil.Discard, r.Body)
+	e.mu.Lock()
+	img := e.addImage("loaded:latest", nil)
+	img.RepoDigests = nil
+	e.emitResource(dockerclient.ImageEventType, dockerclient.ActionLoad, img.Id, nil)
+	e.mu.Unlock()
+	writeProgress(w, map[string]string{"stream": "Loaded image: loaded:latest\n"})
+}
+
+func (e *Engine) handleImageSearch(w http.ResponseWriter, r *http.Request) {
+	term := r.URL.Query().Get("term")
+	e.mu.Lock()
+	seen := make(map[string]bool)
+	ret := []dockerclient.ImageSearch{}
+	for _, img := range e.images {
+		for _, tag := range img.RepoTags {
+			name := repository(tag)
+			if !seen[name] && strings.Contains(name, term) {
+				seen[name] = true
+				ret = append(ret, dockerclient.ImageSearch{Name: name})
+			}
+		}
+	}
+	e.mu.Unlock()
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	writeJSON(w, http.StatusOK, ret)
+}
+
+func (e *Engine) handleImageInspect(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	img := e.imageOr404(w, r)
+	if img == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, img.ImageInfo)
+}
+
+func (e *Engine) handleImageTag(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	ref := query.Get("repo")
+	if tag := query.Get("tag"); tag != "" {
+		ref += ":" + tag
+	}
+	if ref == "" {
+		writeError(w, http.StatusBadRequest, "repository name must have at least one component")
+		return
+	}
+	ref = normalizeRef(ref)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	img := e.imageOr404(w, r)
+	if img == nil {
+		return
+	}
+	e.untag(ref)
+	img.RepoTags = append(img.RepoTags, ref)
+	e.emitResource(dockerclient.ImageEventType, dockerclient.ActionTag, img.Id, map[string]string{"name": ref})
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (e *Engine) handleImagePush(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	ref := name
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		ref += ":" + tag
+	}
+	e.mu.Lock()
+	img := e.lookupImage(ref)
+	if img == nil {
+		e.mu.Unlock()
+		writeError(w, http.StatusNotFound, "An image does not exist locally with the tag: %s", name)
+		return
+	}
+	e.emitResource(dockerclient.ImageEventType, dockerclient.ActionPush, normalizeRef(ref), map[string]string{"name": normalizeRef(ref)})
+	digest := digestOf(img)
+	e.mu.Unlock()
+	writeProgress(w,
+		map[string]string{"status": "The push refers to repository [" + repository(normalizeRef(ref)) + "]"},
+		map[string]string{"status": "latest: digest: " + digest},
+	)
+}
+
+func (e *Engine) handleImageRemove(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	force := boolValue(r, "force")
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	img := e.imageOr404(w, r)
+	if img == nil {
+		return
+	}
+
+	// removing one of several tags only untags the image
+	normalized := normalizeRef(name)
+	if len(img.RepoTags) > 1 {
+		for _, tag := range img.RepoTags {
+			if tag == normalized {
+				e.untag(tag)
+				e.emitResource(dockerclient.ImageEventType, dockerclient.ActionUntag, img.Id, map[string]string{"name": tag})
+				writeJSON(w, http.StatusOK, []dockerclient.ImageDelete{{Untagged: tag}})
+				return
+			}
+		}
+	}
+
+	if !force {
+		for _, c := range e.containers {
+			if c.info.Image == img.Id {
+				writeError(w, http.StatusConflict, "conflict: unable to remove repository reference \"%s\" (must force) - container %s is using its referenced image %s", name, c.info.Id[:12], img.Id[7:19])
+				return
+			}
+		}
+	}
+	ret := []dockerclient.ImageDelete{}
+	for _, tag := range img.RepoTags {
+		ret = append(ret, dockerclient.ImageDelete{Untagged: tag})
+		e.emitResource(dockerclient.ImageEventType, dockerclient.ActionUntag, img.Id, map[string]string{"name": tag})
+	}
+	delete(e.images, img.Id)
+	ret = append(ret, dockerclient.ImageDelete{Deleted: img.Id})
+	e.emitResource(dockerclient.ImageEventType, dockerclient.ActionDelete, img.Id, nil)
+	writeJSON(w, http.StatusOK, ret)
+}
+
+func (e *Engine) handleBuild(w http.ResponseWriter, r *http.Request) {
+	io.Copy(ioutil.Discard, r.Body)
+	ref := r.URL.Query().Get("t")
+	e.mu.Lock()
+	img := e.addImage(ref, nil)
+	if ref != "" {
+		e.emitResource(dockerclient.ImageEventType, dockerclient.ActionTag, img.Id, map[string]string{"name": normalizeRef(ref)})
+	}
+	e.mu.Unlock()
+	writeProgress(w, map[string]string{"stream": "Successfully built " + img.Id[7:19] + "\n"})
+}
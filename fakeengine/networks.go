@@ -0,0 +1,336 @@
+package fakeengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samalba/dockerclient"
+)
+
+// predefined networks can't be removed
+var predefined = map[string]bool{"bridge": true, "host": true, "none": true}
+
+// lookupNetwork finds a network by ID, name or unique ID prefix. It must
+// be called with e.mu held.
+func (e *Engine) lookupNetwork(key string) *dockerclient.NetworkResource {
+	if n, ok := e.networks[key]; ok {
+		return n
+	}
+	var match *dockerclient.NetworkResource
+	for id, n := range e.networks {
+		if n.Name == key {
+			return n
+		}
+		if strings.HasPrefix(id, key) {
+			if match != nil {
+				return nil
+			}
+			match = n
+		}
+	}
+	return match
+}
+
+func (e *Engine) networkOr404(w http.ResponseWriter, r *http.Request) *dockerclient.NetworkResource {
+	id := mux.Vars(r)["id"]
+	n := e.lookupNetwork(id)
+	if n == nil {
+		writeError(w, http.StatusNotFound, "network %s not found", id)
+	}
+	return n
+}
+
+// connect attaches c to network n. It must be called with e.mu held.
+func (e *Engine) connect(n *dockerclient.NetworkResource, c *container, settings *dockerclient.EndpointSettings) {
+	endpoint := &dockerclient.EndpointSettings{}
+	if settings != nil {
+		*endpoint = *settings
+	}
+	e.lastIP++
+	endpoint.NetworkID = n.ID
+	endpoint.EndpointID = newID()
+	endpoint.Gateway = "172.17.0.1"
+	endpoint.IPAddress = fmt.Sprintf("172.17.%d.%d", e.lastIP/254, e.lastIP%254+2)
+	endpoint.IPPrefixLen = 16
+	endpoint.MacAddress = fmt.Sprintf("02:42:ac:11:%02x:%02x", e.lastIP/254, e.lastIP%254+2)
+	c.info.NetworkSettings.Networks[n.Name] = endpoint
+	n.Containers[c.info.Id] = dockerclient.EndpointResource{
+		Name:        c.name(),
+		EndpointID:  endpoint.EndpointID,
+		MacAddress:  endpoint.MacAddress,
+		IPv4Address: fmt.Sprintf("%s/%d", endpoint.IPAddress, endpoint.IPPrefixLen),
+	}
+	e.emitResource(dockerclient.NetworkEventType, dockerclient.ActionConnect, n.ID, map[string]string{
+		"container": c.info.Id,
+		"name":      n.Name,
+		"type":      n.Driver,
+	})
+}
+
+// disconnect detaches c from network n. It must be called with e.mu held.
+func (e *Engine) disconnect(n *dockerclient.NetworkResource, c *container) {
+	delete(c.info.NetworkSettings.Networks, n.Name)
+	delete(n.Containers, c.info.Id)
+	e.emitResource(dockerclient.NetworkEventType, dockerclient.ActionDisconnect, n.ID, map[string]string{
+		"container": c.info.Id,
+		"name":      n.Name,
+		"type":      n.Driver,
+	})
+}
+
+func (e *Engine) handleNetworkList(w http.ResponseWriter, r *http.Request) {
+	filters, err := decodeFilters(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid filters: %v", err)
+		return
+	}
+	e.mu.Lock()
+	ret := []dockerclient.NetworkResource{}
+	for _, n := range e.networks {
+		if !matchLabels(n.Labels, filters["label"]) || !matchAny(n.Driver, filters["driver"]) {
+			continue
+		}
+		if len(filters["name"]) > 0 && !matchSubstring(n.Name, filters["name"]) {
+			continue
+		}
+		if len(filters["id"]) > 0 && !matchPrefix(n.ID, filters["id"]) {
+			continue
+		}
+		ret = append(ret, *n)
+	}
+	e.mu.Unlock()
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	writeJSON(w, http.StatusOK, ret)
+}
+
+func (e *Engine) handleNetworkCreate(w http.ResponseWriter, r *http.Request) {
+	config := &dockerclient.NetworkCreate{}
+	if err := json.NewDecoder(r.Body).Decode(config); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid config: %v", err)
+		return
+	}
+	if config.Name == "" {
+		writeError(w, http.StatusBadRequest, "network name is required")
+		return
+	}
+	if config.Driver == "" {
+		config.Driver = "bridge"
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, n := range e.networks {
+		if n.Name == config.Name && (config.CheckDuplicate || predefined[n.Name]) {
+			writeError(w, http.StatusConflict, "network with name %s already exists", config.Name)
+			return
+		}
+	}
+	n := &dockerclient.NetworkResource{
+		Name:       config.Name,
+		ID:         newID(),
+		Scope:      "local",
+		Driver:     config.Driver,
+		IPAM:       config.IPAM,
+		Containers: make(map[string]dockerclient.EndpointResource),
+		Options:    config.Options,
+		Labels:     config.Labels,
+		Created:    time.Now().UTC(),
+	}
+	e.networks[n.ID] = n
+	e.emitResource(dockerclient.NetworkEventType, dockerclient.ActionCreate, n.ID, map[string]string{
+		"name": n.Name,
+		"type": n.Driver,
+	})
+	writeJSON(w, http.StatusCreated, dockerclient.NetworkCreateResponse{ID: n.ID})
+}
+
+func (e *Engine) handleNetworkInspect(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := e.networkOr404(w, r)
+	if n == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, n)
+}
+
+func (e *Engine) handleNetworkConnect(w http.ResponseWriter, r *http.Request) {
+	var config struct {
+		Container      string
+		EndpointConfig *dockerclient.EndpointSettings
+	}
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid config: %v", err)
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := e.networkOr404(w, r)
+	if n == nil {
+		return
+	}
+	c := e.lookupContainer(config.Container)
+	if c == nil {
+		writeError(w, http.StatusNotFound, "No such container: %s", config.Container)
+		return
+	}
+	if _, ok := n.Containers[c.info.Id]; ok {
+		writeError(w, http.StatusForbidden, "endpoint with name %s already exists in network %s", c.name(), n.Name)
+		return
+	}
+	e.connect(n, c, config.EndpointConfig)
+	c.notify()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (e *Engine) handleNetworkDisconnect(w http.ResponseWriter, r *http.Request) {
+	config := &dockerclient.NetworkDisconnect{}
+	if err := json.NewDecoder(r.Body).Decode(config); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid config: %v", err)
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := e.networkOr404(w, r)
+	if n == nil {
+		return
+	}
+	c := e.lookupContainer(config.Container)
+	if c == nil {
+		writeError(w, http.StatusNotFound, "No such container: %s", config.Container)
+		return
+	}
+	if _, ok := n.Containers[c.info.Id]; !ok {
+		writeError(w, http.StatusForbidden, "container %s is not connected to network %s", c.info.Id, n.Name)
+		return
+	}
+	e.disconnect(n, c)
+	c.notify()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (e *Engine) handleNetworkRemove(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := e.networkOr404(w, r)
+	if n == nil {
+		return
+	}
+	if predefined[n.Name] {
+		writeError(w, http.StatusForbidden, "%s is a pre-defined network and cannot be removed", n.Name)
+		return
+	}
+	if len(n.Containers) > 0 {
+		writeError(w, http.StatusForbidden, "error while removing network: network %s id %s has active endpoints", n.Name, n.ID)
+		return
+	}
+	delete(e.networks, n.ID)
+	e.emitResource(dockerclient.NetworkEventType, dockerclient.ActionDestroy, n.ID, map[string]string{
+		"name": n.Name,
+		"type": n.Driver,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ensureVolume returns the volume called name, creating it if needed. It
+// must be called with e.mu held.
+func (e *Engine) ensureVolume(name, driver string) *dockerclient.Volume {
+	if v, ok := e.volumes[name]; ok {
+		return v
+	}
+	if driver == "" {
+		driver = "local"
+	}
+	v := &dockerclient.Volume{
+		Name:       name,
+		Driver:     driver,
+		Mountpoint: "/var/lib/docker/volumes/" + name + "/_data",
+		Labels:     map[string]string{},
+		CreatedAt:  time.Now().UTC(),
+	}
+	e.volumes[name] = v
+	e.emitResource(dockerclient.VolumeEventType, dockerclient.ActionCreate, name, map[string]string{"driver": driver})
+	return v
+}
+
+// volumeInUse returns the container using volume name, if any
+func (e *Engine) volumeInUse(name string) *container {
+	mountpoint := e.volumes[name].Mountpoint
+	for _, c := range e.containers {
+		for _, m := range c.info.Volumes {
+			if m == mountpoint {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Engine) handleVolumeList(w http.ResponseWriter, r *http.Request) {
+	filters, err := decodeFilters(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid filters: %v", err)
+		return
+	}
+	e.mu.Lock()
+	ret := dockerclient.VolumesListResponse{Volumes: []*dockerclient.Volume{}}
+	for name, v := range e.volumes {
+		if !matchLabels(v.Labels, filters["label"]) || !matchAny(v.Driver, filters["driver"]) {
+			continue
+		}
+		if len(filters["name"]) > 0 && !matchSubstring(name, filters["name"]) {
+			continue
+		}
+		if len(filters["dangling"]) > 0 && (filters["dangling"][0] == "true") != (e.volumeInUse(name) == nil) {
+			continue
+		}
+		volume := *v
+		ret.Volumes = append(ret.Volumes, &volume)
+	}
+	e.mu.Unlock()
+	sort.Slice(ret.Volumes, func(i, j int) bool { return ret.Volumes[i].Name < ret.Volumes[j].Name })
+	writeJSON(w, http.StatusOK, ret)
+}
+
+func (e *Engine) handleVolumeCreate(w http.ResponseWriter, r *http.Request) {
+	request := &dockerclient.VolumeCreateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid config: %v", err)
+		return
+	}
+	if request.Name == "" {
+		request.Name = newID()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if v, ok := e.volumes[request.Name]; ok {
+		writeJSON(w, http.StatusCreated, v)
+		return
+	}
+	v := e.ensureVolume(request.Name, request.Driver)
+	if request.Labels != nil {
+		v.Labels = request.Labels
+	}
+	writeJSON(w, http.StatusCreated, v)
+}
+
+func (e *Engine) handleVolumeRemove(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.volumes[name]; !ok {
+		writeError(w, http.StatusNotFound, "get %s: no such volume", name)
+		return
+	}
+	if c := e.volumeInUse(name); c != nil {
+		writeError(w, http.StatusConflict, "remove %s: volume is in use - [%s]", name, c.info.Id)
+		return
+	}
+	delete(e.volumes, name)
+	e.emitResource(dockerclient.VolumeEventType, dockerclient.ActionDestroy, name, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,405 @@
+// Package gc removes the containers, networks, volumes and images leaked
+// by crashed jobs.
+//
+// A Collector only considers the resources carrying its ownership label,
+// and removes those that expired or whose parent container is gone. A
+// resource expires once the duration of its TTLLabel elapsed since it was
+// created, or once the date of its ExpiresLabel passed. Its ParentLabel
+// names the container it belongs to: when that container doesn't exist
+// anymore, or is collected too, the resource is orphaned. The dangling
+// images carrying the ownership label are removed as well:
+//
+//	c := gc.NewCollector(client, "com.example.ci.job")
+//	report, err := c.Collect(true)
+//	fmt.Print(report)
+//
+// Resources are removed in dependency order: containers first, then
+// networks, volumes and images.
+package gc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+const (
+	// TTLLabel is set to the duration a resource is kept for after its
+	// creation, such as 2h
+	TTLLabel = "com.github.samalba.dockerclient.gc.ttl"
+	// ExpiresLabel is set to the date after which a resource can be
+	// removed, in RFC 3339 format or as seconds since the epoch
+	ExpiresLabel = "com.github.samalba.dockerclient.gc.expires"
+	// ParentLabel is set to the ID or name of the container a resource
+	// belongs to
+	ParentLabel = "com.github.samalba.dockerclient.gc.parent"
+)
+
+// Kind is the kind of a collected resource
+type Kind string
+
+const (
+	KindContainer Kind = "container"
+	KindNetwork   Kind = "network"
+	KindVolume    Kind = "volume"
+	KindImage     Kind = "image"
+)
+
+// Resource is a resource removed by a Collector
+type Resource struct {
+	Kind Kind
+	ID   string
+	Name string
+	// Reason explains why the resource is removed
+	Reason string
+}
+
+func (r *Resource) String() string {
+	return fmt.Sprintf("%s %s (%s)", r.Kind, r.Name, r.Reason)
+}
+
+// Failure is a resource that couldn't be removed
+type Failure struct {
+	Resource
+	Err error
+}
+
+// Report lists what a collection removed
+type Report struct {
+	// Removed are the resources removed, or to remove on a dry run, in
+	// the order they were removed
+	Removed []Resource
+	Failed  []Failure
+}
+
+// String returns the resources removed and the failures, one per line
+func (r *Report) String() string {
+	var buf bytes.Buffer
+	for _, res := range r.Removed {
+		fmt.Fprintf(&buf, "remove %s\n", &res)
+	}
+	for _, f := range r.Failed {
+		fmt.Fprintf(&buf, "failed to remove %s: %v\n", &f.Resource, f.Err)
+	}
+	return buf.String()
+}
+
+type Collector struct {
+	client dockerclient.Client
+	label  string
+
+	// DefaultTTL applies to the resources with neither a TTL nor an
+	// expiry label. If zero, they are only removed once orphaned.
+	DefaultTTL time.Duration
+
+	now func() time.Time
+}
+
+// NewCollector returns a collector of the resources of client carrying
+// label, given as key or key=value
+func NewCollector(client dockerclient.Client, label string) *Collector {
+	return &Collector{client: client, label: label, now: time.Now}
+}
+
+// collection is the state of a pass of Collect
+type collection struct {
+	*Collector
+	dryRun bool
+	report *Report
+	// alive maps the IDs, short IDs and names of the containers left to
+	// the ID of the container
+	alive map[string]string
+}
+
+// Collect removes the expired and orphaned resources, unless dryRun is
+// set, and reports them. Resources that can't be removed are reported as
+// failures without stopping the collection. Containers are removed by
+// force with their anonymous volumes, and networks disconnected from the
+// containers still using them before being removed.
+//
+// An error is only returned when the resources can't be listed.
+func (c *Collector) Collect(dryRun bool) (*Report, error) {
+	col := &collection{Collector: c, dryRun: dryRun, report: &Report{}}
+	if err := col.containers(); err != nil {
+		return col.report, err
+	}
+	if err := col.networks(); err != nil {
+		return col.report, err
+	}
+	if err := col.volumes(); err != nil {
+		return col.report, err
+	}
+	if err := col.images(); err != nil {
+		return col.report, err
+	}
+	return col.report, nil
+}
+
+func (col *collection) containers() error {
+	containers, err := col.client.ListContainers(true, false, "")
+	if err != nil {
+		return err
+	}
+	col.alive = make(map[string]string)
+	for _, container := range containers {
+		col.alive[container.Id] = container.Id
+		col.alive[truncateID(container.Id)] = container.Id
+		for _, name := range container.Names {
+			col.alive[strings.TrimPrefix(name, "/")] = container.Id
+		}
+	}
+
+	var expired []Resource
+	pending := make(map[string]dockerclient.Container)
+	for _, container := range containers {
+		if !matchLabel(container.Labels, col.label) {
+			continue
+		}
+		if reason := col.expired(container.Labels, container.Created); reason != "" {
+			expired = append(expired, Resource{Kind: KindContainer, ID: container.Id, Name: containerName(container), Reason: reason})
+			continue
+		}
+		pending[container.Id] = container
+	}
+	sortResources(expired)
+	col.removeContainers(expired)
+	// a container orphaned by a removal can orphan others in turn
+	for {
+		var orphans []Resource
+		for id, container := range pending {
+			if reason := col.orphaned(container.Labels); reason != "" {
+				orphans = append(orphans, Resource{Kind: KindContainer, ID: id, Name: containerName(container), Reason: reason})
+			}
+		}
+		if len(orphans) == 0 {
+			break
+		}
+		sortResources(orphans)
+		for _, res := range orphans {
+			delete(pending, res.ID)
+		}
+		col.removeContainers(orphans)
+	}
+	return nil
+}
+
+// removeContainers removes containers, burying those removed so that the
+// resources they are the parent of are orphaned. Those that can't be
+// removed stay alive, and so do their children.
+func (col *collection) removeContainers(containers []Resource) {
+	for _, res := range containers {
+		removed := col.remove(res, func() error {
+			return col.client.RemoveContainer(res.ID, true, true)
+		})
+		if removed {
+			col.bury(res.ID)
+		}
+	}
+}
+
+// bury removes container id from the containers alive
+func (col *collection) bury(id string) {
+	for key, aliveID := range col.alive {
+		if aliveID == id {
+			delete(col.alive, key)
+		}
+	}
+}
+
+func (col *collection) networks() error {
+	filters, err := json.Marshal(map[string][]string{"label": {col.label}})
+	if err != nil {
+		return err
+	}
+	networks, err := col.client.ListNetworks(string(filters))
+	if err != nil {
+		return err
+	}
+	var collected []Resource
+	for _, network := range networks {
+		if !matchLabel(network.Labels, col.label) {
+			continue
+		}
+		if reason := col.collectable(network.Labels, network.Created); reason != "" {
+			collected = append(collected, Resource{Kind: KindNetwork, ID: network.ID, Name: network.Name, Reason: reason})
+		}
+	}
+	sortResources(collected)
+	for _, res := range collected {
+		col.remove(res, func() error {
+			network, err := col.client.InspectNetwork(res.ID)
+			if err != nil {
+				return err
+			}
+			for id := range network.Containers {
+				if err := col.client.DisconnectNetwork(res.ID, id, true); err != nil && !dockerclient.IsNotFound(err) {
+					return err
+				}
+			}
+			return col.client.RemoveNetwork(res.ID)
+		})
+	}
+	return nil
+}
+
+func (col *collection) volumes() error {
+	volumes, err := col.client.ListVolumes()
+	if err != nil {
+		return err
+	}
+	var collected []Resource
+	for _, volume := range volumes {
+		if !matchLabel(volume.Labels, col.label) {
+			continue
+		}
+		if reason := col.collectable(volume.Labels, volume.CreatedAt); reason != "" {
+			collected = append(collected, Resource{Kind: KindVolume, ID: volume.Name, Name: volume.Name, Reason: reason})
+		}
+	}
+	sortResources(collected)
+	for _, res := range collected {
+		col.remove(res, func() error {
+			return col.client.RemoveVolume(res.ID)
+		})
+	}
+	return nil
+}
+
+func (col *collection) images() error {
+	images, err := col.client.ListImages(false)
+	if err != nil {
+		return err
+	}
+	var collected []Resource
+	for _, image := range images {
+		if !matchLabel(image.Labels, col.label) || !isDangling(image) {
+			continue
+		}
+		collected = append(collected, Resource{Kind: KindImage, ID: image.Id, Name: truncateID(strings.TrimPrefix(image.Id, "sha256:")), Reason: "dangling"})
+	}
+	sortResources(collected)
+	for _, res := range collected {
+		col.remove(res, func() error {
+			_, err := col.client.RemoveImage(res.ID, false)
+			return err
+		})
+	}
+	return nil
+}
+
+// remove calls remove for res unless on a dry run, and reports it. A
+// resource already gone counts as removed. It returns whether res was
+// removed, or would be on a dry run.
+func (col *collection) remove(res Resource, remove func() error) bool {
+	if !col.dryRun {
+		if err := remove(); err != nil && !dockerclient.IsNotFound(err) {
+			col.report.Failed = append(col.report.Failed, Failure{Resource: res, Err: err})
+			return false
+		}
+	}
+	col.report.Removed = append(col.report.Removed, res)
+	return true
+}
+
+// collectable returns why a network or volume is collected, or an empty
+// string if it isn't
+func (col *collection) collectable(labels map[string]string, created time.Time) string {
+	if reason := col.expired(labels, created); reason != "" {
+		return reason
+	}
+	return col.orphaned(labels)
+}
+
+// expired returns why a resource created at created expired, or an empty
+// string if it didn't. Invalid TTLs and expiry dates, as well as TTLs of
+// resources whose creation date is unknown, are ignored.
+func (c *Collector) expired(labels map[string]string, created time.Time) string {
+	now := c.now()
+	if value, ok := labels[ExpiresLabel]; ok {
+		expires, err := parseTime(value)
+		if err == nil && now.After(expires) {
+			return "expired " + expires.UTC().Format(time.RFC3339)
+		}
+		return ""
+	}
+	ttl := c.DefaultTTL
+	if value, ok := labels[TTLLabel]; ok {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return ""
+		}
+		ttl = d
+	}
+	if ttl <= 0 || created.IsZero() || created.Unix() <= 0 {
+		return ""
+	}
+	if age := now.Sub(created); age > ttl {
+		return fmt.Sprintf("older than %s", ttl)
+	}
+	return ""
+}
+
+// orphaned returns why a resource is orphaned, or an empty string if its
+// parent is alive or it has none
+func (col *collection) orphaned(labels map[string]string) string {
+	parent, ok := labels[ParentLabel]
+	if !ok || parent == "" {
+		return ""
+	}
+	if _, ok := col.alive[parent]; ok {
+		return ""
+	}
+	return "parent " + parent + " is gone"
+}
+
+func parseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// matchLabel reports whether labels carry label, given as key or
+// key=value
+func matchLabel(labels map[string]string, label string) bool {
+	key, value := label, ""
+	hasValue := false
+	if i := strings.Index(label, "="); i >= 0 {
+		key, value, hasValue = label[:i], label[i+1:], true
+	}
+	v, ok := labels[key]
+	return ok && (!hasValue || v == value)
+}
+
+func isDangling(image *dockerclient.Image) bool {
+	for _, tag := range image.RepoTags {
+		if tag != "<none>:<none>" {
+			return false
+		}
+	}
+	return true
+}
+
+func containerName(container dockerclient.Container) string {
+	if len(container.Names) > 0 {
+		return strings.TrimPrefix(container.Names[0], "/")
+	}
+	return truncateID(container.Id)
+}
+
+func truncateID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func sortResources(resources []Resource) {
+	sort.Slice(resources, func(i, j int) bool { return resources[i].Name < resources[j].Name })
+}
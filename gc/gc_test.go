@@ -0,0 +1,212 @@
+package gc
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/samalba/dockerclient/fakeengine"
+)
+
+func TestCollect(t *testing.T) {
+	engine := fakeengine.New()
+	engine.AddImage("busybox", nil)
+	engine.AddImage("", &dockerclient.ContainerConfig{Labels: map[string]string{"ci.job": "1"}})
+	engine.AddImage("", nil)
+	server := engine.Serve()
+	defer server.Close()
+	client, err := dockerclient.NewDockerClient(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	future := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
+	for _, c := range []struct {
+		name   string
+		labels map[string]string
+	}{
+		{"job1", map[string]string{"ci.job": "1", TTLLabel: "1h"}},
+		{"job2", map[string]string{"ci.job": "2", ExpiresLabel: future}},
+		{"sidecar", map[string]string{"ci.job": "1", ParentLabel: "job1"}},
+		{"proxy", map[string]string{"ci.job": "1", ParentLabel: "sidecar"}},
+		{"other", map[string]string{TTLLabel: "1h"}},
+	} {
+		config := &dockerclient.ContainerConfig{Image: "busybox", Labels: c.labels}
+		id, err := client.CreateContainer(config, c.name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := client.StartContainer(id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	network, err := client.CreateNetwork(&dockerclient.NetworkCreate{
+		Name:   "job1_net",
+		Labels: map[string]string{"ci.job": "1", ParentLabel: "job1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ConnectNetwork(network.ID, "other"); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []dockerclient.VolumeCreateRequest{
+		{Name: "job1_data", Labels: map[string]string{"ci.job": "1", ExpiresLabel: past}},
+		{Name: "job2_data", Labels: map[string]string{"ci.job": "2"}},
+	} {
+		if _, err := client.CreateVolume(&v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := NewCollector(client, "ci.job")
+	c.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	expected := []string{
+		"container job1", "container sidecar", "container proxy",
+		"network job1_net", "volume job1_data", "image",
+	}
+
+	report, err := c.Collect(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed := kinds(report); !reflect.DeepEqual(removed, expected) || len(report.Failed) > 0 {
+		t.Fatalf("expected %q to be removed, got\n%s", expected, report)
+	}
+	if reason := report.Removed[2].Reason; reason != "parent sidecar is gone" {
+		t.Fatalf("unexpected reason %q", reason)
+	}
+	containers, err := client.ListContainers(true, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 5 {
+		t.Fatalf("expected a dry run to remove nothing, got %d containers", len(containers))
+	}
+
+	report, err = c.Collect(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed := kinds(report); !reflect.DeepEqual(removed, expected) || len(report.Failed) > 0 {
+		t.Fatalf("expected %q to be removed, got\n%s", expected, report)
+	}
+	containers, err = client.ListContainers(true, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 2 {
+		t.Fatalf("expected job2 and other to be kept, got %+v", containers)
+	}
+	networks, err := client.ListNetworks(`{"label":["ci.job"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	volumes, err := client.ListVolumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	images, err := client.ListImages(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 0 || len(volumes) != 1 || volumes[0].Name != "job2_data" || len(images) != 2 {
+		t.Fatalf("unexpected resources left: %+v %+v %+v", networks, volumes, images)
+	}
+}
+
+func TestCollectRemoveFailure(t *testing.T) {
+	engine := fakeengine.New()
+	engine.AddImage("busybox", nil)
+	server := engine.Serve()
+	defer server.Close()
+	client, err := dockerclient.NewDockerClient(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jobID string
+	for _, c := range []struct {
+		name   string
+		labels map[string]string
+	}{
+		{"job1", map[string]string{"ci.job": "1", TTLLabel: "1h"}},
+		{"sidecar", map[string]string{"ci.job": "1", ParentLabel: "job1"}},
+	} {
+		config := &dockerclient.ContainerConfig{Image: "busybox", Labels: c.labels}
+		id, err := client.CreateContainer(config, c.name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.name == "job1" {
+			jobID = id
+		}
+	}
+	if _, err := client.CreateNetwork(&dockerclient.NetworkCreate{
+		Name:   "job1_net",
+		Labels: map[string]string{"ci.job": "1", ParentLabel: "job1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.InjectFailure(fakeengine.Failure{Method: "DELETE", Path: "^/containers/" + jobID + "$", Message: "busy"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCollector(client, "ci.job")
+	c.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	report, err := c.Collect(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// job1 is still there, so its sidecar and network aren't orphaned
+	if len(report.Removed) != 0 || len(report.Failed) != 1 || report.Failed[0].Name != "job1" {
+		t.Fatalf("expected only job1 to fail, got\n%s", report)
+	}
+	containers, err := client.ListContainers(true, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 2 {
+		t.Fatalf("expected the sidecar to be kept, got %+v", containers)
+	}
+	if _, err := client.InspectNetwork("job1_net"); err != nil {
+		t.Fatalf("expected job1_net to be kept, got %v", err)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	c := &Collector{DefaultTTL: 24 * time.Hour, now: func() time.Time { return now }}
+	for _, test := range []struct {
+		labels  map[string]string
+		created time.Time
+		reason  string
+	}{
+		{map[string]string{TTLLabel: "1h"}, now.Add(-2 * time.Hour), "older than 1h0m0s"},
+		{map[string]string{TTLLabel: "1h"}, now.Add(-time.Minute), ""},
+		{map[string]string{TTLLabel: "soon"}, now.Add(-48 * time.Hour), ""},
+		{map[string]string{TTLLabel: "1h"}, time.Time{}, ""},
+		{nil, now.Add(-48 * time.Hour), "older than 24h0m0s"},
+		{map[string]string{ExpiresLabel: "2020-06-01T11:00:00Z"}, now, "expired 2020-06-01T11:00:00Z"},
+		{map[string]string{ExpiresLabel: "1591012800"}, now.Add(-48 * time.Hour), ""},
+	} {
+		if reason := c.expired(test.labels, test.created); reason != test.reason {
+			t.Errorf("%v created %s: expected %q, got %q", test.labels, test.created, test.reason, reason)
+		}
+	}
+}
+
+func kinds(report *Report) []string {
+	var ret []string
+	for _, res := range report.Removed {
+		s := string(res.Kind)
+		if res.Kind != KindImage {
+			s += " " + res.Name
+		}
+		ret = append(ret, s)
+	}
+	return ret
+}
@@ -0,0 +1,98 @@
+package dockerclient
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNoHealthcheck       = errors.New("Container has no healthcheck")
+	ErrContainerNotRunning = errors.New("Container is not running")
+	ErrHealthTimeout       = errors.New("Timed out waiting for the container to become healthy")
+
+	// healthPollInterval is how often WaitForHealthy inspects the container
+	// in case a health_status event was missed
+	healthPollInterval = time.Second
+)
+
+// WaitForHealthy blocks until the healthcheck of container id reports it
+// healthy. It follows health_status events and polls InspectContainer as a
+// fallback, so it still works if the events stream is unavailable.
+//
+// It returns ErrNoHealthcheck if the container has no healthcheck,
+// ErrContainerNotRunning if the container stops or is removed, and
+// ErrHealthTimeout if it isn't healthy after timeout. An unhealthy
+// container doesn't end the wait, since it may recover before the timeout.
+func WaitForHealthy(client Client, id string, timeout time.Duration) error {
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+
+	// subscribe first, so that a transition happening during the initial
+	// inspect isn't missed
+	events, err := client.MonitorEvents(&MonitorEventsOptions{
+		Filters: &MonitorEventsFilters{
+			Type:      ContainerEventType,
+			Container: id,
+		},
+	}, stopChan)
+	if err != nil {
+		events = nil
+	}
+
+	if done, err := checkHealth(client, id); done {
+		return err
+	}
+
+	ticker := time.NewTicker(healthPollInterval)
+	defer ticker.Stop()
+	deadline := time.After(timeout)
+
+	for {
+		select {
+		case <-deadline:
+			return ErrHealthTimeout
+		case e, ok := <-events:
+			if !ok || e.Error != nil {
+				// fall back to polling only
+				events = nil
+				continue
+			}
+			switch e.BaseAction() {
+			case ActionHealthStatus:
+				if e.HealthStatus() == HealthHealthy {
+					return nil
+				}
+			case ActionDie, ActionStop, ActionKill, ActionDestroy:
+				if done, err := checkHealth(client, id); done {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if done, err := checkHealth(client, id); done {
+				return err
+			}
+		}
+	}
+}
+
+// checkHealth inspects the container and reports whether waiting is over,
+// and with which result
+func checkHealth(client Client, id string) (bool, error) {
+	info, err := client.InspectContainer(id)
+	if err != nil {
+		if IsNotFound(err) {
+			return true, ErrContainerNotRunning
+		}
+		return true, err
+	}
+	if info.State == nil || !info.State.Running {
+		return true, ErrContainerNotRunning
+	}
+	if info.State.Health == nil || info.State.Health.Status == HealthNone || info.State.Health.Status == "" {
+		return true, ErrNoHealthcheck
+	}
+	if info.State.Health.Status == HealthHealthy {
+		return true, nil
+	}
+	return false, nil
+}
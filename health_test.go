@@ -0,0 +1,32 @@
+package dockerclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitForHealthy(t *testing.T) {
+	client := testDockerClient(t)
+	defer func(d time.Duration) { healthPollInterval = d }(healthPollInterval)
+	healthPollInterval = 10 * time.Millisecond
+
+	if err := WaitForHealthy(client, "healthy-id", time.Second); err != nil {
+		t.Fatalf("healthy container: %v", err)
+	}
+	if err := WaitForHealthy(client, "starting-id", time.Second); err != nil {
+		t.Fatalf("starting container: %v", err)
+	}
+	if err := WaitForHealthy(client, "nohealth-id", time.Second); err != ErrNoHealthcheck {
+		t.Fatalf("expected ErrNoHealthcheck, got %v", err)
+	}
+	if err := WaitForHealthy(client, "exited-id", time.Second); err != ErrContainerNotRunning {
+		t.Fatalf("expected ErrContainerNotRunning, got %v", err)
+	}
+}
+
+func TestStateStringHealth(t *testing.T) {
+	s := &State{Running: true, StartedAt: time.Now().UTC(), Health: &Health{Status: HealthStarting}}
+	assertEqual(t, s.String(), "Up Less than a second (health: starting)", "")
+	s.Health.Status = HealthUnhealthy
+	assertEqual(t, s.String(), "Up Less than a second (unhealthy)", "")
+}
@@ -1,5 +1,8 @@
 package dockerclient
 
+//go:generate go run ./cmd/clientgen -kind mock -o mockclient/mock.go
+//go:generate go run ./cmd/clientgen -kind nop -o nopclient/nop.go
+
 import (
 	"io"
 )
@@ -31,6 +34,12 @@ type Client interface {
 	RestartContainer(id string, timeout int) error
 	KillContainer(id, signal string) error
 	Wait(id string) <-chan WaitResult
+	// WaitWithOptions waits for a container to reach the condition given
+	// in options and sends the result on the returned channel, which is
+	// buffered so that abandoning it doesn't leak anything. If a stop
+	// channel is provided, closing it cancels the wait and
+	// ErrWaitCanceled is sent.
+	WaitWithOptions(id string, options *WaitOptions, stopChan <-chan struct{}) <-chan WaitResult
 	// MonitorEvents takes options and an optional stop channel, and returns
 	// an EventOrError channel. If an error is ever sent, then no more
 	// events will be sent. If a stop channel is provided, events will stop
@@ -49,6 +58,9 @@ type Client interface {
 	ListImages(all bool) ([]*Image, error)
 	RemoveImage(name string, force bool) ([]*ImageDelete, error)
 	SearchImages(query, registry string, auth *AuthConfig) ([]ImageSearch, error)
+	// Login checks credentials against a registry, the Docker Hub if
+	// serverAddress is empty
+	Login(auth *AuthConfig, serverAddress string) (*AuthResponse, error)
 	PauseContainer(name string) error
 	UnpauseContainer(name string) error
 	RenameContainer(oldName string, newName string) error
@@ -61,6 +73,7 @@ type Client interface {
 	InspectNetwork(id string) (*NetworkResource, error)
 	CreateNetwork(config *NetworkCreate) (*NetworkCreateResponse, error)
 	ConnectNetwork(id, container string) error
+	ConnectNetworkEndpoint(id, container string, settings *EndpointSettings) error
 	DisconnectNetwork(id, container string, force bool) error
 	RemoveNetwork(id string) error
 }
@@ -0,0 +1,141 @@
+package dockerclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Call is a request of DockerClient on its way to the engine
+type Call struct {
+	// Operation is the name of the Client method sending the request, such
+	// as "CreateContainer"
+	Operation string
+	Request   *http.Request
+	// Body is a copy of the request body, nil when the body is streamed
+	// (LoadImage, BuildImage, ...). Use SetBody to send another one.
+	Body []byte
+}
+
+// SetBody replaces the body of the request
+func (call *Call) SetBody(body []byte) {
+	call.Body = body
+	call.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	call.Request.ContentLength = int64(len(body))
+	call.Request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+// CallHandler sends a call and returns the response of the engine
+type CallHandler func(call *Call) (*http.Response, error)
+
+// Middleware wraps the handler of the calls of a DockerClient. It may change
+// the call before passing it to next, inspect or replace the response, or
+// return a response of its own without calling next at all.
+type Middleware func(next CallHandler) CallHandler
+
+// Use adds middlewares to the chain every request goes through. The first
+// middleware added is the outermost one. Use must not be called while
+// requests are in flight.
+func (client *DockerClient) Use(middlewares ...Middleware) {
+	client.middlewares = append(client.middlewares, middlewares...)
+}
+
+// do sends req for the operation op through the middlewares
+func (client *DockerClient) do(op string, req *http.Request) (*http.Response, error) {
+	call := &Call{Operation: op, Request: req}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			call.Body, _ = ioutil.ReadAll(body)
+			body.Close()
+		}
+	}
+	handler := func(call *Call) (*http.Response, error) {
+		return client.HTTPClient.Do(call.Request)
+	}
+	for i := len(client.middlewares) - 1; i >= 0; i-- {
+		handler = client.middlewares[i](handler)
+	}
+	return handler(call)
+}
+
+// LogEntry describes a call once its response headers are received
+type LogEntry struct {
+	Operation  string
+	Method     string
+	Path       string
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+func (e LogEntry) String() string {
+	s := fmt.Sprintf("operation=%s method=%s path=%q status=%d duration=%s",
+		e.Operation, e.Method, e.Path, e.StatusCode, e.Duration)
+	if e.Err != nil {
+		s += fmt.Sprintf(" error=%q", e.Err.Error())
+	}
+	return s
+}
+
+// LoggingMiddleware passes an entry for every call to logf, for example:
+//
+//	client.Use(dockerclient.LoggingMiddleware(func(e dockerclient.LogEntry) {
+//		log.Println(e)
+//	}))
+func LoggingMiddleware(logf func(LogEntry)) Middleware {
+	return func(next CallHandler) CallHandler {
+		return func(call *Call) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next(call)
+			entry := LogEntry{
+				Operation: call.Operation,
+				Method:    call.Request.Method,
+				Path:      call.Request.URL.RequestURI(),
+				Duration:  time.Since(start),
+				Err:       err,
+			}
+			if resp != nil {
+				entry.StatusCode = resp.StatusCode
+			}
+			logf(entry)
+			return resp, err
+		}
+	}
+}
+
+// TimingMiddleware reports how long every call took to observe. For
+// streams such as MonitorEvents, the duration runs until the response
+// headers are received.
+func TimingMiddleware(observe func(operation string, d time.Duration, err error)) Middleware {
+	return func(next CallHandler) CallHandler {
+		return func(call *Call) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next(call)
+			observe(call.Operation, time.Since(start), err)
+			return resp, err
+		}
+	}
+}
+
+// UserAgentMiddleware sets the User-Agent header of every request
+func UserAgentMiddleware(userAgent string) Middleware {
+	return HeadersMiddleware(map[string]string{"User-Agent": userAgent})
+}
+
+// HeadersMiddleware sets headers on every request, replacing the values
+// set by the client
+func HeadersMiddleware(headers map[string]string) Middleware {
+	return func(next CallHandler) CallHandler {
+		return func(call *Call) (*http.Response, error) {
+			for header, value := range headers {
+				call.Request.Header.Set(header, value)
+			}
+			return next(call)
+		}
+	}
+}
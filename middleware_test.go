@@ -0,0 +1,127 @@
+package dockerclient
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareOperationAndHeaders(t *testing.T) {
+	var userAgent, custom string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent, custom = r.Header.Get("User-Agent"), r.Header.Get("X-Custom")
+		w.Write([]byte(`{"Id": "abc"}`))
+	}))
+	defer server.Close()
+	client, err := NewDockerClient(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []*Call
+	var entries []LogEntry
+	var timed []string
+	client.Use(
+		func(next CallHandler) CallHandler {
+			return func(call *Call) (*http.Response, error) {
+				calls = append(calls, call)
+				return next(call)
+			}
+		},
+		LoggingMiddleware(func(e LogEntry) { entries = append(entries, e) }),
+		TimingMiddleware(func(op string, d time.Duration, err error) { timed = append(timed, op) }),
+		UserAgentMiddleware("test-agent/1.0"),
+		HeadersMiddleware(map[string]string{"X-Custom": "value"}),
+	)
+
+	if _, err := client.CreateContainer(&ContainerConfig{Image: "busybox"}, "web", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0].Operation != "CreateContainer" {
+		t.Fatalf("expected a CreateContainer call, got %+v", calls)
+	}
+	if !bytes.Contains(calls[0].Body, []byte(`"Image":"busybox"`)) {
+		t.Fatalf("expected the call to carry the request body, got %q", calls[0].Body)
+	}
+	if userAgent != "test-agent/1.0" || custom != "value" {
+		t.Fatalf("unexpected headers: User-Agent %q, X-Custom %q", userAgent, custom)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected one log entry, got %+v", entries)
+	}
+	e := entries[0]
+	if e.Operation != "CreateContainer" || e.Method != "POST" || e.StatusCode != 200 || !strings.HasPrefix(e.Path, "/"+APIVersion+"/containers/create") {
+		t.Fatalf("unexpected log entry %+v", e)
+	}
+	if !strings.Contains(e.String(), "operation=CreateContainer") {
+		t.Fatalf("unexpected log line %q", e.String())
+	}
+	if len(timed) != 1 || timed[0] != "CreateContainer" {
+		t.Fatalf("expected CreateContainer to be timed, got %v", timed)
+	}
+
+	// Wait is an operation of its own
+	<-client.Wait("web")
+	<-client.WaitWithOptions("web", nil, nil)
+	if len(timed) != 3 || timed[1] != "Wait" || timed[2] != "WaitWithOptions" {
+		t.Fatalf("expected Wait and WaitWithOptions to be timed, got %v", timed)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	client, err := NewDockerClient("tcp://127.0.0.1:1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Use(func(next CallHandler) CallHandler {
+		return func(call *Call) (*http.Response, error) {
+			if call.Operation != "Version" {
+				return next(call)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     make(http.Header),
+				Body:       ioutil.NopCloser(strings.NewReader(`{"Version": "1.2.3"}`)),
+				Request:    call.Request,
+			}, nil
+		}
+	})
+	version, err := client.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Version != "1.2.3" {
+		t.Fatalf("expected the fabricated version, got %+v", version)
+	}
+	if _, err := client.Info(); err == nil {
+		t.Fatal("expected Info to reach the unreachable engine and fail")
+	}
+}
+
+func TestMiddlewareSetBody(t *testing.T) {
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+	client, err := NewDockerClient(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Use(func(next CallHandler) CallHandler {
+		return func(call *Call) (*http.Response, error) {
+			call.SetBody(bytes.Replace(call.Body, []byte("old"), []byte("new"), 1))
+			return next(call)
+		}
+	})
+	if err := client.StartContainer("abc", &HostConfig{NetworkMode: "old"}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(received, []byte(`"NetworkMode":"new"`)) {
+		t.Fatalf("expected the replaced body to be sent, got %q", received)
+	}
+}
@@ -1,3 +1,5 @@
+// Code generated by clientgen from interface.go. DO NOT EDIT.
+
 package mockclient
 
 import (
@@ -20,7 +22,7 @@ func (client *MockClient) Info() (*dockerclient.Info, error) {
 	return args.Get(0).(*dockerclient.Info), args.Error(1)
 }
 
-func (client *MockClient) ListContainers(all bool, size bool, filters string) ([]dockerclient.Container, error) {
+func (client *MockClient) ListContainers(all, size bool, filters string) ([]dockerclient.Container, error) {
 	args := client.Mock.Called(all, size, filters)
 	return args.Get(0).([]dockerclient.Container), args.Error(1)
 }
@@ -55,9 +57,19 @@ func (client *MockClient) ContainerStats(id string, stopChan <-chan struct{}) (<
 	return args.Get(0).(<-chan dockerclient.StatsOrError), args.Error(1)
 }
 
-func (client *MockClient) AttachContainer(id string, options *dockerclient.AttachOptions) (io.ReadCloser, error) {
-	args := client.Mock.Called(id, options)
-	return args.Get(0).(io.ReadCloser), args.Error(1)
+func (client *MockClient) ExecCreate(config *dockerclient.ExecConfig) (string, error) {
+	args := client.Mock.Called(config)
+	return args.String(0), args.Error(1)
+}
+
+func (client *MockClient) ExecStart(id string, config *dockerclient.ExecConfig) error {
+	args := client.Mock.Called(id, config)
+	return args.Error(0)
+}
+
+func (client *MockClient) ExecResize(id string, width, height int) error {
+	args := client.Mock.Called(id, width, height)
+	return args.Error(0)
 }
 
 func (client *MockClient) StartContainer(id string, config *dockerclient.HostConfig) error {
@@ -65,6 +77,11 @@ func (client *MockClient) StartContainer(id string, config *dockerclient.HostCon
 	return args.Error(0)
 }
 
+func (client *MockClient) AttachContainer(id string, options *dockerclient.AttachOptions) (io.ReadCloser, error) {
+	args := client.Mock.Called(id, options)
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
 func (client *MockClient) StopContainer(id string, timeout int) error {
 	args := client.Mock.Called(id, timeout)
 	return args.Error(0)
@@ -85,6 +102,11 @@ func (client *MockClient) Wait(id string) <-chan dockerclient.WaitResult {
 	return args.Get(0).(<-chan dockerclient.WaitResult)
 }
 
+func (client *MockClient) WaitWithOptions(id string, options *dockerclient.WaitOptions, stopChan <-chan struct{}) <-chan dockerclient.WaitResult {
+	args := client.Mock.Called(id, options, stopChan)
+	return args.Get(0).(<-chan dockerclient.WaitResult)
+}
+
 func (client *MockClient) MonitorEvents(options *dockerclient.MonitorEventsOptions, stopChan <-chan struct{}) (<-chan dockerclient.EventOrError, error) {
 	args := client.Mock.Called(options, stopChan)
 	return args.Get(0).(<-chan dockerclient.EventOrError), args.Error(1)
@@ -98,11 +120,6 @@ func (client *MockClient) StopAllMonitorEvents() {
 	client.Mock.Called()
 }
 
-func (client *MockClient) TagImage(nameOrID string, repo string, tag string, force bool) error {
-	args := client.Mock.Called(nameOrID, repo, tag, force)
-	return args.Error(0)
-}
-
 func (client *MockClient) StartMonitorStats(id string, cb dockerclient.StatCallback, ec chan error, args ...interface{}) {
 	client.Mock.Called(id, cb, ec, args)
 }
@@ -111,6 +128,11 @@ func (client *MockClient) StopAllMonitorStats() {
 	client.Mock.Called()
 }
 
+func (client *MockClient) TagImage(nameOrID string, repo string, tag string, force bool) error {
+	args := client.Mock.Called(nameOrID, repo, tag, force)
+	return args.Error(0)
+}
+
 func (client *MockClient) Version() (*dockerclient.Version, error) {
 	args := client.Mock.Called()
 	return args.Get(0).(*dockerclient.Version), args.Error(1)
@@ -146,11 +168,16 @@ func (client *MockClient) RemoveImage(name string, force bool) ([]*dockerclient.
 	return args.Get(0).([]*dockerclient.ImageDelete), args.Error(1)
 }
 
-func (client *MockClient) SearchImages(query, registry string, authConfig *dockerclient.AuthConfig) ([]dockerclient.ImageSearch, error) {
-	args := client.Mock.Called(query, registry, authConfig)
+func (client *MockClient) SearchImages(query, registry string, auth *dockerclient.AuthConfig) ([]dockerclient.ImageSearch, error) {
+	args := client.Mock.Called(query, registry, auth)
 	return args.Get(0).([]dockerclient.ImageSearch), args.Error(1)
 }
 
+func (client *MockClient) Login(auth *dockerclient.AuthConfig, serverAddress string) (*dockerclient.AuthResponse, error) {
+	args := client.Mock.Called(auth, serverAddress)
+	return args.Get(0).(*dockerclient.AuthResponse), args.Error(1)
+}
+
 func (client *MockClient) PauseContainer(name string) error {
 	args := client.Mock.Called(name)
 	return args.Error(0)
@@ -161,21 +188,6 @@ func (client *MockClient) UnpauseContainer(name string) error {
 	return args.Error(0)
 }
 
-func (client *MockClient) ExecCreate(config *dockerclient.ExecConfig) (string, error) {
-	args := client.Mock.Called(config)
-	return args.String(0), args.Error(1)
-}
-
-func (client *MockClient) ExecStart(id string, config *dockerclient.ExecConfig) error {
-	args := client.Mock.Called(id, config)
-	return args.Error(0)
-}
-
-func (client *MockClient) ExecResize(id string, width, height int) error {
-	args := client.Mock.Called(id, width, height)
-	return args.Error(0)
-}
-
 func (client *MockClient) RenameContainer(oldName string, newName string) error {
 	args := client.Mock.Called(oldName, newName)
 	return args.Error(0)
@@ -226,6 +238,11 @@ func (client *MockClient) ConnectNetwork(id, container string) error {
 	return args.Error(0)
 }
 
+func (client *MockClient) ConnectNetworkEndpoint(id, container string, settings *dockerclient.EndpointSettings) error {
+	args := client.Mock.Called(id, container, settings)
+	return args.Error(0)
+}
+
 func (client *MockClient) DisconnectNetwork(id, container string, force bool) error {
 	args := client.Mock.Called(id, container, force)
 	return args.Error(0)
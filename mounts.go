@@ -0,0 +1,339 @@
+package dockerclient
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/go-units"
+)
+
+var propagations = map[string]bool{
+	"private": true, "rprivate": true, "shared": true, "rshared": true, "slave": true, "rslave": true,
+}
+
+var consistencies = map[string]bool{
+	"consistent": true, "cached": true, "delegated": true, "default": true,
+}
+
+// BindSpec is an entry of HostConfig.Binds, source:target[:options]
+type BindSpec struct {
+	// Source is an absolute path of the host or the name of a volume
+	Source   string
+	Target   string
+	ReadOnly bool
+	// Label is z to relabel the content for SELinux so that it is shared
+	// among containers, Z to keep it private to the container
+	Label       string
+	Propagation string
+	// NoCopy disables copying the content of the target into a new volume
+	NoCopy      bool
+	Consistency string
+}
+
+// ParseBindSpec parses source:target[:options], options being a comma
+// separated list of ro or rw, z or Z, nocopy, a propagation mode and a
+// consistency
+func ParseBindSpec(s string) (*BindSpec, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, errors.New("expected source:target[:options]")
+	}
+	b := &BindSpec{Source: parts[0], Target: parts[1]}
+	if b.Source == "" {
+		return nil, errors.New("source is empty")
+	}
+	if !path.IsAbs(b.Target) {
+		return nil, fmt.Errorf("mount path %q must be absolute", b.Target)
+	}
+	if len(parts) == 2 {
+		return b, nil
+	}
+	var mode string
+	for _, option := range strings.Split(parts[2], ",") {
+		var conflict bool
+		switch {
+		case option == "ro" || option == "rw":
+			conflict, mode = mode != "", option
+			b.ReadOnly = option == "ro"
+		case option == "z" || option == "Z":
+			conflict, b.Label = b.Label != "", option
+		case option == "nocopy":
+			conflict, b.NoCopy = b.NoCopy, true
+		case propagations[option]:
+			conflict, b.Propagation = b.Propagation != "", option
+		case consistencies[option]:
+			conflict, b.Consistency = b.Consistency != "", option
+		default:
+			return nil, fmt.Errorf("invalid option %q", option)
+		}
+		if conflict {
+			return nil, fmt.Errorf("conflicting options in %q", parts[2])
+		}
+	}
+	if b.Propagation != "" && !path.IsAbs(b.Source) {
+		return nil, errors.New("propagation only applies to paths of the host")
+	}
+	return b, nil
+}
+
+func (b *BindSpec) String() string {
+	var options []string
+	if b.ReadOnly {
+		options = append(options, "ro")
+	}
+	for _, option := range []string{b.Label, b.Propagation, b.Consistency} {
+		if option != "" {
+			options = append(options, option)
+		}
+	}
+	if b.NoCopy {
+		options = append(options, "nocopy")
+	}
+	s := b.Source + ":" + b.Target
+	if len(options) > 0 {
+		s += ":" + strings.Join(options, ",")
+	}
+	return s
+}
+
+// ParseMount parses the comma separated key=value fields of docker run
+// --mount, such as type=bind,source=/etc/web,target=/etc/web,readonly
+func ParseMount(s string) (*Mount, error) {
+	m := &Mount{Type: MountTypeVolume}
+	var bind BindOptions
+	var volume VolumeOptions
+	var tmpfs TmpfsOptions
+	for _, field := range strings.Split(s, ",") {
+		parts := strings.SplitN(field, "=", 2)
+		key, value := parts[0], ""
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+		// flags are set without a value
+		flag := func() (bool, error) {
+			if len(parts) == 1 {
+				return true, nil
+			}
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return false, fmt.Errorf("invalid value %q of %s", value, key)
+			}
+			return b, nil
+		}
+		var err error
+		switch key {
+		case "type":
+			m.Type = MountType(value)
+		case "source", "src":
+			m.Source = value
+		case "target", "destination", "dst":
+			m.Target = value
+		case "readonly", "ro":
+			m.ReadOnly, err = flag()
+		case "consistency":
+			m.Consistency = value
+		case "bind-propagation":
+			bind.Propagation = value
+		case "volume-nocopy":
+			volume.NoCopy, err = flag()
+		case "volume-driver":
+			if volume.DriverConfig == nil {
+				volume.DriverConfig = &MountDriver{}
+			}
+			volume.DriverConfig.Name = value
+		case "volume-label", "volume-opt":
+			kv := strings.SplitN(value, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid %s %q, expected key=value", key, value)
+			}
+			if key == "volume-label" {
+				if volume.Labels == nil {
+					volume.Labels = make(map[string]string)
+				}
+				volume.Labels[kv[0]] = kv[1]
+				break
+			}
+			if volume.DriverConfig == nil {
+				volume.DriverConfig = &MountDriver{}
+			}
+			if volume.DriverConfig.Options == nil {
+				volume.DriverConfig.Options = make(map[string]string)
+			}
+			volume.DriverConfig.Options[kv[0]] = kv[1]
+		case "tmpfs-size":
+			if tmpfs.SizeBytes, err = units.RAMInBytes(value); err != nil {
+				err = fmt.Errorf("invalid size %q", value)
+			}
+		case "tmpfs-mode":
+			mode, perr := strconv.ParseUint(value, 8, 32)
+			if perr != nil {
+				err = fmt.Errorf("invalid mode %q", value)
+			}
+			tmpfs.Mode = os.FileMode(mode)
+		default:
+			return nil, fmt.Errorf("unknown field %q", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if bind != (BindOptions{}) {
+		m.BindOptions = &bind
+	}
+	if volume.NoCopy || volume.Labels != nil || volume.DriverConfig != nil {
+		m.VolumeOptions = &volume
+	}
+	if tmpfs != (TmpfsOptions{}) {
+		m.TmpfsOptions = &tmpfs
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// String returns the mount in the syntax of ParseMount
+func (m *Mount) String() string {
+	fields := []string{"type=" + string(m.Type)}
+	if m.Source != "" {
+		fields = append(fields, "source="+m.Source)
+	}
+	fields = append(fields, "target="+m.Target)
+	if m.ReadOnly {
+		fields = append(fields, "readonly")
+	}
+	if m.Consistency != "" {
+		fields = append(fields, "consistency="+m.Consistency)
+	}
+	if o := m.BindOptions; o != nil && o.Propagation != "" {
+		fields = append(fields, "bind-propagation="+o.Propagation)
+	}
+	if o := m.VolumeOptions; o != nil {
+		if o.NoCopy {
+			fields = append(fields, "volume-nocopy")
+		}
+		for _, k := range sortedKeys(o.Labels) {
+			fields = append(fields, "volume-label="+k+"="+o.Labels[k])
+		}
+		if d := o.DriverConfig; d != nil {
+			if d.Name != "" {
+				fields = append(fields, "volume-driver="+d.Name)
+			}
+			for _, k := range sortedKeys(d.Options) {
+				fields = append(fields, "volume-opt="+k+"="+d.Options[k])
+			}
+		}
+	}
+	if o := m.TmpfsOptions; o != nil {
+		if o.SizeBytes != 0 {
+			fields = append(fields, "tmpfs-size="+strconv.FormatInt(o.SizeBytes, 10))
+		}
+		if o.Mode != 0 {
+			fields = append(fields, fmt.Sprintf("tmpfs-mode=%o", o.Mode))
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Validate checks that the target of the mount is absolute and that its
+// source and options fit its type
+func (m *Mount) Validate() error {
+	if m.Target == "" {
+		return errors.New("target is required")
+	}
+	if !path.IsAbs(m.Target) {
+		return fmt.Errorf("mount path %q must be absolute", m.Target)
+	}
+	if m.Consistency != "" && !consistencies[m.Consistency] {
+		return fmt.Errorf("invalid consistency %q", m.Consistency)
+	}
+	if m.BindOptions != nil && m.Type != MountTypeBind {
+		return errors.New("bind options only apply to bind mounts")
+	}
+	if m.VolumeOptions != nil && m.Type != MountTypeVolume {
+		return errors.New("volume options only apply to volume mounts")
+	}
+	if m.TmpfsOptions != nil && m.Type != MountTypeTmpfs {
+		return errors.New("tmpfs options only apply to tmpfs mounts")
+	}
+	switch m.Type {
+	case MountTypeBind:
+		if m.Source == "" {
+			return errors.New("source is required for bind mounts")
+		}
+		if !path.IsAbs(m.Source) {
+			return fmt.Errorf("bind source %q must be absolute", m.Source)
+		}
+		if o := m.BindOptions; o != nil && o.Propagation != "" && !propagations[o.Propagation] {
+			return fmt.Errorf("invalid propagation %q", o.Propagation)
+		}
+	case MountTypeVolume:
+		// an empty source makes an anonymous volume
+	case MountTypeTmpfs:
+		if m.Source != "" {
+			return errors.New("tmpfs mounts take no source")
+		}
+		if o := m.TmpfsOptions; o != nil && o.SizeBytes < 0 {
+			return fmt.Errorf("invalid size %d", o.SizeBytes)
+		}
+	default:
+		return fmt.Errorf("invalid type %q, expected bind, volume or tmpfs", m.Type)
+	}
+	return nil
+}
+
+// Validate checks the port bindings and mounts of the config before they
+// reach the engine: the bindings with ValidatePortBindings, the mounts
+// with Mount.Validate, and that no two mounts, binds and tmpfs mounts
+// included, share a target.
+func (h *HostConfig) Validate() error {
+	if err := ValidatePortBindings(h.PortBindings); err != nil {
+		return err
+	}
+	targets := make(map[string]bool)
+	mounted := func(target string) error {
+		target = path.Clean(target)
+		if targets[target] {
+			return fmt.Errorf("duplicate mount point %s", target)
+		}
+		targets[target] = true
+		return nil
+	}
+	for _, m := range h.Mounts {
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("invalid mount %s: %v", m.Target, err)
+		}
+		if err := mounted(m.Target); err != nil {
+			return err
+		}
+	}
+	for _, bind := range h.Binds {
+		// binds the engine may understand differently, such as Windows
+		// paths, are left to it
+		if b, err := ParseBindSpec(bind); err == nil {
+			if err := mounted(b.Target); err != nil {
+				return err
+			}
+		}
+	}
+	for target := range h.Tmpfs {
+		if err := mounted(target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
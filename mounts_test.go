@@ -0,0 +1,105 @@
+package dockerclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBindSpec(t *testing.T) {
+	bind, err := ParseBindSpec("/srv/web:/usr/share/nginx/html:Z,ro,rshared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &BindSpec{Source: "/srv/web", Target: "/usr/share/nginx/html", ReadOnly: true, Label: "Z", Propagation: "rshared"}
+	if !reflect.DeepEqual(bind, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, bind)
+	}
+	if s := bind.String(); s != "/srv/web:/usr/share/nginx/html:ro,Z,rshared" {
+		t.Fatalf("unexpected bind %s", s)
+	}
+
+	for spec, expected := range map[string]string{
+		"/data":              "expected source:target[:options]",
+		":/data":             "source is empty",
+		"data:data":          `mount path "data" must be absolute`,
+		"data:/data:rx":      `invalid option "rx"`,
+		"data:/data:ro,rw":   `conflicting options in "ro,rw"`,
+		"data:/data:rshared": "propagation only applies to paths of the host",
+	} {
+		if _, err := ParseBindSpec(spec); err == nil || err.Error() != expected {
+			t.Errorf("%s: expected %q, got %v", spec, expected, err)
+		}
+	}
+}
+
+func TestParseMount(t *testing.T) {
+	for _, test := range []struct {
+		spec  string
+		mount Mount
+	}{
+		{
+			"type=bind,source=/etc/web,target=/etc/web,readonly,bind-propagation=rslave",
+			Mount{Type: MountTypeBind, Source: "/etc/web", Target: "/etc/web", ReadOnly: true, BindOptions: &BindOptions{Propagation: "rslave"}},
+		},
+		{
+			"type=volume,source=data,target=/data,volume-nocopy,volume-label=tier=db,volume-driver=local,volume-opt=type=nfs",
+			Mount{Type: MountTypeVolume, Source: "data", Target: "/data", VolumeOptions: &VolumeOptions{
+				NoCopy:       true,
+				Labels:       map[string]string{"tier": "db"},
+				DriverConfig: &MountDriver{Name: "local", Options: map[string]string{"type": "nfs"}},
+			}},
+		},
+		{
+			"type=tmpfs,target=/run,tmpfs-size=67108864,tmpfs-mode=1770",
+			Mount{Type: MountTypeTmpfs, Target: "/run", TmpfsOptions: &TmpfsOptions{SizeBytes: 64 << 20, Mode: 01770}},
+		},
+	} {
+		m, err := ParseMount(test.spec)
+		if err != nil {
+			t.Errorf("%s: %v", test.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(*m, test.mount) {
+			t.Errorf("%s: expected %+v, got %+v", test.spec, test.mount, *m)
+		}
+		if s := m.String(); s != test.spec {
+			t.Errorf("%s: formatted as %s", test.spec, s)
+		}
+	}
+
+	for spec, expected := range map[string]string{
+		"source=data":                      "target is required",
+		"type=image,dst=/x":                `invalid type "image", expected bind, volume or tmpfs`,
+		"type=bind,dst=/x":                 "source is required for bind mounts",
+		"type=bind,src=etc,dst=/x":         `bind source "etc" must be absolute`,
+		"type=tmpfs,src=x,dst=/x":          "tmpfs mounts take no source",
+		"type=volume,dst=/x,tmpfs-size=1m": "tmpfs options only apply to tmpfs mounts",
+		"dst=/x,bind-propagation=shared":   "bind options only apply to bind mounts",
+		"dst=/x,readonly=maybe":            `invalid value "maybe" of readonly`,
+		"dst=/x,volume-label=tier":         `invalid volume-label "tier", expected key=value`,
+		"dst=/x,size=1m":                   `unknown field "size"`,
+	} {
+		if _, err := ParseMount(spec); err == nil || err.Error() != expected {
+			t.Errorf("%s: expected %q, got %v", spec, expected, err)
+		}
+	}
+}
+
+func TestHostConfigValidate(t *testing.T) {
+	h := &HostConfig{
+		Binds:  []string{"data:/data", `C:\data:C:\data`},
+		Mounts: []Mount{{Type: MountTypeVolume, Target: "/cache"}},
+		Tmpfs:  map[string]string{"/run": ""},
+	}
+	if err := h.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	h.Mounts = append(h.Mounts, Mount{Type: MountTypeTmpfs, Target: "/data/"})
+	if err := h.Validate(); err == nil || err.Error() != "duplicate mount point /data" {
+		t.Fatalf("expected a duplicate mount point, got %v", err)
+	}
+	h.Mounts = []Mount{{Type: MountTypeBind, Target: "/etc"}}
+	if err := h.Validate(); err == nil || err.Error() != "invalid mount /etc: source is required for bind mounts" {
+		t.Fatalf("expected an invalid mount, got %v", err)
+	}
+}
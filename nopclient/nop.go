@@ -1,3 +1,5 @@
+// Code generated by clientgen from interface.go. DO NOT EDIT.
+
 package nopclient
 
 import (
@@ -22,7 +24,7 @@ func (client *NopClient) Info() (*dockerclient.Info, error) {
 	return nil, ErrNoEngine
 }
 
-func (client *NopClient) ListContainers(all bool, size bool, filters string) ([]dockerclient.Container, error) {
+func (client *NopClient) ListContainers(all, size bool, filters string) ([]dockerclient.Container, error) {
 	return nil, ErrNoEngine
 }
 
@@ -50,14 +52,26 @@ func (client *NopClient) ContainerStats(id string, stopChan <-chan struct{}) (<-
 	return nil, ErrNoEngine
 }
 
-func (client *NopClient) AttachContainer(id string, options *dockerclient.AttachOptions) (io.ReadCloser, error) {
-	return nil, ErrNoEngine
+func (client *NopClient) ExecCreate(config *dockerclient.ExecConfig) (string, error) {
+	return "", ErrNoEngine
+}
+
+func (client *NopClient) ExecStart(id string, config *dockerclient.ExecConfig) error {
+	return ErrNoEngine
+}
+
+func (client *NopClient) ExecResize(id string, width, height int) error {
+	return ErrNoEngine
 }
 
 func (client *NopClient) StartContainer(id string, config *dockerclient.HostConfig) error {
 	return ErrNoEngine
 }
 
+func (client *NopClient) AttachContainer(id string, options *dockerclient.AttachOptions) (io.ReadCloser, error) {
+	return nil, ErrNoEngine
+}
+
 func (client *NopClient) StopContainer(id string, timeout int) error {
 	return ErrNoEngine
 }
@@ -71,7 +85,15 @@ func (client *NopClient) KillContainer(id, signal string) error {
 }
 
 func (client *NopClient) Wait(id string) <-chan dockerclient.WaitResult {
-	return nil
+	ch := make(chan dockerclient.WaitResult, 1)
+	ch <- dockerclient.WaitResult{Error: ErrNoEngine}
+	return ch
+}
+
+func (client *NopClient) WaitWithOptions(id string, options *dockerclient.WaitOptions, stopChan <-chan struct{}) <-chan dockerclient.WaitResult {
+	ch := make(chan dockerclient.WaitResult, 1)
+	ch <- dockerclient.WaitResult{Error: ErrNoEngine}
+	return ch
 }
 
 func (client *NopClient) MonitorEvents(options *dockerclient.MonitorEventsOptions, stopChan <-chan struct{}) (<-chan dockerclient.EventOrError, error) {
@@ -86,10 +108,6 @@ func (client *NopClient) StopAllMonitorEvents() {
 	return
 }
 
-func (client *NopClient) TagImage(nameOrID string, repo string, tag string, force bool) error {
-	return ErrNoEngine
-}
-
 func (client *NopClient) StartMonitorStats(id string, cb dockerclient.StatCallback, ec chan error, args ...interface{}) {
 	return
 }
@@ -98,6 +116,10 @@ func (client *NopClient) StopAllMonitorStats() {
 	return
 }
 
+func (client *NopClient) TagImage(nameOrID string, repo string, tag string, force bool) error {
+	return ErrNoEngine
+}
+
 func (client *NopClient) Version() (*dockerclient.Version, error) {
 	return nil, ErrNoEngine
 }
@@ -106,7 +128,7 @@ func (client *NopClient) PullImage(name string, auth *dockerclient.AuthConfig) e
 	return ErrNoEngine
 }
 
-func (client *NopClient) PushImage(name, tag string, auth *dockerclient.AuthConfig) error {
+func (client *NopClient) PushImage(name string, tag string, auth *dockerclient.AuthConfig) error {
 	return ErrNoEngine
 }
 
@@ -126,27 +148,19 @@ func (client *NopClient) RemoveImage(name string, force bool) ([]*dockerclient.I
 	return nil, ErrNoEngine
 }
 
-func (client *NopClient) SearchImages(query, registry string, authConfig *dockerclient.AuthConfig) ([]dockerclient.ImageSearch, error) {
+func (client *NopClient) SearchImages(query, registry string, auth *dockerclient.AuthConfig) ([]dockerclient.ImageSearch, error) {
 	return nil, ErrNoEngine
 }
 
-func (client *NopClient) PauseContainer(name string) error {
-	return ErrNoEngine
-}
-
-func (client *NopClient) UnpauseContainer(name string) error {
-	return ErrNoEngine
-}
-
-func (client *NopClient) ExecCreate(config *dockerclient.ExecConfig) (string, error) {
-	return "", ErrNoEngine
+func (client *NopClient) Login(auth *dockerclient.AuthConfig, serverAddress string) (*dockerclient.AuthResponse, error) {
+	return nil, ErrNoEngine
 }
 
-func (client *NopClient) ExecStart(id string, config *dockerclient.ExecConfig) error {
+func (client *NopClient) PauseContainer(name string) error {
 	return ErrNoEngine
 }
 
-func (client *NopClient) ExecResize(id string, width, height int) error {
+func (client *NopClient) UnpauseContainer(name string) error {
 	return ErrNoEngine
 }
 
@@ -190,6 +204,10 @@ func (client *NopClient) ConnectNetwork(id, container string) error {
 	return ErrNoEngine
 }
 
+func (client *NopClient) ConnectNetworkEndpoint(id, container string, settings *dockerclient.EndpointSettings) error {
+	return ErrNoEngine
+}
+
 func (client *NopClient) DisconnectNetwork(id, container string, force bool) error {
 	return ErrNoEngine
 }
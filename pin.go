@@ -0,0 +1,133 @@
+package dockerclient
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PinnedFromLabel is set by PinImage on the containers it pins, to the
+// image reference they were asked for, such as nginx:1.21
+const PinnedFromLabel = "com.github.samalba.dockerclient.pinned-from"
+
+var ErrNoDigest = errors.New("Image has no digest")
+
+// DigestResolver resolves an image reference, such as nginx:1.21, to the
+// digest of its manifest in the registry, as the ResolveDigest method of a
+// registry.Client does
+type DigestResolver func(ref string) (string, error)
+
+// PinOptions configures PinImage
+type PinOptions struct {
+	// Pull pulls the image first, to pin the digest its tag has in the
+	// registry rather than the one of the local image. Images that aren't
+	// present are pulled in any case.
+	Pull bool
+	// Auth is used to pull the image
+	Auth *AuthConfig
+	// Resolver, if set, asks the registry for the digest instead of the
+	// engine, without pulling the image
+	Resolver DigestResolver
+	// Strict fails with ErrNoDigest for images without a digest, such as
+	// those built or loaded locally, which are otherwise left as they are
+	Strict bool
+}
+
+// PinImage rewrites the image of config from a tag to the digest it
+// currently points to, such as nginx@sha256:..., so that the containers
+// created from config run that exact image whatever the tag later points
+// to. The original reference, with the latest tag if it had none, is
+// recorded in the PinnedFromLabel label. Images already referenced by
+// digest are left as they are.
+func PinImage(client Client, config *ContainerConfig, options *PinOptions) error {
+	if options == nil {
+		options = &PinOptions{}
+	}
+	ref := config.Image
+	if strings.Contains(ref, "@") {
+		return nil
+	}
+	// an untagged image stands for its latest tag, and pulling it as is
+	// would pull all of them
+	if imageName(ref) == ref {
+		ref += ":latest"
+	}
+	var digest string
+	var err error
+	if options.Resolver != nil {
+		digest, err = options.Resolver(ref)
+	} else {
+		digest, err = imageDigest(client, ref, options)
+	}
+	if err != nil {
+		return err
+	}
+	if digest == "" {
+		if options.Strict {
+			return fmt.Errorf("%s: %v", ref, ErrNoDigest)
+		}
+		return nil
+	}
+	config.Image = imageName(ref) + "@" + digest
+	if config.Labels == nil {
+		config.Labels = make(map[string]string)
+	}
+	config.Labels[PinnedFromLabel] = ref
+	return nil
+}
+
+// CreatePinnedContainer pins the image of config with PinImage, then
+// creates the container, as CreateContainer does
+func CreatePinnedContainer(client Client, config *ContainerConfig, name string, options *PinOptions) (string, error) {
+	if err := PinImage(client, config, options); err != nil {
+		return "", err
+	}
+	var auth *AuthConfig
+	if options != nil {
+		auth = options.Auth
+	}
+	return client.CreateContainer(config, name, auth)
+}
+
+// imageDigest returns the digest of ref among the RepoDigests of the local
+// image, pulling it first if needed, and an empty string if it has none
+func imageDigest(client Client, ref string, options *PinOptions) (string, error) {
+	if options.Pull {
+		if err := client.PullImage(ref, options.Auth); err != nil {
+			return "", err
+		}
+	}
+	info, err := client.InspectImage(ref)
+	if IsNotFound(err) && !options.Pull {
+		if err := client.PullImage(ref, options.Auth); err != nil {
+			return "", err
+		}
+		info, err = client.InspectImage(ref)
+	}
+	if err != nil {
+		return "", err
+	}
+	// an image pulled from several repositories has a digest in each
+	name := familiarName(imageName(ref))
+	for _, d := range info.RepoDigests {
+		if i := strings.Index(d, "@"); i >= 0 && familiarName(d[:i]) == name {
+			return d[i+1:], nil
+		}
+	}
+	return "", nil
+}
+
+// imageName returns ref without its tag
+func imageName(ref string) string {
+	if i := strings.LastIndex(ref, ":"); i >= 0 && !strings.Contains(ref[i:], "/") {
+		return ref[:i]
+	}
+	return ref
+}
+
+// familiarName strips the implicit registry and namespace of the Docker
+// Hub from name, as the engine does in RepoTags and RepoDigests
+func familiarName(name string) string {
+	name = strings.TrimPrefix(name, "docker.io/")
+	return strings.TrimPrefix(name, "library/")
+}
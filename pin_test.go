@@ -0,0 +1,107 @@
+package dockerclient_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/samalba/dockerclient/fakeengine"
+)
+
+func TestPinImage(t *testing.T) {
+	engine := fakeengine.New()
+	engine.AddImage("nginx:1.21", nil)
+	server := engine.Serve()
+	defer server.Close()
+	client, err := dockerclient.NewDockerClient(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// present images are pinned to their local digest, others are pulled
+	for _, ref := range []string{"nginx:1.21", "docker.io/library/redis:7"} {
+		config := &dockerclient.ContainerConfig{Image: ref}
+		if err := dockerclient.PinImage(client, config, nil); err != nil {
+			t.Fatalf("%s: %v", ref, err)
+		}
+		image, err := client.InspectImage(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := strings.TrimSuffix(ref, ref[strings.LastIndex(ref, ":"):])
+		digest := image.RepoDigests[0][strings.Index(image.RepoDigests[0], "@"):]
+		if config.Image != name+digest || config.Labels[dockerclient.PinnedFromLabel] != ref {
+			t.Fatalf("%s: unexpected image %s pinned from %q", ref, config.Image, config.Labels[dockerclient.PinnedFromLabel])
+		}
+		pinned := config.Image
+		if err := dockerclient.PinImage(client, config, nil); err != nil || config.Image != pinned {
+			t.Fatalf("%s: expected the pinned image to be left as is, got %s %v", ref, config.Image, err)
+		}
+	}
+
+	// imported images have no digest
+	stream, err := client.ImportImage("", "local/app", "dev", bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(stream)
+	stream.Close()
+	config := &dockerclient.ContainerConfig{Image: "local/app:dev"}
+	if err := dockerclient.PinImage(client, config, nil); err != nil || config.Image != "local/app:dev" || config.Labels != nil {
+		t.Fatalf("expected the image to be left as is, got %s %v", config.Image, err)
+	}
+	err = dockerclient.PinImage(client, config, &dockerclient.PinOptions{Strict: true})
+	if err == nil || err.Error() != "local/app:dev: "+dockerclient.ErrNoDigest.Error() {
+		t.Fatalf("expected the image to be refused, got %v", err)
+	}
+
+	id, err := dockerclient.CreatePinnedContainer(client, &dockerclient.ContainerConfig{Image: "nginx:1.21"}, "web", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := client.InspectContainer(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(info.Config.Image, "nginx@sha256:") || info.Config.Labels[dockerclient.PinnedFromLabel] != "nginx:1.21" {
+		t.Fatalf("expected a pinned container, got %s %v", info.Config.Image, info.Config.Labels)
+	}
+}
+
+func TestPinImageResolver(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	var resolved []string
+	options := &dockerclient.PinOptions{Resolver: func(ref string) (string, error) {
+		resolved = append(resolved, ref)
+		if ref == "missing:1" {
+			return "", errors.New("manifest unknown")
+		}
+		return digest, nil
+	}}
+	// the engine isn't asked
+	config := &dockerclient.ContainerConfig{Image: "registry.example.com:5000/app:1.0", Labels: map[string]string{"tier": "front"}}
+	if err := dockerclient.PinImage(nil, config, options); err != nil {
+		t.Fatal(err)
+	}
+	if config.Image != "registry.example.com:5000/app@"+digest || config.Labels["tier"] != "front" {
+		t.Fatalf("unexpected image %s", config.Image)
+	}
+	// untagged images are pinned from their latest tag
+	config = &dockerclient.ContainerConfig{Image: "app"}
+	if err := dockerclient.PinImage(nil, config, options); err != nil {
+		t.Fatal(err)
+	}
+	if config.Image != "app@"+digest || config.Labels[dockerclient.PinnedFromLabel] != "app:latest" || resolved[1] != "app:latest" {
+		t.Fatalf("unexpected image %s pinned from %q", config.Image, config.Labels[dockerclient.PinnedFromLabel])
+	}
+	config = &dockerclient.ContainerConfig{Image: "missing:1"}
+	if err := dockerclient.PinImage(nil, config, options); err == nil || config.Image != "missing:1" {
+		t.Fatalf("expected the resolution to fail, got %s %v", config.Image, err)
+	}
+	if len(resolved) != 3 {
+		t.Fatalf("unexpected resolutions %q", resolved)
+	}
+}
@@ -0,0 +1,125 @@
+package pool
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/samalba/dockerclient"
+)
+
+// NodeError is the error of a single engine of the pool
+type NodeError struct {
+	Node string
+	Err  error
+}
+
+func (e NodeError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Node, e.Err)
+}
+
+// PartialError is returned by the operations run on several engines when
+// some of them failed. The results of the others are returned along with
+// it.
+type PartialError struct {
+	Errors []NodeError
+}
+
+func (e *PartialError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d node(s) failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+func partialError(errs []NodeError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Node < errs[j].Node })
+	return &PartialError{Errors: errs}
+}
+
+// Each calls fn concurrently for every healthy engine. It returns a
+// *PartialError listing the engines for which fn failed and the unhealthy
+// ones, nil if there are none.
+func (p *Pool) Each(fn func(node string, client dockerclient.Client) error) error {
+	clients, errs := p.healthy()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, client := range clients {
+		wg.Add(1)
+		go func(name string, client dockerclient.Client) {
+			defer wg.Done()
+			if err := fn(name, client); err != nil {
+				mu.Lock()
+				errs = append(errs, NodeError{Node: name, Err: err})
+				mu.Unlock()
+			}
+		}(name, client)
+	}
+	wg.Wait()
+	return partialError(errs)
+}
+
+// Container is a container of the engine called Node
+type Container struct {
+	Node string
+	dockerclient.Container
+}
+
+// ListContainers lists the containers of every healthy engine, sorted by
+// engine name. The containers are remembered for routing.
+func (p *Pool) ListContainers(all bool, size bool, filters string) ([]Container, error) {
+	var mu sync.Mutex
+	var ret []Container
+	err := p.Each(func(node string, client dockerclient.Client) error {
+		containers, err := client.ListContainers(all, size, filters)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range containers {
+			ret = append(ret, Container{Node: node, Container: c})
+		}
+		return nil
+	})
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Node < ret[j].Node })
+
+	p.mu.Lock()
+	for _, c := range ret {
+		p.owners[c.Id] = c.Node
+	}
+	p.mu.Unlock()
+	return ret, err
+}
+
+// Image is an image of the engine called Node
+type Image struct {
+	Node string
+	*dockerclient.Image
+}
+
+// ListImages lists the images of every healthy engine, sorted by engine
+// name
+func (p *Pool) ListImages(all bool) ([]Image, error) {
+	var mu sync.Mutex
+	var ret []Image
+	err := p.Each(func(node string, client dockerclient.Client) error {
+		images, err := client.ListImages(all)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, image := range images {
+			ret = append(ret, Image{Node: node, Image: image})
+		}
+		return nil
+	})
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Node < ret[j].Node })
+	return ret, err
+}
@@ -0,0 +1,183 @@
+// Package pool manages a set of named Docker engines.
+//
+// A Pool tracks the health of its engines with periodic Info calls, runs
+// queries such as ListContainers on all the healthy engines at once,
+// attributing every result to its engine, and routes the operations on a
+// container to the engine it lives on:
+//
+//	p := pool.NewPool()
+//	p.Add("node1", client1)
+//	p.Add("node2", client2)
+//	p.CheckHealth()
+//	containers, err := p.ListContainers(true, false, "")
+//	if perr, ok := err.(*pool.PartialError); ok {
+//		// containers holds the results of the other engines
+//	}
+//	err = p.StopContainer(containers[0].Id, 10)
+package pool
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+var (
+	ErrNodeExists         = errors.New("Node already exists")
+	ErrUnknownNode        = errors.New("Unknown node")
+	ErrNodeUnhealthy      = errors.New("Node is unhealthy")
+	ErrAmbiguousContainer = errors.New("Container matches several nodes")
+)
+
+// NodeStatus is the state of an engine of the pool as of its last health
+// check
+type NodeStatus struct {
+	Name    string
+	Healthy bool
+	// Info is the result of the last successful check
+	Info *dockerclient.Info
+	// Err is the error of the last check, nil if it succeeded
+	Err       error
+	LastCheck time.Time
+}
+
+type node struct {
+	client dockerclient.Client
+	status NodeStatus
+}
+
+type Pool struct {
+	mu    sync.RWMutex
+	nodes map[string]*node
+	// owners maps the IDs of the containers seen so far to the name of
+	// their node
+	owners map[string]string
+}
+
+func NewPool() *Pool {
+	return &Pool{
+		nodes:  make(map[string]*node),
+		owners: make(map[string]string),
+	}
+}
+
+// Add adds an engine to the pool. It is considered healthy until a check
+// says otherwise.
+func (p *Pool) Add(name string, client dockerclient.Client) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.nodes[name]; exists {
+		return ErrNodeExists
+	}
+	p.nodes[name] = &node{
+		client: client,
+		status: NodeStatus{Name: name, Healthy: true},
+	}
+	return nil
+}
+
+// Remove removes an engine from the pool
+func (p *Pool) Remove(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.nodes, name)
+	for id, owner := range p.owners {
+		if owner == name {
+			delete(p.owners, id)
+		}
+	}
+}
+
+// Client returns the client of the engine called name
+func (p *Pool) Client(name string) (dockerclient.Client, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	n, ok := p.nodes[name]
+	if !ok {
+		return nil, ErrUnknownNode
+	}
+	return n.client, nil
+}
+
+// Nodes returns the status of every engine, sorted by name
+func (p *Pool) Nodes() []NodeStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	statuses := make([]NodeStatus, 0, len(p.nodes))
+	for _, n := range p.nodes {
+		statuses = append(statuses, n.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// CheckHealth calls Info on every engine concurrently and records which
+// ones answered
+func (p *Pool) CheckHealth() {
+	p.mu.RLock()
+	clients := make(map[string]dockerclient.Client, len(p.nodes))
+	for name, n := range p.nodes {
+		clients[name] = n.client
+	}
+	p.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for name, client := range clients {
+		wg.Add(1)
+		go func(name string, client dockerclient.Client) {
+			defer wg.Done()
+			info, err := client.Info()
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			n, ok := p.nodes[name]
+			if !ok || n.client != client {
+				// removed or replaced meanwhile
+				return
+			}
+			n.status.Healthy = err == nil
+			n.status.Err = err
+			n.status.LastCheck = time.Now()
+			if err == nil {
+				n.status.Info = info
+			}
+		}(name, client)
+	}
+	wg.Wait()
+}
+
+// StartHealthChecks checks the health of the engines every interval until
+// stopChan is closed
+func (p *Pool) StartHealthChecks(interval time.Duration, stopChan <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.CheckHealth()
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// healthy returns the clients of the healthy engines and the errors of the
+// others
+func (p *Pool) healthy() (map[string]dockerclient.Client, []NodeError) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	clients := make(map[string]dockerclient.Client)
+	var errs []NodeError
+	for name, n := range p.nodes {
+		if n.status.Healthy {
+			clients[name] = n.client
+		} else {
+			errs = append(errs, NodeError{Node: name, Err: ErrNodeUnhealthy})
+		}
+	}
+	return clients, errs
+}
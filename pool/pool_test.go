@@ -0,0 +1,148 @@
+package pool
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/samalba/dockerclient/fakeengine"
+)
+
+type testNode struct {
+	engine *fakeengine.Engine
+	server *httptest.Server
+	client *dockerclient.DockerClient
+}
+
+func newTestPool(t *testing.T, names ...string) (*Pool, map[string]*testNode) {
+	p := NewPool()
+	nodes := make(map[string]*testNode)
+	for _, name := range names {
+		engine := fakeengine.New()
+		engine.AddImage("busybox", nil)
+		server := engine.Serve()
+		client, err := dockerclient.NewDockerClient(server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Add(name, client); err != nil {
+			t.Fatal(err)
+		}
+		nodes[name] = &testNode{engine: engine, server: server, client: client}
+	}
+	return p, nodes
+}
+
+func closeNodes(nodes map[string]*testNode) {
+	for _, n := range nodes {
+		n.server.Close()
+	}
+}
+
+func TestPoolHealth(t *testing.T) {
+	p, nodes := newTestPool(t, "node1", "node2")
+	defer closeNodes(nodes)
+	if err := p.Add("node1", nodes["node1"].client); err != ErrNodeExists {
+		t.Fatalf("expected ErrNodeExists, got %v", err)
+	}
+
+	nodes["node2"].server.Close()
+	p.CheckHealth()
+	statuses := p.Nodes()
+	if len(statuses) != 2 || statuses[0].Name != "node1" || statuses[1].Name != "node2" {
+		t.Fatalf("unexpected statuses %+v", statuses)
+	}
+	if !statuses[0].Healthy || statuses[0].Info == nil || statuses[0].LastCheck.IsZero() {
+		t.Fatalf("expected node1 to be healthy, got %+v", statuses[0])
+	}
+	if statuses[1].Healthy || statuses[1].Err == nil {
+		t.Fatalf("expected node2 to be unhealthy, got %+v", statuses[1])
+	}
+}
+
+func TestPoolFanOut(t *testing.T) {
+	p, nodes := newTestPool(t, "node1", "node2", "node3")
+	defer closeNodes(nodes)
+	for _, name := range []string{"node1", "node2", "node2"} {
+		if _, err := p.CreateContainer(name, &dockerclient.ContainerConfig{Image: "busybox"}, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	containers, err := p.ListContainers(true, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 3 || containers[0].Node != "node1" || containers[1].Node != "node2" || containers[2].Node != "node2" {
+		t.Fatalf("unexpected containers %+v", containers)
+	}
+
+	// an engine failing doesn't hide the results of the others
+	nodes["node3"].engine.InjectFailure(fakeengine.Failure{Path: "/containers/json", StatusCode: 500})
+	containers, err = p.ListContainers(true, false, "")
+	perr, ok := err.(*PartialError)
+	if !ok || len(perr.Errors) != 1 || perr.Errors[0].Node != "node3" {
+		t.Fatalf("expected node3 to fail, got %v", err)
+	}
+	if len(containers) != 3 {
+		t.Fatalf("expected the containers of node1 and node2, got %+v", containers)
+	}
+
+	images, err := p.ListImages(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 3 || images[0].Node != "node1" {
+		t.Fatalf("unexpected images %+v", images)
+	}
+}
+
+func TestPoolRouting(t *testing.T) {
+	p, nodes := newTestPool(t, "node1", "node2")
+	defer closeNodes(nodes)
+	id, err := nodes["node2"].client.CreateContainer(&dockerclient.ContainerConfig{Image: "busybox"}, "web", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node, err := p.Locate("web")
+	if err != nil || node != "node2" {
+		t.Fatalf("expected web to be on node2, got %q, %v", node, err)
+	}
+	if err := p.StartContainer(id, nil); err != nil {
+		t.Fatal(err)
+	}
+	info, err := p.InspectContainer(id[:12])
+	if err != nil || !info.State.Running {
+		t.Fatalf("expected the container to run, got %+v, %v", info, err)
+	}
+	if err := p.RemoveContainer(id, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Locate(id); err != dockerclient.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// containers removed by name are forgotten too
+	id, err = p.CreateContainer("node1", &dockerclient.ContainerConfig{Image: "busybox"}, "cache", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.RemoveContainer("cache", true, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.owners[id]; ok {
+		t.Fatalf("expected %s to be forgotten", id)
+	}
+
+	// a name used on several engines is ambiguous
+	if _, err := nodes["node1"].client.CreateContainer(&dockerclient.ContainerConfig{Image: "busybox"}, "db", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nodes["node2"].client.CreateContainer(&dockerclient.ContainerConfig{Image: "busybox"}, "db", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Locate("db"); err != ErrAmbiguousContainer {
+		t.Fatalf("expected ErrAmbiguousContainer, got %v", err)
+	}
+}
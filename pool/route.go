@@ -0,0 +1,173 @@
+package pool
+
+import (
+	"io"
+	"sync"
+
+	"github.com/samalba/dockerclient"
+)
+
+// Locate returns the name of the engine of a container, given its ID, a
+// unique prefix of its ID or its name. Engines are asked with
+// InspectContainer unless the container was already seen by the pool.
+//
+// It returns dockerclient.ErrNotFound if no engine has the container,
+// ErrAmbiguousContainer if several do and a *PartialError if it wasn't found
+// but some engines couldn't be asked.
+func (p *Pool) Locate(id string) (string, error) {
+	node, _, err := p.locate(id)
+	return node, err
+}
+
+// locate returns the name of the engine of container id and its full ID
+func (p *Pool) locate(id string) (string, string, error) {
+	p.mu.RLock()
+	owner, ok := p.owners[id]
+	p.mu.RUnlock()
+	if ok {
+		return owner, id, nil
+	}
+
+	var mu sync.Mutex
+	var found []string
+	var fullID string
+	err := p.Each(func(node string, client dockerclient.Client) error {
+		info, err := client.InspectContainer(id)
+		if dockerclient.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		found = append(found, node)
+		fullID = info.Id
+		mu.Unlock()
+		return nil
+	})
+	switch {
+	case len(found) > 1:
+		return "", "", ErrAmbiguousContainer
+	case len(found) == 0 && err != nil:
+		// the container may be on a failed engine
+		return "", "", err
+	case len(found) == 0:
+		return "", "", dockerclient.ErrNotFound
+	}
+	p.mu.Lock()
+	p.owners[fullID] = found[0]
+	p.mu.Unlock()
+	return found[0], fullID, nil
+}
+
+// forget drops what the pool knows about container id, a full ID
+func (p *Pool) forget(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.owners, id)
+}
+
+// withContainer calls fn with the client of the engine of container id.
+// A container that vanished from the engine the pool remembered is looked
+// for again, in case it was recreated elsewhere.
+func (p *Pool) withContainer(id string, fn func(client dockerclient.Client) error) error {
+	_, err := p.routeContainer(id, fn)
+	return err
+}
+
+// routeContainer is withContainer, also returning the full ID of the
+// container id, which may be a name or a prefix
+func (p *Pool) routeContainer(id string, fn func(client dockerclient.Client) error) (string, error) {
+	p.mu.RLock()
+	_, known := p.owners[id]
+	p.mu.RUnlock()
+
+	node, fullID, err := p.locate(id)
+	if err != nil {
+		return "", err
+	}
+	client, err := p.Client(node)
+	if err != nil {
+		return "", err
+	}
+	err = fn(client)
+	if known && dockerclient.IsNotFound(err) {
+		p.forget(id)
+		if node, fullID, err = p.locate(id); err != nil {
+			return "", err
+		}
+		if client, err = p.Client(node); err != nil {
+			return "", err
+		}
+		return fullID, fn(client)
+	}
+	return fullID, err
+}
+
+// CreateContainer creates a container on the engine called node
+func (p *Pool) CreateContainer(node string, config *dockerclient.ContainerConfig, name string, auth *dockerclient.AuthConfig) (string, error) {
+	client, err := p.Client(node)
+	if err != nil {
+		return "", err
+	}
+	id, err := client.CreateContainer(config, name, auth)
+	if err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	p.owners[id] = node
+	p.mu.Unlock()
+	return id, nil
+}
+
+func (p *Pool) InspectContainer(id string) (*dockerclient.ContainerInfo, error) {
+	var info *dockerclient.ContainerInfo
+	err := p.withContainer(id, func(client dockerclient.Client) (err error) {
+		info, err = client.InspectContainer(id)
+		return
+	})
+	return info, err
+}
+
+func (p *Pool) StartContainer(id string, config *dockerclient.HostConfig) error {
+	return p.withContainer(id, func(client dockerclient.Client) error {
+		return client.StartContainer(id, config)
+	})
+}
+
+func (p *Pool) StopContainer(id string, timeout int) error {
+	return p.withContainer(id, func(client dockerclient.Client) error {
+		return client.StopContainer(id, timeout)
+	})
+}
+
+func (p *Pool) RestartContainer(id string, timeout int) error {
+	return p.withContainer(id, func(client dockerclient.Client) error {
+		return client.RestartContainer(id, timeout)
+	})
+}
+
+func (p *Pool) KillContainer(id, signal string) error {
+	return p.withContainer(id, func(client dockerclient.Client) error {
+		return client.KillContainer(id, signal)
+	})
+}
+
+func (p *Pool) ContainerLogs(id string, options *dockerclient.LogOptions) (io.ReadCloser, error) {
+	var logs io.ReadCloser
+	err := p.withContainer(id, func(client dockerclient.Client) (err error) {
+		logs, err = client.ContainerLogs(id, options)
+		return
+	})
+	return logs, err
+}
+
+func (p *Pool) RemoveContainer(id string, force, volumes bool) error {
+	fullID, err := p.routeContainer(id, func(client dockerclient.Client) error {
+		return client.RemoveContainer(id, force, volumes)
+	})
+	if err == nil {
+		p.forget(fullID)
+	}
+	return err
+}
@@ -0,0 +1,310 @@
+package dockerclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContainerPort is a port of a container and its protocol, written as
+// 80/tcp in the keys of ExposedPorts and PortBindings
+type ContainerPort struct {
+	Port  int
+	Proto string // tcp, udp or sctp
+}
+
+// ParseContainerPort parses port[/protocol], the protocol being tcp by
+// default
+func ParseContainerPort(s string) (ContainerPort, error) {
+	port, proto, err := splitProto(s)
+	if err != nil {
+		return ContainerPort{}, err
+	}
+	n, err := parsePortNumber(port)
+	if err != nil {
+		return ContainerPort{}, err
+	}
+	return ContainerPort{Port: n, Proto: proto}, nil
+}
+
+func (p ContainerPort) String() string {
+	proto := p.Proto
+	if proto == "" {
+		proto = "tcp"
+	}
+	return fmt.Sprintf("%d/%s", p.Port, proto)
+}
+
+// PortRange is a range of ports, First and Last included. A single port
+// is a range of one.
+type PortRange struct {
+	First int
+	Last  int
+}
+
+// ParsePortRange parses a port, or a range of ports such as 8000-8010
+func ParsePortRange(s string) (PortRange, error) {
+	parts := strings.SplitN(s, "-", 2)
+	first, err := parsePortNumber(parts[0])
+	if err != nil {
+		return PortRange{}, err
+	}
+	if len(parts) == 1 {
+		return PortRange{first, first}, nil
+	}
+	last, err := parsePortNumber(parts[1])
+	if err != nil {
+		return PortRange{}, err
+	}
+	if last < first {
+		return PortRange{}, fmt.Errorf("range %s ends before it starts", s)
+	}
+	return PortRange{first, last}, nil
+}
+
+// Len returns the number of ports of the range, 0 for the zero range
+func (r PortRange) Len() int {
+	if r.First == 0 {
+		return 0
+	}
+	return r.Last - r.First + 1
+}
+
+func (r PortRange) String() string {
+	switch {
+	case r.First == 0:
+		return ""
+	case r.First == r.Last:
+		return strconv.Itoa(r.First)
+	}
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+func parsePortNumber(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 65535 {
+		return 0, fmt.Errorf("%q isn't a port number", s)
+	}
+	return n, nil
+}
+
+func splitProto(s string) (string, string, error) {
+	i := strings.LastIndex(s, "/")
+	if i < 0 {
+		return s, "tcp", nil
+	}
+	switch proto := s[i+1:]; proto {
+	case "tcp", "udp", "sctp":
+		return s[:i], proto, nil
+	default:
+		return "", "", fmt.Errorf("invalid protocol %q", proto)
+	}
+}
+
+// PortSpec is a port, or range of ports, of a container published on the
+// host, as given to docker run -p:
+// [[ip:][hostPort[-hostPort]]:]containerPort[-containerPort][/protocol]
+type PortSpec struct {
+	HostIP string
+	// HostPorts is zero to publish on ports picked by the engine. A range
+	// publishing a single container port publishes it on any free port
+	// of the range, otherwise the ranges must have the same length.
+	HostPorts      PortRange
+	ContainerPorts PortRange
+	Proto          string
+}
+
+// ParsePortSpec parses a port published as with docker run -p. IPv6
+// addresses are written in brackets, as in [::1]:80:80.
+func ParsePortSpec(s string) (*PortSpec, error) {
+	spec, proto, err := splitProto(s)
+	if err != nil {
+		return nil, err
+	}
+	var ip string
+	if strings.HasPrefix(spec, "[") {
+		end := strings.Index(spec, "]:")
+		if end < 0 {
+			return nil, errors.New("expected [ipv6]:host:container")
+		}
+		ip, spec = spec[1:end], spec[end+2:]
+		if !strings.Contains(spec, ":") {
+			return nil, errors.New("expected [ipv6]:host:container")
+		}
+	}
+	parts := strings.Split(spec, ":")
+	var hostPorts, containerPorts string
+	switch len(parts) {
+	case 1:
+		containerPorts = parts[0]
+	case 2:
+		hostPorts, containerPorts = parts[0], parts[1]
+	case 3:
+		if ip != "" {
+			return nil, errors.New("expected [ip:][host:]container")
+		}
+		ip, hostPorts, containerPorts = parts[0], parts[1], parts[2]
+	default:
+		return nil, errors.New("expected [ip:][host:]container")
+	}
+	if ip != "" && net.ParseIP(ip) == nil {
+		return nil, fmt.Errorf("%q isn't an IP address", ip)
+	}
+	if containerPorts == "" {
+		return nil, errors.New("no container port")
+	}
+
+	p := &PortSpec{HostIP: ip, Proto: proto}
+	if p.ContainerPorts, err = ParsePortRange(containerPorts); err != nil {
+		return nil, fmt.Errorf("invalid container port: %v", err)
+	}
+	if hostPorts == "" {
+		return p, nil
+	}
+	if p.HostPorts, err = ParsePortRange(hostPorts); err != nil {
+		return nil, fmt.Errorf("invalid host port: %v", err)
+	}
+	if p.ContainerPorts.Len() > 1 && p.ContainerPorts.Len() != p.HostPorts.Len() {
+		return nil, fmt.Errorf("host range %s and container range %s don't have the same length", hostPorts, containerPorts)
+	}
+	return p, nil
+}
+
+func (p *PortSpec) String() string {
+	s := p.ContainerPorts.String()
+	if p.Proto != "" && p.Proto != "tcp" {
+		s += "/" + p.Proto
+	}
+	if p.HostIP == "" && p.HostPorts.Len() == 0 {
+		return s
+	}
+	s = p.HostPorts.String() + ":" + s
+	switch {
+	case strings.Contains(p.HostIP, ":"):
+		s = "[" + p.HostIP + "]:" + s
+	case p.HostIP != "":
+		s = p.HostIP + ":" + s
+	}
+	return s
+}
+
+// Bindings expands the spec into the binding of every container port
+func (p *PortSpec) Bindings() map[ContainerPort]PortBinding {
+	bindings := make(map[ContainerPort]PortBinding)
+	for i := 0; i < p.ContainerPorts.Len(); i++ {
+		port := ContainerPort{Port: p.ContainerPorts.First + i, Proto: p.Proto}
+		binding := PortBinding{HostIp: p.HostIP}
+		switch {
+		case p.HostPorts.Len() == 0:
+		case p.ContainerPorts.Len() == 1:
+			binding.HostPort = p.HostPorts.String()
+		default:
+			binding.HostPort = strconv.Itoa(p.HostPorts.First + i)
+		}
+		bindings[port] = binding
+	}
+	return bindings
+}
+
+// ExposePort adds port to the exposed ports of the container
+func (c *ContainerConfig) ExposePort(port ContainerPort) {
+	if c.ExposedPorts == nil {
+		c.ExposedPorts = make(map[string]struct{})
+	}
+	c.ExposedPorts[port.String()] = struct{}{}
+}
+
+// PublishPort exposes the ports of spec and adds their bindings to the
+// host config
+func (c *ContainerConfig) PublishPort(spec *PortSpec) {
+	h := &c.HostConfig
+	for port, binding := range spec.Bindings() {
+		c.ExposePort(port)
+		if h.PortBindings == nil {
+			h.PortBindings = make(map[string][]PortBinding)
+		}
+		key := port.String()
+		h.PortBindings[key] = append(h.PortBindings[key], binding)
+	}
+}
+
+// PortConflictError is returned when two container ports are bound to the
+// same host port
+type PortConflictError struct {
+	HostIP   string
+	HostPort int
+	Ports    [2]ContainerPort
+}
+
+func (e *PortConflictError) Error() string {
+	host := strconv.Itoa(e.HostPort)
+	if e.HostIP != "" {
+		host = net.JoinHostPort(e.HostIP, host)
+	}
+	return fmt.Sprintf("host port %s/%s is bound to both %s and %s", host, e.Ports[0].Proto, e.Ports[0], e.Ports[1])
+}
+
+// ValidatePortBindings checks that the keys of bindings are container
+// ports and their host ports are valid, and that no host port is bound
+// twice. A port bound on all addresses conflicts with the same port bound
+// on any address. Host port ranges, from which the engine picks a free
+// port, aren't checked for conflicts.
+func ValidatePortBindings(bindings map[string][]PortBinding) error {
+	type bound struct {
+		ip   string
+		port ContainerPort
+	}
+	used := make(map[string][]bound)
+	keys := make([]string, 0, len(bindings))
+	for key := range bindings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		port, err := ParseContainerPort(key)
+		if err != nil {
+			return fmt.Errorf("invalid port %q: %v", key, err)
+		}
+		for _, b := range bindings[key] {
+			if b.HostIp != "" && net.ParseIP(b.HostIp) == nil {
+				return fmt.Errorf("invalid host IP %q of port %s", b.HostIp, key)
+			}
+			if b.HostPort == "" {
+				continue
+			}
+			hostPorts, err := ParsePortRange(b.HostPort)
+			if err != nil {
+				return fmt.Errorf("invalid host port of %s: %v", key, err)
+			}
+			if hostPorts.Len() > 1 {
+				continue
+			}
+			hostPort := fmt.Sprintf("%d/%s", hostPorts.First, port.Proto)
+			for _, other := range used[hostPort] {
+				if other.port != port && overlaps(other.ip, b.HostIp) {
+					ip := b.HostIp
+					if isUnspecified(ip) {
+						ip = other.ip
+					}
+					return &PortConflictError{HostIP: ip, HostPort: hostPorts.First, Ports: [2]ContainerPort{other.port, port}}
+				}
+			}
+			used[hostPort] = append(used[hostPort], bound{b.HostIp, port})
+		}
+	}
+	return nil
+}
+
+func overlaps(ip1, ip2 string) bool {
+	if isUnspecified(ip1) || isUnspecified(ip2) {
+		return true
+	}
+	return net.ParseIP(ip1).Equal(net.ParseIP(ip2))
+}
+
+func isUnspecified(ip string) bool {
+	return ip == "" || net.ParseIP(ip).IsUnspecified()
+}
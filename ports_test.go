@@ -0,0 +1,107 @@
+package dockerclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortSpec(t *testing.T) {
+	for _, test := range []struct {
+		spec     string
+		bindings map[ContainerPort]PortBinding
+	}{
+		{"80", map[ContainerPort]PortBinding{{80, "tcp"}: {}}},
+		{"8080:80", map[ContainerPort]PortBinding{{80, "tcp"}: {HostPort: "8080"}}},
+		{"127.0.0.1::53/udp", map[ContainerPort]PortBinding{{53, "udp"}: {HostIp: "127.0.0.1"}}},
+		{"[::1]:9000-9001:9000-9001/sctp", map[ContainerPort]PortBinding{
+			{9000, "sctp"}: {HostIp: "::1", HostPort: "9000"},
+			{9001, "sctp"}: {HostIp: "::1", HostPort: "9001"},
+		}},
+		{"8000-8010:80", map[ContainerPort]PortBinding{{80, "tcp"}: {HostPort: "8000-8010"}}},
+	} {
+		spec, err := ParsePortSpec(test.spec)
+		if err != nil {
+			t.Errorf("%s: %v", test.spec, err)
+			continue
+		}
+		if s := spec.String(); s != test.spec {
+			t.Errorf("%s: formatted as %s", test.spec, s)
+		}
+		if bindings := spec.Bindings(); !reflect.DeepEqual(bindings, test.bindings) {
+			t.Errorf("%s: expected %v, got %v", test.spec, test.bindings, bindings)
+		}
+	}
+
+	for spec, expected := range map[string]string{
+		"":             "no container port",
+		"80/icmp":      `invalid protocol "icmp"`,
+		"host:80:80":   `"host" isn't an IP address`,
+		"80-79":        "invalid container port: range 80-79 ends before it starts",
+		"0:80":         `invalid host port: "0" isn't a port number`,
+		"80-81:90-92":  "host range 80-81 and container range 90-92 don't have the same length",
+		"[::1]:80":     "expected [ipv6]:host:container",
+		"1:2:3:4":      "expected [ip:][host:]container",
+		"[::1]:1:2:80": "expected [ip:][host:]container",
+	} {
+		if _, err := ParsePortSpec(spec); err == nil || err.Error() != expected {
+			t.Errorf("%q: expected %q, got %v", spec, expected, err)
+		}
+	}
+}
+
+func TestPublishPort(t *testing.T) {
+	config := &ContainerConfig{}
+	for _, s := range []string{"8080:80", "127.0.0.1:8081:80", "53/udp"} {
+		spec, err := ParsePortSpec(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.PublishPort(spec)
+	}
+	bindings := map[string][]PortBinding{
+		"80/tcp": {{HostPort: "8080"}, {HostIp: "127.0.0.1", HostPort: "8081"}},
+		"53/udp": {{}},
+	}
+	if !reflect.DeepEqual(config.HostConfig.PortBindings, bindings) || len(config.ExposedPorts) != 2 {
+		t.Fatalf("unexpected ports %v %v", config.HostConfig.PortBindings, config.ExposedPorts)
+	}
+}
+
+func TestValidatePortBindings(t *testing.T) {
+	for _, test := range []struct {
+		bindings map[string][]PortBinding
+		err      string
+	}{
+		{map[string][]PortBinding{
+			"80/tcp": {{HostPort: "8080"}},
+			"81/tcp": {{HostPort: "8081"}},
+			// other protocols and addresses don't conflict
+			"82/udp": {{HostPort: "8080"}},
+			"83/tcp": {{HostIp: "127.0.0.1", HostPort: "9000"}},
+			"84/tcp": {{HostIp: "127.0.0.2", HostPort: "9000"}},
+			// nor do ranges and ports picked by the engine
+			"85/tcp": {{HostPort: "8080-8090"}, {}},
+		}, ""},
+		{map[string][]PortBinding{
+			"80/tcp": {{HostPort: "8080"}},
+			"81":     {{HostPort: "8080"}},
+		}, "host port 8080/tcp is bound to both 80/tcp and 81/tcp"},
+		{map[string][]PortBinding{
+			"80/tcp": {{HostIp: "127.0.0.1", HostPort: "8080"}},
+			"81/tcp": {{HostIp: "0.0.0.0", HostPort: "8080"}},
+		}, "host port 127.0.0.1:8080/tcp is bound to both 80/tcp and 81/tcp"},
+		{map[string][]PortBinding{"http": {{}}}, `invalid port "http": "http" isn't a port number`},
+		{map[string][]PortBinding{"80/tcp": {{HostPort: "http"}}}, `invalid host port of 80/tcp: "http" isn't a port number`},
+	} {
+		err := ValidatePortBindings(test.bindings)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error %v", test.bindings, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%v: expected %q, got %v", test.bindings, test.err, err)
+		}
+	}
+}
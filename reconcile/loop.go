@@ -0,0 +1,116 @@
+package reconcile
+
+import (
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+// Run reconciles desired right away, then whenever an event shows that an
+// owned container stopped or went away, or that a network or volume was
+// removed, and every resyncPeriod if it isn't zero. It blocks until
+// stopChan is closed.
+//
+// Errors don't stop the loop, they are passed to OnApply along with the
+// plan of every pass.
+func (r *Reconciler) Run(desired *State, resyncPeriod time.Duration, stopChan <-chan struct{}) {
+	trigger := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+	}
+	go r.watch(notify, stopChan)
+	notify()
+
+	var resync <-chan time.Time
+	if resyncPeriod > 0 {
+		ticker := time.NewTicker(resyncPeriod)
+		defer ticker.Stop()
+		resync = ticker.C
+	}
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-trigger:
+			// let a burst of events settle, a single pass handles them all
+			select {
+			case <-time.After(settleDelay):
+			case <-stopChan:
+				return
+			}
+			select {
+			case <-trigger:
+			default:
+			}
+		case <-resync:
+		}
+		plan, err := r.Reconcile(desired, false)
+		if r.OnApply != nil {
+			r.OnApply(plan, err)
+		}
+	}
+}
+
+// watch calls notify for the events that may require a reconciliation,
+// resubscribing if the stream breaks
+func (r *Reconciler) watch(notify func(), stopChan <-chan struct{}) {
+	options := &dockerclient.MonitorEventsOptions{
+		Filters: &dockerclient.MonitorEventsFilters{
+			Types: []dockerclient.EventType{
+				dockerclient.ContainerEventType,
+				dockerclient.NetworkEventType,
+				dockerclient.VolumeEventType,
+			},
+		},
+	}
+	first := true
+	for {
+		events, err := r.client.MonitorEvents(options, stopChan)
+		if err == nil {
+			if !first {
+				// events may have been missed meanwhile
+				notify()
+			}
+			first = false
+			for e := range events {
+				if e.Error != nil {
+					break
+				}
+				if r.drifted(&e.Event) {
+					notify()
+				}
+			}
+		}
+		select {
+		case <-stopChan:
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// drifted tells whether the engine may have drifted from the desired state
+// after event e
+func (r *Reconciler) drifted(e *dockerclient.Event) bool {
+	switch e.Type {
+	case dockerclient.ContainerEventType:
+		if e.Attribute(OwnerLabel) != r.owner {
+			return false
+		}
+		switch e.BaseAction() {
+		case dockerclient.ActionDie, dockerclient.ActionDestroy, dockerclient.ActionRename:
+			return true
+		}
+	case dockerclient.NetworkEventType:
+		switch e.BaseAction() {
+		case dockerclient.ActionDestroy, dockerclient.ActionDisconnect:
+			return true
+		}
+	case dockerclient.VolumeEventType:
+		return e.BaseAction() == dockerclient.ActionDestroy
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+package reconcile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+)
+
+type ActionType string
+
+const (
+	ActionCreate   ActionType = "create"
+	ActionRecreate ActionType = "recreate"
+	ActionStart    ActionType = "start"
+	ActionConnect  ActionType = "connect"
+	ActionRemove   ActionType = "remove"
+)
+
+// Kind is the kind of resource an action applies to
+type Kind string
+
+const (
+	KindContainer Kind = "container"
+	KindNetwork   Kind = "network"
+	KindVolume    Kind = "volume"
+)
+
+// Action is a step of a plan
+type Action struct {
+	Type ActionType
+	Kind Kind
+	Name string
+	// ID is the ID of the existing resource, if any
+	ID string
+	// Network is the network a container is connected to by a connect
+	// action
+	Network string
+	// Reason explains why the action is needed
+	Reason string
+
+	container *ContainerSpec
+	network   *dockerclient.NetworkCreate
+	volume    *dockerclient.VolumeCreateRequest
+}
+
+func (a *Action) String() string {
+	var s string
+	switch a.Type {
+	case ActionConnect:
+		s = fmt.Sprintf("connect container %s to network %s", a.Name, a.Network)
+	default:
+		s = fmt.Sprintf("%s %s %s", a.Type, a.Kind, a.Name)
+	}
+	if a.Reason != "" {
+		s += " (" + a.Reason + ")"
+	}
+	return s
+}
+
+// Plan is the list of actions bringing an engine to a desired state, in
+// the order they are applied
+type Plan struct {
+	Actions []*Action
+}
+
+// Empty tells whether the engine is already in the desired state
+func (p *Plan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+// String returns the actions of the plan, one per line
+func (p *Plan) String() string {
+	var buf bytes.Buffer
+	for _, a := range p.Actions {
+		buf.WriteString(a.String())
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// Plan compares desired with the engine and returns the actions needed to
+// converge. Nothing is changed on the engine.
+//
+// Missing resources are created and owned resources that aren't desired
+// anymore are removed. Containers whose spec changed are recreated, those
+// not running are started and those missing a network are connected to
+// it. Networks and volumes existing with the desired name but not owned
+// are used as they are.
+func (r *Reconciler) Plan(desired *State) (*Plan, error) {
+	specs, err := sortContainers(desired.Containers)
+	if err != nil {
+		return nil, err
+	}
+	filters, err := json.Marshal(map[string][]string{"label": {OwnerLabel + "=" + r.owner}})
+	if err != nil {
+		return nil, err
+	}
+	containers, err := r.client.ListContainers(true, false, string(filters))
+	if err != nil {
+		return nil, err
+	}
+	networks, err := r.client.ListNetworks("")
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := r.client.ListVolumes()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	wanted := make(map[string]bool)
+	for _, spec := range specs {
+		wanted[spec.Name] = true
+	}
+
+	// owned containers not desired anymore go first, they may use names
+	// or ports needed by the others
+	existing := make(map[string]dockerclient.Container)
+	for _, c := range containers {
+		if c.Labels[OwnerLabel] != r.owner || len(c.Names) == 0 {
+			continue
+		}
+		name := strings.TrimPrefix(c.Names[0], "/")
+		if wanted[name] {
+			existing[name] = c
+			continue
+		}
+		plan.Actions = append(plan.Actions, &Action{
+			Type: ActionRemove, Kind: KindContainer, Name: name, ID: c.Id, Reason: "not desired",
+		})
+	}
+
+	// networks and volumes are removed last, once the containers using
+	// them are gone or recreated without them
+	var removals []*Action
+	wantedNetworks := make(map[string]bool)
+	for _, n := range desired.Networks {
+		wantedNetworks[n.Name] = true
+	}
+	existingNetworks := make(map[string]bool)
+	for _, n := range networks {
+		existingNetworks[n.Name] = true
+		if n.Labels[OwnerLabel] == r.owner && !wantedNetworks[n.Name] {
+			removals = append(removals, &Action{
+				Type: ActionRemove, Kind: KindNetwork, Name: n.Name, ID: n.ID, Reason: "not desired",
+			})
+		}
+	}
+	wantedVolumes := make(map[string]bool)
+	for _, v := range desired.Volumes {
+		wantedVolumes[v.Name] = true
+	}
+	existingVolumes := make(map[string]bool)
+	for _, v := range volumes {
+		existingVolumes[v.Name] = true
+		if v.Labels[OwnerLabel] == r.owner && !wantedVolumes[v.Name] {
+			removals = append(removals, &Action{
+				Type: ActionRemove, Kind: KindVolume, Name: v.Name, ID: v.Name, Reason: "not desired",
+			})
+		}
+	}
+
+	for i := range desired.Volumes {
+		v := &desired.Volumes[i]
+		if !existingVolumes[v.Name] {
+			plan.Actions = append(plan.Actions, &Action{Type: ActionCreate, Kind: KindVolume, Name: v.Name, volume: v})
+		}
+	}
+	for i := range desired.Networks {
+		n := &desired.Networks[i]
+		if !existingNetworks[n.Name] {
+			plan.Actions = append(plan.Actions, &Action{Type: ActionCreate, Kind: KindNetwork, Name: n.Name, network: n})
+		}
+	}
+
+	for _, spec := range specs {
+		actions, err := r.planContainer(spec, existing)
+		if err != nil {
+			return nil, err
+		}
+		plan.Actions = append(plan.Actions, actions...)
+	}
+	plan.Actions = append(plan.Actions, removals...)
+	return plan, nil
+}
+
+// planContainer returns the actions converging a single container
+func (r *Reconciler) planContainer(spec *ContainerSpec, existing map[string]dockerclient.Container) ([]*Action, error) {
+	hash, err := spec.hash()
+	if err != nil {
+		return nil, err
+	}
+	c, ok := existing[spec.Name]
+	if !ok {
+		return []*Action{{Type: ActionCreate, Kind: KindContainer, Name: spec.Name, container: spec}}, nil
+	}
+	if c.Labels[ConfigHashLabel] != hash {
+		return []*Action{{
+			Type: ActionRecreate, Kind: KindContainer, Name: spec.Name, ID: c.Id,
+			Reason: "configuration changed", container: spec,
+		}}, nil
+	}
+
+	info, err := r.client.InspectContainer(c.Id)
+	if dockerclient.IsNotFound(err) {
+		return []*Action{{Type: ActionCreate, Kind: KindContainer, Name: spec.Name, container: spec}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var actions []*Action
+	for _, network := range missingNetworks(spec, info) {
+		actions = append(actions, &Action{
+			Type: ActionConnect, Kind: KindContainer, Name: spec.Name, ID: c.Id, Network: network,
+			container: spec,
+		})
+	}
+	if info.State == nil || !info.State.Running {
+		actions = append(actions, &Action{
+			Type: ActionStart, Kind: KindContainer, Name: spec.Name, ID: c.Id, Reason: "not running",
+			container: spec,
+		})
+	}
+	return actions, nil
+}
+
+// missingNetworks returns the networks of spec the container isn't
+// connected to
+func missingNetworks(spec *ContainerSpec, info *dockerclient.ContainerInfo) []string {
+	var missing []string
+	for _, network := range spec.networks() {
+		if _, ok := info.NetworkSettings.Networks[network]; !ok {
+			missing = append(missing, network)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,224 @@
+package reconcile
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/samalba/dockerclient/fakeengine"
+)
+
+func newTestReconciler(t *testing.T) (*Reconciler, *dockerclient.DockerClient, func()) {
+	engine := fakeengine.New()
+	engine.AddImage("busybox", &dockerclient.ContainerConfig{Volumes: map[string]struct{}{"/cache": {}}})
+	engine.AddImage("redis", nil)
+	server := engine.Serve()
+	client, err := dockerclient.NewDockerClient(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewReconciler(client, "test"), client, server.Close
+}
+
+func testState() *State {
+	web := &dockerclient.ContainerConfig{Image: "busybox", Cmd: []string{"httpd"}}
+	web.HostConfig.NetworkMode = "front"
+	web.HostConfig.Links = []string{"db:db"}
+	web.NetworkingConfig.EndpointsConfig = map[string]*dockerclient.EndpointSettings{
+		"front": {Aliases: []string{"www"}},
+		"back":  {Aliases: []string{"api"}},
+	}
+	db := &dockerclient.ContainerConfig{Image: "redis"}
+	db.HostConfig.Binds = []string{"data:/data"}
+	db.NetworkingConfig.EndpointsConfig = map[string]*dockerclient.EndpointSettings{"front": {}}
+	return &State{
+		Containers: []ContainerSpec{
+			{Name: "web", Config: web},
+			{Name: "db", Config: db},
+		},
+		Networks: []dockerclient.NetworkCreate{{Name: "front"}, {Name: "back"}},
+		Volumes:  []dockerclient.VolumeCreateRequest{{Name: "data"}},
+	}
+}
+
+func planLines(plan *Plan) []string {
+	return strings.Split(strings.TrimSpace(plan.String()), "\n")
+}
+
+func assertPlan(t *testing.T, plan *Plan, expected ...string) {
+	if len(expected) == 0 {
+		if !plan.Empty() {
+			t.Fatalf("expected an empty plan, got\n%s", plan)
+		}
+		return
+	}
+	lines := planLines(plan)
+	if len(lines) != len(expected) {
+		t.Fatalf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), plan)
+	}
+	for i := range lines {
+		if lines[i] != expected[i] {
+			t.Fatalf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), plan)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	r, client, stop := newTestReconciler(t)
+	defer stop()
+	desired := testState()
+
+	// a dry run doesn't change anything
+	for i := 0; i < 2; i++ {
+		plan, err := r.Reconcile(desired, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertPlan(t, plan,
+			"create volume data",
+			"create network front",
+			"create network back",
+			"create container db",
+			"create container web",
+		)
+	}
+
+	if _, err := r.Reconcile(desired, false); err != nil {
+		t.Fatal(err)
+	}
+	info, err := client.InspectContainer("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.State.Running || info.Config.Labels[OwnerLabel] != "test" {
+		t.Fatalf("expected web to run with the owner label, got %+v", info)
+	}
+	for network, alias := range map[string]string{"front": "www", "back": "api"} {
+		if endpoint := info.NetworkSettings.Networks[network]; endpoint == nil || endpoint.Aliases[0] != alias {
+			t.Fatalf("expected web to have the alias %s on %s, got %+v", alias, network, info.NetworkSettings.Networks)
+		}
+	}
+	plan, err := r.Plan(desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPlan(t, plan)
+
+	// drift: a stopped container, a changed spec, a removed one
+	if err := client.StopContainer("db", 1); err != nil {
+		t.Fatal(err)
+	}
+	desired.Containers[0].Config.Cmd = []string{"httpd", "-v"}
+	plan, err = r.Plan(desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPlan(t, plan,
+		"start container db (not running)",
+		"recreate container web (configuration changed)",
+	)
+	if err := r.Apply(plan); err != nil {
+		t.Fatal(err)
+	}
+	// the recreated container runs with the anonymous volume of the old one
+	recreated, err := client.InspectContainer("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recreated.Id == info.Id || !recreated.State.Running || len(recreated.Mounts) != 1 || recreated.Mounts[0].Name != info.Mounts[0].Name {
+		t.Fatalf("expected web to be recreated with the volume %s, got %+v", info.Mounts[0].Name, recreated.Mounts)
+	}
+
+	desired.Containers = desired.Containers[1:]
+	desired.Networks = nil
+	desired.Containers[0].Config.NetworkingConfig.EndpointsConfig = nil
+	plan, err = r.Reconcile(desired, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPlan(t, plan,
+		"remove container web (not desired)",
+		"recreate container db (configuration changed)",
+		"remove network back (not desired)",
+		"remove network front (not desired)",
+	)
+	if _, err := client.InspectNetwork("front"); !dockerclient.IsNotFound(err) {
+		t.Fatalf("expected the front network to be removed, got %v", err)
+	}
+}
+
+func TestReconcileUnownedResources(t *testing.T) {
+	r, client, stop := newTestReconciler(t)
+	defer stop()
+	if _, err := client.CreateNetwork(&dockerclient.NetworkCreate{Name: "front"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateContainer(&dockerclient.ContainerConfig{Image: "busybox"}, "other", nil); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := r.Plan(&State{Networks: []dockerclient.NetworkCreate{{Name: "front"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPlan(t, plan)
+}
+
+func TestDependencyCycle(t *testing.T) {
+	r, _, stop := newTestReconciler(t)
+	defer stop()
+	a := &dockerclient.ContainerConfig{Image: "busybox"}
+	a.HostConfig.VolumesFrom = []string{"b:ro"}
+	_, err := r.Plan(&State{Containers: []ContainerSpec{
+		{Name: "a", Config: a},
+		{Name: "b", Config: &dockerclient.ContainerConfig{Image: "busybox"}, DependsOn: []string{"a"}},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected a dependency cycle, got %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	settleDelay = 10 * time.Millisecond
+	r, client, stop := newTestReconciler(t)
+	defer stop()
+	passes := make(chan *Plan, 10)
+	r.OnApply = func(plan *Plan, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		passes <- plan
+	}
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	go r.Run(testState(), 0, stopChan)
+
+	waitPass := func() *Plan {
+		select {
+		case plan := <-passes:
+			return plan
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a reconciliation")
+		}
+		return nil
+	}
+	if plan := waitPass(); len(plan.Actions) != 5 {
+		t.Fatalf("expected the initial pass to create everything, got\n%s", plan)
+	}
+
+	// removing a container behind the reconciler's back triggers a pass
+	// recreating it
+	if err := client.RemoveContainer("web", true, false); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		plan := waitPass()
+		if !plan.Empty() {
+			assertPlan(t, plan, "create container web")
+			break
+		}
+	}
+	if _, err := client.InspectContainer("web"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,155 @@
+package reconcile
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+var (
+	// reconnectDelay is how long Run waits before resubscribing to the
+	// events stream after it was interrupted
+	reconnectDelay = time.Second
+	// settleDelay is how long Run waits after an event before
+	// reconciling, so that a burst of events triggers a single pass
+	settleDelay = 200 * time.Millisecond
+)
+
+type Reconciler struct {
+	client dockerclient.Client
+	owner  string
+
+	// StopTimeout is the timeout given to StopContainer before removing a
+	// container
+	StopTimeout int
+	// OnApply, if set, is called after every pass of Run with the plan
+	// applied and the error of the pass
+	OnApply func(plan *Plan, err error)
+}
+
+// NewReconciler returns a reconciler managing the resources of client
+// labelled with owner
+func NewReconciler(client dockerclient.Client, owner string) *Reconciler {
+	return &Reconciler{client: client, owner: owner, StopTimeout: 10}
+}
+
+// Reconcile plans the actions converging to desired and applies them,
+// unless dryRun is set. The plan is returned in both cases.
+func (r *Reconciler) Reconcile(desired *State, dryRun bool) (*Plan, error) {
+	plan, err := r.Plan(desired)
+	if err != nil || dryRun {
+		return plan, err
+	}
+	return plan, r.Apply(plan)
+}
+
+// Apply carries out the actions of plan in order, stopping at the first
+// error
+func (r *Reconciler) Apply(plan *Plan) error {
+	for _, a := range plan.Actions {
+		if err := r.apply(a); err != nil {
+			return fmt.Errorf("%s: %v", a, err)
+		}
+	}
+	return nil
+}
+
+func (r *Reconciler) apply(a *Action) error {
+	switch a.Kind {
+	case KindVolume:
+		switch a.Type {
+		case ActionCreate:
+			request := *a.volume
+			request.Labels = withLabels(request.Labels, OwnerLabel, r.owner)
+			_, err := r.client.CreateVolume(&request)
+			return err
+		case ActionRemove:
+			return r.client.RemoveVolume(a.ID)
+		}
+	case KindNetwork:
+		switch a.Type {
+		case ActionCreate:
+			config := *a.network
+			config.Labels = withLabels(config.Labels, OwnerLabel, r.owner)
+			_, err := r.client.CreateNetwork(&config)
+			return err
+		case ActionRemove:
+			return r.client.RemoveNetwork(a.ID)
+		}
+	case KindContainer:
+		switch a.Type {
+		case ActionCreate:
+			return r.createContainer(a.container)
+		case ActionRecreate:
+			return r.recreateContainer(a.ID, a.container)
+		case ActionStart:
+			return r.client.StartContainer(a.ID, nil)
+		case ActionConnect:
+			return r.client.ConnectNetworkEndpoint(a.Network, a.ID, a.container.Config.NetworkingConfig.EndpointsConfig[a.Network])
+		case ActionRemove:
+			return r.removeContainer(a.ID)
+		}
+	}
+	return fmt.Errorf("unsupported action")
+}
+
+// containerConfig returns the config of spec, labelled as managed by r
+func (r *Reconciler) containerConfig(spec *ContainerSpec) (*dockerclient.ContainerConfig, error) {
+	hash, err := spec.hash()
+	if err != nil {
+		return nil, err
+	}
+	config := *spec.Config
+	config.Labels = withLabels(config.Labels, OwnerLabel, r.owner, ConfigHashLabel, hash)
+	return &config, nil
+}
+
+// createContainer creates, connects and starts the container of spec
+func (r *Reconciler) createContainer(spec *ContainerSpec) error {
+	config, err := r.containerConfig(spec)
+	if err != nil {
+		return err
+	}
+	id, err := dockerclient.CreateConnectedContainer(r.client, config, spec.Name, nil)
+	if err != nil {
+		if id != "" {
+			r.client.RemoveContainer(id, true, true)
+		}
+		return err
+	}
+	return r.client.StartContainer(id, nil)
+}
+
+// recreateContainer replaces container id with the container of spec,
+// which keeps its anonymous volumes, and starts it. The old container is
+// restored if the new one can't be created.
+func (r *Reconciler) recreateContainer(id string, spec *ContainerSpec) error {
+	config, err := r.containerConfig(spec)
+	if err != nil {
+		return err
+	}
+	newID, err := dockerclient.RecreateContainer(r.client, id, &dockerclient.RecreateOptions{
+		Config:      config,
+		StopTimeout: r.StopTimeout,
+	})
+	if newID == "" {
+		return err
+	}
+	// the new container is left stopped if the old one was
+	if startErr := r.client.StartContainer(newID, nil); startErr != nil {
+		return startErr
+	}
+	return err
+}
+
+func (r *Reconciler) removeContainer(id string) error {
+	if err := r.client.StopContainer(id, r.StopTimeout); err != nil && !dockerclient.IsNotFound(err) {
+		return err
+	}
+	err := r.client.RemoveContainer(id, true, true)
+	if dockerclient.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,164 @@
+// Package reconcile converges an engine towards a desired set of
+// containers, networks and volumes.
+//
+// The resources created by a Reconciler carry an ownership label, so that
+// it only ever changes or removes its own. Reconciling is done in two
+// steps: Plan compares the desired state with the engine and lists the
+// actions needed, Apply carries them out in dependency order. Printing a
+// plan without applying it is a dry run:
+//
+//	r := reconcile.NewReconciler(client, "myapp")
+//	plan, err := r.Plan(desired)
+//	fmt.Print(plan)
+//	err = r.Apply(plan)
+//
+// Run keeps the engine in the desired state, reconciling again whenever an
+// event shows that it drifted.
+package reconcile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+)
+
+const (
+	// OwnerLabel is set to the owner of the Reconciler on every resource
+	// it creates
+	OwnerLabel = "com.github.samalba.dockerclient.owner"
+	// ConfigHashLabel is set to a hash of the spec of the containers, to
+	// recreate them when it changes
+	ConfigHashLabel = "com.github.samalba.dockerclient.config-hash"
+)
+
+// State is a desired set of resources
+type State struct {
+	Containers []ContainerSpec
+	Networks   []dockerclient.NetworkCreate
+	Volumes    []dockerclient.VolumeCreateRequest
+}
+
+// ContainerSpec is a desired container. It is created with Config, its
+// HostConfig and NetworkingConfig included, and kept running.
+type ContainerSpec struct {
+	Name   string
+	Config *dockerclient.ContainerConfig
+	// DependsOn are the names of the containers to create before this
+	// one. Those referred to by VolumesFrom, Links and a container
+	// NetworkMode are added implicitly.
+	DependsOn []string
+}
+
+// hash identifies the spec, to tell whether a container must be recreated
+func (spec *ContainerSpec) hash() (string, error) {
+	data, err := json.Marshal(spec.Config)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// dependencies returns the names of the containers spec depends on
+func (spec *ContainerSpec) dependencies() []string {
+	deps := append([]string(nil), spec.DependsOn...)
+	hostConfig := spec.Config.HostConfig
+	for _, from := range hostConfig.VolumesFrom {
+		deps = append(deps, strings.SplitN(from, ":", 2)[0])
+	}
+	for _, link := range hostConfig.Links {
+		deps = append(deps, strings.TrimPrefix(strings.SplitN(link, ":", 2)[0], "/"))
+	}
+	if strings.HasPrefix(hostConfig.NetworkMode, "container:") {
+		deps = append(deps, strings.TrimPrefix(hostConfig.NetworkMode, "container:"))
+	}
+	return deps
+}
+
+// networks returns the user defined networks the container must be
+// connected to
+func (spec *ContainerSpec) networks() []string {
+	set := make(map[string]bool)
+	switch mode := spec.Config.HostConfig.NetworkMode; {
+	case mode == "", mode == "default", mode == "bridge", mode == "host", mode == "none",
+		strings.HasPrefix(mode, "container:"):
+	default:
+		set[mode] = true
+	}
+	for name := range spec.Config.NetworkingConfig.EndpointsConfig {
+		set[name] = true
+	}
+	var ret []string
+	for name := range set {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// sortContainers returns the specs in an order where every container comes
+// after its dependencies. Dependencies outside of the state are ignored.
+func sortContainers(specs []ContainerSpec) ([]*ContainerSpec, error) {
+	byName := make(map[string]*ContainerSpec, len(specs))
+	for i := range specs {
+		if _, exists := byName[specs[i].Name]; exists {
+			return nil, fmt.Errorf("container %s is declared twice", specs[i].Name)
+		}
+		byName[specs[i].Name] = &specs[i]
+	}
+
+	var sorted []*ContainerSpec
+	// 0 unvisited, 1 being visited, 2 done
+	state := make(map[string]int)
+	var visit func(spec *ContainerSpec, path []string) error
+	visit = func(spec *ContainerSpec, path []string) error {
+		switch state[spec.Name] {
+		case 1:
+			return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), spec.Name)
+		case 2:
+			return nil
+		}
+		state[spec.Name] = 1
+		deps := spec.dependencies()
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if d, ok := byName[dep]; ok {
+				if err := visit(d, append(path, spec.Name)); err != nil {
+					return err
+				}
+			}
+		}
+		state[spec.Name] = 2
+		sorted = append(sorted, spec)
+		return nil
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(byName[name], nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// withLabels returns a copy of labels with extra added
+func withLabels(labels map[string]string, extra ...string) map[string]string {
+	ret := make(map[string]string, len(labels)+len(extra)/2)
+	for k, v := range labels {
+		ret[k] = v
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		ret[extra[i]] = extra[i+1]
+	}
+	return ret
+}
@@ -0,0 +1,333 @@
+package dockerclient
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var ErrContainerExited = errors.New("Container exited right after starting")
+
+// ConfigFromInfo derives the config a container was created with from its
+// inspected info, leaving out what the engine filled in from image, the
+// config of the image the container was created from: its environment,
+// labels, exposed ports, volumes, command and so on. image may be nil, in
+// which case nothing is left out.
+//
+// The network settings are rebuilt from the networks the container is
+// connected to, the alias the engine gives every container, its short ID,
+// excepted.
+func ConfigFromInfo(info *ContainerInfo, image *ImageInfo) *ContainerConfig {
+	config := &ContainerConfig{}
+	if info.Config != nil {
+		*config = *info.Config
+	}
+	var defaults ContainerConfig
+	if image != nil && image.Config != nil {
+		defaults = *image.Config
+	}
+	shortID := truncateID(info.Id)
+
+	config.Env = subtractList(config.Env, defaults.Env)
+	config.Labels = subtractMap(config.Labels, defaults.Labels)
+	config.ExposedPorts = subtractSet(config.ExposedPorts, defaults.ExposedPorts)
+	config.Volumes = subtractSet(config.Volumes, defaults.Volumes)
+	// an entrypoint of its own resets the command of the image
+	if reflect.DeepEqual(config.Entrypoint, defaults.Entrypoint) {
+		config.Entrypoint = nil
+		if reflect.DeepEqual(config.Cmd, defaults.Cmd) {
+			config.Cmd = nil
+		}
+	}
+	if config.WorkingDir == defaults.WorkingDir {
+		config.WorkingDir = ""
+	}
+	if config.User == defaults.User {
+		config.User = ""
+	}
+	if config.StopSignal == defaults.StopSignal {
+		config.StopSignal = ""
+	}
+	if reflect.DeepEqual(config.Healthcheck, defaults.Healthcheck) {
+		config.Healthcheck = nil
+	}
+	if config.Hostname == shortID {
+		config.Hostname = ""
+	}
+	config.OnBuild = nil
+
+	if info.HostConfig != nil {
+		config.HostConfig = *info.HostConfig
+	}
+	// the engine reports links as /name:/container/alias
+	if links := config.HostConfig.Links; len(links) > 0 {
+		config.HostConfig.Links = make([]string, len(links))
+		for i, link := range links {
+			config.HostConfig.Links[i] = createLink(link)
+		}
+	}
+
+	config.NetworkingConfig = NetworkingConfig{}
+	for name, endpoint := range info.NetworkSettings.Networks {
+		if !isUserDefinedNetwork(name) || endpoint == nil {
+			continue
+		}
+		settings := &EndpointSettings{IPAMConfig: endpoint.IPAMConfig, Links: endpoint.Links}
+		for _, alias := range endpoint.Aliases {
+			if alias != shortID {
+				settings.Aliases = append(settings.Aliases, alias)
+			}
+		}
+		if config.NetworkingConfig.EndpointsConfig == nil {
+			config.NetworkingConfig.EndpointsConfig = make(map[string]*EndpointSettings)
+		}
+		config.NetworkingConfig.EndpointsConfig[name] = settings
+	}
+	return config
+}
+
+// BindAnonymousVolumes binds the anonymous volumes of the container of
+// info, such as those of the VOLUME instructions of its image, by name to
+// the same paths in config, so that the data they hold stays with the
+// container created from config to replace it. Paths config already binds
+// are left alone. Engines before API 1.20 don't report the names of the
+// volumes, theirs are left out.
+func BindAnonymousVolumes(config *ContainerConfig, info *ContainerInfo) {
+	bound := make(map[string]bool)
+	for _, bind := range config.HostConfig.Binds {
+		if b, err := ParseBindSpec(bind); err == nil {
+			bound[b.Target] = true
+		}
+	}
+	for _, m := range config.HostConfig.Mounts {
+		bound[m.Target] = true
+	}
+	for _, m := range info.Mounts {
+		// engines before API 1.25 leave the type out
+		if (m.Type != "" && m.Type != MountTypeVolume) || m.Name == "" || bound[m.Destination] {
+			continue
+		}
+		bind := &BindSpec{Source: m.Name, Target: m.Destination, ReadOnly: !m.RW}
+		config.HostConfig.Binds = append(config.HostConfig.Binds, bind.String())
+	}
+}
+
+// truncateID returns the short form of a container ID, as used for
+// hostnames and aliases
+func truncateID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func createLink(link string) string {
+	parts := strings.SplitN(link, ":", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "/") {
+		return link
+	}
+	alias := parts[1]
+	if i := strings.LastIndex(alias, "/"); i >= 0 {
+		alias = alias[i+1:]
+	}
+	return strings.TrimPrefix(parts[0], "/") + ":" + alias
+}
+
+func isUserDefinedNetwork(name string) bool {
+	switch name {
+	case "", "default", "bridge", "host", "none":
+		return false
+	}
+	return !strings.HasPrefix(name, "container:")
+}
+
+func subtractList(list, defaults []string) []string {
+	if len(defaults) == 0 {
+		return list
+	}
+	inherited := make(map[string]bool)
+	for _, s := range defaults {
+		inherited[s] = true
+	}
+	var ret []string
+	for _, s := range list {
+		if !inherited[s] {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+func subtractMap(m, defaults map[string]string) map[string]string {
+	if len(defaults) == 0 {
+		return m
+	}
+	var ret map[string]string
+	for k, v := range m {
+		if d, ok := defaults[k]; ok && d == v {
+			continue
+		}
+		if ret == nil {
+			ret = make(map[string]string)
+		}
+		ret[k] = v
+	}
+	return ret
+}
+
+func subtractSet(set, defaults map[string]struct{}) map[string]struct{} {
+	if len(defaults) == 0 {
+		return set
+	}
+	var ret map[string]struct{}
+	for k := range set {
+		if _, ok := defaults[k]; ok {
+			continue
+		}
+		if ret == nil {
+			ret = make(map[string]struct{})
+		}
+		ret[k] = struct{}{}
+	}
+	return ret
+}
+
+// RecreateOptions configures RecreateContainer
+type RecreateOptions struct {
+	// Config, when set, is the config of the new container instead of the
+	// one derived from the old container, its anonymous volumes still
+	// being bound into it
+	Config *ContainerConfig
+	// Image replaces the image of the container when set
+	Image string
+	// Update is called with the config, derived or not, to change it
+	// before the new container is created
+	Update func(config *ContainerConfig)
+	// Auth is used to create the new container
+	Auth *AuthConfig
+	// StopTimeout is the number of seconds the old container is given to
+	// stop
+	StopTimeout int
+}
+
+// RecreateError is returned by RecreateContainer when one of its steps
+// fails
+type RecreateError struct {
+	Step string // inspect, stop, rename, create, connect, start or remove
+	Err  error
+	// RollbackErr is the error restoring the old container, nil if it was
+	// restored. It is always nil for the remove step, the new container
+	// being up by then.
+	RollbackErr error
+}
+
+func (e *RecreateError) Error() string {
+	if e.RollbackErr != nil {
+		return fmt.Sprintf("recreate: %s: %v (rollback failed: %v)", e.Step, e.Err, e.RollbackErr)
+	}
+	return fmt.Sprintf("recreate: %s: %v", e.Step, e.Err)
+}
+
+// RecreateContainer replaces a container with a new one created from the
+// same config, as derived by ConfigFromInfo, or from options.Config, with
+// the changes of options applied. The new container keeps the name and the
+// volumes of the old one, anonymous ones included, as well as its networks,
+// network aliases, links and volumes-from unless options.Config says
+// otherwise, and is started if the old one was running. It returns the ID
+// of the new container.
+//
+// The old container is stopped and renamed aside while the new one is
+// created. If the new container can't be created, connected or started,
+// or exits right away, it is removed and the old one is renamed back and
+// restarted. Once the new container runs, the old one is removed, leaving
+// its volumes to the new one.
+func RecreateContainer(client Client, id string, options *RecreateOptions) (string, error) {
+	if options == nil {
+		options = &RecreateOptions{}
+	}
+	info, err := client.InspectContainer(id)
+	if err != nil {
+		return "", &RecreateError{Step: "inspect", Err: err}
+	}
+	var config *ContainerConfig
+	if options.Config != nil {
+		c := *options.Config
+		c.HostConfig.Binds = append([]string(nil), c.HostConfig.Binds...)
+		config = &c
+	} else {
+		image, err := client.InspectImage(info.Image)
+		if err != nil && !IsNotFound(err) {
+			return "", &RecreateError{Step: "inspect", Err: err}
+		}
+		config = ConfigFromInfo(info, image)
+	}
+	if options.Image != "" {
+		config.Image = options.Image
+	}
+	BindAnonymousVolumes(config, info)
+	if options.Update != nil {
+		options.Update(config)
+	}
+
+	name := strings.TrimPrefix(info.Name, "/")
+	running := info.State != nil && info.State.Running
+	if running {
+		if err := client.StopContainer(info.Id, options.StopTimeout); err != nil {
+			return "", &RecreateError{Step: "stop", Err: err}
+		}
+	}
+	aside := fmt.Sprintf("%s_%s_old", name, truncateID(info.Id))
+	if err := client.RenameContainer(info.Id, aside); err != nil {
+		e := &RecreateError{Step: "rename", Err: err}
+		if running {
+			e.RollbackErr = client.StartContainer(info.Id, nil)
+		}
+		return "", e
+	}
+
+	newID := ""
+	rollback := func(step string, err error) (string, error) {
+		e := &RecreateError{Step: step, Err: err}
+		if newID != "" {
+			if err := client.RemoveContainer(newID, true, true); err != nil && !IsNotFound(err) {
+				e.RollbackErr = err
+				return "", e
+			}
+		}
+		if err := client.RenameContainer(info.Id, name); err != nil {
+			e.RollbackErr = err
+			return "", e
+		}
+		if running {
+			e.RollbackErr = client.StartContainer(info.Id, nil)
+		}
+		return "", e
+	}
+
+	newID, err = CreateConnectedContainer(client, config, name, options.Auth)
+	if newID == "" && err != nil {
+		return rollback("create", err)
+	}
+	if err != nil {
+		return rollback("connect", err)
+	}
+	if running {
+		if err := client.StartContainer(newID, nil); err != nil {
+			return rollback("start", err)
+		}
+		started, err := client.InspectContainer(newID)
+		if err != nil {
+			return rollback("start", err)
+		}
+		if started.State == nil || !started.State.Running {
+			return rollback("start", ErrContainerExited)
+		}
+	}
+
+	// the anonymous volumes now belong to the new container
+	if err := client.RemoveContainer(info.Id, true, false); err != nil {
+		return newID, &RecreateError{Step: "remove", Err: err}
+	}
+	return newID, nil
+}
@@ -0,0 +1,160 @@
+package dockerclient_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/samalba/dockerclient/fakeengine"
+)
+
+func TestConfigFromInfo(t *testing.T) {
+	image := &dockerclient.ImageInfo{Config: &dockerclient.ContainerConfig{
+		Env:          []string{"PATH=/usr/bin"},
+		Cmd:          []string{"nginx"},
+		Labels:       map[string]string{"maintainer": "nginx", "tier": "any"},
+		ExposedPorts: map[string]struct{}{"80/tcp": {}},
+		WorkingDir:   "/srv",
+	}}
+	info := &dockerclient.ContainerInfo{
+		Id:   "4fa6e0f0c6786287e131c3852c58a2e01cc697a68231826813597e4994f1d6e2",
+		Name: "/web",
+		Config: &dockerclient.ContainerConfig{
+			Hostname:     "4fa6e0f0c678",
+			Image:        "nginx",
+			Env:          []string{"PATH=/usr/bin", "LEVEL=debug"},
+			Cmd:          []string{"nginx"},
+			Labels:       map[string]string{"maintainer": "nginx", "tier": "front"},
+			ExposedPorts: map[string]struct{}{"80/tcp": {}, "443/tcp": {}},
+			WorkingDir:   "/srv",
+		},
+		HostConfig: &dockerclient.HostConfig{Links: []string{"/db:/web/database"}, VolumesFrom: []string{"data"}},
+	}
+	info.NetworkSettings.Networks = map[string]*dockerclient.EndpointSettings{
+		"bridge": {IPAddress: "172.17.0.2"},
+		"front":  {Aliases: []string{"www", "4fa6e0f0c678"}, IPAddress: "10.0.0.2"},
+	}
+
+	config := dockerclient.ConfigFromInfo(info, image)
+	expected := &dockerclient.ContainerConfig{
+		Image:        "nginx",
+		Env:          []string{"LEVEL=debug"},
+		Labels:       map[string]string{"tier": "front"},
+		ExposedPorts: map[string]struct{}{"443/tcp": {}},
+		HostConfig:   dockerclient.HostConfig{Links: []string{"db:database"}, VolumesFrom: []string{"data"}},
+		NetworkingConfig: dockerclient.NetworkingConfig{EndpointsConfig: map[string]*dockerclient.EndpointSettings{
+			"front": {Aliases: []string{"www"}},
+		}},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("expected\n%+v\ngot\n%+v", expected, config)
+	}
+}
+
+func newRecreateEngine(t *testing.T) (*fakeengine.Engine, *dockerclient.DockerClient, string, func()) {
+	engine := fakeengine.New()
+	engine.AddImage("nginx:1.18", &dockerclient.ContainerConfig{
+		Env:     []string{"PATH=/usr/bin"},
+		Cmd:     []string{"nginx"},
+		Volumes: map[string]struct{}{"/var/cache/nginx": {}},
+	})
+	engine.AddImage("nginx:1.19", &dockerclient.ContainerConfig{Env: []string{"PATH=/usr/local/bin"}, Cmd: []string{"nginx"}})
+	server := engine.Serve()
+	client, err := dockerclient.NewDockerClient(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateNetwork(&dockerclient.NetworkCreate{Name: "front"}); err != nil {
+		t.Fatal(err)
+	}
+	config := &dockerclient.ContainerConfig{Image: "nginx:1.18", Env: []string{"LEVEL=debug"}}
+	config.HostConfig.NetworkMode = "front"
+	config.NetworkingConfig.EndpointsConfig = map[string]*dockerclient.EndpointSettings{"front": {Aliases: []string{"www"}}}
+	id, err := client.CreateContainer(config, "web", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateNetwork(&dockerclient.NetworkCreate{Name: "back"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ConnectNetworkEndpoint("back", id, &dockerclient.EndpointSettings{Aliases: []string{"proxy"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.StartContainer(id, nil); err != nil {
+		t.Fatal(err)
+	}
+	return engine, client, id, server.Close
+}
+
+func TestRecreateContainer(t *testing.T) {
+	_, client, id, stop := newRecreateEngine(t)
+	defer stop()
+	old, err := client.InspectContainer(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newID, err := dockerclient.RecreateContainer(client, "web", &dockerclient.RecreateOptions{
+		Image: "nginx:1.19",
+		Update: func(config *dockerclient.ContainerConfig) {
+			config.Env = append(config.Env, "WORKERS=4")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.InspectContainer(id); !dockerclient.IsNotFound(err) {
+		t.Fatalf("expected the old container to be removed, got %v", err)
+	}
+	info, err := client.InspectContainer("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Id != newID || !info.State.Running || info.Config.Image != "nginx:1.19" {
+		t.Fatalf("expected web to run the new image, got %+v", info)
+	}
+	// the environment of the new image replaces the one of the old
+	env := []string{"PATH=/usr/local/bin", "LEVEL=debug", "WORKERS=4"}
+	if !reflect.DeepEqual(info.Config.Env, env) {
+		t.Fatalf("expected the environment %q, got %q", env, info.Config.Env)
+	}
+	for network, alias := range map[string]string{"front": "www", "back": "proxy"} {
+		endpoint := info.NetworkSettings.Networks[network]
+		if endpoint == nil || !reflect.DeepEqual(endpoint.Aliases, []string{alias}) {
+			t.Fatalf("expected web to keep its alias on %s, got %+v", network, info.NetworkSettings.Networks)
+		}
+	}
+	// the volume of the image keeps its data
+	if len(info.Mounts) != 1 || info.Mounts[0].Name != old.Mounts[0].Name || info.Mounts[0].Destination != "/var/cache/nginx" {
+		t.Fatalf("expected the volume %s to be kept, got %+v", old.Mounts[0].Name, info.Mounts)
+	}
+}
+
+func TestRecreateContainerRollback(t *testing.T) {
+	engine, client, id, stop := newRecreateEngine(t)
+	defer stop()
+	engine.InjectFailure(fakeengine.Failure{
+		Method: "POST", Path: "^/containers/[^/]+/start$",
+		StatusCode: 500, Message: "cannot start", Times: 1,
+	})
+
+	_, err := dockerclient.RecreateContainer(client, id, &dockerclient.RecreateOptions{Image: "nginx:1.19"})
+	rerr, ok := err.(*dockerclient.RecreateError)
+	if !ok || rerr.Step != "start" || rerr.RollbackErr != nil {
+		t.Fatalf("expected a rolled back start error, got %v", err)
+	}
+	info, err := client.InspectContainer("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Id != id || !info.State.Running {
+		t.Fatalf("expected the old container to be back, got %+v", info)
+	}
+	containers, err := client.ListContainers(true, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 1 {
+		t.Fatalf("expected the new container to be removed, got %+v", containers)
+	}
+}
@@ -0,0 +1,96 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// challenge is a WWW-Authenticate header, such as
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+type challenge struct {
+	scheme string
+	params map[string]string
+}
+
+func parseChallenge(header string) (*challenge, error) {
+	scheme, rest := header, ""
+	if i := strings.IndexByte(header, ' '); i >= 0 {
+		scheme, rest = header[:i], header[i+1:]
+	}
+	c := &challenge{scheme: strings.ToLower(scheme), params: make(map[string]string)}
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("invalid challenge %q", header)
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid challenge %q", header)
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else if comma := strings.IndexByte(rest, ','); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		c.params[key] = value
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return c, nil
+}
+
+// token asks the authorization server of the bearer challenge c for a token
+// granting scope, with the credentials of the client if it has some
+func (client *Client) token(c *challenge, scope string) (string, error) {
+	realm, err := url.Parse(c.params["realm"])
+	if err != nil || realm.Scheme == "" {
+		return "", fmt.Errorf("invalid token realm %q", c.params["realm"])
+	}
+	query := realm.Query()
+	if service := c.params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	// the challenge names the scope the request needs, which may be wider
+	// than the one asked for, such as delete
+	if s := c.params["scope"]; s != "" {
+		scope = s
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+	req, err := http.NewRequest("GET", realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if client.auth != nil && client.auth.Username != "" {
+		req.SetBasicAuth(client.auth.Username, client.auth.Password)
+	}
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request to %s failed: %s", realm.Host, resp.Status)
+	}
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", err
+	}
+	if t.Token == "" {
+		t.Token = t.AccessToken
+	}
+	if t.Token == "" {
+		return "", fmt.Errorf("no token in the response of %s", realm.Host)
+	}
+	return t.Token, nil
+}
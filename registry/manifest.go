@@ -0,0 +1,96 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// Media types of the manifests the client accepts
+const (
+	MediaTypeManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOCIManifest  = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex     = "application/vnd.oci.image.index.v1+json"
+)
+
+var acceptedManifests = []string{MediaTypeManifest, MediaTypeManifestList, MediaTypeOCIManifest, MediaTypeOCIIndex}
+
+// Descriptor points to a blob or, in a manifest list, to the manifest of
+// a platform
+type Descriptor struct {
+	MediaType   string            `json:"mediaType,omitempty"`
+	Size        int64             `json:"size"`
+	Digest      string            `json:"digest"`
+	URLs        []string          `json:"urls,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *Platform         `json:"platform,omitempty"`
+}
+
+// Platform is the platform of a manifest in a manifest list
+type Platform struct {
+	Architecture string   `json:"architecture"`
+	OS           string   `json:"os"`
+	OSVersion    string   `json:"os.version,omitempty"`
+	OSFeatures   []string `json:"os.features,omitempty"`
+	Variant      string   `json:"variant,omitempty"`
+}
+
+// Manifest is an image manifest or a manifest list, in either the Docker
+// schema 2 or the OCI format
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Config        *Descriptor       `json:"config,omitempty"`
+	Layers        []Descriptor      `json:"layers,omitempty"`
+	Manifests     []Descriptor      `json:"manifests,omitempty"` // The manifests of a list
+	Annotations   map[string]string `json:"annotations,omitempty"`
+
+	// Digest is the digest of Raw, the manifest as sent by the registry,
+	// which the digest of an image refers to
+	Digest string `json:"-"`
+	Raw    []byte `json:"-"`
+}
+
+// IsList reports whether the manifest is a list of the manifests of the
+// platforms of an image
+func (m *Manifest) IsList() bool {
+	return m.MediaType == MediaTypeManifestList || m.MediaType == MediaTypeOCIIndex
+}
+
+// ForPlatform returns the manifest of os and arch, such as linux and
+// arm64, in the manifest list, and nil if there is none. variant, such as
+// v7 for arm, is ignored when empty.
+func (m *Manifest) ForPlatform(os, arch, variant string) *Descriptor {
+	for i, d := range m.Manifests {
+		p := d.Platform
+		if p == nil || p.OS != os || p.Architecture != arch {
+			continue
+		}
+		if variant == "" || p.Variant == variant {
+			return &m.Manifests[i]
+		}
+	}
+	return nil
+}
+
+func parseManifest(data []byte, mediaType string) (*Manifest, error) {
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.SchemaVersion != 2 {
+		return nil, fmt.Errorf("unsupported manifest schema version %d", m.SchemaVersion)
+	}
+	// OCI manifests may leave their media type to the Content-Type
+	if m.MediaType == "" {
+		m.MediaType = mediaType
+	}
+	m.Digest = digestOf(data)
+	m.Raw = data
+	return m, nil
+}
+
+func digestOf(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
@@ -0,0 +1,105 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultDomain is the domain of images named without one, such as
+	// nginx or library/nginx
+	DefaultDomain = "docker.io"
+	// DefaultURL is the address of the registry of DefaultDomain
+	DefaultURL = "https://registry-1.docker.io"
+)
+
+var (
+	domainRegexp     = regexp.MustCompile(`^[A-Za-z0-9]+(?:[.-][A-Za-z0-9]+)*(?::[0-9]+)?$`)
+	repositoryRegexp = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	tagRegexp        = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	digestRegexp     = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// Reference is an image reference, such as nginx:1.21 or
+// registry.example.com:5000/team/app@sha256:...
+type Reference struct {
+	Domain     string // Such as docker.io or localhost:5000
+	Repository string // Path of the image in the registry, such as library/nginx
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses ref as the engine does: the first component is the
+// domain of the registry when it contains a dot or a port or is localhost,
+// and official images of the Docker Hub are in the library namespace
+func ParseReference(ref string) (*Reference, error) {
+	r := &Reference{}
+	name := ref
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, r.Digest = name[:i], name[i+1:]
+		if !ValidDigest(r.Digest) {
+			return nil, fmt.Errorf("invalid digest %q in %q", r.Digest, ref)
+		}
+	}
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i:], "/") {
+		name, r.Tag = name[:i], name[i+1:]
+		if !tagRegexp.MatchString(r.Tag) {
+			return nil, fmt.Errorf("invalid tag %q in %q", r.Tag, ref)
+		}
+	}
+	r.Domain, r.Repository = DefaultDomain, name
+	if i := strings.Index(name, "/"); i >= 0 {
+		if first := name[:i]; strings.ContainsAny(first, ".:") || first == "localhost" {
+			r.Domain, r.Repository = first, name[i+1:]
+		}
+	}
+	if !domainRegexp.MatchString(r.Domain) {
+		return nil, fmt.Errorf("invalid domain %q in %q", r.Domain, ref)
+	}
+	if r.Domain == DefaultDomain && !strings.Contains(r.Repository, "/") {
+		r.Repository = "library/" + r.Repository
+	}
+	if !repositoryRegexp.MatchString(r.Repository) {
+		return nil, fmt.Errorf("invalid repository %q in %q", r.Repository, ref)
+	}
+	return r, nil
+}
+
+// Name returns the repository with its domain, left out for the Docker Hub
+// like the engine does, such as nginx or localhost:5000/app
+func (r *Reference) Name() string {
+	if r.Domain == DefaultDomain {
+		return strings.TrimPrefix(r.Repository, "library/")
+	}
+	return r.Domain + "/" + r.Repository
+}
+
+// String returns the reference in its shortest form
+func (r *Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// URL returns the address of the registry of the reference
+func (r *Reference) URL() string {
+	if r.Domain == DefaultDomain {
+		return DefaultURL
+	}
+	if strings.HasPrefix(r.Domain, "localhost") || strings.HasPrefix(r.Domain, "127.") {
+		return "http://" + r.Domain
+	}
+	return "https://" + r.Domain
+}
+
+// ValidDigest reports whether digest is a sha256 digest, such as the
+// Docker-Content-Digest of a manifest
+func ValidDigest(digest string) bool {
+	return digestRegexp.MatchString(digest)
+}
@@ -0,0 +1,307 @@
+// Package registry is a client of the Docker Registry HTTP API v2, to list
+// the tags of a repository and fetch or delete its manifests without going
+// through an engine
+package registry
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+var (
+	ErrNotFound     = errors.New("Not found")
+	ErrUnauthorized = errors.New("Unauthorized")
+
+	defaultTimeout = 30 * time.Second
+)
+
+// Error is an error status of the registry, with the errors of its body
+type Error struct {
+	StatusCode int
+	Status     string
+	Errors     []ErrorDetail
+}
+
+// ErrorDetail is an error of the registry, such as MANIFEST_UNKNOWN
+type ErrorDetail struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Detail  interface{} `json:"detail,omitempty"`
+}
+
+func (e *Error) Error() string {
+	var msgs []string
+	for _, d := range e.Errors {
+		msgs = append(msgs, d.Message)
+	}
+	if len(msgs) == 0 {
+		return e.Status
+	}
+	return fmt.Sprintf("%s: %s", e.Status, strings.Join(msgs, ", "))
+}
+
+// Client talks to a registry, authenticating with the token or the
+// credentials of its AuthConfig when the registry challenges it
+type Client struct {
+	URL        *url.URL
+	HTTPClient *http.Client
+	auth       *dockerclient.AuthConfig
+
+	mu     sync.Mutex
+	basic  bool              // The registry asked for basic authentication
+	tokens map[string]string // Bearer tokens by scope
+}
+
+// NewClient returns a client of the registry at registryURL, such as
+// https://registry-1.docker.io or localhost:5000 over HTTPS. auth may be nil
+// for anonymous access. If its RegistryToken is set, it is sent as is, and
+// otherwise tokens are asked for with its username and password.
+func NewClient(registryURL string, auth *dockerclient.AuthConfig, tlsConfig *tls.Config) (*Client, error) {
+	if !strings.Contains(registryURL, "://") {
+		registryURL = "https://" + registryURL
+	}
+	u, err := url.Parse(registryURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	httpClient := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+		Timeout:   defaultTimeout,
+	}
+	return &Client{URL: u, HTTPClient: httpClient, auth: auth, tokens: make(map[string]string)}, nil
+}
+
+// Tags returns the tags of repository, such as library/nginx, following
+// the pages of the registry
+func (client *Client) Tags(repository string) ([]string, error) {
+	tags := []string{}
+	path := fmt.Sprintf("/v2/%s/tags/list", repository)
+	for path != "" {
+		resp, err := client.do("GET", repository, path, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, page.Tags...)
+		path = nextPage(resp.Header.Get("Link"))
+	}
+	return tags, nil
+}
+
+// nextPage returns the path of the next page of a Link header, such as
+// </v2/app/tags/list?last=v1&n=100>; rel="next"
+func nextPage(link string) string {
+	if !strings.Contains(link, `rel="next"`) {
+		return ""
+	}
+	start, end := strings.IndexByte(link, '<'), strings.IndexByte(link, '>')
+	if start < 0 || end < start {
+		return ""
+	}
+	u, err := url.Parse(link[start+1 : end])
+	if err != nil {
+		return ""
+	}
+	return u.RequestURI()
+}
+
+// Manifest returns the manifest of reference, a tag or a digest, in
+// repository. Manifest lists are returned as such: ForPlatform picks the
+// manifest of a platform, which can then be fetched by digest.
+func (client *Client) Manifest(repository, reference string) (*Manifest, error) {
+	resp, err := client.do("GET", repository, manifestPath(repository, reference), map[string]string{
+		"Accept": strings.Join(acceptedManifests, ", "),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	m, err := parseManifest(data, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	if ValidDigest(reference) && m.Digest != reference {
+		return nil, fmt.Errorf("manifest of %s has the digest %s", reference, m.Digest)
+	}
+	return m, nil
+}
+
+// Digest resolves reference, usually a tag, to the digest of its manifest,
+// which is that of its manifest list for multi-platform images
+func (client *Client) Digest(repository, reference string) (string, error) {
+	resp, err := client.do("HEAD", repository, manifestPath(repository, reference), map[string]string{
+		"Accept": strings.Join(acceptedManifests, ", "),
+	})
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if digest := resp.Header.Get("Docker-Content-Digest"); ValidDigest(digest) {
+		return digest, nil
+	}
+	// the header is optional, the manifest has to be hashed then
+	m, err := client.Manifest(repository, reference)
+	if err != nil {
+		return "", err
+	}
+	return m.Digest, nil
+}
+
+// ResolveDigest resolves ref, an image reference such as
+// localhost:5000/app:1.0 whose domain is assumed to be the registry's, to
+// the digest of its manifest, the latest tag standing for a missing one.
+// It is a dockerclient.DigestResolver, for PinImage to ask the registry.
+func (client *Client) ResolveDigest(ref string) (string, error) {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	reference := r.Digest
+	if reference == "" {
+		reference = r.Tag
+	}
+	if reference == "" {
+		reference = "latest"
+	}
+	return client.Digest(r.Repository, reference)
+}
+
+// BlobExists reports whether the blob digest, a layer or a config, is in
+// repository
+func (client *Client) BlobExists(repository, digest string) (bool, error) {
+	resp, err := client.do("HEAD", repository, fmt.Sprintf("/v2/%s/blobs/%s", repository, digest), nil)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// DeleteManifest deletes the manifest digest from repository, along with
+// the tags pointing to it. Registries only delete manifests by digest and
+// when configured to.
+func (client *Client) DeleteManifest(repository, digest string) error {
+	if !ValidDigest(digest) {
+		return fmt.Errorf("invalid digest %q", digest)
+	}
+	resp, err := client.do("DELETE", repository, manifestPath(repository, digest), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func manifestPath(repository, reference string) string {
+	return fmt.Sprintf("/v2/%s/manifests/%s", repository, reference)
+}
+
+// do sends a request about repository, answering the authentication
+// challenge of the registry if it gets one. Error statuses are returned
+// as errors, the body of the response is closed by the caller otherwise.
+func (client *Client) do(method, repository, path string, headers map[string]string) (*http.Response, error) {
+	scope := "repository:" + repository + ":pull"
+	if method == "DELETE" {
+		scope = "repository:" + repository + ":delete"
+	}
+	resp, err := client.send(method, path, headers, scope)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && (client.auth == nil || client.auth.RegistryToken == "") {
+		resp.Body.Close()
+		c, err := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, err
+		}
+		switch c.scheme {
+		case "bearer":
+			token, err := client.token(c, scope)
+			if err != nil {
+				return nil, err
+			}
+			client.mu.Lock()
+			client.tokens[scope] = token
+			client.mu.Unlock()
+		case "basic":
+			if client.auth == nil || client.auth.Username == "" {
+				return nil, ErrUnauthorized
+			}
+			client.mu.Lock()
+			client.basic = true
+			client.mu.Unlock()
+		default:
+			return nil, fmt.Errorf("unsupported authentication scheme %q", c.scheme)
+		}
+		if resp, err = client.send(method, path, headers, scope); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	}
+	e := &Error{StatusCode: resp.StatusCode, Status: resp.Status}
+	if method != "HEAD" {
+		var body struct {
+			Errors []ErrorDetail `json:"errors"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&body) == nil {
+			e.Errors = body.Errors
+		}
+	}
+	return nil, e
+}
+
+func (client *Client) send(method, path string, headers map[string]string, scope string) (*http.Response, error) {
+	req, err := http.NewRequest(method, client.URL.String()+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for header, value := range headers {
+		req.Header.Set(header, value)
+	}
+	client.mu.Lock()
+	token, basic := client.tokens[scope], client.basic
+	client.mu.Unlock()
+	switch {
+	case client.auth != nil && client.auth.RegistryToken != "":
+		req.Header.Set("Authorization", "Bearer "+client.auth.RegistryToken)
+	case token != "":
+		req.Header.Set("Authorization", "Bearer "+token)
+	case basic:
+		req.SetBasicAuth(client.auth.Username, client.auth.Password)
+	}
+	return client.HTTPClient.Do(req)
+}
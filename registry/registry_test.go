@@ -0,0 +1,286 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+)
+
+// testRegistry is a registry holding team/app, behind a token server
+// granting tokens to user:secret only
+type testRegistry struct {
+	*httptest.Server
+	mu        sync.Mutex
+	manifests map[string][]byte // By tag and digest
+	types     map[string]string
+	blobs     map[string]bool
+	tokens    int // Number of tokens given out
+}
+
+func newTestRegistry() *testRegistry {
+	r := &testRegistry{manifests: make(map[string][]byte), types: make(map[string]string), blobs: make(map[string]bool)}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+
+	config := `sha256:` + strings.Repeat("c", 64)
+	layer := `sha256:` + strings.Repeat("1", 64)
+	r.blobs[config], r.blobs[layer] = true, true
+	amd64 := r.put("", MediaTypeManifest, fmt.Sprintf(`{
+		"schemaVersion": 2,
+		"mediaType": %q,
+		"config": {"mediaType": "application/vnd.docker.container.image.v1+json", "size": 1469, "digest": %q},
+		"layers": [{"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip", "size": 2811478, "digest": %q}]
+	}`, MediaTypeManifest, config, layer))
+	arm64 := r.put("", MediaTypeOCIManifest, fmt.Sprintf(`{
+		"schemaVersion": 2,
+		"config": {"mediaType": "application/vnd.oci.image.config.v1+json", "size": 1471, "digest": %q},
+		"layers": []
+	}`, config))
+	r.put("1.0", MediaTypeManifestList, fmt.Sprintf(`{
+		"schemaVersion": 2,
+		"mediaType": %q,
+		"manifests": [
+			{"mediaType": %q, "size": 528, "digest": %q, "platform": {"architecture": "amd64", "os": "linux"}},
+			{"mediaType": %q, "size": 401, "digest": %q, "platform": {"architecture": "arm64", "os": "linux", "variant": "v8"}}
+		]
+	}`, MediaTypeManifestList, MediaTypeManifest, amd64, MediaTypeOCIManifest, arm64))
+	r.put("1.1", MediaTypeManifest, string(r.manifests[amd64]))
+	return r
+}
+
+func (r *testRegistry) put(tag, mediaType, manifest string) string {
+	digest := digestOf([]byte(manifest))
+	for _, ref := range []string{tag, digest} {
+		if ref != "" {
+			r.manifests[ref] = []byte(manifest)
+			r.types[ref] = mediaType
+		}
+	}
+	return digest
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if req.URL.Path == "/token" {
+		if user, password, ok := req.BasicAuth(); !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.tokens++
+		json.NewEncoder(w).Encode(map[string]string{"token": "token-for-" + req.URL.Query().Get("scope")})
+		return
+	}
+
+	const prefix = "/v2/team/app/"
+	scope := "repository:team/app:pull"
+	if req.Method == "DELETE" {
+		scope = "repository:team/app:*"
+	}
+	if req.Header.Get("Authorization") != "Bearer token-for-"+scope && req.Header.Get("Authorization") != "Bearer static" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="%s"`, r.URL, scope))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(req.URL.Path, prefix)
+	switch {
+	case path == "tags/list":
+		// two tags a page
+		if req.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/team/app/tags/list?last=1.0&n=2>; rel="next"`)
+			w.Write([]byte(`{"name": "team/app", "tags": ["0.9", "1.0"]}`))
+			return
+		}
+		w.Write([]byte(`{"name": "team/app", "tags": ["1.1"]}`))
+	case strings.HasPrefix(path, "manifests/"):
+		ref := strings.TrimPrefix(path, "manifests/")
+		manifest, ok := r.manifests[ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": [{"code": "MANIFEST_UNKNOWN", "message": "manifest unknown"}]}`))
+			return
+		}
+		switch req.Method {
+		case "DELETE":
+			if !ValidDigest(ref) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			for k, m := range r.manifests {
+				if string(m) == string(manifest) {
+					delete(r.manifests, k)
+				}
+			}
+			w.WriteHeader(http.StatusAccepted)
+			return
+		case "HEAD":
+			w.Header().Set("Docker-Content-Digest", digestOf(manifest))
+		}
+		// OCI manifests don't have to carry their media type
+		w.Header().Set("Content-Type", r.types[ref])
+		w.Write(manifest)
+	case strings.HasPrefix(path, "blobs/"):
+		if !r.blobs[strings.TrimPrefix(path, "blobs/")] {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestClient(t *testing.T) {
+	r := newTestRegistry()
+	defer r.Close()
+	client, err := NewClient(r.URL, &dockerclient.AuthConfig{Username: "user", Password: "secret"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := client.Tags("team/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"0.9", "1.0", "1.1"}; !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("expected the tags %q, got %q", expected, tags)
+	}
+
+	list, err := client.Manifest("team/app", "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !list.IsList() || len(list.Manifests) != 2 || list.Digest != digestOf(r.manifests["1.0"]) {
+		t.Fatalf("unexpected manifest list %+v", list)
+	}
+	arm := list.ForPlatform("linux", "arm64", "")
+	if arm == nil || arm.Platform.Variant != "v8" || list.ForPlatform("windows", "amd64", "") != nil {
+		t.Fatalf("unexpected manifest of linux/arm64 %+v", arm)
+	}
+	m, err := client.Manifest("team/app", arm.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.IsList() || m.MediaType != MediaTypeOCIManifest || m.Config.Size != 1471 {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+
+	digest, err := client.Digest("team/app", "1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amd := list.ForPlatform("linux", "amd64", ""); digest != amd.Digest {
+		t.Fatalf("expected 1.1 to resolve to %s, got %s", amd.Digest, digest)
+	}
+	var resolve dockerclient.DigestResolver = client.ResolveDigest
+	if resolved, err := resolve(r.URL[len("http://"):] + "/team/app:1.1"); err != nil || resolved != digest {
+		t.Fatalf("expected the reference to resolve to %s, got %s %v", digest, resolved, err)
+	}
+	if _, err := client.Digest("team/app", "2.0"); err != ErrNotFound {
+		t.Fatalf("expected an unknown tag, got %v", err)
+	}
+
+	for blob, expected := range map[string]bool{m.Config.Digest: true, "sha256:" + strings.Repeat("0", 64): false} {
+		if exists, err := client.BlobExists("team/app", blob); err != nil || exists != expected {
+			t.Fatalf("%s: expected %v, got %v %v", blob, expected, exists, err)
+		}
+	}
+
+	if err := client.DeleteManifest("team/app", "1.1"); err == nil {
+		t.Fatal("expected manifests to be deleted by digest only")
+	}
+	if err := client.DeleteManifest("team/app", digest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Manifest("team/app", "1.1"); err != ErrNotFound {
+		t.Fatalf("expected 1.1 to be deleted, got %v", err)
+	}
+	// tokens are asked for once a scope
+	if r.tokens != 2 {
+		t.Fatalf("expected a token to pull and one to delete, got %d", r.tokens)
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	r := newTestRegistry()
+	defer r.Close()
+
+	client, _ := NewClient(r.URL, &dockerclient.AuthConfig{RegistryToken: "static"}, nil)
+	if _, err := client.Tags("team/app"); err != nil {
+		t.Fatal(err)
+	}
+	if r.tokens != 0 {
+		t.Fatalf("expected the registry token to be sent as is, %d tokens were asked for", r.tokens)
+	}
+
+	client, _ = NewClient(r.URL, &dockerclient.AuthConfig{Username: "user", Password: "wrong"}, nil)
+	if _, err := client.Tags("team/app"); err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Fatalf("expected the token request to fail, got %v", err)
+	}
+	client, _ = NewClient(r.URL, &dockerclient.AuthConfig{RegistryToken: "expired"}, nil)
+	if _, err := client.Tags("team/app"); err != ErrUnauthorized {
+		t.Fatalf("expected the registry token to be refused, got %v", err)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	c, err := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull,push"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull,push",
+	}
+	if c.scheme != "bearer" || !reflect.DeepEqual(c.params, expected) {
+		t.Fatalf("unexpected challenge %+v", c)
+	}
+	if c, err = parseChallenge(`Basic realm=registry`); err != nil || c.scheme != "basic" || c.params["realm"] != "registry" {
+		t.Fatalf("unexpected challenge %+v %v", c, err)
+	}
+	if _, err = parseChallenge(`Bearer realm="unterminated`); err == nil {
+		t.Fatal("expected an invalid challenge")
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	for _, test := range []struct {
+		ref      string
+		expected Reference
+		name     string
+	}{
+		{"nginx", Reference{Domain: DefaultDomain, Repository: "library/nginx"}, "nginx"},
+		{"team/app:1.0", Reference{Domain: DefaultDomain, Repository: "team/app", Tag: "1.0"}, "team/app"},
+		{"localhost:5000/app", Reference{Domain: "localhost:5000", Repository: "app"}, "localhost:5000/app"},
+		{"ghcr.io/org/tool:v2@" + digest, Reference{Domain: "ghcr.io", Repository: "org/tool", Tag: "v2", Digest: digest}, "ghcr.io/org/tool"},
+	} {
+		r, err := ParseReference(test.ref)
+		if err != nil {
+			t.Errorf("%s: %v", test.ref, err)
+			continue
+		}
+		if *r != test.expected || r.Name() != test.name || r.String() != test.ref {
+			t.Errorf("%s: unexpected reference %+v named %s", test.ref, *r, r.Name())
+		}
+	}
+	if r, _ := ParseReference("localhost:5000/app"); r.URL() != "http://localhost:5000" {
+		t.Errorf("unexpected URL %s", r.URL())
+	}
+	for ref, expected := range map[string]string{
+		"App":               `invalid repository "library/App" in "App"`,
+		"app:":              `invalid tag "" in "app:"`,
+		"app@sha256:abc":    `invalid digest "sha256:abc" in "app@sha256:abc"`,
+		"example.com/a//b":  `invalid repository "a//b" in "example.com/a//b"`,
+		"app:1.0:extra/tag": `invalid domain "app:1.0:extra" in "app:1.0:extra/tag"`,
+	} {
+		if _, err := ParseReference(ref); err == nil || err.Error() != expected {
+			t.Errorf("%s: expected %q, got %v", ref, expected, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// diffQueries compares two raw queries regardless of the order of their
+// parameters, and returns the differing parameters as a diff
+func diffQueries(expected, actual string) string {
+	if expected == actual {
+		return ""
+	}
+	e, err1 := url.ParseQuery(expected)
+	a, err2 := url.ParseQuery(actual)
+	if err1 != nil || err2 != nil {
+		return lineDiff([]string{expected}, []string{actual})
+	}
+	if reflect.DeepEqual(e, a) {
+		return ""
+	}
+	return lineDiff(queryLines(e), queryLines(a))
+}
+
+func queryLines(values url.Values) []string {
+	var lines []string
+	for key, vs := range values {
+		for _, v := range vs {
+			lines = append(lines, key+"="+v)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// diffBodies compares two bodies, as JSON documents if they both are
+func diffBodies(expected, actual []byte) string {
+	var e, a interface{}
+	if json.Unmarshal(expected, &e) == nil && json.Unmarshal(actual, &a) == nil {
+		if reflect.DeepEqual(e, a) {
+			return ""
+		}
+		// re-indenting makes the diff line up with the keys that differ;
+		// maps are marshalled with sorted keys
+		ei, _ := json.MarshalIndent(e, "", "  ")
+		ai, _ := json.MarshalIndent(a, "", "  ")
+		return lineDiff(strings.Split(string(ei), "\n"), strings.Split(string(ai), "\n"))
+	}
+	if !utf8.Valid(expected) || !utf8.Valid(actual) {
+		return fmt.Sprintf("  binary bodies differ: expected %d bytes, got %d bytes", len(expected), len(actual))
+	}
+	return lineDiff(splitLines(expected), splitLines(actual))
+}
+
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(string(bytes.TrimSuffix(data, []byte("\n"))), "\n")
+}
+
+// lineDiff returns a unified-style diff of two lists of lines, with "-"
+// for expected lines missing from actual and "+" for the extra ones
+func lineDiff(expected, actual []string) string {
+	// lengths of the longest common subsequences of the suffixes
+	lcs := make([][]int, len(expected)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(actual)+1)
+	}
+	for i := len(expected) - 1; i >= 0; i-- {
+		for j := len(actual) - 1; j >= 0; j-- {
+			if expected[i] == actual[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(expected) || j < len(actual) {
+		switch {
+		case i < len(expected) && j < len(actual) && expected[i] == actual[j]:
+			lines = append(lines, "  "+expected[i])
+			i++
+			j++
+		case i < len(expected) && (j == len(actual) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+expected[i])
+			i++
+		default:
+			lines = append(lines, "+ "+actual[j])
+			j++
+		}
+	}
+	return strings.Join(lines, "\n")
+}
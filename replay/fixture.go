@@ -0,0 +1,118 @@
+// Package replay records the HTTP traffic between a DockerClient and a
+// daemon into fixture files, and replays it offline.
+//
+// A Recorder wraps the transport of DockerClient.HTTPClient and captures
+// every request with its streamed response, including the time between
+// chunks:
+//
+//	recorder := replay.NewRecorder(client.HTTPClient.Transport)
+//	client.HTTPClient.Transport = recorder
+//	... exercise the client against a real daemon ...
+//	recorder.Save("testdata/pull.json")
+//
+// A Replayer then serves the fixture instead of the daemon, failing any
+// request that doesn't match the recording with a diff of what differs:
+//
+//	replayer, err := replay.Load("testdata/pull.json")
+//	client.HTTPClient.Transport = replayer
+//	... run the same calls ...
+//	err = replayer.Done()
+package replay
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+// Fixture is the content of a fixture file
+type Fixture struct {
+	Interactions []*Interaction
+}
+
+// Interaction is a request and the response it got
+type Interaction struct {
+	Request  Request
+	Response *Response `json:",omitempty"`
+	// Error is set instead of Response when the request failed
+	Error string `json:",omitempty"`
+}
+
+// Request is a recorded request. Request headers aren't recorded, since
+// they may carry credentials.
+type Request struct {
+	Method string
+	Path   string
+	Query  string  `json:",omitempty"`
+	Body   Payload `json:",omitempty"`
+}
+
+// Response is a recorded response, with its body split in the chunks it
+// was received in
+type Response struct {
+	StatusCode int
+	Header     http.Header `json:",omitempty"`
+	Chunks     []Chunk     `json:",omitempty"`
+}
+
+// Chunk is a piece of a response body, received Delay after the previous
+// one or after the headers
+type Chunk struct {
+	Delay time.Duration `json:",omitempty"`
+	Data  Payload
+}
+
+// Payload is the data of a body. It is written to fixtures as a string
+// when it is valid UTF-8, and as {"base64": "..."} otherwise, such as for
+// the multiplexed output of logs and attach.
+type Payload []byte
+
+func (p Payload) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(p) {
+		return json.Marshal(string(p))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(p)})
+}
+
+func (p *Payload) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*p = Payload(s)
+		return nil
+	}
+	var encoded map[string]string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded["base64"])
+	if err != nil {
+		return err
+	}
+	*p = decoded
+	return nil
+}
+
+// LoadFixture reads a fixture file
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fixture := &Fixture{}
+	if err := json.Unmarshal(data, fixture); err != nil {
+		return nil, err
+	}
+	return fixture, nil
+}
+
+// Save writes the fixture to path, indented so that it diffs well
+func (f *Fixture) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,114 @@
+package replay
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Recorder is an http.RoundTripper that forwards requests to another
+// transport and records them
+type Recorder struct {
+	transport http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []*Interaction
+}
+
+// NewRecorder returns a recorder forwarding requests to transport, or to
+// http.DefaultTransport if it is nil
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{transport: transport}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	interaction := &Interaction{
+		Request: Request{
+			Method: req.Method,
+			Path:   req.URL.Path,
+			Query:  req.URL.RawQuery,
+		},
+	}
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		interaction.Request.Body = body
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	// interactions are kept in the order requests were sent, even if a
+	// streamed response is still being recorded
+	r.mu.Lock()
+	r.interactions = append(r.interactions, interaction)
+	r.mu.Unlock()
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		r.mu.Lock()
+		interaction.Error = err.Error()
+		r.mu.Unlock()
+		return nil, err
+	}
+	r.mu.Lock()
+	interaction.Response = &Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+	}
+	r.mu.Unlock()
+	resp.Body = &recordingBody{ReadCloser: resp.Body, recorder: r, response: interaction.Response, last: time.Now()}
+	return resp, nil
+}
+
+// Fixture returns what was recorded so far. Responses still being
+// streamed are included up to their last chunk.
+func (r *Recorder) Fixture() *Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fixture := &Fixture{}
+	for _, interaction := range r.interactions {
+		recorded := *interaction
+		if interaction.Response != nil {
+			response := *interaction.Response
+			response.Chunks = append([]Chunk{}, response.Chunks...)
+			recorded.Response = &response
+		}
+		fixture.Interactions = append(fixture.Interactions, &recorded)
+	}
+	return fixture
+}
+
+// Save writes what was recorded so far to a fixture file
+func (r *Recorder) Save(path string) error {
+	return r.Fixture().Save(path)
+}
+
+// recordingBody records the chunks of a response body as they are read
+type recordingBody struct {
+	io.ReadCloser
+	recorder *Recorder
+	response *Response
+	last     time.Time
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		now := time.Now()
+		b.recorder.mu.Lock()
+		b.response.Chunks = append(b.response.Chunks, Chunk{
+			Delay: now.Sub(b.last),
+			Data:  append(Payload{}, p[:n]...),
+		})
+		b.recorder.mu.Unlock()
+		b.last = now
+	}
+	return n, err
+}
@@ -0,0 +1,200 @@
+package replay
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+	"github.com/samalba/dockerclient/fakeengine"
+)
+
+func newClient(t *testing.T, url string, transport http.RoundTripper) *dockerclient.DockerClient {
+	client, err := dockerclient.NewDockerClient(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport != nil {
+		client.HTTPClient.Transport = transport
+	}
+	return client
+}
+
+// record runs fn against a fake engine and returns the recorded fixture
+func record(t *testing.T, fn func(*fakeengine.Engine, *dockerclient.DockerClient)) *Fixture {
+	engine := fakeengine.New()
+	server := engine.Serve()
+	defer server.Close()
+	recorder := NewRecorder(nil)
+	fn(engine, newClient(t, server.URL, recorder))
+	return recorder.Fixture()
+}
+
+func TestRecordAndReplayPull(t *testing.T) {
+	fixture := record(t, func(_ *fakeengine.Engine, client *dockerclient.DockerClient) {
+		if err := client.PullImage("busybox", nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(fixture.Interactions) != 1 {
+		t.Fatalf("expected 1 interaction, got %d", len(fixture.Interactions))
+	}
+	response := fixture.Interactions[0].Response
+	if response.StatusCode != http.StatusOK || len(response.Chunks) == 0 {
+		t.Fatalf("unexpected recorded response %+v", response)
+	}
+
+	path := filepath.Join(t.TempDir(), "pull.json")
+	if err := fixture.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	replayer, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newClient(t, "http://replay", replayer)
+	if err := client.PullImage("busybox", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := replayer.Done(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayFixture(t *testing.T) {
+	replayer, err := Load("testdata/events.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newClient(t, "http://replay", replayer)
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	events, err := client.MonitorEvents(nil, stopChan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for i := 0; i < 2; i++ {
+		e := <-events
+		if e.Error != nil {
+			t.Fatal(e.Error)
+		}
+		actions = append(actions, string(e.Action))
+	}
+	if strings.Join(actions, ",") != "create,start" {
+		t.Fatalf("unexpected events %v", actions)
+	}
+}
+
+func TestReplayStatsWithTiming(t *testing.T) {
+	fixture := &Fixture{Interactions: []*Interaction{{
+		Request: Request{Method: "GET", Path: "/v1.15/containers/web/stats"},
+		Response: &Response{
+			StatusCode: http.StatusOK,
+			Chunks: []Chunk{
+				{Data: Payload(`{"memory_stats":{"usage":1}}` + "\n")},
+				{Delay: 50 * time.Millisecond, Data: Payload(`{"memory_stats":{"usage":2}}` + "\n")},
+			},
+		},
+	}}}
+	replayer := NewReplayer(fixture)
+	replayer.Speed = 1
+	client := newClient(t, "http://replay", replayer)
+
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	start := time.Now()
+	stats, err := client.ContainerStats("web", stopChan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, usage := range []uint64{1, 2} {
+		s := <-stats
+		if s.Error != nil {
+			t.Fatal(s.Error)
+		}
+		if s.MemoryStats.Usage != usage {
+			t.Fatalf("expected usage %d, got %d", usage, s.MemoryStats.Usage)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("the delay between chunks should be replayed, got %s", elapsed)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	fixture := record(t, func(engine *fakeengine.Engine, client *dockerclient.DockerClient) {
+		engine.AddImage("busybox", nil)
+		if _, err := client.CreateContainer(&dockerclient.ContainerConfig{Image: "busybox", Cmd: []string{"true"}}, "web", nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	replayer := NewReplayer(fixture)
+	client := newClient(t, "http://replay", replayer)
+	_, err := client.CreateContainer(&dockerclient.ContainerConfig{Image: "busybox", Cmd: []string{"false"}}, "db", nil)
+	if err == nil {
+		t.Fatal("expected a mismatch")
+	}
+	for _, expected := range []string{"- name=web", "+ name=db", `-     "true"`, `+     "false"`} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected the diff to contain %q, got:\n%v", expected, err)
+		}
+	}
+	if err := replayer.Done(); err == nil {
+		t.Fatal("the unmatched interaction should be reported")
+	}
+}
+
+func TestReplayIncompleteInteraction(t *testing.T) {
+	replayer := NewReplayer(&Fixture{Interactions: []*Interaction{{Request: Request{Method: "GET", Path: "/_ping"}}}})
+	client := &http.Client{Transport: replayer}
+	_, err := client.Get("http://replay/_ping")
+	if err == nil || !strings.Contains(err.Error(), "the interaction for GET /_ping has neither a response nor an error") {
+		t.Fatalf("expected the interaction to be reported, got %v", err)
+	}
+}
+
+func TestReplayAnyOrder(t *testing.T) {
+	fixture := record(t, func(_ *fakeengine.Engine, client *dockerclient.DockerClient) {
+		client.Info()
+		client.Version()
+	})
+	replayer := NewReplayer(fixture)
+	client := newClient(t, "http://replay", replayer)
+	if _, err := client.Version(); err == nil {
+		t.Fatal("requests out of order should fail by default")
+	}
+
+	replayer = NewReplayer(fixture)
+	replayer.AnyOrder = true
+	client = newClient(t, "http://replay", replayer)
+	if _, err := client.Version(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Info(); err != nil {
+		t.Fatal(err)
+	}
+	if err := replayer.Done(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPayloadBinary(t *testing.T) {
+	p := Payload{1, 0, 0, 0, 0, 0, 0, 3, 0xff, 'h', 'i'}
+	data, err := p.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "base64") {
+		t.Fatalf("binary data should be base64 encoded, got %s", data)
+	}
+	var decoded Payload
+	if err := decoded.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded) != string(p) {
+		t.Fatalf("expected %v, got %v", p, decoded)
+	}
+}
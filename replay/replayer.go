@@ -0,0 +1,205 @@
+package replay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MismatchError is returned by a Replayer for a request that doesn't match
+// the recording
+type MismatchError struct {
+	Request Request
+	// Expected is the closest recorded request, nil if none was left
+	Expected *Request
+	Diff     string
+}
+
+func (e *MismatchError) Error() string {
+	if e.Expected == nil {
+		return fmt.Sprintf("replay: unexpected request %s %s: no recorded request left", e.Request.Method, e.Request.Path)
+	}
+	return fmt.Sprintf("replay: request %s %s doesn't match the recording:\n%s", e.Request.Method, e.Request.Path, e.Diff)
+}
+
+// Replayer is an http.RoundTripper that answers requests from a fixture
+type Replayer struct {
+	// Speed scales the delays between chunks: 1 replays them in real time,
+	// 0 (the default) sends every chunk as soon as it is read
+	Speed float64
+	// AnyOrder lets requests match any interaction not replayed yet. By
+	// default they must come in the order they were recorded.
+	AnyOrder bool
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+// NewReplayer returns a replayer for the interactions of fixture
+func NewReplayer(fixture *Fixture) *Replayer {
+	return &Replayer{
+		interactions: fixture.Interactions,
+		used:         make([]bool, len(fixture.Interactions)),
+	}
+}
+
+// Load returns a replayer for a fixture file
+func Load(path string) (*Replayer, error) {
+	fixture, err := LoadFixture(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(fixture), nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	actual := Request{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.RawQuery,
+	}
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		actual.Body = body
+	}
+
+	interaction, err := r.match(actual)
+	if err != nil {
+		return nil, err
+	}
+	if interaction.Error != "" {
+		return nil, errors.New(interaction.Error)
+	}
+
+	recorded := interaction.Response
+	if recorded == nil {
+		return nil, fmt.Errorf("replay: the interaction for %s has neither a response nor an error", requestLine(&interaction.Request))
+	}
+	pr, pw := io.Pipe()
+	go r.stream(req, recorded.Chunks, pw)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header.Clone(),
+		Body:          pr,
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// match finds the interaction for a request and marks it replayed
+func (r *Replayer) match(actual Request) (*Interaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var closest *Request
+	for i, interaction := range r.interactions {
+		if r.used[i] {
+			continue
+		}
+		expected := &interaction.Request
+		if diff := diffRequests(expected, &actual); diff != "" {
+			if !r.AnyOrder {
+				return nil, &MismatchError{Request: actual, Expected: expected, Diff: diff}
+			}
+			if closest == nil || (expected.Method == actual.Method && expected.Path == actual.Path) {
+				closest = expected
+			}
+			continue
+		}
+		r.used[i] = true
+		return interaction, nil
+	}
+	if closest == nil {
+		return nil, &MismatchError{Request: actual}
+	}
+	return nil, &MismatchError{Request: actual, Expected: closest, Diff: diffRequests(closest, &actual)}
+}
+
+// stream writes the chunks of a response to the pipe, stopping early if
+// the request is canceled or the body closed
+func (r *Replayer) stream(req *http.Request, chunks []Chunk, pw *io.PipeWriter) {
+	for _, chunk := range chunks {
+		if delay := time.Duration(float64(chunk.Delay) * r.Speed); delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-req.Context().Done():
+				pw.CloseWithError(req.Context().Err())
+				return
+			}
+		}
+		if _, err := pw.Write(chunk.Data); err != nil {
+			return
+		}
+	}
+	pw.Close()
+}
+
+// Unused returns the interactions that haven't been replayed
+func (r *Replayer) Unused() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []*Interaction
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// Done returns an error listing the recorded requests that were never
+// made, if any
+func (r *Replayer) Done() error {
+	unused := r.Unused()
+	if len(unused) == 0 {
+		return nil
+	}
+	lines := []string{fmt.Sprintf("replay: %d recorded requests were not made:", len(unused))}
+	for _, interaction := range unused {
+		lines = append(lines, "  "+requestLine(&interaction.Request))
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
+
+func requestLine(req *Request) string {
+	if req.Query == "" {
+		return req.Method + " " + req.Path
+	}
+	return req.Method + " " + req.Path + "?" + req.Query
+}
+
+// diffRequests returns a description of the differences between two
+// requests, or "" if they match
+func diffRequests(expected, actual *Request) string {
+	var diff []string
+	if expected.Method != actual.Method {
+		diff = append(diff, fmt.Sprintf("method: expected %s, got %s", expected.Method, actual.Method))
+	}
+	if expected.Path != actual.Path {
+		diff = append(diff, fmt.Sprintf("path: expected %s, got %s", expected.Path, actual.Path))
+	}
+	if d := diffQueries(expected.Query, actual.Query); d != "" {
+		diff = append(diff, "query:\n"+d)
+	}
+	if !bytes.Equal(expected.Body, actual.Body) {
+		if d := diffBodies(expected.Body, actual.Body); d != "" {
+			diff = append(diff, "body:\n"+d)
+		}
+	}
+	return strings.Join(diff, "\n")
+}
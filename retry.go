@@ -0,0 +1,245 @@
+package dockerclient
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// idempotentOperations are the operations a RetryPolicy applies to unless
+// told otherwise. Sending them twice has the same effect as sending them
+// once.
+var idempotentOperations = map[string]bool{
+	"Info":             true,
+	"Version":          true,
+	"InspectContainer": true,
+	"InspectImage":     true,
+	"InspectNetwork":   true,
+	"ListContainers":   true,
+	"ListImages":       true,
+	"ListNetworks":     true,
+	"ListVolumes":      true,
+	"ContainerChanges": true,
+	"SearchImages":     true,
+	"PullImage":        true,
+}
+
+// DefaultRetryPolicy is a starting point for RetryMiddleware: up to four
+// attempts, waiting about 100ms, 200ms then 400ms in between
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Jitter:         0.2,
+}
+
+// RetryPolicy describes how calls failing with a transient error are
+// retried.
+//
+// It applies to the idempotent operations only: Info, Version, the Inspect*
+// and List* calls, ContainerChanges, SearchImages and PullImage. Others,
+// such as CreateContainer or ExecStart, are retried only when listed in
+// OptIn or Operations. Calls sending a streamed body, as BuildImage does,
+// are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts, the first one included. A
+	// value below 2 disables retries.
+	MaxAttempts int
+	// InitialBackoff is the wait after the first failure. It doubles after
+	// every attempt, up to MaxBackoff when it is set.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the fraction of the wait picked at random, 0.2 making it
+	// vary between 80% and 100% of the backoff
+	Jitter float64
+	// Retryable tells whether a failed attempt is worth retrying. It
+	// defaults to IsTransient.
+	Retryable func(resp *http.Response, err error) bool
+	// OptIn lists operations that aren't idempotent but are retried
+	// anyway with this policy, such as "CreateContainer"
+	OptIn []string
+	// Operations overrides the policy of single operations, opting them
+	// in if needed. A nil policy disables retries for the operation.
+	Operations map[string]*RetryPolicy
+	// OnRetry is called after every failed attempt that is retried, and
+	// once more when giving up
+	OnRetry func(RetryEvent)
+}
+
+// retryPolicyKey is the context key of the policy set by WithRetryPolicy
+type retryPolicyKey struct{}
+
+// WithRetryPolicy returns a client sharing the connections, version and
+// middlewares of client, whose calls are retried as policy describes
+// instead of as the RetryMiddleware of client would, nil turning retries
+// off. It only changes single calls, without a RetryMiddleware of its own:
+//
+//	// not worth waiting for
+//	client.WithRetryPolicy(nil).InspectContainer(id)
+//	// known to be safe to send twice
+//	client.WithRetryPolicy(&policy).CreateContainer(config, name, nil)
+//
+// The policy applies to the operation whether it is idempotent or not,
+// but calls sending a streamed body are still never retried.
+func (client *DockerClient) WithRetryPolicy(policy *RetryPolicy) *DockerClient {
+	setPolicy := func(next CallHandler) CallHandler {
+		return func(call *Call) (*http.Response, error) {
+			ctx := context.WithValue(call.Request.Context(), retryPolicyKey{}, policy)
+			call.Request = call.Request.WithContext(ctx)
+			return next(call)
+		}
+	}
+	return &DockerClient{
+		URL:         client.URL,
+		HTTPClient:  client.HTTPClient,
+		TLSConfig:   client.TLSConfig,
+		middlewares: append([]Middleware{setPolicy}, client.middlewares...),
+		version:     client.version,
+		OnWarning:   client.OnWarning,
+	}
+}
+
+// RetryEvent describes a failed attempt
+type RetryEvent struct {
+	Operation string
+	// Attempt is the number of the failed attempt, starting at 1
+	Attempt int
+	// StatusCode is the status of the response, 0 when there was none
+	StatusCode int
+	Err        error
+	// Delay is the wait before the next attempt
+	Delay time.Duration
+	// GaveUp is set when the attempt was the last one
+	GaveUp bool
+}
+
+// IsTransient reports whether a request failed because the engine was
+// unreachable or answered with a server error, as it does while the daemon
+// restarts
+func IsTransient(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp != nil && resp.StatusCode >= 500
+}
+
+// RetryMiddleware retries the calls failing with a transient error, as
+// described by policy or, for the calls of a client returned by
+// WithRetryPolicy, by the policy of that call. Canceled calls aren't
+// retried. Middlewares added before it see the final outcome only, those
+// added after it see every attempt:
+//
+//	policy := dockerclient.DefaultRetryPolicy
+//	policy.OnRetry = func(e dockerclient.RetryEvent) { retries.Inc() }
+//	client.Use(dockerclient.RetryMiddleware(&policy))
+func RetryMiddleware(policy *RetryPolicy) Middleware {
+	return func(next CallHandler) CallHandler {
+		return func(call *Call) (*http.Response, error) {
+			p := policy.forOperation(call.Operation)
+			if override, ok := call.Request.Context().Value(retryPolicyKey{}).(*RetryPolicy); ok {
+				p = override
+			}
+			req := call.Request
+			if p == nil || p.MaxAttempts < 2 || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+				return next(call)
+			}
+			retryable := p.Retryable
+			if retryable == nil {
+				retryable = IsTransient
+			}
+
+			original := *call
+			backoff := p.InitialBackoff
+			for attempt := 1; ; attempt++ {
+				resp, err := next(call)
+				if !retryable(resp, err) || req.Context().Err() != nil {
+					return resp, err
+				}
+				event := RetryEvent{Operation: call.Operation, Attempt: attempt, Err: err}
+				if resp != nil {
+					event.StatusCode = resp.StatusCode
+				}
+				if attempt >= p.MaxAttempts {
+					event.GaveUp = true
+					p.notify(event)
+					return resp, err
+				}
+				event.Delay = p.jitter(backoff)
+				p.notify(event)
+
+				// prepare the next attempt before discarding this one, so
+				// that its outcome is returned if that fails
+				retry := original
+				var rewindErr error
+				if retry.Request, rewindErr = rewind(req); rewindErr != nil {
+					return resp, err
+				}
+				if resp != nil {
+					resp.Body.Close()
+				}
+				if !waitBackoff(req, event.Delay) {
+					return nil, req.Context().Err()
+				}
+				call = &retry
+				backoff *= 2
+				if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+					backoff = p.MaxBackoff
+				}
+			}
+		}
+	}
+}
+
+func (p *RetryPolicy) forOperation(op string) *RetryPolicy {
+	if override, ok := p.Operations[op]; ok {
+		return override
+	}
+	if idempotentOperations[op] {
+		return p
+	}
+	for _, name := range p.OptIn {
+		if name == op {
+			return p
+		}
+	}
+	return nil
+}
+
+func (p *RetryPolicy) jitter(backoff time.Duration) time.Duration {
+	if p.Jitter <= 0 || backoff <= 0 {
+		return backoff
+	}
+	return backoff - time.Duration(rand.Float64()*p.Jitter*float64(backoff))
+}
+
+func (p *RetryPolicy) notify(event RetryEvent) {
+	if p.OnRetry != nil {
+		p.OnRetry(event)
+	}
+}
+
+// rewind returns a copy of req ready to be sent again
+func rewind(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return retry, nil
+}
+
+// waitBackoff waits for d, returning false if the request is canceled meanwhile
+func waitBackoff(req *http.Request, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-req.Context().Done():
+		return false
+	}
+}
@@ -0,0 +1,166 @@
+package dockerclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first failures requests with a 503, then answers
+// with an empty JSON object
+func flakyServer(failures int) (*httptest.Server, func() int) {
+	var mu sync.Mutex
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		count++
+		n := count
+		mu.Unlock()
+		ioutil.ReadAll(r.Body)
+		if n <= failures {
+			http.Error(w, "daemon is restarting", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"Id": "abc"}`))
+	}))
+	return server, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return count
+	}
+}
+
+func retryClient(t *testing.T, url string, policy *RetryPolicy) *DockerClient {
+	client, err := NewDockerClient(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Use(RetryMiddleware(policy))
+	return client
+}
+
+func TestRetryIdempotentOperation(t *testing.T) {
+	server, requests := flakyServer(2)
+	defer server.Close()
+	var events []RetryEvent
+	client := retryClient(t, server.URL, &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Jitter:         0.5,
+		OnRetry:        func(e RetryEvent) { events = append(events, e) },
+	})
+	if _, err := client.Info(); err != nil {
+		t.Fatal(err)
+	}
+	if requests() != 3 {
+		t.Fatalf("expected 3 requests, got %d", requests())
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 retry events, got %+v", events)
+	}
+	for i, e := range events {
+		if e.Operation != "Info" || e.Attempt != i+1 || e.StatusCode != 503 || e.GaveUp {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	server, requests := flakyServer(10)
+	defer server.Close()
+	var last RetryEvent
+	client := retryClient(t, server.URL, &RetryPolicy{
+		MaxAttempts: 2,
+		OnRetry:     func(e RetryEvent) { last = e },
+	})
+	_, err := client.Version()
+	if e, ok := err.(Error); !ok || e.StatusCode != 503 {
+		t.Fatalf("expected the last 503 to be returned, got %v", err)
+	}
+	if requests() != 2 || !last.GaveUp || last.Attempt != 2 {
+		t.Fatalf("expected to give up after 2 attempts, got %d requests and %+v", requests(), last)
+	}
+}
+
+func TestRetryOptIn(t *testing.T) {
+	server, requests := flakyServer(1)
+	defer server.Close()
+	client := retryClient(t, server.URL, &RetryPolicy{MaxAttempts: 3})
+	if _, err := client.CreateContainer(&ContainerConfig{Image: "busybox"}, "", nil); err == nil {
+		t.Fatal("expected CreateContainer not to be retried")
+	}
+	if requests() != 1 {
+		t.Fatalf("expected a single request, got %d", requests())
+	}
+
+	server, requests = flakyServer(1)
+	defer server.Close()
+	client = retryClient(t, server.URL, &RetryPolicy{MaxAttempts: 3, OptIn: []string{"CreateContainer"}})
+	if _, err := client.CreateContainer(&ContainerConfig{Image: "busybox"}, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if requests() != 2 {
+		t.Fatalf("expected the body to be sent again, got %d requests", requests())
+	}
+}
+
+func TestRetryOperationOverride(t *testing.T) {
+	server, requests := flakyServer(2)
+	defer server.Close()
+	client := retryClient(t, server.URL, &RetryPolicy{
+		MaxAttempts: 3,
+		Operations: map[string]*RetryPolicy{
+			"Info":      nil,
+			"ExecStart": {MaxAttempts: 2},
+		},
+	})
+	if _, err := client.Info(); err == nil {
+		t.Fatal("expected Info not to be retried")
+	}
+	if err := client.ExecStart("abc", &ExecConfig{Detach: true}); err != nil {
+		t.Fatal(err)
+	}
+	if requests() != 3 {
+		t.Fatalf("expected 3 requests, got %d", requests())
+	}
+}
+
+func TestRetryPerCall(t *testing.T) {
+	server, requests := flakyServer(2)
+	defer server.Close()
+	client := retryClient(t, server.URL, &RetryPolicy{MaxAttempts: 3})
+	if _, err := client.WithRetryPolicy(nil).Info(); err == nil {
+		t.Fatal("expected Info not to be retried")
+	}
+	// the policy of the call opts CreateContainer in
+	if _, err := client.WithRetryPolicy(&RetryPolicy{MaxAttempts: 2}).CreateContainer(&ContainerConfig{Image: "busybox"}, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if requests() != 3 {
+		t.Fatalf("expected 3 requests, got %d", requests())
+	}
+	// the client itself is left as it was
+	if _, err := client.CreateContainer(&ContainerConfig{Image: "busybox"}, "", nil); err != nil || requests() != 4 {
+		t.Fatalf("expected a single request, got %d %v", requests(), err)
+	}
+}
+
+func TestRetryConnectionRefused(t *testing.T) {
+	server, _ := flakyServer(0)
+	url := server.URL
+	server.Close()
+	attempts := 0
+	client := retryClient(t, url, &RetryPolicy{
+		MaxAttempts: 3,
+		OnRetry:     func(RetryEvent) { attempts++ },
+	})
+	if _, err := client.Info(); err != ErrConnectionRefused {
+		t.Fatalf("expected ErrConnectionRefused, got %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 failed attempts, got %d", attempts)
+	}
+}
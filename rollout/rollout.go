@@ -0,0 +1,349 @@
+// Package rollout updates the replicas of a service, run as containers
+// sharing a label, to a new image without taking them all down at once.
+//
+// The replicas are replaced a batch at a time: a replacement is created and
+// started next to every replica of the batch, and the old replicas are only
+// stopped and removed once all the replacements are healthy, or running
+// when they have no healthcheck. The replacements then take the names of
+// the replicas they replace. If a replacement fails, the batch is
+// abandoned and the replicas already updated are rolled back the same way:
+//
+//	result, err := rollout.Update(client, &rollout.Options{
+//		Label:     "com.example.service=web",
+//		Image:     "nginx:1.19",
+//		BatchSize: 2,
+//		Progress: func(p rollout.Progress) {
+//			log.Printf("%s %s (%d/%d)", p.Step, p.Container, p.Done, p.Total)
+//		},
+//	})
+//
+// The replacements are created with the networks and network aliases of
+// their replica, and its anonymous volumes bound by name, so that the data
+// they hold survives the update and a rollback. Replacements share those
+// volumes with their replica while both run.
+//
+// As replacements run next to the replicas they replace, replicas
+// publishing fixed host ports can't be updated this way.
+package rollout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+var (
+	ErrNoLabel = errors.New("No label selecting the replicas")
+	ErrNoImage = errors.New("No image to update to")
+)
+
+// Step is a step of an update
+type Step string
+
+const (
+	StepPull     Step = "pull"     // the image is being pulled
+	StepStart    Step = "start"    // a replacement was started
+	StepReady    Step = "ready"    // a replacement is healthy or running
+	StepReplaced Step = "replaced" // an old replica was removed
+	StepFailed   Step = "failed"   // a replacement failed, the update is rolled back
+	StepRollback Step = "rollback" // an updated replica was restored
+	StepDone     Step = "done"     // all the replicas are updated
+)
+
+// Progress is reported to Options.Progress as the update goes
+type Progress struct {
+	Step Step
+	// Container is the name of the replica, empty for the pull and done
+	// steps
+	Container string
+	// Done is the number of replicas updated so far out of Total
+	Done  int
+	Total int
+	Err   error
+}
+
+// Options configures Update
+type Options struct {
+	// Label selects the replicas, as key=value
+	Label string
+	Image string
+	// Auth is used to pull the image and create the replacements
+	Auth *dockerclient.AuthConfig
+	// BatchSize is the number of replicas updated at once, 1 if zero
+	BatchSize int
+	// StopTimeout is the number of seconds the old replicas are given to
+	// stop
+	StopTimeout int
+	// ReadyTimeout is how long a replacement has to become ready, a
+	// minute if zero
+	ReadyTimeout time.Duration
+	// Update is called with the config of every replacement, derived from
+	// its replica, to change it further
+	Update   func(config *dockerclient.ContainerConfig)
+	Progress func(Progress)
+}
+
+// Error is returned by Update when a replica can't be updated
+type Error struct {
+	Container string
+	Step      string // pull, list, inspect, create, connect, start, ready, stop, remove or rename
+	Err       error
+	// RollbackErr is the error rolling back the updated replicas, nil if
+	// they were all restored
+	RollbackErr error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("rollout: %s", e.Step)
+	if e.Container != "" {
+		msg += " " + e.Container
+	}
+	msg += fmt.Sprintf(": %v", e.Err)
+	if e.RollbackErr != nil {
+		msg += fmt.Sprintf(" (rollback failed: %v)", e.RollbackErr)
+	}
+	return msg
+}
+
+// Result lists what Update did
+type Result struct {
+	// Updated maps the names of the updated replicas to the IDs of their
+	// replacements
+	Updated map[string]string
+	// Skipped are the replicas already running the image
+	Skipped []string
+	// RolledBack is set when the update failed and the updated replicas
+	// were restored
+	RolledBack bool
+}
+
+// replica is a container of the group
+type replica struct {
+	id   string
+	name string
+	// config is the config of the replica, the one restored by a rollback
+	config *dockerclient.ContainerConfig
+	// next is the ID of its replacement, while it is started
+	next string
+}
+
+type updater struct {
+	client  dockerclient.Client
+	options *Options
+	total   int
+	done    int
+}
+
+// Update pulls options.Image and replaces the running replicas selected by
+// options.Label with containers created from it, skipping those already
+// running it. The replacements keep the config of their replica, as
+// derived by dockerclient.ConfigFromInfo, with options.Update applied.
+func Update(client dockerclient.Client, options *Options) (*Result, error) {
+	if options.Label == "" {
+		return nil, ErrNoLabel
+	}
+	if options.Image == "" {
+		return nil, ErrNoImage
+	}
+	u := &updater{client: client, options: options}
+	result := &Result{Updated: make(map[string]string)}
+
+	u.report(Progress{Step: StepPull})
+	if err := client.PullImage(options.Image, options.Auth); err != nil {
+		return result, &Error{Step: "pull", Err: err}
+	}
+	image, err := client.InspectImage(options.Image)
+	if err != nil {
+		return result, &Error{Step: "pull", Err: err}
+	}
+
+	replicas, skipped, err := u.list(image.Id)
+	if err != nil {
+		return result, err
+	}
+	result.Skipped = skipped
+	u.total = len(replicas)
+
+	batchSize := options.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	var updated []*replica
+	for start := 0; start < len(replicas); start += batchSize {
+		end := start + batchSize
+		if end > len(replicas) {
+			end = len(replicas)
+		}
+		batch := replicas[start:end]
+		replaced, err := u.replace(batch, func(r *replica) *dockerclient.ContainerConfig {
+			config := *r.config
+			config.Image = options.Image
+			if options.Update != nil {
+				options.Update(&config)
+			}
+			return &config
+		}, StepReplaced)
+		for _, r := range batch[:replaced] {
+			updated = append(updated, r)
+			result.Updated[r.name] = r.id
+		}
+		if err != nil {
+			u.report(Progress{Step: StepFailed, Container: err.Container, Err: err.Err})
+			err.RollbackErr = u.rollback(updated)
+			result.RolledBack = err.RollbackErr == nil
+			if result.RolledBack {
+				result.Updated = make(map[string]string)
+			}
+			return result, err
+		}
+	}
+	u.report(Progress{Step: StepDone})
+	return result, nil
+}
+
+// list returns the running replicas to update, sorted by name, and the
+// names of those already running image
+func (u *updater) list(image string) ([]*replica, []string, error) {
+	filters, err := json.Marshal(map[string][]string{"label": {u.options.Label}})
+	if err != nil {
+		return nil, nil, err
+	}
+	containers, err := u.client.ListContainers(false, false, string(filters))
+	if err != nil {
+		return nil, nil, &Error{Step: "list", Err: err}
+	}
+	var replicas []*replica
+	var skipped []string
+	for _, c := range containers {
+		info, err := u.client.InspectContainer(c.Id)
+		if err != nil {
+			return nil, nil, &Error{Step: "inspect", Container: c.Id, Err: err}
+		}
+		name := strings.TrimPrefix(info.Name, "/")
+		if info.Image == image {
+			skipped = append(skipped, name)
+			continue
+		}
+		oldImage, err := u.client.InspectImage(info.Image)
+		if err != nil && !dockerclient.IsNotFound(err) {
+			return nil, nil, &Error{Step: "inspect", Container: name, Err: err}
+		}
+		config := dockerclient.ConfigFromInfo(info, oldImage)
+		dockerclient.BindAnonymousVolumes(config, info)
+		replicas = append(replicas, &replica{id: info.Id, name: name, config: config})
+	}
+	sort.Slice(replicas, func(i, j int) bool { return replicas[i].name < replicas[j].name })
+	sort.Strings(skipped)
+	return replicas, skipped, nil
+}
+
+// replace starts a replacement for every replica of batch, created with
+// the config configFor returns, waits for them to be ready and removes the
+// replicas, whose id becomes the one of their replacement. It returns the
+// number of replicas replaced, the first ones of batch: on failure, the
+// replacements of the others are removed.
+func (u *updater) replace(batch []*replica, configFor func(*replica) *dockerclient.ContainerConfig, step Step) (int, *Error) {
+	// a replacement that couldn't be renamed keeps its _next name until
+	// the rollback replaces it, so the rollback's are named differently
+	suffix := "_next"
+	if step == StepRollback {
+		suffix = "_prev"
+	}
+	replaced := 0
+	fail := func(r *replica, stepName string, err error) (int, *Error) {
+		for _, r := range batch[replaced:] {
+			if r.next != "" {
+				u.client.RemoveContainer(r.next, true, true)
+				r.next = ""
+			}
+		}
+		return replaced, &Error{Step: stepName, Container: r.name, Err: err}
+	}
+	for _, r := range batch {
+		id, err := dockerclient.CreateConnectedContainer(u.client, configFor(r), r.name+suffix, u.options.Auth)
+		r.next = id
+		if id == "" && err != nil {
+			return fail(r, "create", err)
+		}
+		if err != nil {
+			return fail(r, "connect", err)
+		}
+		if err := u.client.StartContainer(id, nil); err != nil {
+			return fail(r, "start", err)
+		}
+		u.report(Progress{Step: StepStart, Container: r.name})
+	}
+	for _, r := range batch {
+		if err := u.waitReady(r.next); err != nil {
+			return fail(r, "ready", err)
+		}
+		u.report(Progress{Step: StepReady, Container: r.name})
+	}
+
+	for _, r := range batch {
+		if err := u.client.StopContainer(r.id, u.options.StopTimeout); err != nil && !dockerclient.IsNotFound(err) {
+			return fail(r, "stop", err)
+		}
+		if err := u.client.RemoveContainer(r.id, true, false); err != nil && !dockerclient.IsNotFound(err) {
+			// the replica is kept, running again
+			u.client.StartContainer(r.id, nil)
+			return fail(r, "remove", err)
+		}
+		r.id, r.next = r.next, ""
+		replaced++
+		if step == StepReplaced {
+			u.done++
+		} else {
+			u.done--
+		}
+		if err := u.client.RenameContainer(r.id, r.name); err != nil {
+			// the replacement runs, under its temporary name, and is the
+			// one a rollback replaces
+			return fail(r, "rename", err)
+		}
+		u.report(Progress{Step: step, Container: r.name})
+	}
+	return replaced, nil
+}
+
+// rollback replaces the updated replicas with containers created from
+// their former config
+func (u *updater) rollback(updated []*replica) error {
+	for i := len(updated) - 1; i >= 0; i-- {
+		r := updated[i]
+		_, err := u.replace([]*replica{r}, func(r *replica) *dockerclient.ContainerConfig {
+			return r.config
+		}, StepRollback)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitReady waits for container id to be healthy, or running if it has no
+// healthcheck
+func (u *updater) waitReady(id string) error {
+	timeout := u.options.ReadyTimeout
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	err := dockerclient.WaitForHealthy(u.client, id, timeout)
+	if err == dockerclient.ErrNoHealthcheck {
+		return nil
+	}
+	return err
+}
+
+func (u *updater) report(p Progress) {
+	if u.options.Progress == nil {
+		return
+	}
+	p.Done, p.Total = u.done, u.total
+	u.options.Progress(p)
+}
@@ -0,0 +1,258 @@
+package rollout
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/samalba/dockerclient/fakeengine"
+	"github.com/samalba/dockerclient/mockclient"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	noAuth       = (*dockerclient.AuthConfig)(nil)
+	noHostConfig = (*dockerclient.HostConfig)(nil)
+)
+
+func running(id string) *dockerclient.ContainerInfo {
+	return &dockerclient.ContainerInfo{Id: id, State: &dockerclient.State{Running: true}}
+}
+
+// newGroup mocks a group of replicas labelled app=web, web_3 running the
+// new image already
+func newGroup() *mockclient.MockClient {
+	client := mockclient.NewMockClient()
+	client.On("PullImage", "web:2", noAuth).Return(nil)
+	client.On("InspectImage", "web:2").Return(&dockerclient.ImageInfo{Id: "sha256:new"}, nil)
+	client.On("InspectImage", "sha256:old").Return(&dockerclient.ImageInfo{Id: "sha256:old"}, nil)
+	// readiness is polled
+	client.On("MonitorEvents", mock.Anything, mock.Anything).Return((<-chan dockerclient.EventOrError)(nil), errors.New("no events"))
+	client.On("ListContainers", false, false, `{"label":["app=web"]}`).Return([]dockerclient.Container{
+		{Id: "c3"}, {Id: "c2"}, {Id: "c1"},
+	}, nil)
+	for _, c := range []struct{ id, name, image string }{
+		{"c1", "/web_1", "sha256:old"},
+		{"c2", "/web_2", "sha256:old"},
+		{"c3", "/web_3", "sha256:new"},
+	} {
+		client.On("InspectContainer", c.id).Return(&dockerclient.ContainerInfo{
+			Id:     c.id,
+			Name:   c.name,
+			Image:  c.image,
+			Config: &dockerclient.ContainerConfig{Image: "web:1", Env: []string{"LEVEL=debug"}},
+			State:  &dockerclient.State{Running: true},
+		}, nil)
+	}
+	return client
+}
+
+// expectReplace mocks the replacement of container old, named name, with
+// new, created as name+suffix
+func expectReplace(client *mockclient.MockClient, old, name, suffix, new string) {
+	client.On("CreateContainer", mock.Anything, name+suffix, noAuth).Return(new, nil).Once()
+	client.On("StartContainer", new, noHostConfig).Return(nil).Once()
+	client.On("StopContainer", old, 10).Return(nil).Once()
+	client.On("RemoveContainer", old, true, false).Return(nil).Once()
+	client.On("RenameContainer", new, name).Return(nil).Once()
+}
+
+// createdConfigs returns the configs of the containers created as name
+func createdConfigs(client *mockclient.MockClient, name string) []*dockerclient.ContainerConfig {
+	var configs []*dockerclient.ContainerConfig
+	for _, call := range client.Calls {
+		if call.Method == "CreateContainer" && call.Arguments.String(1) == name {
+			configs = append(configs, call.Arguments.Get(0).(*dockerclient.ContainerConfig))
+		}
+	}
+	return configs
+}
+
+func TestUpdate(t *testing.T) {
+	client := newGroup()
+	expectReplace(client, "c1", "web_1", "_next", "n1")
+	expectReplace(client, "c2", "web_2", "_next", "n2")
+	client.On("InspectContainer", "n1").Return(running("n1"), nil)
+	// n2 is healthy on its second inspection
+	starting := running("n2")
+	starting.State.Health = &dockerclient.Health{Status: "starting"}
+	client.On("InspectContainer", "n2").Return(starting, nil).Once()
+	healthy := running("n2")
+	healthy.State.Health = &dockerclient.Health{Status: dockerclient.HealthHealthy}
+	client.On("InspectContainer", "n2").Return(healthy, nil).Once()
+
+	var steps []string
+	result, err := Update(client, &Options{
+		Label:       "app=web",
+		Image:       "web:2",
+		BatchSize:   2,
+		StopTimeout: 10,
+		Update: func(config *dockerclient.ContainerConfig) {
+			config.Env = append(config.Env, "WORKERS=4")
+		},
+		Progress: func(p Progress) {
+			steps = append(steps, string(p.Step)+" "+p.Container)
+			if p.Step == StepDone && p.Done != 2 {
+				t.Errorf("expected 2 replicas done, got %+v", p)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AssertExpectations(t)
+
+	expected := []string{
+		"pull ",
+		"start web_1", "start web_2",
+		"ready web_1", "ready web_2",
+		"replaced web_1", "replaced web_2",
+		"done ",
+	}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Fatalf("expected the steps %q, got %q", expected, steps)
+	}
+	if !reflect.DeepEqual(result.Updated, map[string]string{"web_1": "n1", "web_2": "n2"}) || !reflect.DeepEqual(result.Skipped, []string{"web_3"}) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	created := createdConfigs(client, "web_1_next")[0]
+	if created.Image != "web:2" || !reflect.DeepEqual(created.Env, []string{"LEVEL=debug", "WORKERS=4"}) {
+		t.Fatalf("unexpected replacement config %+v", created)
+	}
+}
+
+func TestUpdateRollback(t *testing.T) {
+	client := newGroup()
+	expectReplace(client, "c1", "web_1", "_next", "n1")
+	client.On("InspectContainer", "n1").Return(running("n1"), nil)
+	// the replacement of web_2 exits and is removed
+	client.On("CreateContainer", mock.Anything, "web_2_next", noAuth).Return("n2", nil).Once()
+	client.On("StartContainer", "n2", noHostConfig).Return(nil).Once()
+	client.On("InspectContainer", "n2").Return(&dockerclient.ContainerInfo{Id: "n2", State: &dockerclient.State{}}, nil)
+	client.On("RemoveContainer", "n2", true, true).Return(nil).Once()
+	// web_1 is restored
+	expectReplace(client, "n1", "web_1", "_prev", "r1")
+	client.On("InspectContainer", "r1").Return(running("r1"), nil)
+
+	var failed Progress
+	result, err := Update(client, &Options{
+		Label:       "app=web",
+		Image:       "web:2",
+		StopTimeout: 10,
+		Progress: func(p Progress) {
+			if p.Step == StepFailed {
+				failed = p
+			}
+		},
+	})
+	rerr, ok := err.(*Error)
+	if !ok || rerr.Step != "ready" || rerr.Container != "web_2" || rerr.Err != dockerclient.ErrContainerNotRunning || rerr.RollbackErr != nil {
+		t.Fatalf("expected a rolled back ready error, got %v", err)
+	}
+	if failed.Container != "web_2" || failed.Done != 1 || failed.Total != 2 {
+		t.Fatalf("unexpected failure progress %+v", failed)
+	}
+	if !result.RolledBack || len(result.Updated) != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	client.AssertExpectations(t)
+
+	updated, restored := createdConfigs(client, "web_1_next"), createdConfigs(client, "web_1_prev")
+	if len(updated) != 1 || updated[0].Image != "web:2" || len(restored) != 1 || restored[0].Image != "web:1" {
+		t.Fatalf("expected web_1 to be restored with its former image, got %+v %+v", updated, restored)
+	}
+	last := client.Calls[len(client.Calls)-1]
+	if last.Method != "RenameContainer" || last.Arguments.String(0) != "r1" {
+		t.Fatalf("expected the rollback to end with web_1 renamed, got %s %v", last.Method, last.Arguments)
+	}
+}
+
+func TestUpdateRenameFailure(t *testing.T) {
+	client := newGroup()
+	// web_1 is replaced but its replacement keeps its temporary name
+	client.On("CreateContainer", mock.Anything, "web_1_next", noAuth).Return("n1", nil).Once()
+	client.On("StartContainer", "n1", noHostConfig).Return(nil).Once()
+	client.On("InspectContainer", "n1").Return(running("n1"), nil)
+	client.On("StopContainer", "c1", 10).Return(nil).Once()
+	client.On("RemoveContainer", "c1", true, false).Return(nil).Once()
+	client.On("RenameContainer", "n1", "web_1").Return(errors.New("name in use")).Once()
+	// and is the one replaced by the rollback
+	expectReplace(client, "n1", "web_1", "_prev", "r1")
+	client.On("InspectContainer", "r1").Return(running("r1"), nil)
+
+	result, err := Update(client, &Options{Label: "app=web", Image: "web:2", StopTimeout: 10})
+	if rerr, ok := err.(*Error); !ok || rerr.Step != "rename" || rerr.Container != "web_1" || rerr.RollbackErr != nil {
+		t.Fatalf("expected a rolled back rename error, got %v", err)
+	}
+	if !result.RolledBack {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	client.AssertExpectations(t)
+}
+
+func TestUpdateNetworksAndVolumes(t *testing.T) {
+	engine := fakeengine.New()
+	engine.AddImage("web:1", &dockerclient.ContainerConfig{Volumes: map[string]struct{}{"/cache": {}}})
+	server := engine.Serve()
+	defer server.Close()
+	client, err := dockerclient.NewDockerClient(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, network := range []string{"front", "back"} {
+		if _, err := client.CreateNetwork(&dockerclient.NetworkCreate{Name: network}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a replica on two networks, with an anonymous volume
+	config := &dockerclient.ContainerConfig{Image: "web:1", Labels: map[string]string{"app": "web"}}
+	config.HostConfig.NetworkMode = "front"
+	config.NetworkingConfig.EndpointsConfig = map[string]*dockerclient.EndpointSettings{
+		"front": {Aliases: []string{"www"}},
+		"back":  {Aliases: []string{"api"}},
+	}
+	id, err := dockerclient.CreateConnectedContainer(client, config, "web_1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.StartContainer(id, nil); err != nil {
+		t.Fatal(err)
+	}
+	old, err := client.InspectContainer(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Update(client, &Options{Label: "app=web", Image: "web:2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := client.InspectContainer("web_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Id != result.Updated["web_1"] || info.Config.Image != "web:2" || !info.State.Running {
+		t.Fatalf("expected web_1 to be updated, got %+v", info)
+	}
+	for network, alias := range map[string]string{"front": "www", "back": "api"} {
+		if endpoint := info.NetworkSettings.Networks[network]; endpoint == nil || endpoint.Aliases[0] != alias {
+			t.Fatalf("expected web_1 to have the alias %s on %s, got %+v", alias, network, info.NetworkSettings.Networks)
+		}
+	}
+	if len(info.Mounts) != 1 || info.Mounts[0].Name != old.Mounts[0].Name {
+		t.Fatalf("expected web_1 to keep the volume %s, got %+v", old.Mounts[0].Name, info.Mounts)
+	}
+}
+
+func TestUpdateErrors(t *testing.T) {
+	if _, err := Update(nil, &Options{Image: "web:2"}); err != ErrNoLabel {
+		t.Fatalf("expected ErrNoLabel, got %v", err)
+	}
+	client := mockclient.NewMockClient()
+	client.On("PullImage", "web:2", noAuth).Return(errors.New("not found"))
+	_, err := Update(client, &Options{Label: "app=web", Image: "web:2"})
+	if rerr, ok := err.(*Error); !ok || rerr.Step != "pull" {
+		t.Fatalf("expected a pull error, got %v", err)
+	}
+}
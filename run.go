@@ -0,0 +1,143 @@
+package dockerclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+var ErrRunCanceled = errors.New("Run canceled")
+
+// RunOptions configures Run
+type RunOptions struct {
+	Config *ContainerConfig
+	Name   string
+	// Auth is used both to pull the image and to create the container
+	Auth *AuthConfig
+	// Stdout and Stderr receive the output of the container. With a TTY
+	// the output isn't multiplexed and is all written to Stdout. Nil
+	// writers aren't attached.
+	Stdout io.Writer
+	Stderr io.Writer
+	// AutoRemove removes the container and its volumes once it has exited
+	AutoRemove bool
+}
+
+// RunError is returned by Run when one of its steps fails
+type RunError struct {
+	Step        string // pull, create, attach, start, wait or remove
+	ContainerID string // empty if the container wasn't created
+	Err         error
+}
+
+func (e *RunError) Error() string {
+	if e.ContainerID == "" {
+		return fmt.Sprintf("run: %s: %v", e.Step, e.Err)
+	}
+	return fmt.Sprintf("run: %s %s: %v", e.Step, e.ContainerID, e.Err)
+}
+
+// Unwrap returns the error of the step, for errors.Is and errors.As
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// Run creates a container, pulling its image first if it isn't present,
+// starts it, streams its output to the writers of options and waits for it
+// to exit. It returns the exit code of the container, or -1 and a *RunError
+// if a step failed.
+//
+// Closing stopChan kills the container; it is also removed if AutoRemove
+// is set. The returned error then wraps ErrRunCanceled.
+func Run(client Client, options *RunOptions, stopChan <-chan struct{}) (int, error) {
+	config := options.Config
+	id, err := client.CreateContainer(config, options.Name, options.Auth)
+	if err == ErrImageNotFound {
+		if err := client.PullImage(config.Image, options.Auth); err != nil {
+			return -1, &RunError{Step: "pull", Err: err}
+		}
+		if isClosed(stopChan) {
+			return -1, &RunError{Step: "pull", Err: ErrRunCanceled}
+		}
+		id, err = client.CreateContainer(config, options.Name, options.Auth)
+	}
+	if err != nil {
+		return -1, &RunError{Step: "create", Err: err}
+	}
+
+	fail := func(step string, err error) (int, error) {
+		client.KillContainer(id, "KILL")
+		if options.AutoRemove {
+			client.RemoveContainer(id, true, true)
+		}
+		return -1, &RunError{Step: step, ContainerID: id, Err: err}
+	}
+	if isClosed(stopChan) {
+		return fail("create", ErrRunCanceled)
+	}
+
+	// attach before starting, so that no output is lost
+	var copied chan error
+	if options.Stdout != nil || options.Stderr != nil {
+		stream, err := client.AttachContainer(id, &AttachOptions{
+			Stream: true,
+			Stdout: options.Stdout != nil,
+			Stderr: options.Stderr != nil,
+		})
+		if err != nil {
+			return fail("attach", err)
+		}
+		defer stream.Close()
+		copied = make(chan error, 1)
+		go func() {
+			copied <- copyOutput(stream, options.Stdout, options.Stderr, config.Tty)
+		}()
+	}
+
+	if err := client.StartContainer(id, nil); err != nil {
+		return fail("start", err)
+	}
+
+	result := <-client.WaitWithOptions(id, nil, stopChan)
+	if result.Error == ErrWaitCanceled {
+		return fail("wait", ErrRunCanceled)
+	}
+	if result.Error != nil {
+		return fail("wait", result.Error)
+	}
+
+	// the attach stream ends with the container, drain what's left of it
+	if copied != nil {
+		select {
+		case <-copied:
+		case <-stopChan:
+			return fail("attach", ErrRunCanceled)
+		}
+	}
+
+	if options.AutoRemove {
+		if err := client.RemoveContainer(id, true, true); err != nil {
+			return result.ExitCode, &RunError{Step: "remove", ContainerID: id, Err: err}
+		}
+	}
+	return result.ExitCode, nil
+}
+
+// copyOutput copies an attach stream to stdout and stderr
+func copyOutput(stream io.Reader, stdout, stderr io.Writer, tty bool) error {
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	if tty {
+		_, err := io.Copy(stdout, stream)
+		return err
+	}
+	_, err := stdcopy.StdCopy(stdout, stderr, stream)
+	return err
+}
@@ -0,0 +1,62 @@
+package dockerclient
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	client := testDockerClient(t)
+	var stdout, stderr bytes.Buffer
+	code, err := Run(client, &RunOptions{
+		Config:     &ContainerConfig{Image: "notpulled", Cmd: []string{"false"}},
+		Stdout:     &stdout,
+		Stderr:     &stderr,
+		AutoRemove: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, code, 2, "")
+	assertEqual(t, stdout.String(), "hello\n", "")
+	assertEqual(t, stderr.String(), "oops\n", "")
+	if !pulledImages["notpulled"] {
+		t.Fatal("the missing image should have been pulled")
+	}
+	if !removedContainers["notpulled-run-id"] {
+		t.Fatal("the container should have been removed")
+	}
+}
+
+func TestRunPullFailure(t *testing.T) {
+	client := testDockerClient(t)
+	_, err := Run(client, &RunOptions{Config: &ContainerConfig{Image: "wrongimg"}}, nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	runErr, ok := err.(*RunError)
+	if !ok {
+		t.Fatalf("expected a *RunError, got %T", err)
+	}
+	assertEqual(t, runErr.Step, "pull", "")
+}
+
+func TestRunCanceled(t *testing.T) {
+	client := testDockerClient(t)
+	stopChan := make(chan struct{})
+	time.AfterFunc(50*time.Millisecond, func() { close(stopChan) })
+	code, err := Run(client, &RunOptions{
+		Config:     &ContainerConfig{Image: "sleep"},
+		AutoRemove: true,
+	}, stopChan)
+	assertEqual(t, code, -1, "")
+	runErr, ok := err.(*RunError)
+	if !ok || runErr.Err != ErrRunCanceled || !errors.Is(err, ErrRunCanceled) {
+		t.Fatalf("expected a canceled *RunError, got %v", err)
+	}
+	if !removedContainers["sleep-run-id"] {
+		t.Fatal("the canceled container should have been removed")
+	}
+}
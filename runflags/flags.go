@@ -0,0 +1,571 @@
+package runflags
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/samalba/dockerclient"
+)
+
+// cpuPeriod is the CFS period --cpus sets, 100ms in microseconds
+const cpuPeriod = 100000
+
+type flag struct {
+	long   string
+	short  byte
+	isBool bool
+	set    func(p *parser, value string) error
+	// unsupported explains why a flag of docker run is rejected
+	unsupported string
+}
+
+func (f *flag) String() string {
+	if f.short != 0 {
+		return fmt.Sprintf("-%c, --%s", f.short, f.long)
+	}
+	return "--" + f.long
+}
+
+var (
+	longFlags  = make(map[string]*flag)
+	shortFlags = make(map[byte]*flag)
+)
+
+func init() {
+	for i := range flags {
+		f := &flags[i]
+		longFlags[f.long] = f
+		if f.short != 0 {
+			shortFlags[f.short] = f
+		}
+	}
+	// deprecated spelling of --network
+	longFlags["net"] = longFlags["network"]
+}
+
+func stringFlag(long string, short byte, field func(p *parser) *string) flag {
+	return flag{long: long, short: short, set: func(p *parser, value string) error {
+		*field(p) = value
+		return nil
+	}}
+}
+
+func listFlag(long string, short byte, field func(p *parser) *[]string) flag {
+	return flag{long: long, short: short, set: func(p *parser, value string) error {
+		list := field(p)
+		*list = append(*list, value)
+		return nil
+	}}
+}
+
+func boolFlag(long string, short byte, field func(p *parser) *bool) flag {
+	return flag{long: long, short: short, isBool: true, set: func(p *parser, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("expected a boolean")
+		}
+		*field(p) = b
+		return nil
+	}}
+}
+
+func sizeFlag(long string, short byte, allowUnlimited bool, field func(p *parser) *int64) flag {
+	return flag{long: long, short: short, set: func(p *parser, value string) error {
+		if allowUnlimited && value == "-1" {
+			*field(p) = -1
+			return nil
+		}
+		size, err := units.RAMInBytes(value)
+		if err != nil || size < 0 {
+			return errors.New("expected a size such as 512m")
+		}
+		*field(p) = size
+		return nil
+	}}
+}
+
+func intFlag(long string, short byte, field func(p *parser) *int64) flag {
+	return flag{long: long, short: short, set: func(p *parser, value string) error {
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.New("expected an integer")
+		}
+		*field(p) = i
+		return nil
+	}}
+}
+
+func durationFlag(long string, field func(p *parser) *time.Duration) flag {
+	return flag{long: long, set: func(p *parser, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("expected a duration such as 1m30s")
+		}
+		if d < 0 {
+			return errors.New("duration can't be negative")
+		}
+		*field(p) = d
+		p.healthSet = true
+		return nil
+	}}
+}
+
+func unsupported(long, reason string) flag {
+	return flag{long: long, unsupported: reason}
+}
+
+func config(p *parser) *dockerclient.ContainerConfig { return p.cmd.Config }
+func host(p *parser) *dockerclient.HostConfig        { return &p.cmd.Config.HostConfig }
+
+var flags = []flag{
+	stringFlag("name", 0, func(p *parser) *string { return &p.cmd.Name }),
+	boolFlag("detach", 'd', func(p *parser) *bool { return &p.cmd.Detach }),
+	boolFlag("rm", 0, func(p *parser) *bool { return &p.cmd.Remove }),
+	{long: "interactive", short: 'i', isBool: true, set: func(p *parser, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("expected a boolean")
+		}
+		p.interactive, config(p).OpenStdin = b, b
+		return nil
+	}},
+	boolFlag("tty", 't', func(p *parser) *bool { return &config(p).Tty }),
+	{long: "attach", short: 'a', set: func(p *parser, value string) error {
+		switch strings.ToLower(value) {
+		case "stdin", "stdout", "stderr":
+		default:
+			return errors.New("expected stdin, stdout or stderr")
+		}
+		p.attach = append(p.attach, value)
+		return nil
+	}},
+
+	{long: "env", short: 'e', set: func(p *parser, value string) error {
+		if value == "" || strings.HasPrefix(value, "=") {
+			return errors.New("variable name is empty")
+		}
+		if !strings.Contains(value, "=") {
+			// docker run takes the value from its environment
+			if kv, ok := lookupEnv(value); ok {
+				p.env = append(p.env, kv)
+			}
+			return nil
+		}
+		p.env = append(p.env, value)
+		return nil
+	}},
+	listFlag("env-file", 0, func(p *parser) *[]string { return &p.envFiles }),
+	{long: "label", short: 'l', set: func(p *parser, value string) error {
+		parts := strings.SplitN(value, "=", 2)
+		if parts[0] == "" {
+			return errors.New("label name is empty")
+		}
+		c := config(p)
+		if c.Labels == nil {
+			c.Labels = make(map[string]string)
+		}
+		c.Labels[parts[0]] = ""
+		if len(parts) == 2 {
+			c.Labels[parts[0]] = parts[1]
+		}
+		return nil
+	}},
+	stringFlag("hostname", 'h', func(p *parser) *string { return &config(p).Hostname }),
+	stringFlag("domainname", 0, func(p *parser) *string { return &config(p).Domainname }),
+	stringFlag("user", 'u', func(p *parser) *string { return &config(p).User }),
+	{long: "workdir", short: 'w', set: func(p *parser, value string) error {
+		if !path.IsAbs(value) {
+			return errors.New("working directory must be an absolute path")
+		}
+		config(p).WorkingDir = value
+		return nil
+	}},
+	{long: "entrypoint", set: func(p *parser, value string) error {
+		// an empty entrypoint resets the one of the image
+		config(p).Entrypoint = []string{value}
+		return nil
+	}},
+	stringFlag("stop-signal", 0, func(p *parser) *string { return &config(p).StopSignal }),
+	stringFlag("mac-address", 0, func(p *parser) *string { return &config(p).MacAddress }),
+
+	{long: "publish", short: 'p', set: func(p *parser, value string) error {
+		spec, err := dockerclient.ParsePortSpec(value)
+		if err != nil {
+			return err
+		}
+		config(p).PublishPort(spec)
+		return nil
+	}},
+	boolFlag("publish-all", 'P', func(p *parser) *bool { return &host(p).PublishAllPorts }),
+	{long: "expose", set: func(p *parser, value string) error {
+		if strings.Contains(value, ":") {
+			return errors.New("expose takes container ports only, use --publish to publish them")
+		}
+		spec, err := dockerclient.ParsePortSpec(value)
+		if err != nil {
+			return err
+		}
+		for port := range spec.Bindings() {
+			config(p).ExposePort(port)
+		}
+		return nil
+	}},
+
+	{long: "volume", short: 'v', set: parseVolume},
+	{long: "mount", set: func(p *parser, value string) error {
+		m, err := dockerclient.ParseMount(value)
+		if err != nil {
+			return err
+		}
+		h := host(p)
+		h.Mounts = append(h.Mounts, *m)
+		return nil
+	}},
+	{long: "tmpfs", set: func(p *parser, value string) error {
+		parts := strings.SplitN(value, ":", 2)
+		if !path.IsAbs(parts[0]) {
+			return fmt.Errorf("mount path %q must be absolute", parts[0])
+		}
+		h := host(p)
+		if h.Tmpfs == nil {
+			h.Tmpfs = make(map[string]string)
+		}
+		h.Tmpfs[parts[0]] = ""
+		if len(parts) == 2 {
+			h.Tmpfs[parts[0]] = parts[1]
+		}
+		return nil
+	}},
+	listFlag("volumes-from", 0, func(p *parser) *[]string { return &host(p).VolumesFrom }),
+	stringFlag("volume-driver", 0, func(p *parser) *string { return &host(p).VolumeDriver }),
+
+	{long: "network", set: func(p *parser, value string) error {
+		if value == "" {
+			return errors.New("network name is empty")
+		}
+		p.networks = append(p.networks, value)
+		return nil
+	}},
+	listFlag("network-alias", 0, func(p *parser) *[]string { return &p.aliases }),
+	{long: "ip", set: func(p *parser, value string) error {
+		if ip := net.ParseIP(value); ip == nil || ip.To4() == nil {
+			return errors.New("expected an IPv4 address")
+		}
+		p.ipv4 = value
+		return nil
+	}},
+	{long: "ip6", set: func(p *parser, value string) error {
+		if ip := net.ParseIP(value); ip == nil || ip.To4() != nil {
+			return errors.New("expected an IPv6 address")
+		}
+		p.ipv6 = value
+		return nil
+	}},
+	{long: "link", set: func(p *parser, value string) error {
+		parts := strings.Split(value, ":")
+		if len(parts) > 2 || parts[0] == "" {
+			return errors.New("expected name[:alias]")
+		}
+		if len(parts) == 1 {
+			value += ":" + parts[0]
+		}
+		h := host(p)
+		h.Links = append(h.Links, value)
+		return nil
+	}},
+	listFlag("dns", 0, func(p *parser) *[]string { return &host(p).Dns }),
+	listFlag("dns-search", 0, func(p *parser) *[]string { return &host(p).DnsSearch }),
+	listFlag("dns-option", 0, func(p *parser) *[]string { return &host(p).DNSOptions }),
+	{long: "add-host", set: func(p *parser, value string) error {
+		parts := strings.SplitN(value, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return errors.New("expected host:ip")
+		}
+		if parts[1] != "host-gateway" && net.ParseIP(parts[1]) == nil {
+			return fmt.Errorf("%q isn't an IP address", parts[1])
+		}
+		h := host(p)
+		h.ExtraHosts = append(h.ExtraHosts, value)
+		return nil
+	}},
+
+	{long: "restart", set: func(p *parser, value string) error {
+		policy, err := parseRestart(value)
+		if err != nil {
+			return err
+		}
+		host(p).RestartPolicy = policy
+		return nil
+	}},
+	{long: "ulimit", set: func(p *parser, value string) error {
+		ulimit, err := parseUlimit(value)
+		if err != nil {
+			return err
+		}
+		h := host(p)
+		h.Ulimits = append(h.Ulimits, ulimit)
+		return nil
+	}},
+	{long: "device", set: func(p *parser, value string) error {
+		device, err := parseDevice(value)
+		if err != nil {
+			return err
+		}
+		h := host(p)
+		h.Devices = append(h.Devices, device)
+		return nil
+	}},
+	listFlag("cap-add", 0, func(p *parser) *[]string { return &host(p).CapAdd }),
+	listFlag("cap-drop", 0, func(p *parser) *[]string { return &host(p).CapDrop }),
+	listFlag("security-opt", 0, func(p *parser) *[]string { return &host(p).SecurityOpt }),
+	listFlag("group-add", 0, func(p *parser) *[]string { return &host(p).GroupAdd }),
+	boolFlag("privileged", 0, func(p *parser) *bool { return &host(p).Privileged }),
+	boolFlag("read-only", 0, func(p *parser) *bool { return &host(p).ReadonlyRootfs }),
+	boolFlag("oom-kill-disable", 0, func(p *parser) *bool { return &host(p).OomKillDisable }),
+	{long: "oom-score-adj", set: func(p *parser, value string) error {
+		score, err := strconv.Atoi(value)
+		if err != nil || score < -1000 || score > 1000 {
+			return errors.New("expected an integer between -1000 and 1000")
+		}
+		host(p).OomScoreAdj = score
+		return nil
+	}},
+	stringFlag("pid", 0, func(p *parser) *string { return &host(p).PidMode }),
+	stringFlag("ipc", 0, func(p *parser) *string { return &host(p).IpcMode }),
+	stringFlag("uts", 0, func(p *parser) *string { return &host(p).UTSMode }),
+	stringFlag("cgroup-parent", 0, func(p *parser) *string { return &host(p).CgroupParent }),
+
+	sizeFlag("memory", 'm', false, func(p *parser) *int64 { return &host(p).Memory }),
+	sizeFlag("memory-swap", 0, true, func(p *parser) *int64 { return &host(p).MemorySwap }),
+	sizeFlag("memory-reservation", 0, false, func(p *parser) *int64 { return &host(p).MemoryReservation }),
+	sizeFlag("kernel-memory", 0, false, func(p *parser) *int64 { return &host(p).KernelMemory }),
+	sizeFlag("shm-size", 0, false, func(p *parser) *int64 { return &host(p).ShmSize }),
+	intFlag("memory-swappiness", 0, func(p *parser) *int64 { return &host(p).MemorySwappiness }),
+	{long: "cpus", set: func(p *parser, value string) error {
+		cpus, err := strconv.ParseFloat(value, 64)
+		if err != nil || cpus <= 0 {
+			return errors.New("expected a positive number of CPUs such as 1.5")
+		}
+		p.cpus = int64(cpus*cpuPeriod + 0.5)
+		return nil
+	}},
+	intFlag("cpu-shares", 'c', func(p *parser) *int64 { return &host(p).CpuShares }),
+	{long: "cpu-period", set: func(p *parser, value string) error {
+		period, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.New("expected an integer")
+		}
+		host(p).CpuPeriod, p.cpuPeriodSet = period, true
+		return nil
+	}},
+	intFlag("cpu-quota", 0, func(p *parser) *int64 { return &host(p).CpuQuota }),
+	stringFlag("cpuset-cpus", 0, func(p *parser) *string { return &host(p).CpusetCpus }),
+	stringFlag("cpuset-mems", 0, func(p *parser) *string { return &host(p).CpusetMems }),
+	intFlag("blkio-weight", 0, func(p *parser) *int64 { return &host(p).BlkioWeight }),
+
+	stringFlag("log-driver", 0, func(p *parser) *string { return &host(p).LogConfig.Type }),
+	{long: "log-opt", set: func(p *parser, value string) error {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return errors.New("expected key=value")
+		}
+		h := host(p)
+		if h.LogConfig.Config == nil {
+			h.LogConfig.Config = make(map[string]string)
+		}
+		h.LogConfig.Config[parts[0]] = parts[1]
+		return nil
+	}},
+
+	{long: "health-cmd", set: func(p *parser, value string) error {
+		p.health.Test, p.healthSet = []string{"CMD-SHELL", value}, true
+		return nil
+	}},
+	durationFlag("health-interval", func(p *parser) *time.Duration { return &p.health.Interval }),
+	durationFlag("health-timeout", func(p *parser) *time.Duration { return &p.health.Timeout }),
+	durationFlag("health-start-period", func(p *parser) *time.Duration { return &p.health.StartPeriod }),
+	{long: "health-retries", set: func(p *parser, value string) error {
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			return errors.New("expected a positive integer")
+		}
+		p.health.Retries, p.healthSet = retries, true
+		return nil
+	}},
+	boolFlag("no-healthcheck", 0, func(p *parser) *bool { return &p.noHealth }),
+
+	unsupported("stop-timeout", "the container config has no stop timeout"),
+	unsupported("init", "the host config has no init setting"),
+	unsupported("sysctl", "the host config has no sysctls"),
+	unsupported("runtime", "the host config has no runtime setting"),
+	unsupported("userns", "the host config has no user namespace mode"),
+	unsupported("platform", "pull the image for the platform first"),
+	unsupported("pull", "pull the image first"),
+	unsupported("gpus", "the host config has no device requests"),
+	unsupported("cidfile", "write the ID CreateContainer returns instead"),
+	unsupported("sig-proxy", "signals are forwarded by the caller"),
+	unsupported("detach-keys", "attaching is up to the caller"),
+}
+
+// parseVolume parses [source:]target[:options], source being a named
+// volume or a path of the host
+func parseVolume(p *parser, value string) error {
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return errors.New("expected [source:]target[:options]")
+	}
+	target := parts[0]
+	if len(parts) > 1 {
+		target = parts[1]
+	}
+	if !path.IsAbs(target) {
+		return fmt.Errorf("mount path %q must be absolute", target)
+	}
+	if len(parts) == 1 {
+		anonymous(config(p), target)
+		return nil
+	}
+	source := parts[0]
+	if source == "" {
+		return errors.New("source is empty")
+	}
+	if isPath(source) {
+		abs, err := filepath.Abs(source)
+		if err != nil {
+			return err
+		}
+		parts[0] = abs
+	}
+	bind, err := dockerclient.ParseBindSpec(strings.Join(parts, ":"))
+	if err != nil {
+		return err
+	}
+	h := host(p)
+	h.Binds = append(h.Binds, bind.String())
+	return nil
+}
+
+func isPath(s string) bool {
+	return strings.HasPrefix(s, "/") || strings.HasPrefix(s, ".") || strings.HasPrefix(s, "~")
+}
+
+func anonymous(c *dockerclient.ContainerConfig, target string) {
+	if c.Volumes == nil {
+		c.Volumes = make(map[string]struct{})
+	}
+	c.Volumes[target] = struct{}{}
+}
+
+// parseRestart parses no, always, unless-stopped or
+// on-failure[:max-retries]
+func parseRestart(s string) (dockerclient.RestartPolicy, error) {
+	parts := strings.SplitN(s, ":", 2)
+	policy := dockerclient.RestartPolicy{Name: parts[0]}
+	switch parts[0] {
+	case "no", "always", "unless-stopped":
+		if len(parts) == 2 {
+			return policy, fmt.Errorf("maximum retry count can't be used with restart policy %s", parts[0])
+		}
+	case "on-failure":
+		if len(parts) == 2 {
+			count, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil || count < 0 {
+				return policy, fmt.Errorf("maximum retry count %q isn't a positive integer", parts[1])
+			}
+			policy.MaximumRetryCount = count
+		}
+	default:
+		return policy, fmt.Errorf("invalid restart policy %s, expected no, always, unless-stopped or on-failure[:max-retries]", parts[0])
+	}
+	return policy, nil
+}
+
+var ulimitNames = map[string]bool{
+	"core": true, "cpu": true, "data": true, "fsize": true, "locks": true, "memlock": true,
+	"msgqueue": true, "nice": true, "nofile": true, "nproc": true, "rss": true, "rtprio": true,
+	"rttime": true, "sigpending": true, "stack": true,
+}
+
+// parseUlimit parses name=soft[:hard]
+func parseUlimit(s string) (dockerclient.Ulimit, error) {
+	parts := strings.SplitN(s, "=", 2)
+	ulimit := dockerclient.Ulimit{Name: parts[0]}
+	if len(parts) != 2 {
+		return ulimit, errors.New("expected name=soft[:hard]")
+	}
+	if !ulimitNames[ulimit.Name] {
+		return ulimit, fmt.Errorf("invalid ulimit type %q", ulimit.Name)
+	}
+	limits := strings.SplitN(parts[1], ":", 2)
+	var err error
+	if ulimit.Soft, err = parseLimit(limits[0]); err != nil {
+		return ulimit, err
+	}
+	ulimit.Hard = ulimit.Soft
+	if len(limits) == 2 {
+		if ulimit.Hard, err = parseLimit(limits[1]); err != nil {
+			return ulimit, err
+		}
+		if ulimit.Soft > ulimit.Hard {
+			return ulimit, fmt.Errorf("soft limit %d is greater than hard limit %d", ulimit.Soft, ulimit.Hard)
+		}
+	}
+	return ulimit, nil
+}
+
+func parseLimit(s string) (uint64, error) {
+	if s == "-1" {
+		// unlimited
+		return ^uint64(0), nil
+	}
+	limit, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("limit %q isn't a positive integer", s)
+	}
+	return limit, nil
+}
+
+// parseDevice parses host[:container][:permissions]
+func parseDevice(s string) (dockerclient.DeviceMapping, error) {
+	parts := strings.Split(s, ":")
+	device := dockerclient.DeviceMapping{PathOnHost: parts[0], CgroupPermissions: "rwm"}
+	switch {
+	case len(parts) == 3:
+		device.PathInContainer, device.CgroupPermissions = parts[1], parts[2]
+	case len(parts) == 2 && isPermissions(parts[1]):
+		device.CgroupPermissions = parts[1]
+	case len(parts) == 2:
+		device.PathInContainer = parts[1]
+	case len(parts) > 3:
+		return device, errors.New("expected host[:container][:permissions]")
+	}
+	if device.PathInContainer == "" {
+		device.PathInContainer = device.PathOnHost
+	}
+	if !path.IsAbs(device.PathOnHost) || !path.IsAbs(device.PathInContainer) {
+		return device, errors.New("device paths must be absolute")
+	}
+	if !isPermissions(device.CgroupPermissions) {
+		return device, fmt.Errorf("invalid permissions %q, expected a combination of r, w and m", device.CgroupPermissions)
+	}
+	return device, nil
+}
+
+func isPermissions(s string) bool {
+	if s == "" || len(s) > 3 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("rwm", c) {
+			return false
+		}
+	}
+	return true
+}
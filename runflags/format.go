@@ -0,0 +1,363 @@
+package runflags
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+)
+
+// defaultShmSize is the size of /dev/shm when --shm-size isn't given
+const defaultShmSize = 64 * 1024 * 1024
+
+// Format renders info as an equivalent docker run command line, starting
+// with "docker run". The settings are rendered as inspected, those
+// inherited from the image, such as its environment, included. Only the
+// network given to --network gets its aliases and addresses, as docker run
+// can't set them on the others.
+func Format(info *dockerclient.ContainerInfo) []string {
+	args := []string{"docker", "run"}
+	add := func(flag string, values ...string) {
+		for _, value := range values {
+			args = append(args, flag, value)
+		}
+	}
+	addIf := func(flag string, value string) {
+		if value != "" {
+			args = append(args, flag, value)
+		}
+	}
+	addBool := func(flag string, value bool) {
+		if value {
+			args = append(args, flag)
+		}
+	}
+
+	c := info.Config
+	if c == nil {
+		c = &dockerclient.ContainerConfig{}
+	}
+	h := info.HostConfig
+	if h == nil {
+		h = &c.HostConfig
+	}
+
+	addBool("-d", !c.AttachStdout && !c.AttachStderr)
+	addBool("-i", c.OpenStdin)
+	addBool("-t", c.Tty)
+	addIf("--name", strings.TrimPrefix(info.Name, "/"))
+	if len(info.Id) < 12 || c.Hostname != info.Id[:12] {
+		addIf("--hostname", c.Hostname)
+	}
+	addIf("--domainname", c.Domainname)
+	addIf("--user", c.User)
+	addIf("--workdir", c.WorkingDir)
+	add("-e", c.Env...)
+	for _, key := range sortedKeys(c.Labels) {
+		add("-l", key+"="+c.Labels[key])
+	}
+
+	add("-p", formatPorts(h.PortBindings)...)
+	for _, port := range sortedSet(c.ExposedPorts) {
+		if _, ok := h.PortBindings[port]; !ok {
+			add("--expose", strings.TrimSuffix(port, "/tcp"))
+		}
+	}
+	addBool("-P", h.PublishAllPorts)
+
+	add("-v", h.Binds...)
+	bound := make(map[string]bool)
+	for _, bind := range h.Binds {
+		if parts := strings.Split(bind, ":"); len(parts) > 1 {
+			bound[parts[1]] = true
+		}
+	}
+	for _, m := range h.Mounts {
+		bound[m.Target] = true
+	}
+	for _, target := range sortedSet(c.Volumes) {
+		if !bound[target] && h.Tmpfs[target] == "" {
+			add("-v", target)
+		}
+	}
+	for _, target := range sortedKeys(h.Tmpfs) {
+		if options := h.Tmpfs[target]; options != "" {
+			target += ":" + options
+		}
+		add("--tmpfs", target)
+	}
+	for _, m := range h.Mounts {
+		add("--mount", m.String())
+	}
+	add("--volumes-from", h.VolumesFrom...)
+	addIf("--volume-driver", h.VolumeDriver)
+
+	args = append(args, formatNetworks(info, c, h)...)
+	for _, link := range h.Links {
+		add("--link", formatLink(link))
+	}
+	add("--dns", h.Dns...)
+	add("--dns-search", h.DnsSearch...)
+	add("--dns-option", h.DNSOptions...)
+	add("--add-host", h.ExtraHosts...)
+
+	if name := h.RestartPolicy.Name; name != "" && name != "no" {
+		if name == "on-failure" && h.RestartPolicy.MaximumRetryCount > 0 {
+			name += ":" + strconv.FormatInt(h.RestartPolicy.MaximumRetryCount, 10)
+		}
+		add("--restart", name)
+	}
+	for _, ulimit := range h.Ulimits {
+		value := ulimit.Name + "=" + formatLimit(ulimit.Soft)
+		if ulimit.Hard != ulimit.Soft {
+			value += ":" + formatLimit(ulimit.Hard)
+		}
+		add("--ulimit", value)
+	}
+	for _, device := range h.Devices {
+		value := device.PathOnHost
+		if device.PathInContainer != "" && device.PathInContainer != device.PathOnHost {
+			value += ":" + device.PathInContainer
+		}
+		if device.CgroupPermissions != "" && device.CgroupPermissions != "rwm" {
+			value += ":" + device.CgroupPermissions
+		}
+		add("--device", value)
+	}
+	add("--cap-add", h.CapAdd...)
+	add("--cap-drop", h.CapDrop...)
+	add("--security-opt", h.SecurityOpt...)
+	add("--group-add", h.GroupAdd...)
+	addBool("--privileged", h.Privileged)
+	addBool("--read-only", h.ReadonlyRootfs)
+	addBool("--oom-kill-disable", h.OomKillDisable)
+	if h.OomScoreAdj != 0 {
+		add("--oom-score-adj", strconv.Itoa(h.OomScoreAdj))
+	}
+	addIf("--pid", h.PidMode)
+	if h.IpcMode != "private" && h.IpcMode != "shareable" {
+		addIf("--ipc", h.IpcMode)
+	}
+	addIf("--uts", h.UTSMode)
+	addIf("--cgroup-parent", h.CgroupParent)
+
+	addIf("--memory", formatSize(h.Memory))
+	if h.MemorySwap == -1 {
+		add("--memory-swap", "-1")
+	} else {
+		addIf("--memory-swap", formatSize(h.MemorySwap))
+	}
+	addIf("--memory-reservation", formatSize(h.MemoryReservation))
+	addIf("--kernel-memory", formatSize(h.KernelMemory))
+	if h.ShmSize != defaultShmSize {
+		addIf("--shm-size", formatSize(h.ShmSize))
+	}
+	if h.MemorySwappiness > 0 {
+		add("--memory-swappiness", strconv.FormatInt(h.MemorySwappiness, 10))
+	}
+	if h.CpuPeriod == cpuPeriod && h.CpuQuota > 0 {
+		add("--cpus", strconv.FormatFloat(float64(h.CpuQuota)/cpuPeriod, 'f', -1, 64))
+	} else {
+		addIf("--cpu-period", formatInt(h.CpuPeriod))
+		addIf("--cpu-quota", formatInt(h.CpuQuota))
+	}
+	addIf("--cpu-shares", formatInt(h.CpuShares))
+	addIf("--cpuset-cpus", h.CpusetCpus)
+	addIf("--cpuset-mems", h.CpusetMems)
+	addIf("--blkio-weight", formatInt(h.BlkioWeight))
+
+	addIf("--log-driver", h.LogConfig.Type)
+	for _, key := range sortedKeys(h.LogConfig.Config) {
+		add("--log-opt", key+"="+h.LogConfig.Config[key])
+	}
+
+	if health := c.Healthcheck; health != nil {
+		switch {
+		case len(health.Test) > 0 && health.Test[0] == "NONE":
+			args = append(args, "--no-healthcheck")
+		case len(health.Test) > 1 && health.Test[0] == "CMD-SHELL":
+			add("--health-cmd", health.Test[1])
+		case len(health.Test) > 1 && health.Test[0] == "CMD":
+			add("--health-cmd", Quote(health.Test[1:]))
+		}
+		if health.Interval > 0 {
+			add("--health-interval", health.Interval.String())
+		}
+		if health.Timeout > 0 {
+			add("--health-timeout", health.Timeout.String())
+		}
+		if health.StartPeriod > 0 {
+			add("--health-start-period", health.StartPeriod.String())
+		}
+		if health.Retries > 0 {
+			add("--health-retries", strconv.Itoa(health.Retries))
+		}
+	}
+	addIf("--stop-signal", c.StopSignal)
+	addIf("--mac-address", c.MacAddress)
+
+	// --entrypoint takes a single word, the others go before the command
+	var cmd []string
+	if len(c.Entrypoint) > 0 {
+		add("--entrypoint", c.Entrypoint[0])
+		cmd = append(cmd, c.Entrypoint[1:]...)
+	}
+	cmd = append(cmd, c.Cmd...)
+	if len(cmd) > 0 && strings.HasPrefix(c.Image, "-") {
+		args = append(args, "--")
+	}
+	args = append(args, c.Image)
+	return append(args, cmd...)
+}
+
+// formatPorts renders port bindings as -p values, sorted by container port
+func formatPorts(bindings map[string][]dockerclient.PortBinding) []string {
+	keys := make([]string, 0, len(bindings))
+	for port := range bindings {
+		keys = append(keys, port)
+	}
+	sort.Strings(keys)
+	var ports []string
+	for _, port := range keys {
+		container := strings.TrimSuffix(port, "/tcp")
+		for _, b := range bindings[port] {
+			switch {
+			case b.HostIp == "" && b.HostPort == "":
+				ports = append(ports, container)
+			case b.HostIp == "":
+				ports = append(ports, b.HostPort+":"+container)
+			case strings.Contains(b.HostIp, ":"):
+				ports = append(ports, "["+b.HostIp+"]:"+b.HostPort+":"+container)
+			default:
+				ports = append(ports, b.HostIp+":"+b.HostPort+":"+container)
+			}
+		}
+	}
+	return ports
+}
+
+// formatNetworks renders the network mode and the other networks of the
+// container
+func formatNetworks(info *dockerclient.ContainerInfo, c *dockerclient.ContainerConfig, h *dockerclient.HostConfig) []string {
+	endpoints := info.NetworkSettings.Networks
+	if len(endpoints) == 0 {
+		endpoints = c.NetworkingConfig.EndpointsConfig
+	}
+	var args []string
+	mode := h.NetworkMode
+	if mode != "" && mode != "default" && mode != "bridge" {
+		args = append(args, "--network", mode)
+		if settings := endpoints[mode]; settings != nil && isUserDefined(mode) {
+			for _, alias := range settings.Aliases {
+				// the engine adds the short ID of the container
+				if len(alias) < 12 || !strings.HasPrefix(info.Id, alias) {
+					args = append(args, "--network-alias", alias)
+				}
+			}
+			if ipam := settings.IPAMConfig; ipam != nil {
+				if ipam.IPv4Address != "" {
+					args = append(args, "--ip", ipam.IPv4Address)
+				}
+				if ipam.IPv6Address != "" {
+					args = append(args, "--ip6", ipam.IPv6Address)
+				}
+			}
+		}
+	}
+	var others []string
+	for network := range endpoints {
+		if network != mode && isUserDefined(network) {
+			others = append(others, network)
+		}
+	}
+	sort.Strings(others)
+	for _, network := range others {
+		args = append(args, "--network", network)
+	}
+	return args
+}
+
+// formatLink renders a link as name:alias, the engine reporting them as
+// /name:/container/alias
+func formatLink(link string) string {
+	parts := strings.SplitN(link, ":", 2)
+	if len(parts) != 2 {
+		return link
+	}
+	name := strings.TrimPrefix(parts[0], "/")
+	alias := parts[1]
+	if i := strings.LastIndex(alias, "/"); i >= 0 {
+		alias = alias[i+1:]
+	}
+	if alias == name {
+		return name
+	}
+	return name + ":" + alias
+}
+
+func formatLimit(limit uint64) string {
+	if limit == ^uint64(0) {
+		return "-1"
+	}
+	return strconv.FormatUint(limit, 10)
+}
+
+// formatSize renders a number of bytes with the largest unit it is a
+// multiple of, "" for 0
+func formatSize(size int64) string {
+	if size <= 0 {
+		return ""
+	}
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"g", 1 << 30}, {"m", 1 << 20}, {"k", 1 << 10}} {
+		if size%unit.size == 0 {
+			return fmt.Sprintf("%d%s", size/unit.size, unit.suffix)
+		}
+	}
+	return strconv.FormatInt(size, 10)
+}
+
+func formatInt(i int64) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.FormatInt(i, 10)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedSet(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var safeWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// Quote joins args into a command line a shell splits back into args
+func Quote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if safeWord.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
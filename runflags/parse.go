@@ -0,0 +1,393 @@
+// Package runflags translates docker run command lines into the config
+// types of dockerclient, and containers back into docker run command
+// lines.
+//
+//	cmd, err := runflags.ParseLine(`docker run -d -p 8080:80 -e LEVEL=debug --name web nginx`)
+//	if err != nil {
+//		// err points to the faulty flag, such as
+//		// invalid argument "80:" for "-p, --publish" flag: ...
+//	}
+//	id, err := client.CreateContainer(cmd.Config, cmd.Name, nil)
+//
+//	args := runflags.Format(info)
+//	fmt.Println(runflags.Quote(args))
+//
+// The client side flags of docker run that have no equivalent in the
+// configs, such as --stop-timeout or --pull, are rejected.
+package runflags
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+)
+
+var ErrNoImage = errors.New("No image given")
+
+// Command is a parsed docker run command line
+type Command struct {
+	Name   string
+	Config *dockerclient.ContainerConfig
+	// Detach is set by -d, Remove by --rm. The container is run
+	// attached, and kept once it exits, otherwise.
+	Detach bool
+	Remove bool
+}
+
+// FlagError is an invalid flag, or an invalid value of a flag
+type FlagError struct {
+	Flag  string
+	Value string
+	Err   error
+}
+
+func (e *FlagError) Error() string {
+	return fmt.Sprintf("invalid argument %q for %q flag: %v", e.Value, e.Flag, e.Err)
+}
+
+// ParseLine parses a docker run command line, split into words as a shell
+// would. The leading "docker run" or "docker container run" is optional.
+func ParseLine(line string) (*Command, error) {
+	args, err := splitLine(line)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(args)
+}
+
+// Parse parses the arguments of docker run: flags, the image and the
+// command. The leading "docker run" or "docker container run" is optional.
+func Parse(args []string) (*Command, error) {
+	args = trimPrefix(args)
+	p := &parser{cmd: &Command{Config: &dockerclient.ContainerConfig{}}}
+	for len(args) > 0 {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			args = append([]string{arg}, args...)
+			break
+		}
+		var err error
+		if strings.HasPrefix(arg, "--") {
+			args, err = p.parseLong(arg[2:], args)
+		} else {
+			args, err = p.parseShort(arg[1:], args)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(args) == 0 {
+		return nil, ErrNoImage
+	}
+	c := p.cmd.Config
+	c.Image, c.Cmd = args[0], args[1:]
+	if len(c.Cmd) == 0 {
+		c.Cmd = nil
+	}
+	if err := p.finish(); err != nil {
+		return nil, err
+	}
+	return p.cmd, nil
+}
+
+func trimPrefix(args []string) []string {
+	if len(args) > 0 && args[0] == "docker" {
+		args = args[1:]
+	}
+	if len(args) > 1 && args[0] == "container" && args[1] == "run" {
+		return args[2:]
+	}
+	if len(args) > 0 && args[0] == "run" {
+		return args[1:]
+	}
+	return args
+}
+
+func (p *parser) parseLong(arg string, args []string) ([]string, error) {
+	name, value, hasValue := arg, "", false
+	if i := strings.Index(arg, "="); i >= 0 {
+		name, value, hasValue = arg[:i], arg[i+1:], true
+	}
+	f := longFlags[name]
+	if f == nil {
+		return nil, fmt.Errorf("unknown flag: --%s", name)
+	}
+	if f.isBool {
+		if !hasValue {
+			value = "true"
+		}
+		return args, p.set(f, value)
+	}
+	if !hasValue {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("flag needs an argument: --%s", name)
+		}
+		value, args = args[0], args[1:]
+	}
+	return args, p.set(f, value)
+}
+
+// parseShort parses grouped short flags such as -it, the last one taking
+// the next argument, or the rest of the group, as its value
+func (p *parser) parseShort(group string, args []string) ([]string, error) {
+	for i := 0; i < len(group); i++ {
+		f := shortFlags[group[i]]
+		if f == nil {
+			return nil, fmt.Errorf("unknown shorthand flag: %q in -%s", group[i], group)
+		}
+		rest := group[i+1:]
+		if f.isBool {
+			value := "true"
+			if strings.HasPrefix(rest, "=") {
+				value, i = rest[1:], len(group)
+			}
+			if err := p.set(f, value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		value := strings.TrimPrefix(rest, "=")
+		if rest == "" {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("flag needs an argument: %q in -%s", group[i], group)
+			}
+			value, args = args[0], args[1:]
+		}
+		return args, p.set(f, value)
+	}
+	return args, nil
+}
+
+func (p *parser) set(f *flag, value string) error {
+	if f.unsupported != "" {
+		return fmt.Errorf("--%s isn't supported: %s", f.long, f.unsupported)
+	}
+	if err := f.set(p, value); err != nil {
+		return &FlagError{Flag: f.String(), Value: value, Err: err}
+	}
+	return nil
+}
+
+// parser holds the flags that are only applied once all are known
+type parser struct {
+	cmd *Command
+
+	env      []string
+	envFiles []string
+
+	networks []string
+	aliases  []string
+	ipv4     string
+	ipv6     string
+
+	health       dockerclient.HealthConfig
+	healthSet    bool
+	noHealth     bool
+	cpus         int64
+	cpuPeriodSet bool
+	attach       []string
+	interactive  bool
+}
+
+func (p *parser) finish() error {
+	c := p.cmd.Config
+	h := &c.HostConfig
+
+	for _, file := range p.envFiles {
+		vars, err := readEnvFile(file)
+		if err != nil {
+			return &FlagError{Flag: "--env-file", Value: file, Err: err}
+		}
+		c.Env = append(c.Env, vars...)
+	}
+	c.Env = append(c.Env, p.env...)
+
+	if err := p.finishNetworks(); err != nil {
+		return err
+	}
+
+	if p.noHealth {
+		if p.healthSet {
+			return errors.New("--no-healthcheck conflicts with --health-* options")
+		}
+		c.Healthcheck = &dockerclient.HealthConfig{Test: []string{"NONE"}}
+	} else if p.healthSet {
+		health := p.health
+		c.Healthcheck = &health
+	}
+
+	if p.cpus != 0 {
+		if p.cpuPeriodSet || h.CpuQuota != 0 {
+			return errors.New("--cpus conflicts with --cpu-period and --cpu-quota")
+		}
+		h.CpuPeriod, h.CpuQuota = cpuPeriod, p.cpus
+	}
+
+	// attach to the output by default, as docker run does
+	if !p.cmd.Detach {
+		if len(p.attach) == 0 {
+			p.attach = []string{"stdout", "stderr"}
+			if p.interactive {
+				p.attach = append(p.attach, "stdin")
+			}
+		}
+		for _, stream := range p.attach {
+			switch strings.ToLower(stream) {
+			case "stdin":
+				c.AttachStdin = true
+				c.StdinOnce = c.OpenStdin
+			case "stdout":
+				c.AttachStdout = true
+			case "stderr":
+				c.AttachStderr = true
+			}
+		}
+	} else if len(p.attach) > 0 {
+		return errors.New("--attach conflicts with --detach")
+	}
+	return nil
+}
+
+func (p *parser) finishNetworks() error {
+	c := p.cmd.Config
+	h := &c.HostConfig
+	if len(p.networks) == 0 {
+		if len(p.aliases) > 0 || p.ipv4 != "" || p.ipv6 != "" {
+			return errors.New("--network-alias, --ip and --ip6 require a user-defined network")
+		}
+		return nil
+	}
+	h.NetworkMode = p.networks[0]
+	for i, network := range p.networks {
+		if !isUserDefined(network) {
+			if i > 0 {
+				return fmt.Errorf("network %s can't be joined as an additional network", network)
+			}
+			if len(p.aliases) > 0 || p.ipv4 != "" || p.ipv6 != "" {
+				return fmt.Errorf("network-scoped aliases and addresses are only supported for user-defined networks, not %s", network)
+			}
+			continue
+		}
+		settings := &dockerclient.EndpointSettings{}
+		// as docker run does, the aliases and addresses are those on the
+		// first network
+		if i == 0 {
+			settings.Aliases = p.aliases
+			if p.ipv4 != "" || p.ipv6 != "" {
+				settings.IPAMConfig = &dockerclient.EndpointIPAMConfig{IPv4Address: p.ipv4, IPv6Address: p.ipv6}
+			}
+		}
+		if c.NetworkingConfig.EndpointsConfig == nil {
+			c.NetworkingConfig.EndpointsConfig = make(map[string]*dockerclient.EndpointSettings)
+		}
+		c.NetworkingConfig.EndpointsConfig[network] = settings
+	}
+	return nil
+}
+
+// isUserDefined tells whether network is a network of the engine, not one
+// of the built-in modes
+func isUserDefined(network string) bool {
+	switch network {
+	case "default", "bridge", "host", "none":
+		return false
+	}
+	return !strings.HasPrefix(network, "container:")
+}
+
+// lookupEnv returns VAR=value for a --env VAR without a value, false if
+// VAR isn't set
+func lookupEnv(name string) (string, bool) {
+	value, ok := os.LookupEnv(name)
+	return name + "=" + value, ok
+}
+
+// readEnvFile reads a file of VAR=value lines, as --env-file does. Blank
+// lines and comments are skipped, and a VAR alone takes its value from the
+// environment.
+func readEnvFile(file string) ([]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var env []string
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimLeft(strings.TrimRight(line, "\r"), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "=") {
+			return nil, fmt.Errorf("line %d: variable name is empty", i+1)
+		}
+		if !strings.Contains(line, "=") {
+			if kv, ok := lookupEnv(line); ok {
+				env = append(env, kv)
+			}
+			continue
+		}
+		env = append(env, line)
+	}
+	return env, nil
+}
+
+// splitLine splits a command line into words as a shell would, honouring
+// quotes, backslashes and line continuations
+func splitLine(s string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteByte(c)
+			}
+		case quote == '"':
+			switch {
+			case c == '"':
+				quote = 0
+			case c == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`", s[i+1]) >= 0:
+				i++
+				word.WriteByte(s[i])
+			default:
+				word.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			i++
+		case c == '\\' && i+1 < len(s):
+			i++
+			word.WriteByte(s[i])
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
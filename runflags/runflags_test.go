@@ -0,0 +1,187 @@
+package runflags
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+func TestParse(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "web.env")
+	if err := ioutil.WriteFile(envFile, []byte("# web\nLEVEL=info\n\nPORT=80\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := ParseLine(`docker run -dit --name web \
+		-p 8080:80 -p 127.0.0.1:9000-9001:9000-9001/udp -p [::1]:53:53/udp --expose 7000-7001 \
+		-v data:/data:ro -v /cache --mount type=bind,src=/etc/web,dst=/etc/web,readonly --tmpfs /run:size=64m \
+		--env-file ` + envFile + ` -e LEVEL=debug -l tier=front \
+		--restart=on-failure:3 --ulimit nofile=1024:2048 --device /dev/sda:/dev/xvda:r --cap-add NET_ADMIN \
+		-m 512m --memory-swap=-1 --cpus 1.5 \
+		--log-driver syslog --log-opt tag=web \
+		--network front --network-alias www --ip 10.0.0.2 --network back \
+		--health-cmd "curl -f http://localhost" --health-interval 30s --health-retries 3 \
+		nginx:1.19 nginx -g 'daemon off;'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmd.Detach || cmd.Name != "web" {
+		t.Fatalf("unexpected command %+v", cmd)
+	}
+	c := cmd.Config
+	h := c.HostConfig
+	if c.Image != "nginx:1.19" || !reflect.DeepEqual(c.Cmd, []string{"nginx", "-g", "daemon off;"}) {
+		t.Fatalf("unexpected image %s and command %q", c.Image, c.Cmd)
+	}
+	if !c.Tty || !c.OpenStdin || c.AttachStdout {
+		t.Fatalf("expected a detached interactive container, got %+v", c)
+	}
+
+	bindings := map[string][]dockerclient.PortBinding{
+		"80/tcp":   {{HostPort: "8080"}},
+		"9000/udp": {{HostIp: "127.0.0.1", HostPort: "9000"}},
+		"9001/udp": {{HostIp: "127.0.0.1", HostPort: "9001"}},
+		"53/udp":   {{HostIp: "::1", HostPort: "53"}},
+	}
+	if !reflect.DeepEqual(h.PortBindings, bindings) || len(c.ExposedPorts) != 6 {
+		t.Fatalf("unexpected ports %v %v", h.PortBindings, c.ExposedPorts)
+	}
+	if !reflect.DeepEqual(h.Binds, []string{"data:/data:ro"}) {
+		t.Fatalf("unexpected binds %q", h.Binds)
+	}
+	mounts := []dockerclient.Mount{{Type: dockerclient.MountTypeBind, Source: "/etc/web", Target: "/etc/web", ReadOnly: true}}
+	if !reflect.DeepEqual(h.Mounts, mounts) {
+		t.Fatalf("unexpected mounts %+v", h.Mounts)
+	}
+	if _, ok := c.Volumes["/cache"]; !ok || h.Tmpfs["/run"] != "size=64m" {
+		t.Fatalf("unexpected volumes %v and tmpfs %v", c.Volumes, h.Tmpfs)
+	}
+	if !reflect.DeepEqual(c.Env, []string{"LEVEL=info", "PORT=80", "LEVEL=debug"}) || c.Labels["tier"] != "front" {
+		t.Fatalf("unexpected environment %q and labels %v", c.Env, c.Labels)
+	}
+	if h.RestartPolicy != (dockerclient.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}) {
+		t.Fatalf("unexpected restart policy %+v", h.RestartPolicy)
+	}
+	if !reflect.DeepEqual(h.Ulimits, []dockerclient.Ulimit{{Name: "nofile", Soft: 1024, Hard: 2048}}) {
+		t.Fatalf("unexpected ulimits %+v", h.Ulimits)
+	}
+	if !reflect.DeepEqual(h.Devices, []dockerclient.DeviceMapping{{PathOnHost: "/dev/sda", PathInContainer: "/dev/xvda", CgroupPermissions: "r"}}) {
+		t.Fatalf("unexpected devices %+v", h.Devices)
+	}
+	if h.Memory != 512<<20 || h.MemorySwap != -1 || h.CpuPeriod != 100000 || h.CpuQuota != 150000 {
+		t.Fatalf("unexpected resources %+v", h)
+	}
+	if h.LogConfig.Type != "syslog" || h.LogConfig.Config["tag"] != "web" {
+		t.Fatalf("unexpected logging %+v", h.LogConfig)
+	}
+	front := c.NetworkingConfig.EndpointsConfig["front"]
+	if h.NetworkMode != "front" || front == nil || !reflect.DeepEqual(front.Aliases, []string{"www"}) || front.IPAMConfig.IPv4Address != "10.0.0.2" {
+		t.Fatalf("unexpected networks %s %+v", h.NetworkMode, c.NetworkingConfig.EndpointsConfig)
+	}
+	if _, ok := c.NetworkingConfig.EndpointsConfig["back"]; !ok {
+		t.Fatal("expected the container to join back")
+	}
+	health := c.Healthcheck
+	if !reflect.DeepEqual(health.Test, []string{"CMD-SHELL", "curl -f http://localhost"}) || health.Interval != 30*time.Second || health.Retries != 3 {
+		t.Fatalf("unexpected healthcheck %+v", health)
+	}
+}
+
+func TestParseAttached(t *testing.T) {
+	cmd, err := Parse([]string{"--rm", "-i", "busybox", "--", "-n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cmd.Config
+	if !cmd.Remove || cmd.Detach || !c.AttachStdin || !c.AttachStdout || !c.AttachStderr || !c.StdinOnce {
+		t.Fatalf("expected an attached container, got %+v", c)
+	}
+	if !reflect.DeepEqual(c.Cmd, []string{"--", "-n"}) {
+		t.Fatalf("expected the command to be kept as is, got %q", c.Cmd)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, test := range []struct {
+		line string
+		err  string
+	}{
+		{"docker run", "No image given"},
+		{"docker run --bogus nginx", "unknown flag: --bogus"},
+		{"docker run -k nginx", `unknown shorthand flag: 'k' in -k`},
+		{"docker run nginx --name", ""},
+		{"docker run --name", "flag needs an argument: --name"},
+		{"docker run -p 80: nginx", `invalid argument "80:" for "-p, --publish" flag: no container port`},
+		{"docker run -p 70000 nginx", `invalid argument "70000" for "-p, --publish" flag: invalid container port: "70000" isn't a port number`},
+		{"docker run -p 80-81:90-92 nginx", `invalid argument "80-81:90-92" for "-p, --publish" flag: host range 80-81 and container range 90-92 don't have the same length`},
+		{"docker run -p 80/icmp nginx", `invalid argument "80/icmp" for "-p, --publish" flag: invalid protocol "icmp"`},
+		{"docker run -v data:relative nginx", `invalid argument "data:relative" for "-v, --volume" flag: mount path "relative" must be absolute`},
+		{"docker run -v data:/data:rx nginx", `invalid argument "data:/data:rx" for "-v, --volume" flag: invalid option "rx"`},
+		{"docker run --mount type=volume,src=data nginx", `invalid argument "type=volume,src=data" for "--mount" flag: target is required`},
+		{"docker run --mount type=image,dst=/x nginx", `invalid argument "type=image,dst=/x" for "--mount" flag: invalid type "image", expected bind, volume or tmpfs`},
+		{"docker run --restart always:3 nginx", `invalid argument "always:3" for "--restart" flag: maximum retry count can't be used with restart policy always`},
+		{"docker run --restart sometimes nginx", `invalid argument "sometimes" for "--restart" flag: invalid restart policy sometimes, expected no, always, unless-stopped or on-failure[:max-retries]`},
+		{"docker run --ulimit nofile=2048:1024 nginx", `invalid argument "nofile=2048:1024" for "--ulimit" flag: soft limit 2048 is greater than hard limit 1024`},
+		{"docker run --ulimit files=10 nginx", `invalid argument "files=10" for "--ulimit" flag: invalid ulimit type "files"`},
+		{"docker run --device /dev/sda:/dev/xvda:rwx nginx", `invalid argument "/dev/sda:/dev/xvda:rwx" for "--device" flag: invalid permissions "rwx", expected a combination of r, w and m`},
+		{"docker run -m lots nginx", `invalid argument "lots" for "-m, --memory" flag: expected a size such as 512m`},
+		{"docker run --cpus 0 nginx", `invalid argument "0" for "--cpus" flag: expected a positive number of CPUs such as 1.5`},
+		{"docker run --cpus 1 --cpu-quota 5000 nginx", "--cpus conflicts with --cpu-period and --cpu-quota"},
+		{"docker run --log-opt tag nginx", `invalid argument "tag" for "--log-opt" flag: expected key=value`},
+		{"docker run --network host --network-alias web nginx", "network-scoped aliases and addresses are only supported for user-defined networks, not host"},
+		{"docker run --health-interval soon nginx", `invalid argument "soon" for "--health-interval" flag: expected a duration such as 1m30s`},
+		{"docker run --no-healthcheck --health-retries 3 nginx", "--no-healthcheck conflicts with --health-* options"},
+		{"docker run --stop-timeout 10 nginx", "--stop-timeout isn't supported: the container config has no stop timeout"},
+		{"docker run -e =x nginx", `invalid argument "=x" for "-e, --env" flag: variable name is empty`},
+	} {
+		_, err := ParseLine(test.line)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.line, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: expected %q, got %v", test.line, test.err, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	line := `docker run -d -i -t --name web --user app -e 'GREETING=hello world' -l tier=front ` +
+		`-p 127.0.0.1::53/udp -p 8080:80 --expose 7000 -v data:/data:ro -v /cache --tmpfs /run:size=64m ` +
+		`--network front --network-alias www --network back --link db:database ` +
+		`--restart on-failure:3 --ulimit nofile=1024:2048 --device /dev/sda:r --cap-add NET_ADMIN --privileged ` +
+		`--memory 512m --cpus 1.5 --log-driver syslog --log-opt tag=web ` +
+		`--health-cmd 'curl -f http://localhost' --health-interval 30s ` +
+		`--entrypoint /bin/sh nginx:1.19 -c 'nginx -g "daemon off;"'`
+	cmd, err := ParseLine(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostConfig := cmd.Config.HostConfig
+	info := &dockerclient.ContainerInfo{Name: "/web", Config: cmd.Config, HostConfig: &hostConfig}
+	formatted := Quote(Format(info))
+	if formatted != line {
+		t.Fatalf("expected\n%s\ngot\n%s", line, formatted)
+	}
+
+	// the engine reports links with the name of the container, and adds
+	// its short ID to the aliases
+	info.Id = "4fa6e0f0c6786287e131c3852c58a2e01cc697a68231826813597e4994f1d6e2"
+	info.HostConfig.Links = []string{"/db:/web/database"}
+	info.NetworkSettings.Networks = map[string]*dockerclient.EndpointSettings{
+		"front": {Aliases: []string{"www", "4fa6e0f0c678"}},
+		"back":  {Aliases: []string{"4fa6e0f0c678"}},
+	}
+	again, err := Parse(Format(info))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again.Config, cmd.Config) {
+		t.Fatalf("expected\n%+v\ngot\n%+v", cmd.Config, again.Config)
+	}
+}
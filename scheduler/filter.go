@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"fmt"
+	"path"
+)
+
+// Filter rules out the engines that can't take a container
+type Filter interface {
+	Name() string
+	// Filter returns the nodes that can take the container
+	Filter(req *Request, nodes []*Node) ([]*Node, error)
+}
+
+// DefaultFilters are the filters of a scheduler created without any
+var DefaultFilters = []Filter{
+	ConstraintFilter{},
+	AffinityFilter{},
+	PortFilter{},
+	ResourceFilter{},
+}
+
+// ResourceFilter keeps the engines with enough memory and CPU left for the
+// HostConfig.Memory and HostConfig.CpuShares of the container, 1024 shares
+// counting as one CPU
+type ResourceFilter struct{}
+
+func (ResourceFilter) Name() string { return "resource" }
+
+func (ResourceFilter) Filter(req *Request, nodes []*Node) ([]*Node, error) {
+	var ret []*Node
+	for _, n := range nodes {
+		if n.fits(req) {
+			ret = append(ret, n)
+		}
+	}
+	return ret, nil
+}
+
+// PortFilter keeps the engines where the host ports of the
+// HostConfig.PortBindings of the container are free
+type PortFilter struct{}
+
+func (PortFilter) Name() string { return "port" }
+
+func (PortFilter) Filter(req *Request, nodes []*Node) ([]*Node, error) {
+	requested := requestedPorts(req)
+	var ret []*Node
+nodes:
+	for _, n := range nodes {
+		for _, want := range requested {
+			for _, used := range n.ports {
+				if want.conflicts(used) {
+					continue nodes
+				}
+			}
+		}
+		ret = append(ret, n)
+	}
+	return ret, nil
+}
+
+// ConstraintFilter keeps the engines whose labels match the Constraints of
+// the request. Values are shell patterns, as in "region==eu-*".
+type ConstraintFilter struct{}
+
+func (ConstraintFilter) Name() string { return "constraint" }
+
+func (ConstraintFilter) Filter(req *Request, nodes []*Node) ([]*Node, error) {
+	for _, c := range req.Constraints {
+		expr, err := parseExpression("constraint", c)
+		if err != nil {
+			return nil, err
+		}
+		var ret []*Node
+		for _, n := range nodes {
+			value, ok := n.Labels[expr.key]
+			matched := false
+			if ok {
+				if matched, err = path.Match(expr.value, value); err != nil {
+					return nil, fmt.Errorf("invalid constraint %q: %v", c, err)
+				}
+			}
+			if matched != expr.negate {
+				ret = append(ret, n)
+			}
+		}
+		nodes = ret
+	}
+	return nodes, nil
+}
+
+// AffinityFilter keeps the engines satisfying the Affinities of the
+// request: "container==name" and "image==reference" keep the engines
+// having the container or the image, != the others
+type AffinityFilter struct{}
+
+func (AffinityFilter) Name() string { return "affinity" }
+
+func (AffinityFilter) Filter(req *Request, nodes []*Node) ([]*Node, error) {
+	for _, a := range req.Affinities {
+		expr, err := parseExpression("affinity", a)
+		if err != nil {
+			return nil, err
+		}
+		var has func(n *Node, ref string) bool
+		switch expr.key {
+		case "container":
+			has = (*Node).hasContainer
+		case "image":
+			has = (*Node).hasImage
+		default:
+			return nil, fmt.Errorf("invalid affinity %q: expected container or image", a)
+		}
+		var ret []*Node
+		for _, n := range nodes {
+			if has(n, expr.value) != expr.negate {
+				ret = append(ret, n)
+			}
+		}
+		nodes = ret
+	}
+	return nodes, nil
+}
@@ -0,0 +1,227 @@
+package scheduler
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/samalba/dockerclient"
+	"github.com/samalba/dockerclient/pool"
+)
+
+// sharesPerCPU is the number of CPU shares counted as one CPU, the default
+// shares of a container
+const sharesPerCPU = 1024
+
+// Node is an engine as seen by the scheduler
+type Node struct {
+	Name string
+	// Labels are the labels of the engine, from Info
+	Labels map[string]string
+	// NCPU and MemTotal are the capacity of the engine, 0 if unknown
+	NCPU     int64
+	MemTotal int64
+	// UsedMemory and UsedCpuShares are reserved by the containers of the
+	// engine, stopped ones included since they may be started again
+	UsedMemory    int64
+	UsedCpuShares int64
+	Containers    []dockerclient.Container
+	Images        []*dockerclient.Image
+
+	// ports are the host ports bound by the containers
+	ports []hostPort
+}
+
+type hostPort struct {
+	ip    string
+	port  string
+	proto string
+}
+
+// conflicts tells whether two bindings can't be used at the same time
+func (p hostPort) conflicts(other hostPort) bool {
+	if p.port != other.port || p.proto != other.proto {
+		return false
+	}
+	anyIP := func(ip string) bool { return ip == "" || ip == "0.0.0.0" || ip == "::" }
+	return anyIP(p.ip) || anyIP(other.ip) || p.ip == other.ip
+}
+
+// NewNode describes the engine called name, asking client for its
+// capacity, containers and images
+func NewNode(name string, client dockerclient.Client) (*Node, error) {
+	info, err := client.Info()
+	if err != nil {
+		return nil, err
+	}
+	n := &Node{
+		Name:     name,
+		Labels:   map[string]string{"node": name},
+		NCPU:     info.NCPU,
+		MemTotal: info.MemTotal,
+	}
+	for _, label := range info.Labels {
+		if i := strings.Index(label, "="); i > 0 {
+			n.Labels[label[:i]] = label[i+1:]
+		}
+	}
+
+	if n.Containers, err = client.ListContainers(true, false, ""); err != nil {
+		return nil, err
+	}
+	for _, c := range n.Containers {
+		for _, p := range c.Ports {
+			if p.PublicPort != 0 {
+				n.ports = append(n.ports, hostPort{ip: p.IP, port: strconv.Itoa(p.PublicPort), proto: p.Type})
+			}
+		}
+		info, err := client.InspectContainer(c.Id)
+		if dockerclient.IsNotFound(err) {
+			// removed meanwhile
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if info.HostConfig != nil {
+			n.UsedMemory += info.HostConfig.Memory
+			n.UsedCpuShares += info.HostConfig.CpuShares
+		}
+	}
+
+	if n.Images, err = client.ListImages(false); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// NodesFromPool describes the healthy engines of p. The error is a
+// *pool.PartialError if some engines couldn't be described, in which case
+// the others are returned.
+func NodesFromPool(p *pool.Pool) ([]*Node, error) {
+	var mu sync.Mutex
+	var nodes []*Node
+	err := p.Each(func(name string, client dockerclient.Client) error {
+		n, err := NewNode(name, client)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		nodes = append(nodes, n)
+		mu.Unlock()
+		return nil
+	})
+	sortByName(nodes)
+	return nodes, err
+}
+
+// requestedPorts returns the host ports bound by the container
+func requestedPorts(req *Request) []hostPort {
+	var ports []hostPort
+	for spec, bindings := range req.Config.HostConfig.PortBindings {
+		proto := "tcp"
+		if i := strings.Index(spec, "/"); i >= 0 {
+			proto = spec[i+1:]
+		}
+		for _, b := range bindings {
+			if b.HostPort != "" && b.HostPort != "0" {
+				ports = append(ports, hostPort{ip: b.HostIp, port: b.HostPort, proto: proto})
+			}
+		}
+	}
+	return ports
+}
+
+// fits tells whether the engine has the memory and CPU left for the
+// container. Unknown capacities are unlimited.
+func (n *Node) fits(req *Request) bool {
+	memory, cpuShares := req.resources()
+	if n.MemTotal > 0 && memory > 0 && n.UsedMemory+memory > n.MemTotal {
+		return false
+	}
+	if n.NCPU > 0 && cpuShares > 0 && n.UsedCpuShares+cpuShares > n.NCPU*sharesPerCPU {
+		return false
+	}
+	return true
+}
+
+// usage is the fraction of the capacity of the engine that would be
+// reserved once the container is placed, averaged over memory and CPU
+func (n *Node) usage(req *Request) float64 {
+	memory, cpuShares := req.resources()
+	var total float64
+	var count int
+	if n.MemTotal > 0 {
+		total += float64(n.UsedMemory+memory) / float64(n.MemTotal)
+		count++
+	}
+	if n.NCPU > 0 {
+		total += float64(n.UsedCpuShares+cpuShares) / float64(n.NCPU*sharesPerCPU)
+		count++
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
+
+// reserve accounts for the container placed on the engine
+func (n *Node) reserve(req *Request) {
+	memory, cpuShares := req.resources()
+	n.UsedMemory += memory
+	n.UsedCpuShares += cpuShares
+	n.ports = append(n.ports, requestedPorts(req)...)
+	c := dockerclient.Container{Image: req.Config.Image, Labels: req.Config.Labels}
+	if req.Name != "" {
+		c.Names = []string{"/" + req.Name}
+	}
+	n.Containers = append(n.Containers, c)
+	if !n.hasImage(req.Config.Image) {
+		n.Images = append(n.Images, &dockerclient.Image{RepoTags: []string{req.Config.Image}})
+	}
+}
+
+// hasContainer tells whether the engine has a container with that name or
+// ID prefix. An empty reference matches none.
+func (n *Node) hasContainer(ref string) bool {
+	ref = strings.TrimPrefix(ref, "/")
+	if ref == "" {
+		return false
+	}
+	for _, c := range n.Containers {
+		if c.Id != "" && strings.HasPrefix(c.Id, ref) {
+			return true
+		}
+		for _, name := range c.Names {
+			if strings.TrimPrefix(name, "/") == ref {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasImage tells whether the engine has an image with that tag, digest or
+// ID prefix. A reference without a tag means latest.
+func (n *Node) hasImage(ref string) bool {
+	tagged := ref
+	if !strings.Contains(ref, "@") && strings.LastIndex(ref, ":") <= strings.LastIndex(ref, "/") {
+		tagged += ":latest"
+	}
+	for _, image := range n.Images {
+		if image.Id != "" && (strings.HasPrefix(image.Id, ref) || strings.HasPrefix(strings.TrimPrefix(image.Id, "sha256:"), ref)) {
+			return true
+		}
+		for _, tag := range image.RepoTags {
+			if tag == ref || tag == tagged {
+				return true
+			}
+		}
+		for _, digest := range image.RepoDigests {
+			if digest == ref {
+				return true
+			}
+		}
+	}
+	return false
+}
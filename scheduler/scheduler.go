@@ -0,0 +1,134 @@
+// Package scheduler chooses the engine a new container should run on.
+//
+// Candidate engines are described by Nodes, built from Info,
+// ListContainers and ListImages. Filters rule out the engines that can't
+// take the container: not enough memory or CPU left, a host port already
+// taken, a label constraint or an affinity not satisfied. A Strategy then
+// ranks the remaining ones:
+//
+//	nodes, err := scheduler.NodesFromPool(p)
+//	s := scheduler.New(scheduler.SpreadStrategy{})
+//	node, err := s.Select(&scheduler.Request{
+//		Config:      config,
+//		Constraints: []string{"storage==ssd"},
+//		Affinities:  []string{"image==redis"},
+//	}, nodes)
+//	id, err := p.CreateContainer(node.Name, config, "", nil)
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+)
+
+var ErrNoConfig = errors.New("Request has no container config")
+
+// Request describes a container to place
+type Request struct {
+	Config *dockerclient.ContainerConfig
+	// Name is the name of the container, if any. Containers placed later
+	// can refer to it in their affinities.
+	Name string
+	// Constraints are expressions on the labels of the engines, as
+	// "storage==ssd" or "region!=us-*". The name of an engine is the
+	// "node" label.
+	Constraints []string
+	// Affinities are expressions on the containers and images of the
+	// engines, as "container==web" to run next to the web container,
+	// "container!=db" to run away from it, or "image==redis" to run where
+	// the redis image was already pulled.
+	Affinities []string
+}
+
+// resources returns the memory and CPU shares requested by the container
+func (r *Request) resources() (memory int64, cpuShares int64) {
+	memory, cpuShares = r.Config.HostConfig.Memory, r.Config.HostConfig.CpuShares
+	// fields of the legacy API
+	if memory == 0 {
+		memory = r.Config.Memory
+	}
+	if cpuShares == 0 {
+		cpuShares = r.Config.CpuShares
+	}
+	return
+}
+
+// NoNodeError is returned when no engine can take a container
+type NoNodeError struct {
+	// Filter is the name of the filter that ruled out the last candidates
+	Filter string
+}
+
+func (e *NoNodeError) Error() string {
+	if e.Filter == "" {
+		return "No node available"
+	}
+	return fmt.Sprintf("No node available: all nodes were ruled out by the %s filter", e.Filter)
+}
+
+// Scheduler chooses engines with a strategy, among the engines its filters
+// keep
+type Scheduler struct {
+	Strategy Strategy
+	Filters  []Filter
+}
+
+// New returns a scheduler using strategy and the given filters, or
+// DefaultFilters if there are none
+func New(strategy Strategy, filters ...Filter) *Scheduler {
+	if len(filters) == 0 {
+		filters = DefaultFilters
+	}
+	return &Scheduler{Strategy: strategy, Filters: filters}
+}
+
+// Select returns the best engine for the container. The container is
+// accounted for on that engine, so that selecting several containers in a
+// row, before creating them, takes the previous ones into account.
+func (s *Scheduler) Select(req *Request, nodes []*Node) (*Node, error) {
+	if req.Config == nil {
+		return nil, ErrNoConfig
+	}
+	if len(nodes) == 0 {
+		return nil, &NoNodeError{}
+	}
+	candidates := nodes
+	for _, filter := range s.Filters {
+		var err error
+		if candidates, err = filter.Filter(req, candidates); err != nil {
+			return nil, err
+		}
+		if len(candidates) == 0 {
+			return nil, &NoNodeError{Filter: filter.Name()}
+		}
+	}
+	ranked := s.Strategy.Rank(req, candidates)
+	if len(ranked) == 0 {
+		return nil, &NoNodeError{}
+	}
+	ranked[0].reserve(req)
+	return ranked[0], nil
+}
+
+// expression is a parsed constraint or affinity
+type expression struct {
+	key    string
+	value  string
+	negate bool
+}
+
+func parseExpression(kind, expr string) (*expression, error) {
+	for _, op := range []string{"==", "!="} {
+		if i := strings.Index(expr, op); i > 0 {
+			return &expression{
+				key:    strings.TrimSpace(expr[:i]),
+				value:  strings.TrimSpace(expr[i+len(op):]),
+				negate: op == "!=",
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid %s %q: expected key==value or key!=value", kind, expr)
+}
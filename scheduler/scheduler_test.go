@@ -0,0 +1,194 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/samalba/dockerclient"
+	"github.com/samalba/dockerclient/fakeengine"
+	"github.com/samalba/dockerclient/pool"
+)
+
+const gb = 1024 * 1024 * 1024
+
+func testNodes() []*Node {
+	return []*Node{
+		{Name: "node1", Labels: map[string]string{"node": "node1", "storage": "ssd"}, NCPU: 4, MemTotal: 8 * gb},
+		{Name: "node2", Labels: map[string]string{"node": "node2", "storage": "disk"}, NCPU: 4, MemTotal: 8 * gb},
+		{Name: "node3", Labels: map[string]string{"node": "node3", "storage": "ssd"}, NCPU: 2, MemTotal: 2 * gb},
+	}
+}
+
+func request(memory int64) *Request {
+	config := &dockerclient.ContainerConfig{Image: "busybox"}
+	config.HostConfig.Memory = memory
+	return &Request{Config: config}
+}
+
+func TestSpread(t *testing.T) {
+	s := New(SpreadStrategy{})
+	nodes := testNodes()
+	var placed []string
+	for i := 0; i < 4; i++ {
+		n, err := s.Select(request(gb), nodes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		placed = append(placed, n.Name)
+	}
+	// node3 is the most loaded once it got one container
+	expected := []string{"node1", "node2", "node3", "node1"}
+	for i := range expected {
+		if placed[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, placed)
+		}
+	}
+}
+
+func TestBinpack(t *testing.T) {
+	s := New(BinpackStrategy{})
+	nodes := testNodes()
+	var placed []string
+	for i := 0; i < 3; i++ {
+		n, err := s.Select(request(gb), nodes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		placed = append(placed, n.Name)
+	}
+	// node3 fills up first, then the ties are broken by name
+	expected := []string{"node3", "node3", "node1"}
+	for i := range expected {
+		if placed[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, placed)
+		}
+	}
+}
+
+func TestResourceFilter(t *testing.T) {
+	s := New(RandomStrategy{})
+	nodes := testNodes()
+	if _, err := s.Select(request(16*gb), nodes); err == nil {
+		t.Fatal("expected no node to have 16GB left")
+	} else if e, ok := err.(*NoNodeError); !ok || e.Filter != "resource" {
+		t.Fatalf("expected the resource filter to rule out every node, got %v", err)
+	}
+
+	req := request(0)
+	req.Config.HostConfig.CpuShares = 3 * 1024
+	for i := 0; i < 2; i++ {
+		n, err := s.Select(req, nodes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.Name == "node3" {
+			t.Fatal("node3 doesn't have 3 CPUs")
+		}
+	}
+	if _, err := s.Select(req, nodes); err == nil {
+		t.Fatal("expected the CPUs to be exhausted")
+	}
+}
+
+func TestConstraintAndAffinity(t *testing.T) {
+	s := New(SpreadStrategy{})
+	for _, test := range []struct {
+		constraints, affinities []string
+		expected                string
+	}{
+		{[]string{"storage==ssd"}, nil, "node1"},
+		{[]string{"storage==ssd", "node!=node1"}, nil, "node3"},
+		{[]string{"storage==s*"}, []string{"container!=db"}, "node1"},
+		{nil, []string{"container==db"}, "node3"},
+		{nil, []string{"image==redis"}, "node2"},
+	} {
+		req := request(0)
+		req.Constraints, req.Affinities = test.constraints, test.affinities
+		nodes := testNodes()
+		nodes[2].Containers = []dockerclient.Container{{Id: "abcdef", Names: []string{"/db"}}}
+		nodes[1].Images = []*dockerclient.Image{{Id: "sha256:123", RepoTags: []string{"redis:latest"}}}
+		n, err := s.Select(req, nodes)
+		if err != nil {
+			t.Fatalf("%v %v: %v", test.constraints, test.affinities, err)
+		}
+		if n.Name != test.expected {
+			t.Fatalf("%v %v: expected %s, got %s", test.constraints, test.affinities, test.expected, n.Name)
+		}
+	}
+
+	req := request(0)
+	req.Constraints = []string{"storage"}
+	if _, err := s.Select(req, testNodes()); err == nil {
+		t.Fatal("expected an invalid constraint to fail")
+	}
+
+	// an empty container reference isn't a prefix of every ID
+	req = request(0)
+	req.Affinities = []string{"container=="}
+	nodes := testNodes()
+	nodes[2].Containers = []dockerclient.Container{{Id: "abcdef", Names: []string{"/db"}}}
+	if n, err := s.Select(req, nodes); err == nil {
+		t.Fatalf("expected no engine to have the container, got %s", n.Name)
+	}
+}
+
+func TestPortFilter(t *testing.T) {
+	s := New(SpreadStrategy{})
+	nodes := testNodes()[:2]
+	nodes[0].ports = []hostPort{{ip: "0.0.0.0", port: "80", proto: "tcp"}}
+
+	req := request(0)
+	req.Config.HostConfig.PortBindings = map[string][]dockerclient.PortBinding{
+		"80/tcp": {{HostPort: "80"}},
+	}
+	n, err := s.Select(req, nodes)
+	if err != nil || n.Name != "node2" {
+		t.Fatalf("expected node2, got %v, %v", n, err)
+	}
+	if _, err := s.Select(req, nodes); err == nil {
+		t.Fatal("expected port 80 to be taken everywhere")
+	}
+
+	// another protocol or host IP doesn't conflict
+	req.Config.HostConfig.PortBindings = map[string][]dockerclient.PortBinding{
+		"80/udp": {{HostPort: "80"}},
+	}
+	if _, err := s.Select(req, nodes); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNodesFromPool(t *testing.T) {
+	p := pool.NewPool()
+	for _, name := range []string{"node1", "node2"} {
+		engine := fakeengine.New()
+		engine.SetInfo(dockerclient.Info{NCPU: 2, MemTotal: 4 * gb, Labels: []string{"zone=" + name}})
+		engine.AddImage("redis", nil)
+		server := engine.Serve()
+		defer server.Close()
+		client, err := dockerclient.NewDockerClient(server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := &dockerclient.ContainerConfig{Image: "redis"}
+		config.HostConfig.Memory = gb
+		if _, err := client.CreateContainer(config, "", nil); err != nil {
+			t.Fatal(err)
+		}
+		p.Add(name, client)
+	}
+
+	nodes, err := NodesFromPool(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 || nodes[0].Name != "node1" {
+		t.Fatalf("unexpected nodes %+v", nodes)
+	}
+	n := nodes[1]
+	if n.Labels["zone"] != "node2" || n.Labels["node"] != "node2" || n.NCPU != 2 || n.MemTotal != 4*gb {
+		t.Fatalf("unexpected node %+v", n)
+	}
+	if n.UsedMemory != gb || len(n.Containers) != 1 || !n.hasImage("redis") {
+		t.Fatalf("expected the redis container to be accounted for, got %+v", n)
+	}
+}
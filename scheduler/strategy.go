@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// Strategy ranks the engines able to take a container
+type Strategy interface {
+	// Rank returns nodes sorted by preference, best first
+	Rank(req *Request, nodes []*Node) []*Node
+}
+
+// SpreadStrategy prefers the engines running the fewest containers, then
+// the least loaded ones, so that losing an engine affects as few
+// containers as possible
+type SpreadStrategy struct{}
+
+func (SpreadStrategy) Rank(req *Request, nodes []*Node) []*Node {
+	ret := sortByName(append([]*Node(nil), nodes...))
+	sort.SliceStable(ret, func(i, j int) bool {
+		if len(ret[i].Containers) != len(ret[j].Containers) {
+			return len(ret[i].Containers) < len(ret[j].Containers)
+		}
+		return ret[i].usage(req) < ret[j].usage(req)
+	})
+	return ret
+}
+
+// BinpackStrategy prefers the most loaded engines, so that the others are
+// kept free for large containers
+type BinpackStrategy struct{}
+
+func (BinpackStrategy) Rank(req *Request, nodes []*Node) []*Node {
+	ret := sortByName(append([]*Node(nil), nodes...))
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ui, uj := ret[i].usage(req), ret[j].usage(req); ui != uj {
+			return ui > uj
+		}
+		return len(ret[i].Containers) > len(ret[j].Containers)
+	})
+	return ret
+}
+
+// RandomStrategy picks engines at random
+type RandomStrategy struct{}
+
+func (RandomStrategy) Rank(req *Request, nodes []*Node) []*Node {
+	ret := make([]*Node, len(nodes))
+	for i, j := range rand.Perm(len(nodes)) {
+		ret[i] = nodes[j]
+	}
+	return ret
+}
+
+func sortByName(nodes []*Node) []*Node {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes
+}
@@ -1,8 +1,10 @@
 package dockerclient
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	"time"
 
 	"github.com/docker/go-units"
@@ -30,11 +32,14 @@ type ContainerConfig struct {
 	OnBuild         []string
 	Labels          map[string]string
 	StopSignal      string
+	Healthcheck     *HealthConfig `json:",omitempty"`
 
-	// FIXME: VolumeDriver have been removed since docker 1.9
+	// VolumeDriver moved to the host config with API 1.21, where it is
+	// sent for engines speaking that version or a later one
 	VolumeDriver string
 
-	// FIXME: The following fields have been removed since API v1.18
+	// The following fields moved to the host config with API 1.18, where
+	// they are sent likewise, except PortSpecs which is left out
 	Memory     int64
 	MemorySwap int64
 	CpuShares  int64
@@ -91,12 +96,13 @@ type HostConfig struct {
 	VolumeDriver         string
 	OomScoreAdj          int
 	Tmpfs                map[string]string
-	ShmSize              int64 `json:"omitempty"`
+	ShmSize              int64 `json:",omitempty"`
 	BlkioWeightDevice    []WeightDevice
 	BlkioDeviceReadBps   []ThrottleDevice
 	BlkioDeviceWriteBps  []ThrottleDevice
 	BlkioDeviceReadIOps  []ThrottleDevice
 	BlkioDeviceWriteIOps []ThrottleDevice
+	Mounts               []Mount `json:",omitempty"`
 }
 
 type WeightDevice struct {
@@ -142,12 +148,23 @@ type AttachOptions struct {
 }
 
 type MonitorEventsFilters struct {
-	Event        string `json:",omitempty"`
-	Events     []string `json:",omitempty"`
-	Image        string `json:",omitempty"`
-	Images     []string `json:",omitempty"`
-	Container    string `json:",omitempty"`
-	Containers []string `json:",omitempty"`
+	Event      string      `json:",omitempty"`
+	Events     []string    `json:",omitempty"`
+	Type       EventType   `json:",omitempty"`
+	Types      []EventType `json:",omitempty"`
+	Image      string      `json:",omitempty"`
+	Images     []string    `json:",omitempty"`
+	Container  string      `json:",omitempty"`
+	Containers []string    `json:",omitempty"`
+	Volume     string      `json:",omitempty"`
+	Volumes    []string    `json:",omitempty"`
+	Network    string      `json:",omitempty"`
+	Networks   []string    `json:",omitempty"`
+	Daemon     string      `json:",omitempty"`
+	Daemons    []string    `json:",omitempty"`
+	// Labels are matched as "key" or "key=value"
+	Label  string   `json:",omitempty"`
+	Labels []string `json:",omitempty"`
 }
 
 type MonitorEventsOptions struct {
@@ -166,6 +183,53 @@ type PortBinding struct {
 	HostPort string
 }
 
+// MountType is the type of a Mount
+type MountType string
+
+const (
+	MountTypeBind   MountType = "bind"
+	MountTypeVolume MountType = "volume"
+	MountTypeTmpfs  MountType = "tmpfs"
+)
+
+// Mount is a mount of HostConfig.Mounts, supported by API 1.25 and later
+type Mount struct {
+	Type     MountType `json:",omitempty"`
+	Source   string    `json:",omitempty"` // a host path or a volume name
+	Target   string    `json:",omitempty"`
+	ReadOnly bool      `json:",omitempty"`
+	// Consistency is consistent, cached or delegated, used by Docker for
+	// Mac only
+	Consistency string `json:",omitempty"`
+
+	BindOptions   *BindOptions   `json:",omitempty"`
+	VolumeOptions *VolumeOptions `json:",omitempty"`
+	TmpfsOptions  *TmpfsOptions  `json:",omitempty"`
+}
+
+type BindOptions struct {
+	// Propagation is private, rprivate, shared, rshared, slave or rslave
+	Propagation string `json:",omitempty"`
+}
+
+type VolumeOptions struct {
+	// NoCopy disables copying the content of the target into a new volume
+	NoCopy       bool              `json:",omitempty"`
+	Labels       map[string]string `json:",omitempty"`
+	DriverConfig *MountDriver      `json:",omitempty"`
+}
+
+// MountDriver is the driver creating the volume of a Mount
+type MountDriver struct {
+	Name    string            `json:",omitempty"`
+	Options map[string]string `json:",omitempty"`
+}
+
+type TmpfsOptions struct {
+	SizeBytes int64       `json:",omitempty"`
+	Mode      os.FileMode `json:",omitempty"`
+}
+
 type State struct {
 	Running    bool
 	Paused     bool
@@ -178,6 +242,44 @@ type State struct {
 	StartedAt  time.Time
 	FinishedAt time.Time
 	Ghost      bool
+	Health     *Health `json:",omitempty"`
+}
+
+// HealthConfig holds the configuration of a container healthcheck
+type HealthConfig struct {
+	// Test is the check to run: an empty slice inherits the check of the
+	// image, {"NONE"} disables it, {"CMD", args...} execs args directly and
+	// {"CMD-SHELL", command} runs command with the system's default shell.
+	Test []string `json:",omitempty"`
+
+	// Zero values inherit the setting of the image
+	Interval    time.Duration `json:",omitempty"` // Time between two checks
+	Timeout     time.Duration `json:",omitempty"` // Time after which a check is considered hung
+	StartPeriod time.Duration `json:",omitempty"` // Grace period during which failures don't count
+	Retries     int           `json:",omitempty"` // Consecutive failures needed to be unhealthy
+}
+
+// Health statuses reported in State.Health
+const (
+	HealthNone      = "none"
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// Health holds the health of a container with a healthcheck
+type Health struct {
+	Status        string
+	FailingStreak int
+	Log           []*HealthcheckResult
+}
+
+// HealthcheckResult is the outcome of a single run of a healthcheck
+type HealthcheckResult struct {
+	Start    time.Time
+	End      time.Time
+	ExitCode int
+	Output   string
 }
 
 // String returns a human-readable description of the state
@@ -191,6 +293,15 @@ func (s *State) String() string {
 			return fmt.Sprintf("Restarting (%d) %s ago", s.ExitCode, units.HumanDuration(time.Now().UTC().Sub(s.FinishedAt)))
 		}
 
+		if s.Health != nil {
+			switch s.Health.Status {
+			case HealthNone, "":
+			case HealthStarting:
+				return fmt.Sprintf("Up %s (health: starting)", units.HumanDuration(time.Now().UTC().Sub(s.StartedAt)))
+			default:
+				return fmt.Sprintf("Up %s (%s)", units.HumanDuration(time.Now().UTC().Sub(s.StartedAt)), s.Health.Status)
+			}
+		}
 		return fmt.Sprintf("Up %s", units.HumanDuration(time.Now().UTC().Sub(s.StartedAt)))
 	}
 
@@ -245,6 +356,8 @@ type ImageInfo struct {
 	Id              string
 	Os              string
 	Parent          string
+	RepoDigests     []string
+	RepoTags        []string
 	Size            int64
 	VirtualSize     int64
 }
@@ -259,7 +372,7 @@ type ImageSearch struct {
 
 type ContainerInfo struct {
 	Id              string
-	Created         string
+	Created         time.Time
 	Path            string
 	Name            string
 	Args            []string
@@ -267,23 +380,100 @@ type ContainerInfo struct {
 	Config          *ContainerConfig
 	State           *State
 	Image           string
-	NetworkSettings struct {
-		IPAddress   string `json:"IpAddress"`
-		IPPrefixLen int    `json:"IpPrefixLen"`
-		Gateway     string
-		Bridge      string
-		Ports       map[string][]PortBinding
-		Networks    map[string]*EndpointSettings
+	NetworkSettings NetworkSettings
+	SysInitPath     string
+	ResolvConfPath  string
+	HostnamePath    string
+	HostsPath       string
+	LogPath         string
+	Driver          string
+	Platform        string
+	MountLabel      string
+	ProcessLabel    string
+	AppArmorProfile string
+	RestartCount    int
+	GraphDriver     *GraphDriverData `json:",omitempty"`
+	Mounts          []MountPoint
+	Volumes         map[string]string // Before API 1.20, superseded by Mounts
+	HostConfig      *HostConfig
+	SizeRw          int64 `json:",omitempty"` // Only set when asked for the size
+	SizeRootFs      int64 `json:",omitempty"`
+}
+
+// NetworkSettings are the network details of a running container
+type NetworkSettings struct {
+	Bridge                 string
+	SandboxID              string
+	SandboxKey             string
+	HairpinMode            bool
+	LinkLocalIPv6Address   string
+	LinkLocalIPv6PrefixLen int
+	Ports                  map[string][]PortBinding
+	SecondaryIPAddresses   []Address
+	SecondaryIPv6Addresses []Address
+	Networks               map[string]*EndpointSettings
+
+	// Settings of the default bridge network, also found in Networks since
+	// API 1.21
+	EndpointID          string
+	Gateway             string
+	GlobalIPv6Address   string
+	GlobalIPv6PrefixLen int
+	IPAddress           string `json:"IpAddress"`
+	IPPrefixLen         int    `json:"IpPrefixLen"`
+	IPv6Gateway         string
+	MacAddress          string
+}
+
+// Address is an IP address and the length of its network prefix
+type Address struct {
+	Addr      string
+	PrefixLen int
+}
+
+// GraphDriverData is the storage driver of a container or image and its
+// driver-specific details, such as the layer directories of overlay2
+type GraphDriverData struct {
+	Name string
+	Data map[string]string
+}
+
+// MountPoint is a mount of a running container
+type MountPoint struct {
+	Type        MountType `json:",omitempty"`
+	Name        string    `json:",omitempty"` // Name of the volume
+	Source      string
+	Destination string
+	Driver      string `json:",omitempty"`
+	Mode        string
+	RW          bool
+	Propagation string
+}
+
+// ChangeKind is the kind of a change to the filesystem of a container
+type ChangeKind int
+
+const (
+	ChangeModify ChangeKind = iota
+	ChangeAdd
+	ChangeDelete
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeModify:
+		return "modified"
+	case ChangeAdd:
+		return "added"
+	case ChangeDelete:
+		return "deleted"
 	}
-	SysInitPath    string
-	ResolvConfPath string
-	Volumes        map[string]string
-	HostConfig     *HostConfig
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
 }
 
 type ContainerChanges struct {
 	Path string
-	Kind int
+	Kind ChangeKind
 }
 
 type Port struct {
@@ -321,18 +511,51 @@ type Container struct {
 	Id              string
 	Names           []string
 	Image           string
+	ImageID         string
 	Command         string
-	Created         int64
+	Created         time.Time
+	State           string // Such as created, running or exited, since API 1.23
 	Status          string
 	Ports           []Port
 	SizeRw          int64
 	SizeRootFs      int64
 	Labels          map[string]string
+	Mounts          []MountPoint
 	NetworkSettings struct {
 		Networks map[string]EndpointSettings
 	}
 }
 
+// containerJSON is Container as listed by the engine, which sends its
+// creation time as a unix timestamp
+type containerJSON Container
+
+func (c Container) MarshalJSON() ([]byte, error) {
+	var created int64
+	if !c.Created.IsZero() {
+		created = c.Created.Unix()
+	}
+	return json.Marshal(struct {
+		containerJSON
+		Created int64
+	}{containerJSON(c), created})
+}
+
+func (c *Container) UnmarshalJSON(data []byte) error {
+	v := struct {
+		*containerJSON
+		Created int64
+	}{containerJSON: (*containerJSON)(c)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c.Created = time.Time{}
+	if v.Created != 0 {
+		c.Created = time.Unix(v.Created, 0)
+	}
+	return nil
+}
+
 type Actor struct {
 	ID         string
 	Attributes map[string]string
@@ -343,8 +566,8 @@ type Event struct {
 	ID     string `json:"id,omitempty"`
 	From   string `json:"from,omitempty"`
 
-	Type   string
-	Action string
+	Type   EventType
+	Action EventAction
 	Actor  Actor
 
 	Time     int64 `json:"time,omitempty"`
@@ -428,7 +651,26 @@ type EventOrError struct {
 	Error error
 }
 
+// WaitCondition selects the state change a wait returns on
+type WaitCondition string
+
+const (
+	// WaitConditionNotRunning returns as soon as the container isn't
+	// running, immediately if it is already stopped. This is the default.
+	WaitConditionNotRunning WaitCondition = "not-running"
+	// WaitConditionNextExit returns on the next exit of the container,
+	// even if it isn't running yet
+	WaitConditionNextExit WaitCondition = "next-exit"
+	// WaitConditionRemoved returns once the container has been removed
+	WaitConditionRemoved WaitCondition = "removed"
+)
+
+type WaitOptions struct {
+	Condition WaitCondition
+}
+
 type WaitResult struct {
+	ID       string
 	ExitCode int
 	Error    error
 }
@@ -555,6 +797,7 @@ type Volume struct {
 	Driver     string            // Driver is the Driver name used to create the volume
 	Mountpoint string            // Mountpoint is the location on disk of the volume
 	Labels     map[string]string // Labels hold metadata about the volume
+	CreatedAt  time.Time         // CreatedAt is zero for engines older than API 1.29
 }
 
 type VolumesListResponse struct {
@@ -600,6 +843,7 @@ type NetworkResource struct {
 	Containers map[string]EndpointResource
 	Options    map[string]string
 	Labels     map[string]string // Labels hold metadata about the network
+	Created    time.Time         // Created is zero for engines older than API 1.24
 }
 
 // EndpointResource contains network resources allocated and used for a container in a network
@@ -630,7 +874,8 @@ type NetworkCreateResponse struct {
 
 // NetworkConnect represents the data to be used to connect a container to the network
 type NetworkConnect struct {
-	Container string
+	Container      string
+	EndpointConfig *EndpointSettings `json:",omitempty"`
 }
 
 // NetworkDisconnect represents the data to be used to disconnect a container from the network
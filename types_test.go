@@ -0,0 +1,117 @@
+package dockerclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func decodeFixture(t *testing.T, name string, v interface{}) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+}
+
+func TestDecodeContainerInfo(t *testing.T) {
+	var v115, v124, v141 ContainerInfo
+	decodeFixture(t, "container_inspect_v1.15.json", &v115)
+	decodeFixture(t, "container_inspect_v1.24.json", &v124)
+	decodeFixture(t, "container_inspect_v1.41.json", &v141)
+
+	if created := time.Date(2014, 11, 11, 15, 8, 25, 92233484, time.UTC); !v115.Created.Equal(created) {
+		t.Errorf("v1.15: expected to be created at %s, got %s", created, v115.Created)
+	}
+	if v115.NetworkSettings.IPAddress != "172.17.0.2" || v115.NetworkSettings.IPPrefixLen != 16 || v115.NetworkSettings.Gateway != "172.17.42.1" {
+		t.Errorf("v1.15: unexpected network settings %+v", v115.NetworkSettings)
+	}
+	if v115.Volumes["/var/cache/nginx"] == "" || v115.Mounts != nil || v115.Config.Memory != 64<<20 {
+		t.Errorf("v1.15: unexpected volumes %v and mounts %v", v115.Volumes, v115.Mounts)
+	}
+
+	if v124.RestartCount != 2 || v124.Driver != "aufs" || v124.GraphDriver == nil || v124.GraphDriver.Name != "aufs" {
+		t.Errorf("v1.24: unexpected driver %s, graph driver %+v or restart count %d", v124.Driver, v124.GraphDriver, v124.RestartCount)
+	}
+	if v124.LogPath == "" || v124.State.ExitCode != 137 || v124.State.StateString() != "exited" {
+		t.Errorf("v1.24: unexpected log path %q or state %+v", v124.LogPath, v124.State)
+	}
+	mounts := []MountPoint{
+		{
+			Name:        "0b8c2c3f4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f8",
+			Source:      "/var/lib/docker/volumes/0b8c2c3f4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f8/_data",
+			Destination: "/data",
+			Driver:      "local",
+			RW:          true,
+		},
+		{Source: "/srv/redis.conf", Destination: "/usr/local/etc/redis/redis.conf", Mode: "ro", Propagation: "rprivate"},
+	}
+	if !reflect.DeepEqual(v124.Mounts, mounts) {
+		t.Errorf("v1.24: expected the mounts %+v, got %+v", mounts, v124.Mounts)
+	}
+	if settings := v124.NetworkSettings; settings.SandboxID == "" || settings.Networks["backend"] == nil || settings.IPAddress != "" {
+		t.Errorf("v1.24: unexpected network settings %+v", settings)
+	}
+
+	if v141.Platform != "linux" || v141.AppArmorProfile != "docker-default" || v141.SizeRw != 1093 || v141.SizeRootFs != 133190164 {
+		t.Errorf("v1.41: unexpected platform %q, profile %q or sizes %d %d", v141.Platform, v141.AppArmorProfile, v141.SizeRw, v141.SizeRootFs)
+	}
+	if v141.GraphDriver.Data["UpperDir"] != "/var/lib/docker/overlay2/4f1a2b3c/diff" {
+		t.Errorf("v1.41: unexpected graph driver %+v", v141.GraphDriver)
+	}
+	if v141.Mounts[0].Type != MountTypeVolume || v141.Mounts[0].Name != "proxy-cache" || v141.Mounts[1].Type != MountTypeTmpfs {
+		t.Errorf("v1.41: unexpected mounts %+v", v141.Mounts)
+	}
+	if len(v141.HostConfig.Mounts) != 2 || v141.HostConfig.Mounts[1].TmpfsOptions.SizeBytes != 16<<20 {
+		t.Errorf("v1.41: unexpected host config mounts %+v", v141.HostConfig.Mounts)
+	}
+	addresses := []Address{{Addr: "172.17.0.9", PrefixLen: 16}}
+	if !reflect.DeepEqual(v141.NetworkSettings.SecondaryIPAddresses, addresses) || v141.NetworkSettings.SandboxKey == "" {
+		t.Errorf("v1.41: unexpected network settings %+v", v141.NetworkSettings)
+	}
+	if v141.State.Health == nil || v141.State.Health.Status != HealthHealthy || v141.Config.Healthcheck.Interval != 30*time.Second {
+		t.Errorf("v1.41: unexpected health %+v", v141.State.Health)
+	}
+}
+
+func TestDecodeContainers(t *testing.T) {
+	var v115, v141 []Container
+	decodeFixture(t, "containers_list_v1.15.json", &v115)
+	decodeFixture(t, "containers_list_v1.41.json", &v141)
+
+	if c := v115[0]; !c.Created.Equal(time.Unix(1415718505, 0)) || c.State != "" || c.ImageID != "" || len(c.Ports) != 2 {
+		t.Errorf("v1.15: unexpected container %+v", c)
+	}
+	c := v141[0]
+	if !c.Created.Equal(time.Unix(1623666667, 0)) || c.State != "running" || c.ImageID != "sha256:d1a364dc548d5357f0da3268c888e1971bbdb957ee3f028fe7194f1d61c6fdee" {
+		t.Errorf("v1.41: unexpected container %+v", c)
+	}
+	if len(c.Mounts) != 1 || c.Mounts[0].Name != "proxy-cache" || c.NetworkSettings.Networks["bridge"].IPAddress != "172.17.0.3" {
+		t.Errorf("v1.41: unexpected mounts %+v or networks %+v", c.Mounts, c.NetworkSettings.Networks)
+	}
+
+	// the creation time is sent back as a unix timestamp
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["Created"] != float64(1623666667) {
+		t.Fatalf("expected a unix timestamp, got %v", fields["Created"])
+	}
+	var decoded Container
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, c) {
+		t.Fatalf("expected %+v, got %+v", c, decoded)
+	}
+}
@@ -2,6 +2,7 @@ package dockerclient
 
 import (
 	"crypto/tls"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
@@ -39,3 +40,25 @@ func newHTTPClient(u *url.URL, tlsConfig *tls.Config, timeout time.Duration, set
 	}
 	return &http.Client{Transport: httpTransport}
 }
+
+// cancelOnClose cancels the request a stream belongs to once it is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// isClosed reports whether stopChan is closed, without blocking
+func isClosed(stopChan <-chan struct{}) bool {
+	select {
+	case <-stopChan:
+		return true
+	default:
+		return false
+	}
+}
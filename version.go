@@ -0,0 +1,292 @@
+package dockerclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MinAPIVersion is the oldest API version requests can be shaped for
+	MinAPIVersion = "1.12"
+	// MaxAPIVersion is the newest API version NegotiateAPIVersion picks
+	MaxAPIVersion = "1.41"
+)
+
+// fieldVersions are the API versions the fields of the create request
+// appeared in, keyed by their path in the request
+var fieldVersions = map[string]string{
+	"Labels":                          "1.18",
+	"VolumeDriver":                    "1.19",
+	"StopSignal":                      "1.21",
+	"NetworkingConfig":                "1.22",
+	"Healthcheck":                     "1.24",
+	"HostConfig.ReadonlyRootfs":       "1.17",
+	"HostConfig.Memory":               "1.18",
+	"HostConfig.MemorySwap":           "1.18",
+	"HostConfig.CpuShares":            "1.18",
+	"HostConfig.CpusetCpus":           "1.18",
+	"HostConfig.Ulimits":              "1.18",
+	"HostConfig.LogConfig":            "1.18",
+	"HostConfig.CgroupParent":         "1.18",
+	"HostConfig.CpuPeriod":            "1.19",
+	"HostConfig.CpuQuota":             "1.19",
+	"HostConfig.CpusetMems":           "1.19",
+	"HostConfig.BlkioWeight":          "1.19",
+	"HostConfig.OomKillDisable":       "1.19",
+	"HostConfig.GroupAdd":             "1.20",
+	"HostConfig.MemorySwappiness":     "1.20",
+	"HostConfig.UTSMode":              "1.20",
+	"HostConfig.KernelMemory":         "1.21",
+	"HostConfig.MemoryReservation":    "1.21",
+	"HostConfig.DNSOptions":           "1.21",
+	"HostConfig.VolumeDriver":         "1.21",
+	"HostConfig.Tmpfs":                "1.22",
+	"HostConfig.ShmSize":              "1.22",
+	"HostConfig.OomScoreAdj":          "1.22",
+	"HostConfig.BlkioWeightDevice":    "1.22",
+	"HostConfig.BlkioDeviceReadBps":   "1.22",
+	"HostConfig.BlkioDeviceWriteBps":  "1.22",
+	"HostConfig.BlkioDeviceReadIOps":  "1.22",
+	"HostConfig.BlkioDeviceWriteIOps": "1.22",
+	"HostConfig.Mounts":               "1.25",
+}
+
+// legacyResources are the resources of the config sent at its top level
+// before API 1.18, mapped to their field of the host config
+var legacyResources = map[string]string{
+	"Memory":     "Memory",
+	"MemorySwap": "MemorySwap",
+	"CpuShares":  "CpuShares",
+	"Cpuset":     "CpusetCpus",
+}
+
+// SetAPIVersion sets the API version of the requests, such as 1.24, and
+// shapes their bodies for it. It must not be called while requests are in
+// flight.
+func (client *DockerClient) SetAPIVersion(version string) {
+	client.version = strings.TrimPrefix(version, "v")
+}
+
+// ClientVersion returns the API version of the requests, APIVersion until
+// one is set or negotiated
+func (client *DockerClient) ClientVersion() string {
+	if client.version == "" {
+		return strings.TrimPrefix(APIVersion, "v")
+	}
+	return client.version
+}
+
+// NegotiateAPIVersion sets the API version of the requests to the one of
+// the engine, MaxAPIVersion at most, and returns it
+func (client *DockerClient) NegotiateAPIVersion() (string, error) {
+	// engines reject versions older than they support, so /version is
+	// asked without one
+	data, err := client.doRequest("Version", "GET", "/version", nil, nil)
+	if err != nil {
+		return "", err
+	}
+	v := &Version{}
+	if err := json.Unmarshal(data, v); err != nil {
+		return "", err
+	}
+	version := v.ApiVersion
+	if version == "" || !validVersion(version) {
+		return "", fmt.Errorf("invalid API version %q", version)
+	}
+	if versionLess(version, MinAPIVersion) {
+		return "", fmt.Errorf("API %s of the engine is older than %s", version, MinAPIVersion)
+	}
+	if versionLess(MaxAPIVersion, version) {
+		version = MaxAPIVersion
+	}
+	client.SetAPIVersion(version)
+	return version, nil
+}
+
+// apiPath returns path prefixed with the API version of the requests
+func (client *DockerClient) apiPath(format string, args ...interface{}) string {
+	return "/v" + client.ClientVersion() + fmt.Sprintf(format, args...)
+}
+
+func (client *DockerClient) warn(warnings ...string) {
+	if client.OnWarning == nil {
+		return
+	}
+	for _, w := range warnings {
+		client.OnWarning(w)
+	}
+}
+
+// MarshalContainerConfig encodes config as the body of a create request
+// for API version, returning what couldn't be sent as warnings. The zero
+// fields are left out, so that the engine applies its defaults.
+//
+// Before API 1.18, the memory and CPU limits are sent at the top level of
+// the config, and after in its host config, wherever they were set.
+// The volume driver moves from the config to its host config with API 1.21
+// likewise. Fields the version doesn't know about are left out, and
+// PortSpecs, replaced by ExposedPorts and PortBindings, after API 1.17. If
+// version is empty, the fields are sent where they were set, whatever the
+// version of the engine.
+func MarshalContainerConfig(config *ContainerConfig, version string) ([]byte, []string, error) {
+	if version != "" && !validVersion(version) {
+		return nil, nil, fmt.Errorf("invalid API version %q", version)
+	}
+	fields := nonZeroFields(reflect.ValueOf(config).Elem())
+	var warnings []string
+	if version != "" {
+		host, _ := fields["HostConfig"].(map[string]interface{})
+		if host == nil {
+			host = make(map[string]interface{})
+		}
+		if versionLess(version, "1.18") {
+			for top, field := range legacyResources {
+				move(host, field, fields, top)
+			}
+		} else {
+			for top, field := range legacyResources {
+				move(fields, top, host, field)
+			}
+			if _, ok := fields["PortSpecs"]; ok {
+				delete(fields, "PortSpecs")
+				warnings = append(warnings, fmt.Sprintf("PortSpecs isn't supported by API %s, use ExposedPorts and PortBindings", version))
+			}
+		}
+		// the volume driver was in the config for API 1.19 and 1.20
+		if versionLess(version, "1.21") {
+			move(host, "VolumeDriver", fields, "VolumeDriver")
+		} else {
+			move(fields, "VolumeDriver", host, "VolumeDriver")
+		}
+		warnings = append(warnings, dropUnsupported(fields, "", version)...)
+		warnings = append(warnings, dropUnsupported(host, "HostConfig.", version)...)
+		if len(host) > 0 {
+			fields["HostConfig"] = host
+		} else {
+			delete(fields, "HostConfig")
+		}
+	}
+	data, err := json.Marshal(fields)
+	return data, warnings, err
+}
+
+// MarshalHostConfig encodes hostConfig as the body of a start request for
+// API version, leaving out its zero fields and those version doesn't know
+// about as MarshalContainerConfig does. Engines stopped taking a host
+// config on start with API 1.24, it is then left out with a warning.
+func MarshalHostConfig(hostConfig *HostConfig, version string) ([]byte, []string, error) {
+	if version != "" && !validVersion(version) {
+		return nil, nil, fmt.Errorf("invalid API version %q", version)
+	}
+	if hostConfig == nil {
+		return []byte("null"), nil, nil
+	}
+	if version != "" && !versionLess(version, "1.24") {
+		return nil, []string{fmt.Sprintf("API %s doesn't take a host config on start, set it on creation", version)}, nil
+	}
+	fields := nonZeroFields(reflect.ValueOf(hostConfig).Elem())
+	var warnings []string
+	if version != "" {
+		warnings = dropUnsupported(fields, "HostConfig.", version)
+	}
+	data, err := json.Marshal(fields)
+	return data, warnings, err
+}
+
+// move moves the field from of src to the field to of dst, unless it is
+// set in dst already
+func move(src map[string]interface{}, from string, dst map[string]interface{}, to string) {
+	v, ok := src[from]
+	if !ok {
+		return
+	}
+	delete(src, from)
+	if _, ok := dst[to]; !ok {
+		dst[to] = v
+	}
+}
+
+// dropUnsupported removes the fields of version doesn't know about and
+// returns a warning for each
+func dropUnsupported(fields map[string]interface{}, prefix, version string) []string {
+	var warnings []string
+	for name := range fields {
+		min, ok := fieldVersions[prefix+name]
+		if ok && versionLess(version, min) {
+			delete(fields, name)
+			warnings = append(warnings, fmt.Sprintf("%s%s isn't supported by API %s, it needs %s", prefix, name, version, min))
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// nonZeroFields returns the fields of the struct v that aren't zero, keyed
+// by their JSON name. The nested host and networking configs are returned
+// as maps as well.
+func nonZeroFields(v reflect.Value) map[string]interface{} {
+	fields := make(map[string]interface{})
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			}
+		}
+		value := v.Field(i)
+		if isZero(value) {
+			continue
+		}
+		if value.Kind() == reflect.Struct && (f.Type == reflect.TypeOf(HostConfig{}) || f.Type == reflect.TypeOf(NetworkingConfig{})) {
+			fields[name] = nonZeroFields(value)
+			continue
+		}
+		fields[name] = value.Interface()
+	}
+	return fields
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func validVersion(version string) bool {
+	_, _, ok := parseVersion(version)
+	return ok
+}
+
+func parseVersion(version string) (int, int, bool) {
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	return major, minor, err1 == nil && err2 == nil
+}
+
+// versionLess reports whether API version a is older than b
+func versionLess(a, b string) bool {
+	aMajor, aMinor, _ := parseVersion(a)
+	bMajor, bMinor, _ := parseVersion(b)
+	if aMajor != bMajor {
+		return aMajor < bMajor
+	}
+	return aMinor < bMinor
+}
@@ -0,0 +1,182 @@
+package dockerclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func testConfig() *ContainerConfig {
+	config := &ContainerConfig{
+		Image:        "nginx",
+		Memory:       64 << 20,
+		PortSpecs:    []string{"80"},
+		VolumeDriver: "local",
+		Healthcheck:  &HealthConfig{Test: []string{"CMD", "true"}},
+	}
+	config.HostConfig.CpuShares = 512
+	config.HostConfig.ShmSize = 1 << 20
+	config.HostConfig.Mounts = []Mount{{Type: MountTypeTmpfs, Target: "/run"}}
+	return config
+}
+
+func decode(t *testing.T, data []byte) map[string]interface{} {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
+
+func keys(m interface{}) []string {
+	var ret []string
+	for k := range m.(map[string]interface{}) {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestMarshalContainerConfig(t *testing.T) {
+	for _, test := range []struct {
+		version  string
+		top      []string
+		host     []string
+		warnings []string
+	}{
+		{
+			"",
+			[]string{"Healthcheck", "HostConfig", "Image", "Memory", "PortSpecs", "VolumeDriver"},
+			[]string{"CpuShares", "Mounts", "ShmSize"},
+			nil,
+		},
+		{
+			"1.15",
+			[]string{"CpuShares", "Image", "Memory", "PortSpecs"},
+			nil,
+			[]string{
+				"Healthcheck isn't supported by API 1.15, it needs 1.24",
+				"VolumeDriver isn't supported by API 1.15, it needs 1.19",
+				"HostConfig.Mounts isn't supported by API 1.15, it needs 1.25",
+				"HostConfig.ShmSize isn't supported by API 1.15, it needs 1.22",
+			},
+		},
+		{
+			"1.20",
+			[]string{"HostConfig", "Image", "VolumeDriver"},
+			[]string{"CpuShares", "Memory"},
+			[]string{
+				"PortSpecs isn't supported by API 1.20, use ExposedPorts and PortBindings",
+				"Healthcheck isn't supported by API 1.20, it needs 1.24",
+				"HostConfig.Mounts isn't supported by API 1.20, it needs 1.25",
+				"HostConfig.ShmSize isn't supported by API 1.20, it needs 1.22",
+			},
+		},
+		{
+			"1.41",
+			[]string{"Healthcheck", "HostConfig", "Image"},
+			[]string{"CpuShares", "Memory", "Mounts", "ShmSize", "VolumeDriver"},
+			[]string{"PortSpecs isn't supported by API 1.41, use ExposedPorts and PortBindings"},
+		},
+	} {
+		data, warnings, err := MarshalContainerConfig(testConfig(), test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields := decode(t, data)
+		if top := keys(fields); !reflect.DeepEqual(top, test.top) {
+			t.Errorf("%q: expected the fields %q, got %q", test.version, test.top, top)
+		}
+		var host []string
+		if h, ok := fields["HostConfig"]; ok {
+			host = keys(h)
+		}
+		if !reflect.DeepEqual(host, test.host) {
+			t.Errorf("%q: expected the host config fields %q, got %q", test.version, test.host, host)
+		}
+		if !reflect.DeepEqual(warnings, test.warnings) {
+			t.Errorf("%q: expected the warnings %q, got %q", test.version, test.warnings, warnings)
+		}
+	}
+
+	// set in both places, the host config wins
+	config := testConfig()
+	config.HostConfig.Memory = 128 << 20
+	data, _, err := MarshalContainerConfig(config, "1.41")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if memory := decode(t, data)["HostConfig"].(map[string]interface{})["Memory"]; memory != float64(128<<20) {
+		t.Fatalf("expected the memory of the host config, got %v", memory)
+	}
+	if _, _, err := MarshalContainerConfig(config, "latest"); err == nil {
+		t.Fatal("expected an invalid version")
+	}
+}
+
+func TestMarshalHostConfig(t *testing.T) {
+	hostConfig := &HostConfig{Binds: []string{"/data:/data"}, Tmpfs: map[string]string{"/run": ""}}
+	data, warnings, err := MarshalHostConfig(hostConfig, "1.21")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields := keys(decode(t, data)); !reflect.DeepEqual(fields, []string{"Binds"}) {
+		t.Fatalf("unexpected fields %q", fields)
+	}
+	if !reflect.DeepEqual(warnings, []string{"HostConfig.Tmpfs isn't supported by API 1.21, it needs 1.22"}) {
+		t.Fatalf("unexpected warnings %q", warnings)
+	}
+	data, warnings, err = MarshalHostConfig(hostConfig, "1.24")
+	if err != nil || data != nil || len(warnings) != 1 {
+		t.Fatalf("expected the host config to be left out, got %s %q %v", data, warnings, err)
+	}
+}
+
+func TestNegotiateAPIVersion(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/version":
+			w.Write([]byte(`{"ApiVersion": "1.43"}`))
+		case "/v1.41/containers/create":
+			body, _ = ioutil.ReadAll(r.Body)
+			w.Write([]byte(`{"Id": "4fa6e0f0", "Warnings": ["Your kernel does not support swap limit capabilities"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client, err := NewDockerClient(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings []string
+	client.OnWarning = func(warning string) {
+		warnings = append(warnings, warning)
+	}
+
+	version, err := client.NegotiateAPIVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != MaxAPIVersion || client.ClientVersion() != MaxAPIVersion {
+		t.Fatalf("expected %s to be negotiated, got %s", MaxAPIVersion, version)
+	}
+	if _, err := client.CreateContainer(testConfig(), "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := decode(t, body)["Memory"]; ok {
+		t.Fatalf("expected the memory to be sent in the host config, got %s", body)
+	}
+	expected := []string{
+		"PortSpecs isn't supported by API 1.41, use ExposedPorts and PortBindings",
+		"Your kernel does not support swap limit capabilities",
+	}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Fatalf("expected the warnings %q, got %q", expected, warnings)
+	}
+}
@@ -0,0 +1,28 @@
+package dockerclient
+
+import (
+	"sync"
+)
+
+// WaitMany waits for several containers at once and sends each result on
+// the returned channel as soon as that container is done. The channel is
+// closed once every container has been waited for. Closing stopChan
+// cancels the waits that are still pending.
+func WaitMany(client Client, ids []string, options *WaitOptions, stopChan <-chan struct{}) <-chan WaitResult {
+	results := make(chan WaitResult, len(ids))
+	var wg sync.WaitGroup
+	wg.Add(len(ids))
+	for _, id := range ids {
+		go func(id string) {
+			defer wg.Done()
+			result := <-client.WaitWithOptions(id, options, stopChan)
+			result.ID = id
+			results <- result
+		}(id)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}